@@ -2,10 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"reflect"
 	"strconv"
+	"time"
 
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/search"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -15,81 +19,97 @@ import (
 type GinAgentHandler struct {
 	DB     *gorm.DB
 	Logger *zap.Logger
+	Search search.Engine
 }
 
 // NewGinAgentHandler 创建一个新的GinAgentHandler实例
-func NewGinAgentHandler(db *gorm.DB, logger *zap.Logger) *GinAgentHandler {
+func NewGinAgentHandler(db *gorm.DB, logger *zap.Logger, searchEngine search.Engine) *GinAgentHandler {
 	return &GinAgentHandler{
 		DB:     db,
 		Logger: logger,
+		Search: searchEngine,
 	}
 }
 
-// GetAgents 获取工作流商店中的代理列表
-func (h *GinAgentHandler) GetAgents(c *gin.Context) {
-	// 解析查询参数
-	category := c.Query("category")
-	search := c.Query("search")
-
-	// 分页参数
-	limit := 20
-	offset := 0
-
-	if limitStr := c.Query("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-			if limit > 100 {
-				limit = 100 // 限制最大查询数量
-			}
-		}
+// parseIntQuery 解析可选的整数查询参数，未提供时返回nil
+func parseIntQuery(c *gin.Context, name string) (*int, error) {
+	value := c.Query(name)
+	if value == "" {
+		return nil, nil
 	}
-
-	if offsetStr := c.Query("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, errors.New("无效的参数: " + name)
 	}
+	return &n, nil
+}
 
-	// 查询代理列表（只查询公开的代理）
-	var agents []models.Agent
-	query := h.DB.Model(&models.Agent{}).Where("is_public = ?", true)
-
-	// 应用类别筛选
-	if category != "" {
-		query = query.Where("category = ?", category)
+// parseAgentSearchQuery 解析代理搜索的查询参数
+func parseAgentSearchQuery(c *gin.Context) (search.Query, error) {
+	q := search.Query{
+		Text:     c.Query("search"),
+		Category: c.Query("category"),
+		Type:     c.Query("type"),
+		Tag:      c.Query("tag"),
+		Sort:     c.Query("sort"),
 	}
 
-	// 应用搜索筛选
-	if search != "" {
-		query = query.Where("name LIKE ? OR description LIKE ?", "%"+search+"%", "%"+search+"%")
+	var err error
+	if q.MinPrice, err = parseIntQuery(c, "min_price"); err != nil {
+		return q, err
+	}
+	if q.MaxPrice, err = parseIntQuery(c, "max_price"); err != nil {
+		return q, err
+	}
+	if ratingStr := c.Query("min_rating"); ratingStr != "" {
+		if q.MinRating, err = strconv.ParseFloat(ratingStr, 64); err != nil {
+			return q, errors.New("无效的参数: min_rating")
+		}
 	}
 
-	// 获取总记录数
-	var total int64
-	query.Count(&total)
+	q.Limit, q.Offset = parsePagination(c)
+	return q, nil
+}
 
-	// 获取分页数据（按购买次数、评分、创建时间排序）
-	result := query.Order("purchase_count DESC, rating DESC, created_at DESC").Offset(offset).Limit(limit).Find(&agents)
-	if result.Error != nil {
-		h.Logger.Error("获取代理列表失败", zap.Error(result.Error))
+// GetAgents 获取工作流商店中的代理列表，支持关键词搜索、筛选、排序和分面统计
+func (h *GinAgentHandler) GetAgents(c *gin.Context) {
+	q, err := parseAgentSearchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.Search.Search(q)
+	if err != nil {
+		if errors.Is(err, search.ErrInvalidSort) || errors.Is(err, search.ErrInvalidQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("获取代理列表失败", zap.Error(err), zap.String("search", q.Text))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "获取代理列表失败: " + result.Error.Error(),
+			"message": "获取代理列表失败",
 		})
 		return
 	}
 
+	if err := models.LoadAgentTags(h.DB, result.Agents); err != nil {
+		h.Logger.Error("获取代理标签失败", zap.Error(err))
+	}
+
 	// 返回结果
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data": gin.H{
-			"agents": agents,
-			"pagination": gin.H{
-				"total":     total,
-				"page":      offset/limit + 1,
-				"page_size": limit,
-				"pages":     (total + int64(limit) - 1) / int64(limit),
-			},
+			"agents":     result.Agents,
+			"facets":     result.Facets,
+			"pagination": paginationData(result.Total, q.Limit, q.Offset),
 		},
 	})
 }
@@ -126,6 +146,8 @@ func (h *GinAgentHandler) GetAgent(c *gin.Context) {
 		return
 	}
 
+	loadAgentTags(h.DB, h.Logger, &agent)
+
 	// 返回结果
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
@@ -133,6 +155,74 @@ func (h *GinAgentHandler) GetAgent(c *gin.Context) {
 	})
 }
 
+// AgentVersionSummary 版本列表项，不含定义内容
+type AgentVersionSummary struct {
+	Version   string    `json:"version"`
+	Changelog string    `json:"changelog"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// summarizeAgentVersions 转换为不含定义内容的版本列表
+func summarizeAgentVersions(versions []models.AgentVersion) []AgentVersionSummary {
+	summaries := make([]AgentVersionSummary, 0, len(versions))
+	for _, v := range versions {
+		summaries = append(summaries, AgentVersionSummary{
+			Version:   v.Version,
+			Changelog: v.Changelog,
+			CreatedAt: v.CreatedAt,
+		})
+	}
+	return summaries
+}
+
+// GetAgentVersions 获取公开代理的版本历史及变更日志
+func (h *GinAgentHandler) GetAgentVersions(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的代理ID",
+		})
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.Where("is_public = ?", true).First(&agent, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+		} else {
+			h.Logger.Error("获取代理失败", zap.Error(err), zap.Int64("id", id))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取代理失败",
+			})
+		}
+		return
+	}
+
+	versions, err := models.ListAgentVersions(h.DB, id)
+	if err != nil {
+		h.Logger.Error("获取代理版本失败", zap.Error(err), zap.Int64("id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取代理版本失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"latestVersion": agent.LatestVersion,
+			"versions":      summarizeAgentVersions(versions),
+		},
+	})
+}
+
 // GetAgentCategories 获取代理分类列表
 func (h *GinAgentHandler) GetAgentCategories(c *gin.Context) {
 	// 查询公开代理的所有不同分类
@@ -161,20 +251,81 @@ type AgentCreateRequest struct {
 	Type        string          `json:"type" binding:"required"`
 	Category    string          `json:"category" binding:"required"`
 	Icon        string          `json:"icon"`
+	CoverImage  string          `json:"coverImage" binding:"max=500"`
 	Definition  json.RawMessage `json:"definition" binding:"required"`
+	InputSchema json.RawMessage `json:"inputSchema"` // 输入参数的JSON Schema（可选）
 	Price       int             `json:"price" binding:"required,min=0"`
 	IsPublic    bool            `json:"is_public"`
+	Tags        []string        `json:"tags"`      // 标签，可通过 /api/agent-tags/suggestions 获取推荐
+	Version     string          `json:"version"`   // 初始版本号，默认1.0.0
+	Changelog   string          `json:"changelog"` // 版本说明
 }
 
-// CreateAgent 创建代理（管理员功能）
-func (h *GinAgentHandler) CreateAgent(c *gin.Context) {
-	// 检查管理员权限
-	userRole, exists := c.Get("userRole")
-	if !exists || userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{
+// agentAuditSnapshot 代理的审计快照，定义内容较大，只记录摘要字段
+func agentAuditSnapshot(agent *models.Agent) json.RawMessage {
+	return models.AuditSnapshot(gin.H{
+		"name":        agent.Name,
+		"description": agent.Description,
+		"type":        agent.Type,
+		"category":    agent.Category,
+		"icon":        agent.Icon,
+		"price":       agent.Price,
+		"isPublic":    agent.IsPublic,
+		"version":     agent.LatestVersion,
+		"status":      agent.Status,
+		"creatorId":   agent.CreatorID,
+		"tags":        agent.Tags,
+	})
+}
+
+// loadAgentTags 填充代理的标签，失败时只记录日志
+func loadAgentTags(db *gorm.DB, logger *zap.Logger, agent *models.Agent) {
+	if err := agent.LoadTags(db); err != nil {
+		logger.Error("获取代理标签失败", zap.Error(err), zap.Int64("id", agent.ID))
+	}
+}
+
+// validAgentTags 校验标签，失败时已写入400响应
+func validAgentTags(c *gin.Context, tags []string) bool {
+	if _, err := models.NormalizeTags(tags); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"status":  "error",
-			"message": "权限不足，只有管理员可以创建代理",
+			"message": err.Error(),
 		})
+		return false
+	}
+	return true
+}
+
+// validInputSchema 校验输入参数的JSON Schema，提供时必须是JSON对象，失败时已写入400响应
+func validInputSchema(c *gin.Context, schema json.RawMessage) bool {
+	if len(schema) == 0 {
+		return true
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal(schema, &v); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "输入参数Schema必须是有效的JSON对象",
+		})
+		return false
+	}
+	return true
+}
+
+// jsonEqual 比较两个JSON文档的内容是否相同，忽略格式差异
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// CreateAgent 创建代理（管理员功能）
+func (h *GinAgentHandler) CreateAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
@@ -197,6 +348,9 @@ func (h *GinAgentHandler) CreateAgent(c *gin.Context) {
 		})
 		return
 	}
+	if !validAgentTags(c, req.Tags) || !validInputSchema(c, req.InputSchema) {
+		return
+	}
 
 	// 创建代理
 	agent := models.Agent{
@@ -205,23 +359,43 @@ func (h *GinAgentHandler) CreateAgent(c *gin.Context) {
 		Type:          req.Type,
 		Category:      req.Category,
 		Icon:          req.Icon,
+		CoverImage:    req.CoverImage,
 		Definition:    req.Definition,
+		InputSchema:   req.InputSchema,
 		Price:         req.Price,
 		PurchaseCount: 0,
 		Rating:        0.0,
 		IsPublic:      req.IsPublic,
+		Status:        models.AgentStatusPublished,
 	}
 
-	result := h.DB.Create(&agent)
-	if result.Error != nil {
-		h.Logger.Error("创建代理失败", zap.Error(result.Error))
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CreateVersionedAgent(tx, &agent, req.Version, req.Changelog, principal.UserID); err != nil {
+			return err
+		}
+		return models.SetAgentTags(tx, agent.ID, req.Tags)
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrAgentVersionInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("创建代理失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "创建代理失败: " + result.Error.Error(),
+			"message": "创建代理失败: " + err.Error(),
 		})
 		return
 	}
 
+	agent.Tags, _ = models.NormalizeTags(req.Tags)
+	event := newAuditEvent(c, models.AuditActionAgentCreate, "agent", strconv.FormatInt(agent.ID, 10))
+	event.After = agentAuditSnapshot(&agent)
+	recordAudit(h.DB, h.Logger, event)
+
 	// 返回创建的代理
 	c.JSON(http.StatusCreated, gin.H{
 		"status": "success",
@@ -236,20 +410,20 @@ type AgentUpdateRequest struct {
 	Type        string          `json:"type"`
 	Category    string          `json:"category"`
 	Icon        string          `json:"icon"`
+	CoverImage  string          `json:"coverImage" binding:"max=500"`
 	Definition  json.RawMessage `json:"definition"`
+	InputSchema json.RawMessage `json:"inputSchema"` // 提供时替换输入参数的JSON Schema
 	Price       int             `json:"price"`
 	IsPublic    *bool           `json:"is_public"`
+	Tags        []string        `json:"tags"`      // 提供时替换全部标签，空数组表示清除
+	Version     string          `json:"version"`   // 定义变更时发布的版本号，默认递增修订号
+	Changelog   string          `json:"changelog"` // 定义变更时的版本说明
 }
 
 // UpdateAgent 更新代理（管理员功能）
 func (h *GinAgentHandler) UpdateAgent(c *gin.Context) {
-	// 检查管理员权限
-	userRole, exists := c.Get("userRole")
-	if !exists || userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{
-			"status":  "error",
-			"message": "权限不足，只有管理员可以更新代理",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
 
@@ -304,6 +478,9 @@ func (h *GinAgentHandler) UpdateAgent(c *gin.Context) {
 			return
 		}
 	}
+	if !validAgentTags(c, req.Tags) || !validInputSchema(c, req.InputSchema) {
+		return
+	}
 
 	// 准备更新数据
 	updates := map[string]interface{}{}
@@ -322,8 +499,11 @@ func (h *GinAgentHandler) UpdateAgent(c *gin.Context) {
 	if req.Icon != "" {
 		updates["icon"] = req.Icon
 	}
-	if len(req.Definition) > 0 {
-		updates["definition"] = req.Definition
+	if req.CoverImage != "" {
+		updates["cover_image"] = req.CoverImage
+	}
+	if len(req.InputSchema) > 0 {
+		updates["input_schema"] = req.InputSchema
 	}
 	if req.Price > 0 {
 		updates["price"] = req.Price
@@ -332,19 +512,60 @@ func (h *GinAgentHandler) UpdateAgent(c *gin.Context) {
 		updates["is_public"] = *req.IsPublic
 	}
 
-	// 更新代理
-	result = h.DB.Model(&agent).Updates(updates)
-	if result.Error != nil {
-		h.Logger.Error("更新代理失败", zap.Error(result.Error), zap.Int64("id", id))
+	// 更新代理，定义变更时发布新版本
+	loadAgentTags(h.DB, h.Logger, &agent)
+	before := agentAuditSnapshot(&agent)
+	var version *models.AgentVersion
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&agent).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Tags != nil {
+			if err := models.SetAgentTags(tx, agent.ID, req.Tags); err != nil {
+				return err
+			}
+		}
+		if len(req.Definition) > 0 && !jsonEqual(req.Definition, agent.Definition) {
+			var err error
+			version, err = models.PublishAgentVersion(tx, &agent, models.PublishAgentVersionInput{
+				Version:    req.Version,
+				Changelog:  req.Changelog,
+				Definition: req.Definition,
+				CreatedBy:  principal.UserID,
+			})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrAgentVersionInvalid) || errors.Is(err, models.ErrAgentVersionNotNewer) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("更新代理失败", zap.Error(err), zap.Int64("id", id))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "更新代理失败: " + result.Error.Error(),
+			"message": "更新代理失败: " + err.Error(),
 		})
 		return
 	}
 
 	// 重新获取更新后的代理
 	h.DB.First(&agent, id)
+	loadAgentTags(h.DB, h.Logger, &agent)
+
+	event := newAuditEvent(c, models.AuditActionAgentUpdate, "agent", idStr)
+	event.Before = before
+	event.After = agentAuditSnapshot(&agent)
+	if version != nil {
+		event.Description = "发布版本 " + version.Version
+	}
+	recordAudit(h.DB, h.Logger, event)
 
 	// 返回更新后的代理
 	c.JSON(http.StatusOK, gin.H{
@@ -353,18 +574,8 @@ func (h *GinAgentHandler) UpdateAgent(c *gin.Context) {
 	})
 }
 
-// DeleteAgent 删除代理（管理员功能）
+// DeleteAgent 删除代理，移至回收站，保留期内可恢复（管理员功能）
 func (h *GinAgentHandler) DeleteAgent(c *gin.Context) {
-	// 检查管理员权限
-	userRole, exists := c.Get("userRole")
-	if !exists || userRole != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{
-			"status":  "error",
-			"message": "权限不足，只有管理员可以删除代理",
-		})
-		return
-	}
-
 	// 获取路径参数
 	idStr := c.Param("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -395,31 +606,19 @@ func (h *GinAgentHandler) DeleteAgent(c *gin.Context) {
 		return
 	}
 
-	// 检查是否有用户购买了此代理
-	var workflowCount int64
-	h.DB.Model(&models.Workflow{}).Where("agent_id = ?", id).Count(&workflowCount)
-	if workflowCount > 0 {
-		c.JSON(http.StatusConflict, gin.H{
-			"status":  "error",
-			"message": "无法删除代理，已有用户购买了此代理",
-		})
+	// 软删除代理，已被购买的代理只能下架
+	if err := models.SoftDeleteAgent(h.DB, &agent); err != nil {
+		respondArchiveError(c, h.Logger, err, "删除代理失败")
 		return
 	}
 
-	// 删除代理
-	result = h.DB.Delete(&agent)
-	if result.Error != nil {
-		h.Logger.Error("删除代理失败", zap.Error(result.Error), zap.Int64("id", id))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": "删除代理失败: " + result.Error.Error(),
-		})
-		return
-	}
+	event := newAuditEvent(c, models.AuditActionAgentDelete, "agent", idStr)
+	event.Before = agentAuditSnapshot(&agent)
+	recordAudit(h.DB, h.Logger, event)
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
-		"message": "代理删除成功",
+		"message": "代理已移至回收站",
 	})
 }
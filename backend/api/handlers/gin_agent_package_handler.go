@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/agentpack"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// agentPackagePublicDir 静态文件目录，导出时读取本地封面图，导入时保存内嵌的封面图
+const agentPackagePublicDir = "public"
+
+// agentPackageErrorStatus 代理包业务错误对应的HTTP状态码，非业务错误返回0
+func agentPackageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, agentpack.ErrConflict):
+		return http.StatusConflict
+	case errors.Is(err, agentpack.ErrInvalidPackage),
+		errors.Is(err, agentpack.ErrUnsupportedVersion),
+		errors.Is(err, agentpack.ErrChecksumMismatch),
+		errors.Is(err, models.ErrAgentVersionInvalid),
+		errors.Is(err, models.ErrTagInvalid):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondAgentPackageError 输出代理包相关错误，非业务错误记录日志并返回500
+func (h *GinAgentHandler) respondAgentPackageError(c *gin.Context, err error, message string) {
+	if status := agentPackageErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// ExportAgent 导出代理包，包含定义、元数据、封面图和输入参数Schema（管理员功能）
+func (h *GinAgentHandler) ExportAgent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+			return
+		}
+		h.Logger.Error("获取代理失败", zap.Error(err), zap.Int64("id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取代理失败",
+		})
+		return
+	}
+	loadAgentTags(h.DB, h.Logger, &agent)
+
+	pkg, err := agentpack.FromAgent(&agent, agentPackagePublicDir)
+	if err != nil {
+		h.respondAgentPackageError(c, err, "导出代理失败")
+		return
+	}
+	data, err := agentpack.Encode(pkg)
+	if err != nil {
+		h.respondAgentPackageError(c, err, "导出代理失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionAgentExport, "agent", strconv.FormatInt(agent.ID, 10))
+	event.After = models.AuditSnapshot(gin.H{
+		"version":  pkg.Agent.Version,
+		"checksum": pkg.Checksum,
+	})
+	recordAudit(h.DB, h.Logger, event)
+
+	c.Header("Content-Disposition", `attachment; filename="`+pkg.Filename(agent.ID)+`"`)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", data)
+}
+
+// ImportAgent 导入代理包（管理员功能）
+//
+// 请求体为导出的代理包。on_conflict指定已存在同名代理时的处理方式（fail、skip、overwrite、rename，默认fail），
+// publish=true时将导入的代理设为公开。
+func (h *GinAgentHandler) ImportAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	mode, err := agentpack.ParseConflictMode(c.Query("on_conflict"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	publish, _ := strconv.ParseBool(c.DefaultQuery("publish", "false"))
+
+	pkg, err := agentpack.Decode(c.Request.Body)
+	if err != nil {
+		h.respondAgentPackageError(c, err, "导入代理失败")
+		return
+	}
+	result, err := agentpack.Import(h.DB, pkg, agentpack.ImportOptions{
+		OnConflict: mode,
+		Publish:    publish,
+		CreatedBy:  principal.UserID,
+		PublicDir:  agentPackagePublicDir,
+	})
+	if err != nil {
+		h.respondAgentPackageError(c, err, "导入代理失败")
+		return
+	}
+
+	h.respondImport(c, pkg, result)
+}
+
+// respondImport 记录导入审计并返回导入结果，跳过的导入不记录审计
+func (h *GinAgentHandler) respondImport(c *gin.Context, pkg *agentpack.Package, result *agentpack.ImportResult) {
+	status := http.StatusOK
+	if result.Action == agentpack.ActionCreated {
+		status = http.StatusCreated
+	}
+	if result.Action != agentpack.ActionSkipped {
+		event := newAuditEvent(c, models.AuditActionAgentImport, "agent", strconv.FormatInt(result.Agent.ID, 10))
+		event.Description = "导入代理包，版本 " + result.Version
+		if result.Action == agentpack.ActionOverwritten {
+			event.Description = "导入代理包覆盖已有代理，版本 " + result.Version
+		}
+		event.After = agentAuditSnapshot(result.Agent)
+		recordAudit(h.DB, h.Logger, event)
+	}
+
+	c.JSON(status, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"action":   result.Action,
+			"version":  result.Version,
+			"checksum": pkg.Checksum,
+			"agent":    result.Agent,
+		},
+	})
+}
@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinAPIKeyHandler 处理个人API密钥相关的请求
+type GinAPIKeyHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+}
+
+// NewGinAPIKeyHandler 创建新的API密钥处理程序
+func NewGinAPIKeyHandler(db *gorm.DB, logger *zap.Logger) *GinAPIKeyHandler {
+	return &GinAPIKeyHandler{
+		DB:     db,
+		Logger: logger,
+	}
+}
+
+// APIKeyResponse API密钥响应结构，不包含密钥明文
+type APIKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	Active     bool       `json:"active"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	LastUsedIP string     `json:"lastUsedIp"`
+	RevokedAt  *time.Time `json:"revokedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// newAPIKeyResponse 构建API密钥响应
+func newAPIKeyResponse(key *models.APIKey, now time.Time) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.ScopeList(),
+		Active:     key.IsActive(now),
+		ExpiresAt:  key.ExpiresAt,
+		LastUsedAt: key.LastUsedAt,
+		LastUsedIP: key.LastUsedIP,
+		RevokedAt:  key.RevokedAt,
+		CreatedAt:  key.CreatedAt,
+	}
+}
+
+// GetAPIKeyScopes 获取可分配给API密钥的权限范围
+func (h *GinAPIKeyHandler) GetAPIKeyScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   models.AllAPIKeyScopes,
+	})
+}
+
+// GetAPIKeys 获取当前用户的API密钥列表
+func (h *GinAPIKeyHandler) GetAPIKeys(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	keys, err := models.GetUserAPIKeys(h.DB, uid)
+	if err != nil {
+		h.Logger.Error("获取API密钥失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取API密钥失败",
+		})
+		return
+	}
+
+	now := time.Now()
+	list := make([]APIKeyResponse, 0, len(keys))
+	for i := range keys {
+		list = append(list, newAPIKeyResponse(&keys[i], now))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   list,
+	})
+}
+
+// CreateAPIKeyRequest 创建API密钥请求结构
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" binding:"required,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays *int     `json:"expiresInDays" binding:"omitempty,min=1,max=3650"` // 为空表示永不过期
+}
+
+// CreateAPIKey 创建API密钥，密钥明文只在本次响应中返回
+func (h *GinAPIKeyHandler) CreateAPIKey(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	var req CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	// 校验并去重权限范围
+	scopes := make([]string, 0, len(req.Scopes))
+	seen := make(map[string]bool)
+	for _, scope := range req.Scopes {
+		if !models.IsValidAPIKeyScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "无效的权限范围: " + scope,
+			})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		t := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	plain, key, err := models.CreateAPIKey(h.DB, uid, req.Name, scopes, expiresAt)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyLimitExceeded) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("创建API密钥失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "创建API密钥失败",
+		})
+		return
+	}
+
+	h.Logger.Info("用户创建了API密钥", zap.String("user_id", uid), zap.Int64("key_id", key.ID), zap.Strings("scopes", scopes))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "API密钥已创建，请立即复制保存，之后将无法再次查看",
+		"data": gin.H{
+			"key":    plain,
+			"apiKey": newAPIKeyResponse(key, time.Now()),
+		},
+	})
+}
+
+// RevokeAPIKey 吊销API密钥
+func (h *GinAPIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的密钥ID",
+		})
+		return
+	}
+
+	if err := models.RevokeAPIKey(h.DB, uid, id); err != nil {
+		if errors.Is(err, models.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("吊销API密钥失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "吊销API密钥失败",
+		})
+		return
+	}
+
+	h.Logger.Info("用户吊销了API密钥", zap.String("user_id", uid), zap.Int64("key_id", id))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "API密钥已吊销",
+	})
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// archiveErrorStatus 删除和恢复业务错误对应的HTTP状态码，非业务错误返回0
+func archiveErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrDeletedNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrWorkflowRunning),
+		errors.Is(err, models.ErrExecutionRunning),
+		errors.Is(err, models.ErrExecutionWorkflowGone),
+		errors.Is(err, models.ErrAgentPurchased):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrAgentListingNotAllowed):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondArchiveError 输出删除和恢复相关错误，非业务错误记录日志并返回500
+func respondArchiveError(c *gin.Context, logger *zap.Logger, err error, message string) {
+	if status := archiveErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// GetDeletedWorkflows 获取当前工作空间回收站中的工作流
+func (h *GinWorkflowHandler) GetDeletedWorkflows(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	workflows, total, err := models.ListDeletedWorkflows(h.DB, principal.Workspace, limit, offset)
+	if err != nil {
+		respondArchiveError(c, h.Logger, err, "获取回收站失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"workflows":  workflows,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// RestoreWorkflow 从回收站恢复工作流及与它一起删除的执行记录，恢复后计入工作流数量配额
+func (h *GinWorkflowHandler) RestoreWorkflow(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的工作流ID")
+	if !ok {
+		return
+	}
+
+	var workflow *models.Workflow
+	err := h.Quotas.ReserveWorkflow(principal.Workspace, func(tx *gorm.DB) error {
+		var err error
+		workflow, err = models.RestoreWorkflow(tx, principal.Workspace, id)
+		return err
+	})
+	if err != nil {
+		if quotaExceeded(err) {
+			respondQuotaError(c, h.Logger, err)
+			return
+		}
+		respondArchiveError(c, h.Logger, err, "恢复工作流失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "工作流已恢复",
+		"data":    workflow,
+	})
+}
+
+// GetDeletedExecutions 获取当前工作空间回收站中单独删除的执行记录
+func (h *GinExecutionHandler) GetDeletedExecutions(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	executions, total, err := models.ListDeletedExecutions(h.DB, principal.Workspace, limit, offset)
+	if err != nil {
+		respondArchiveError(c, h.Logger, err, "获取回收站失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"executions": executions,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// RestoreExecution 从回收站恢复执行记录
+func (h *GinExecutionHandler) RestoreExecution(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionExecute)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的执行ID")
+	if !ok {
+		return
+	}
+
+	execution, err := models.RestoreExecution(h.DB, principal.Workspace, id)
+	if err != nil {
+		respondArchiveError(c, h.Logger, err, "恢复执行记录失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "执行记录已恢复",
+		"data":    execution,
+	})
+}
+
+// GetDeletedAgents 获取回收站中的代理（管理员功能）
+func (h *GinAgentHandler) GetDeletedAgents(c *gin.Context) {
+	limit, offset := parsePagination(c)
+	agents, total, err := models.ListDeletedAgents(h.DB, limit, offset)
+	if err != nil {
+		respondArchiveError(c, h.Logger, err, "获取回收站失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"agents":     agents,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// RestoreAgent 从回收站恢复代理（管理员功能）
+func (h *GinAgentHandler) RestoreAgent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	agent, err := models.RestoreAgent(h.DB, id)
+	if err != nil {
+		respondArchiveError(c, h.Logger, err, "恢复代理失败")
+		return
+	}
+	loadAgentTags(h.DB, h.Logger, agent)
+
+	event := newAuditEvent(c, models.AuditActionAgentRestore, "agent", strconv.FormatInt(agent.ID, 10))
+	event.After = agentAuditSnapshot(agent)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "代理已恢复",
+		"data":    agent,
+	})
+}
+
+// AgentListingRequest 上架或下架代理请求结构
+type AgentListingRequest struct {
+	Listed *bool  `json:"listed" binding:"required"`
+	Reason string `json:"reason" binding:"max=500"` // 下架原因
+}
+
+// SetAgentListing 上架或下架代理，下架的代理不在商店展示，已购买的用户不受影响（管理员功能）
+func (h *GinAgentHandler) SetAgentListing(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	var req AgentListingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+			return
+		}
+		respondArchiveError(c, h.Logger, err, "获取代理失败")
+		return
+	}
+
+	before := models.AuditSnapshot(gin.H{
+		"isPublic":     agent.IsPublic,
+		"unlistedAt":   agent.UnlistedAt,
+		"unlistReason": agent.UnlistReason,
+	})
+	if err := models.SetAgentListing(h.DB, &agent, *req.Listed, req.Reason); err != nil {
+		respondArchiveError(c, h.Logger, err, "更新代理上架状态失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionAgentListing, "agent", strconv.FormatInt(agent.ID, 10))
+	event.Before = before
+	event.After = models.AuditSnapshot(gin.H{
+		"isPublic":     agent.IsPublic,
+		"unlistedAt":   agent.UnlistedAt,
+		"unlistReason": agent.UnlistReason,
+	})
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agent,
+	})
+}
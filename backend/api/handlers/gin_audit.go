@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// newAuditEvent 根据当前请求构建审计事件，自动填充操作人、IP和请求ID
+func newAuditEvent(c *gin.Context, action models.AuditAction, targetType, targetID string) *models.AuditEvent {
+	event := &models.AuditEvent{
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		RequestID:  middleware.GetRequestID(c),
+	}
+	if principal, ok := middleware.GetPrincipal(c); ok {
+		event.ActorID = principal.UserID
+		event.ActorRole = principal.Role
+		event.AuthMethod = principal.AuthMethod
+		event.OrganizationID = principal.Workspace.OrganizationID
+	}
+	return event
+}
+
+// recordAudit 在操作完成后记录审计事件，失败时只记录日志，不影响已完成的操作
+//
+// 涉及资金的操作应在业务事务中直接调用models.CreateAuditEvent，保证审计记录与余额变动同时生效。
+func recordAudit(db *gorm.DB, logger *zap.Logger, event *models.AuditEvent) {
+	if err := models.CreateAuditEvent(db, event); err != nil {
+		logger.Error("记录审计事件失败", zap.Error(err), zap.String("action", string(event.Action)), zap.String("request_id", event.RequestID))
+	}
+}
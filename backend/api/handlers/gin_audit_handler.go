@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxAuditExportRows 单次导出审计事件的最大行数
+const maxAuditExportRows = 50000
+
+// GinAuditHandler 处理审计日志查询请求
+type GinAuditHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+}
+
+// NewGinAuditHandler 创建新的审计日志处理程序
+func NewGinAuditHandler(db *gorm.DB, logger *zap.Logger) *GinAuditHandler {
+	return &GinAuditHandler{
+		DB:     db,
+		Logger: logger,
+	}
+}
+
+// parseAuditTime 解析时间参数，支持RFC3339和日期格式；日期格式作为截止时间时包含当天
+func parseAuditTime(value string, endOfDay bool) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
+
+// parseAuditFilter 从查询参数读取审计事件过滤条件
+func parseAuditFilter(c *gin.Context) (models.AuditEventFilter, bool) {
+	filter := models.AuditEventFilter{
+		Action:     c.Query("action"),
+		ActorID:    c.Query("actorId"),
+		TargetType: c.Query("targetType"),
+		TargetID:   c.Query("targetId"),
+		RequestID:  c.Query("requestId"),
+	}
+
+	from, err := parseAuditTime(c.Query("from"), false)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的开始时间，格式应为RFC3339或YYYY-MM-DD",
+		})
+		return filter, false
+	}
+	to, err := parseAuditTime(c.Query("to"), true)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的结束时间，格式应为RFC3339或YYYY-MM-DD",
+		})
+		return filter, false
+	}
+	filter.From = from
+	filter.To = to
+	return filter, true
+}
+
+// GetAuditEvents 按条件查询审计事件（管理员）
+func (h *GinAuditHandler) GetAuditEvents(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	// 获取分页参数
+	limit := 20
+	offset := 0
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+			if limit > 100 {
+				limit = 100
+			}
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	events, total, err := models.ListAuditEvents(h.DB, filter, limit, offset)
+	if err != nil {
+		h.Logger.Error("获取审计事件失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取审计事件失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"events": events,
+			"pagination": gin.H{
+				"total":     total,
+				"page":      offset/limit + 1,
+				"page_size": limit,
+				"pages":     (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// csvFormulaPrefixes 电子表格会当作公式执行的单元格开头字符
+const csvFormulaPrefixes = "=+-@\t\r"
+
+// csvCell 在可能被电子表格当作公式的单元格前加单引号，导出的CSV中含有用户填写的内容时使用
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// ExportAuditEvents 按条件导出审计事件CSV（管理员）
+func (h *GinAuditHandler) ExportAuditEvents(c *gin.Context) {
+	filter, ok := parseAuditFilter(c)
+	if !ok {
+		return
+	}
+
+	events, total, err := models.ListAuditEvents(h.DB, filter, maxAuditExportRows, 0)
+	if err != nil {
+		h.Logger.Error("导出审计事件失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "导出审计事件失败",
+		})
+		return
+	}
+	if total > maxAuditExportRows {
+		c.Header("X-Export-Truncated", "true")
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=audit_events_%s.csv", time.Now().Format("20060102150405")))
+	c.Status(http.StatusOK)
+
+	// 写入UTF-8 BOM，方便Excel正确识别中文
+	c.Writer.Write([]byte("\xEF\xBB\xBF"))
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"id", "created_at", "action", "actor_id", "actor_role", "auth_method", "organization_id", "target_type", "target_id", "ip", "user_agent", "request_id", "before", "after", "description"})
+	for _, event := range events {
+		organizationID := ""
+		if event.OrganizationID != nil {
+			organizationID = strconv.FormatInt(*event.OrganizationID, 10)
+		}
+		writer.Write([]string{
+			strconv.FormatInt(event.ID, 10),
+			event.CreatedAt.Format(time.RFC3339),
+			string(event.Action),
+			csvCell(event.ActorID),
+			event.ActorRole,
+			event.AuthMethod,
+			organizationID,
+			event.TargetType,
+			csvCell(event.TargetID),
+			csvCell(event.IP),
+			csvCell(event.UserAgent),
+			csvCell(event.RequestID),
+			csvCell(string(event.Before)),
+			csvCell(string(event.After)),
+			csvCell(event.Description),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		h.Logger.Error("写入审计事件CSV失败", zap.Error(err))
+	}
+}
@@ -1,14 +1,24 @@
 package handlers
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alexfaker/jilang-agent/config"
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/loginguard"
+	"github.com/alexfaker/jilang-agent/pkg/mailer"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -16,19 +26,23 @@ import (
 
 // GinAuthHandler 处理认证相关的请求
 type GinAuthHandler struct {
-	DB        *gorm.DB
-	Logger    *zap.Logger
-	Config    config.AuthConfig
-	Validator *validator.Validate
+	DB         *gorm.DB
+	Logger     *zap.Logger
+	Config     config.AuthConfig
+	Mailer     mailer.Mailer
+	LoginGuard *loginguard.Guard
+	Validator  *validator.Validate
 }
 
 // NewGinAuthHandler 创建一个新的GinAuthHandler实例
-func NewGinAuthHandler(db *gorm.DB, logger *zap.Logger, cfg config.AuthConfig) *GinAuthHandler {
+func NewGinAuthHandler(db *gorm.DB, logger *zap.Logger, cfg config.AuthConfig, m mailer.Mailer, guard *loginguard.Guard) *GinAuthHandler {
 	return &GinAuthHandler{
-		DB:        db,
-		Logger:    logger,
-		Config:    cfg,
-		Validator: validator.New(),
+		DB:         db,
+		Logger:     logger,
+		Config:     cfg,
+		Mailer:     m,
+		LoginGuard: guard,
+		Validator:  validator.New(),
 	}
 }
 
@@ -101,8 +115,13 @@ func (h *GinAuthHandler) Register(c *gin.Context) {
 		return
 	}
 
+	// 发送邮箱验证邮件，失败时用户可稍后重新发送
+	if err := h.sendVerificationEmail(c.Request.Context(), user); err != nil {
+		h.Logger.Warn("发送验证邮件失败", zap.Error(err), zap.String("user_id", user.UserID))
+	}
+
 	// 生成令牌
-	token, err := h.generateToken(*user)
+	token, refreshToken, err := h.issueTokens(c, *user, false)
 	if err != nil {
 		h.Logger.Error("生成令牌失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -117,12 +136,16 @@ func (h *GinAuthHandler) Register(c *gin.Context) {
 		"status": "success",
 		"data": gin.H{
 			"user": gin.H{
-				"userID":   user.UserID,
-				"username": user.Username,
-				"email":    user.Email,
-				"role":     user.Role,
+				"userID":        user.UserID,
+				"username":      user.Username,
+				"email":         user.Email,
+				"role":          user.Role,
+				"emailVerified": user.IsEmailVerified(),
 			},
-			"token": token,
+			"token":        token,
+			"refreshToken": refreshToken,
+			"tokenType":    "Bearer",
+			"expiresIn":    int(h.Config.AccessTokenTTL().Seconds()),
 		},
 	})
 }
@@ -144,35 +167,200 @@ func (h *GinAuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	// 检查账号和IP是否处于等待或锁定状态
+	decision, err := h.LoginGuard.Check(ctx, req.Email, ip)
+	if err != nil {
+		h.Logger.Error("检查登录限制失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "登录失败",
+		})
+		return
+	}
+	if !decision.Allowed {
+		h.rejectThrottledLogin(c, decision)
+		return
+	}
+
 	// 查找用户 - 支持邮箱登录
 	var user models.User
-	if err := h.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	err = h.DB.Where("email = ?", req.Email).First(&user).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		h.Logger.Error("查找用户失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "登录失败",
+		})
+		return
+	}
+
+	// 验证密码，用户不存在时也执行一次哈希比较，避免通过响应时间判断邮箱是否注册
+	passwordHash := dummyPasswordHash
+	if err == nil {
+		passwordHash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(passwordHash, []byte(req.Password)) != nil || err != nil {
+		var found *models.User
+		if err == nil {
+			found = &user
+		}
+		h.recordLoginFailure(c, req.Email, found)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "邮箱或密码不正确",
+		})
+		return
+	}
+
+	h.beginLogin(c, user)
+}
+
+// beginLogin 第一步认证通过后继续登录
+//
+// 已启用两步验证时只签发挑战令牌，通过第二步验证后才签发访问令牌；
+// 此时不清除失败计数，第二步的失败仍计入账号的失败次数。
+func (h *GinAuthHandler) beginLogin(c *gin.Context, user models.User) {
+	mfa, err := models.GetUserMFA(h.DB, user.UserID)
+	if err != nil {
+		h.Logger.Error("获取两步验证配置失败", zap.Error(err), zap.String("user_id", user.UserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "登录失败",
+		})
+		return
+	}
+	if mfa.IsEnabled() {
+		challengeToken, err := h.generateMFAChallengeToken(user)
+		if err != nil {
+			h.Logger.Error("生成两步验证挑战令牌失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "登录失败",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data": gin.H{
+				"mfaRequired":    true,
+				"challengeToken": challengeToken,
+				"expiresIn":      int(h.Config.MFAChallengeTTL().Seconds()),
+			},
+		})
+		return
+	}
+
+	h.completeLogin(c, user, false)
+}
+
+// LoginMFARequest 登录第二步请求结构，验证码和恢复码二选一
+type LoginMFARequest struct {
+	ChallengeToken string `json:"challengeToken" binding:"required"`
+	Code           string `json:"code"`
+	RecoveryCode   string `json:"recoveryCode"`
+}
+
+// LoginMFA 登录第二步：使用挑战令牌和两步验证码（或恢复码）换取令牌
+func (h *GinAuthHandler) LoginMFA(c *gin.Context) {
+	var req LoginMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+	if strings.TrimSpace(req.Code) == "" && strings.TrimSpace(req.RecoveryCode) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请提供验证码或恢复码",
+		})
+		return
+	}
+
+	userID, err := h.parseMFAChallengeToken(req.ChallengeToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "登录验证已过期，请重新登录",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Where("user_id = ?", userID).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"status":  "error",
-				"message": "邮箱或密码不正确",
+				"message": "用户不存在",
 			})
 		} else {
 			h.Logger.Error("查找用户失败", zap.Error(err))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"status":  "error",
-				"message": "登录失败: " + err.Error(),
+				"message": "登录失败",
 			})
 		}
 		return
 	}
 
-	// 验证密码
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
+	// 第二步与密码登录共用失败计数，防止暴力猜测验证码
+	decision, err := h.LoginGuard.Check(c.Request.Context(), user.Email, c.ClientIP())
+	if err != nil {
+		h.Logger.Error("检查登录限制失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "邮箱或密码不正确",
+			"message": "登录失败",
 		})
 		return
 	}
+	if !decision.Allowed {
+		h.rejectThrottledLogin(c, decision)
+		return
+	}
+
+	if err := models.VerifyMFA(h.DB, user.UserID, req.Code, req.RecoveryCode); err != nil {
+		switch {
+		case errors.Is(err, models.ErrMFACodeInvalid), errors.Is(err, models.ErrMFARecoveryInvalid):
+			h.recordLoginFailure(c, user.Email, &user)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrMFANotEnabled):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "登录验证已失效，请重新登录",
+			})
+		default:
+			h.Logger.Error("校验两步验证失败", zap.Error(err), zap.String("user_id", user.UserID))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "登录失败",
+			})
+		}
+		return
+	}
+
+	if req.Code == "" {
+		h.Logger.Info("用户使用恢复码完成登录", zap.String("user_id", user.UserID), zap.String("ip", c.ClientIP()))
+	}
+
+	h.completeLogin(c, user, true)
+}
+
+// completeLogin 清除失败计数并签发令牌，完成登录
+func (h *GinAuthHandler) completeLogin(c *gin.Context, user models.User, mfaVerified bool) {
+	if err := h.LoginGuard.RecordSuccess(c.Request.Context(), user.Email); err != nil {
+		h.Logger.Warn("清除登录失败计数失败", zap.Error(err))
+	}
 
 	// 生成令牌
-	token, err := h.generateToken(user)
+	token, refreshToken, err := h.issueTokens(c, user, mfaVerified)
 	if err != nil {
 		h.Logger.Error("生成令牌失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -187,27 +375,42 @@ func (h *GinAuthHandler) Login(c *gin.Context) {
 		h.Logger.Warn("更新最后登录时间失败", zap.Error(err))
 	}
 
+	event := newAuditEvent(c, models.AuditActionLogin, "user", user.UserID)
+	event.ActorID = user.UserID
+	event.ActorRole = user.Role
+	if mfaVerified {
+		event.Description = "通过两步验证登录"
+	}
+	recordAudit(h.DB, h.Logger, event)
+
 	// 返回用户信息和令牌
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data": gin.H{
 			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"email":    user.Email,
-				"role":     user.Role,
+				"id":            user.ID,
+				"username":      user.Username,
+				"email":         user.Email,
+				"role":          user.Role,
+				"emailVerified": user.IsEmailVerified(),
+				"mfaEnabled":    mfaVerified,
+				// 角色要求两步验证但尚未启用时，前端应引导用户绑定
+				"mfaEnrollmentRequired": !mfaVerified && h.Config.MFARequiredForRole(user.Role),
 			},
-			"token": token,
+			"token":        token,
+			"refreshToken": refreshToken,
+			"tokenType":    "Bearer",
+			"expiresIn":    int(h.Config.AccessTokenTTL().Seconds()),
 		},
 	})
 }
 
 // RefreshTokenRequest 刷新令牌请求结构
 type RefreshTokenRequest struct {
-	Token string `json:"token" binding:"required"`
+	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
-// RefreshToken 刷新令牌
+// RefreshToken 使用刷新令牌换取新的令牌对，旧的刷新令牌随即失效
 func (h *GinAuthHandler) RefreshToken(c *gin.Context) {
 	var req RefreshTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -218,107 +421,536 @@ func (h *GinAuthHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	// 解析令牌
-	token, err := jwt.Parse(req.Token, func(token *jwt.Token) (interface{}, error) {
-		return []byte(h.Config.JWTSecret), nil
-	})
-	// 处理解析错误
+	// 轮换刷新令牌
+	refreshToken, stored, err := models.RotateRefreshToken(h.DB, req.RefreshToken, h.Config.RefreshTokenTTL(), h.refreshTokenMeta(c))
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的令牌: " + err.Error(),
-		})
+		switch {
+		case errors.Is(err, models.ErrRefreshTokenReused):
+			h.Logger.Warn("检测到刷新令牌重复使用", zap.String("ip", c.ClientIP()))
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrRefreshTokenInvalid), errors.Is(err, models.ErrRefreshTokenExpired):
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		default:
+			h.Logger.Error("轮换刷新令牌失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "刷新令牌失败",
+			})
+		}
 		return
 	}
 
-	// 验证令牌
-	if !token.Valid {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的令牌",
-		})
+	// 查找用户
+	var user models.User
+	if err := h.DB.Where("user_id = ?", stored.UserID).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "用户不存在",
+			})
+		} else {
+			h.Logger.Error("查找用户失败", zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "刷新令牌失败",
+			})
+		}
 		return
 	}
 
-	// 从令牌中获取声明
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{
+	// 生成新的访问令牌
+	accessToken, err := h.generateAccessToken(user, stored.MFAVerified)
+	if err != nil {
+		h.Logger.Error("生成访问令牌失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "无效的令牌声明",
+			"message": "刷新令牌失败",
 		})
 		return
 	}
 
-	// 获取用户ID
-	userID, ok := claims["user_id"].(float64)
+	// 返回新令牌对
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   h.tokenPairResponse(accessToken, refreshToken),
+	})
+}
+
+// LogoutRequest 退出登录请求结构
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Logout 退出当前会话：吊销当前访问令牌及提交的刷新令牌
+func (h *GinAuthHandler) Logout(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
 	if !ok {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户ID",
-		})
 		return
 	}
+	uid := principal.UserID
 
-	// 查找用户
-	var user models.User
-	if err := h.DB.First(&user, int64(userID)).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"status":  "error",
-				"message": "用户不存在",
-			})
-		} else {
-			h.Logger.Error("查找用户失败", zap.Error(err))
+	var req LogoutRequest
+	// 请求体可选
+	_ = c.ShouldBindJSON(&req)
+
+	if req.RefreshToken != "" {
+		if err := models.RevokeRefreshToken(h.DB, uid, req.RefreshToken); err != nil {
+			h.Logger.Error("吊销刷新令牌失败", zap.Error(err), zap.String("user_id", uid))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"status":  "error",
-				"message": "刷新令牌失败: " + err.Error(),
+				"message": "退出登录失败",
 			})
+			return
 		}
+	}
+
+	if err := models.RevokeAccessToken(h.DB, uid, principal.TokenID, principal.TokenExpiresAt); err != nil {
+		h.Logger.Error("吊销访问令牌失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "退出登录失败",
+		})
 		return
 	}
 
-	// 生成新令牌
-	newToken, err := h.generateToken(user)
-	if err != nil {
-		h.Logger.Error("生成新令牌失败", zap.Error(err))
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "已退出登录",
+	})
+}
+
+// LogoutAll 退出全部设备：吊销用户的全部刷新令牌及已签发的访问令牌
+func (h *GinAuthHandler) LogoutAll(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	if err := models.RevokeAllUserTokens(h.DB, uid); err != nil {
+		h.Logger.Error("吊销用户全部令牌失败", zap.Error(err), zap.String("user_id", uid))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "刷新令牌失败: " + err.Error(),
+			"message": "退出全部设备失败",
 		})
 		return
 	}
 
-	// 返回新令牌
+	h.Logger.Info("用户已退出全部设备", zap.String("user_id", uid))
+
 	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-		"data": gin.H{
-			"token": newToken,
-		},
+		"status":  "success",
+		"message": "已退出全部设备",
 	})
 }
 
-// generateToken 生成JWT令牌
-func (h *GinAuthHandler) generateToken(user models.User) (string, error) {
-	// 设置令牌过期时间
-	expirationTime := time.Now().Add(time.Duration(h.Config.TokenExpiration) * time.Hour)
+// issueTokens 为用户签发访问令牌和新的刷新令牌，mfaVerified表示本次登录是否通过了两步验证
+func (h *GinAuthHandler) issueTokens(c *gin.Context, user models.User, mfaVerified bool) (string, string, error) {
+	accessToken, err := h.generateAccessToken(user, mfaVerified)
+	if err != nil {
+		return "", "", err
+	}
+
+	meta := h.refreshTokenMeta(c)
+	meta.MFAVerified = mfaVerified
+	refreshToken, _, err := models.IssueRefreshToken(h.DB, user.UserID, "", h.Config.RefreshTokenTTL(), meta)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
+// tokenPairResponse 构建令牌对响应
+func (h *GinAuthHandler) tokenPairResponse(accessToken, refreshToken string) gin.H {
+	return gin.H{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"tokenType":    "Bearer",
+		"expiresIn":    int(h.Config.AccessTokenTTL().Seconds()),
+	}
+}
+
+// refreshTokenMeta 获取签发刷新令牌时记录的客户端信息
+func (h *GinAuthHandler) refreshTokenMeta(c *gin.Context) models.RefreshTokenMeta {
+	return models.RefreshTokenMeta{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+// generateAccessToken 生成短期有效的JWT访问令牌
+func (h *GinAuthHandler) generateAccessToken(user models.User, mfaVerified bool) (string, error) {
+	now := time.Now()
 
 	// 创建令牌声明
 	claims := jwt.MapClaims{
 		"user_id":  user.UserID,
 		"username": user.Username,
 		"role":     user.Role,
-		"exp":      expirationTime.Unix(),
+		"typ":      "access",
+		"mfa":      mfaVerified,
+		"gen":      user.TokenGeneration,
+		"jti":      uuid.New().String(),
+		"iat":      now.Unix(),
+		"exp":      now.Add(h.Config.AccessTokenTTL()).Unix(),
 	}
 
 	// 创建令牌
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	// 签名令牌
-	tokenString, err := token.SignedString([]byte(h.Config.JWTSecret))
+	return token.SignedString([]byte(h.Config.JWTSecret))
+}
+
+// generateMFAChallengeToken 生成登录第二步使用的短期挑战令牌，不能用于访问接口
+func (h *GinAuthHandler) generateMFAChallengeToken(user models.User) (string, error) {
+	now := time.Now()
+
+	claims := jwt.MapClaims{
+		"user_id": user.UserID,
+		"typ":     "mfa_challenge",
+		"jti":     uuid.New().String(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(h.Config.MFAChallengeTTL()).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(h.Config.JWTSecret))
+}
+
+// parseMFAChallengeToken 解析挑战令牌，返回用户ID
+func (h *GinAuthHandler) parseMFAChallengeToken(tokenString string) (string, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return []byte(h.Config.JWTSecret), nil
+	})
 	if err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["typ"] != "mfa_challenge" {
+		return "", jwt.ErrTokenInvalidClaims
+	}
+
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return "", jwt.ErrTokenInvalidClaims
+	}
+	return userID, nil
+}
+
+// sendVerificationEmail 生成邮箱验证令牌并发送验证邮件
+func (h *GinAuthHandler) sendVerificationEmail(ctx context.Context, user *models.User) error {
+	token, err := models.CreateUserToken(h.DB, user.UserID, models.UserTokenPurposeEmailVerify, h.Config.EmailVerifyTTL())
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(h.Config.FrontendURL, "/") + "/verify-email?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "请验证您的邮箱",
+		Body: fmt.Sprintf("%s，您好：\n\n请在%d小时内点击以下链接完成邮箱验证：\n%s\n\n如果这不是您本人的操作，请忽略此邮件。",
+			user.Username, h.Config.EmailVerifyExpiration, link),
+	})
+}
+
+// VerifyEmailRequest 邮箱验证请求结构
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// VerifyEmail 使用邮件中的令牌验证邮箱
+func (h *GinAuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := models.ConsumeUserToken(tx, req.Token, models.UserTokenPurposeEmailVerify)
+		if err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Where("user_id = ?", token.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if user.IsEmailVerified() {
+			return nil
+		}
+		return user.MarkEmailVerified(tx)
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrUserTokenInvalid) || errors.Is(err, models.ErrUserTokenExpired) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("验证邮箱失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "验证邮箱失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "邮箱验证成功",
+	})
+}
+
+// ResendVerification 重新发送邮箱验证邮件
+func (h *GinAuthHandler) ResendVerification(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	var user models.User
+	if err := h.DB.Where("user_id = ?", uid).First(&user).Error; err != nil {
+		h.Logger.Error("获取用户失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取用户失败",
+		})
+		return
+	}
+
+	if user.IsEmailVerified() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "邮箱已验证，无需重复验证",
+		})
+		return
+	}
+
+	if err := h.sendVerificationEmail(c.Request.Context(), &user); err != nil {
+		h.Logger.Error("发送验证邮件失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "发送验证邮件失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "验证邮件已发送",
+	})
+}
+
+// ForgotPasswordRequest 忘记密码请求结构
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ForgotPassword 发送密码重置邮件
+//
+// 无论邮箱是否存在都返回成功，避免泄露注册信息。
+func (h *GinAuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	err := h.DB.Where("email = ?", req.Email).First(&user).Error
+	if err == nil {
+		if err := h.sendPasswordResetEmail(c.Request.Context(), &user); err != nil {
+			h.Logger.Error("发送密码重置邮件失败", zap.Error(err), zap.String("user_id", user.UserID))
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		h.Logger.Error("查找用户失败", zap.Error(err))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "如果该邮箱已注册，您将收到一封密码重置邮件",
+	})
+}
+
+// sendPasswordResetEmail 生成密码重置令牌并发送邮件
+func (h *GinAuthHandler) sendPasswordResetEmail(ctx context.Context, user *models.User) error {
+	token, err := models.CreateUserToken(h.DB, user.UserID, models.UserTokenPurposePasswordReset, h.Config.PasswordResetTTL())
+	if err != nil {
+		return err
+	}
+
+	link := strings.TrimRight(h.Config.FrontendURL, "/") + "/reset-password?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "重置您的密码",
+		Body: fmt.Sprintf("%s，您好：\n\n请在%d分钟内点击以下链接重置密码：\n%s\n\n如果这不是您本人的操作，请忽略此邮件，您的密码不会被修改。",
+			user.Username, h.Config.PasswordResetExpiration, link),
+	})
+}
+
+// ResetPasswordRequest 重置密码请求结构
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required,min=8"`
+}
+
+// ResetPassword 使用邮件中的令牌重置密码，并注销该用户的全部会话
+func (h *GinAuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	var user models.User
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := models.ConsumeUserToken(tx, req.Token, models.UserTokenPurposePasswordReset)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", token.UserID).First(&user).Error; err != nil {
+			return err
+		}
+		if err := user.SetPassword(tx, req.NewPassword); err != nil {
+			return err
+		}
+
+		// 能收到重置邮件即证明邮箱有效
+		if !user.IsEmailVerified() {
+			if err := user.MarkEmailVerified(tx); err != nil {
+				return err
+			}
+		}
+
+		return models.RevokeAllUserTokens(tx, user.UserID)
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrUserTokenInvalid) || errors.Is(err, models.ErrUserTokenExpired) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("重置密码失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "重置密码失败",
+		})
+		return
+	}
+
+	h.Logger.Info("用户已重置密码", zap.String("user_id", user.UserID))
+
+	event := newAuditEvent(c, models.AuditActionPasswordReset, "user", user.UserID)
+	event.ActorID = user.UserID
+	event.ActorRole = user.Role
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "密码已重置，请使用新密码登录",
+	})
+}
+
+// dummyPasswordHash 用户不存在时用于比较的占位哈希
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("jilang-agent-dummy-password"), bcrypt.DefaultCost)
+
+// rejectThrottledLogin 拒绝处于等待或锁定状态的登录请求
+func (h *GinAuthHandler) rejectThrottledLogin(c *gin.Context, decision loginguard.Decision) {
+	seconds := int(math.Ceil(decision.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+
+	message := fmt.Sprintf("登录尝试过于频繁，请在%d秒后重试", seconds)
+	if decision.Locked {
+		message = fmt.Sprintf("登录失败次数过多，已暂时锁定，请在%d分钟后重试", int(math.Ceil(decision.RetryAfter.Minutes())))
+	}
+
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"status":     "error",
+		"message":    message,
+		"retryAfter": seconds,
+	})
+}
+
+// recordLoginFailure 记录登录失败，触发锁定时记录安全告警并通知用户
+func (h *GinAuthHandler) recordLoginFailure(c *gin.Context, email string, user *models.User) {
+	ctx := c.Request.Context()
+	ip := c.ClientIP()
+
+	event := newAuditEvent(c, models.AuditActionLoginFailed, "user", "")
+	event.Description = "登录邮箱: " + email
+	if user != nil {
+		event.TargetID = user.UserID
+	}
+	recordAudit(h.DB, h.Logger, event)
+
+	result, err := h.LoginGuard.RecordFailure(ctx, email, ip)
+	if err != nil {
+		h.Logger.Error("记录登录失败次数失败", zap.Error(err))
+		return
+	}
+
+	if result.AccountLocked {
+		alert := &models.SecurityAlert{
+			Type:    models.SecurityAlertAccountLocked,
+			IP:      ip,
+			Message: fmt.Sprintf("账号 %s 因多次登录失败被锁定至 %s", email, result.LockedUntil.Format("2006-01-02 15:04:05")),
+		}
+		if user != nil {
+			alert.UserID = user.UserID
+		}
+		if err := models.CreateSecurityAlert(h.DB, alert); err != nil {
+			h.Logger.Error("记录安全告警失败", zap.Error(err))
+		}
+		h.Logger.Warn("账号因多次登录失败被锁定", zap.String("email", email), zap.String("ip", ip))
+
+		if user != nil {
+			if err := h.Mailer.Send(ctx, mailer.Message{
+				To:      user.Email,
+				Subject: "账号安全提醒：登录已被暂时锁定",
+				Body: fmt.Sprintf("%s，您好：\n\n您的账号在短时间内多次登录失败（最近一次来自IP %s），为保护账号安全，登录已被锁定至 %s。\n\n如果这不是您本人的操作，建议尽快重置密码。",
+					user.Username, ip, result.LockedUntil.Format("2006-01-02 15:04:05")),
+			}); err != nil {
+				h.Logger.Warn("发送账号锁定通知失败", zap.Error(err), zap.String("user_id", user.UserID))
+			}
+		}
+	}
+
+	if result.IPLocked {
+		alert := &models.SecurityAlert{
+			Type:    models.SecurityAlertIPLocked,
+			IP:      ip,
+			Message: fmt.Sprintf("IP %s 因多次登录失败被锁定至 %s", ip, result.LockedUntil.Format("2006-01-02 15:04:05")),
+		}
+		if err := models.CreateSecurityAlert(h.DB, alert); err != nil {
+			h.Logger.Error("记录安全告警失败", zap.Error(err))
+		}
+		h.Logger.Warn("IP因多次登录失败被锁定", zap.String("ip", ip))
+	}
 }
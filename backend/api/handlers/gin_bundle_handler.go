@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetBundles 获取公开的套餐及其中的代理
+func (h *GinCatalogHandler) GetBundles(c *gin.Context) {
+	bundles, err := models.ListAgentBundles(h.DB, true)
+	if err != nil {
+		h.respondCatalogError(c, err, "获取套餐列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   bundles,
+	})
+}
+
+// GetBundle 获取公开套餐详情
+func (h *GinCatalogHandler) GetBundle(c *gin.Context) {
+	h.respondBundle(c, true)
+}
+
+// respondBundle 输出套餐详情及按顺序排列的代理
+func (h *GinCatalogHandler) respondBundle(c *gin.Context, publicOnly bool) {
+	id, ok := parseIDParam(c, "id", "无效的套餐ID")
+	if !ok {
+		return
+	}
+
+	bundle, err := models.GetAgentBundle(h.DB, id, publicOnly)
+	if err == nil {
+		err = models.LoadAgentTags(h.DB, bundle.Agents)
+	}
+	if err != nil {
+		h.respondCatalogError(c, err, "获取套餐失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   bundle,
+	})
+}
+
+// GetAdminBundles 获取全部套餐，包括未公开的（管理员功能）
+func (h *GinCatalogHandler) GetAdminBundles(c *gin.Context) {
+	bundles, err := models.ListAgentBundles(h.DB, false)
+	if err != nil {
+		h.respondCatalogError(c, err, "获取套餐列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   bundles,
+	})
+}
+
+// GetAdminBundle 获取套餐详情，包括未公开的（管理员功能）
+func (h *GinCatalogHandler) GetAdminBundle(c *gin.Context) {
+	h.respondBundle(c, false)
+}
+
+// BundleRequest 创建或修改套餐请求结构
+type BundleRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description string  `json:"description"`
+	CoverImage  string  `json:"coverImage" binding:"max=500"`
+	Price       int     `json:"price" binding:"min=0"`
+	IsPublic    bool    `json:"isPublic"`
+	AgentIDs    []int64 `json:"agentIds"` // 按顺序排列的代理ID，创建时必填，修改时提供则替换套餐中的全部代理
+}
+
+// bindBundle 解析套餐请求，失败时已写入响应
+func bindBundle(c *gin.Context) (*BundleRequest, bool) {
+	var req BundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// saveBundle 在事务中保存套餐及其代理，并重新加载代理列表
+func (h *GinCatalogHandler) saveBundle(bundle *models.AgentBundle, agentIDs []int64) error {
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(bundle).Error; err != nil {
+			return err
+		}
+		if agentIDs == nil {
+			return nil
+		}
+		return models.SetBundleAgents(tx, bundle.ID, agentIDs)
+	})
+	if err != nil {
+		return err
+	}
+	bundles := []models.AgentBundle{*bundle}
+	if err := models.LoadBundleAgents(h.DB, bundles); err != nil {
+		return err
+	}
+	*bundle = bundles[0]
+	return nil
+}
+
+// bundleAuditSnapshot 套餐的审计快照，代理只记录ID
+func bundleAuditSnapshot(bundle *models.AgentBundle) json.RawMessage {
+	agentIDs := make([]int64, len(bundle.Agents))
+	for i, agent := range bundle.Agents {
+		agentIDs[i] = agent.ID
+	}
+	return models.AuditSnapshot(gin.H{
+		"name":     bundle.Name,
+		"price":    bundle.Price,
+		"isPublic": bundle.IsPublic,
+		"agentIds": agentIDs,
+	})
+}
+
+// CreateBundle 创建套餐（管理员功能）
+func (h *GinCatalogHandler) CreateBundle(c *gin.Context) {
+	req, ok := bindBundle(c)
+	if !ok {
+		return
+	}
+	if req.AgentIDs == nil {
+		h.respondCatalogError(c, models.ErrBundleAgents, "创建套餐失败")
+		return
+	}
+
+	bundle := models.AgentBundle{
+		Name:        req.Name,
+		Description: req.Description,
+		CoverImage:  req.CoverImage,
+		Price:       req.Price,
+		IsPublic:    req.IsPublic,
+	}
+	if err := h.saveBundle(&bundle, req.AgentIDs); err != nil {
+		h.respondCatalogError(c, err, "创建套餐失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionBundleUpdate, "agent_bundle", strconv.FormatInt(bundle.ID, 10))
+	event.After = bundleAuditSnapshot(&bundle)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   bundle,
+	})
+}
+
+// UpdateBundle 修改套餐（管理员功能）
+func (h *GinCatalogHandler) UpdateBundle(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的套餐ID")
+	if !ok {
+		return
+	}
+
+	bundle, err := models.GetAgentBundle(h.DB, id, false)
+	if err != nil {
+		h.respondCatalogError(c, err, "获取套餐失败")
+		return
+	}
+
+	req, ok := bindBundle(c)
+	if !ok {
+		return
+	}
+
+	before := bundleAuditSnapshot(bundle)
+	bundle.Name = req.Name
+	bundle.Description = req.Description
+	bundle.CoverImage = req.CoverImage
+	bundle.Price = req.Price
+	bundle.IsPublic = req.IsPublic
+	if err := h.saveBundle(bundle, req.AgentIDs); err != nil {
+		h.respondCatalogError(c, err, "修改套餐失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionBundleUpdate, "agent_bundle", strconv.FormatInt(id, 10))
+	event.Before = before
+	event.After = bundleAuditSnapshot(bundle)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   bundle,
+	})
+}
+
+// DeleteBundle 删除套餐，已购买的工作流不受影响（管理员功能）
+func (h *GinCatalogHandler) DeleteBundle(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的套餐ID")
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteAgentBundle(h.DB, id); err != nil {
+		h.respondCatalogError(c, err, "删除套餐失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionBundleDelete, "agent_bundle", strconv.FormatInt(id, 10))
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "套餐删除成功",
+	})
+}
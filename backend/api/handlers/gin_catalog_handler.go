@@ -0,0 +1,532 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/search"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinCatalogHandler 处理商店标签、合集、套餐、推荐位和特价相关的请求
+type GinCatalogHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+	Search search.Engine
+}
+
+// NewGinCatalogHandler 创建新的商店目录处理程序
+func NewGinCatalogHandler(db *gorm.DB, logger *zap.Logger, searchEngine search.Engine) *GinCatalogHandler {
+	return &GinCatalogHandler{
+		DB:     db,
+		Logger: logger,
+		Search: searchEngine,
+	}
+}
+
+// catalogErrorStatus 目录业务错误对应的HTTP状态码，非业务错误返回0
+func catalogErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrCollectionNotFound),
+		errors.Is(err, models.ErrBundleNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrCollectionAgents),
+		errors.Is(err, models.ErrBundleAgents),
+		errors.Is(err, models.ErrSaleInvalid),
+		errors.Is(err, search.ErrInvalidSort),
+		errors.Is(err, search.ErrInvalidQuery):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondCatalogError 输出目录相关错误，非业务错误记录日志并返回500
+func (h *GinCatalogHandler) respondCatalogError(c *gin.Context, err error, message string) {
+	if status := catalogErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// queryLimit 解析limit参数，未提供或无效时使用默认值，不超过max
+func queryLimit(c *gin.Context, def, max int) int {
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit <= 0 {
+		return def
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
+
+// GetTags 获取公开代理使用的标签及数量，prefix用于输入补全
+func (h *GinCatalogHandler) GetTags(c *gin.Context) {
+	tags, err := models.ListPopularTags(h.DB, c.Query("prefix"), queryLimit(c, 50, 200))
+	if err != nil {
+		h.respondCatalogError(c, err, "获取标签失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   tags,
+	})
+}
+
+// GetTagSuggestions 创建代理时推荐标签
+func (h *GinCatalogHandler) GetTagSuggestions(c *gin.Context) {
+	var exclude []string
+	if value := c.Query("exclude"); value != "" {
+		exclude = strings.Split(value, ",")
+	}
+
+	suggestions, err := models.SuggestTags(h.DB, models.SuggestTagsInput{
+		Prefix:   c.Query("q"),
+		Text:     c.Query("text"),
+		Category: c.Query("category"),
+		Exclude:  exclude,
+	}, queryLimit(c, 10, 50))
+	if err != nil {
+		h.respondCatalogError(c, err, "获取推荐标签失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   suggestions,
+	})
+}
+
+// GetTagAgents 按标签浏览公开代理，支持与代理列表相同的筛选和排序参数
+func (h *GinCatalogHandler) GetTagAgents(c *gin.Context) {
+	q, err := parseAgentSearchQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	q.Tag = c.Param("name")
+
+	result, err := h.Search.Search(q)
+	if err == nil {
+		err = models.LoadAgentTags(h.DB, result.Agents)
+	}
+	if err != nil {
+		h.respondCatalogError(c, err, "获取标签代理失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"tag":        strings.ToLower(strings.TrimSpace(q.Tag)),
+			"agents":     result.Agents,
+			"facets":     result.Facets,
+			"pagination": paginationData(result.Total, q.Limit, q.Offset),
+		},
+	})
+}
+
+// GetFeaturedAgents 获取当前处于推荐时间段内的代理
+func (h *GinCatalogHandler) GetFeaturedAgents(c *gin.Context) {
+	agents, err := models.ListFeaturedAgents(h.DB, time.Now(), queryLimit(c, 10, 50))
+	if err == nil {
+		err = models.LoadAgentTags(h.DB, agents)
+	}
+	if err != nil {
+		h.respondCatalogError(c, err, "获取推荐代理失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agents,
+	})
+}
+
+// FeatureAgentRequest 设置推荐位请求结构
+type FeatureAgentRequest struct {
+	Featured bool       `json:"featured"`
+	From     *time.Time `json:"from"`  // 推荐开始时间，为空表示立即开始
+	Until    *time.Time `json:"until"` // 推荐结束时间，为空表示不结束
+	Rank     int        `json:"rank"`  // 推荐位顺序，越小越靠前
+}
+
+// FeatureAgent 设置或取消代理的推荐位（管理员功能）
+func (h *GinCatalogHandler) FeatureAgent(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	var req FeatureAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	if req.From != nil && req.Until != nil && !req.Until.After(*req.From) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "推荐结束时间必须晚于开始时间",
+		})
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+			return
+		}
+		h.respondCatalogError(c, err, "获取代理失败")
+		return
+	}
+	if req.Featured && !agent.IsPublic {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "只能推荐公开的代理",
+		})
+		return
+	}
+
+	before := models.AuditSnapshot(gin.H{
+		"featured": agent.IsFeatured,
+		"from":     agent.FeaturedFrom,
+		"until":    agent.FeaturedUntil,
+		"rank":     agent.FeaturedRank,
+	})
+	updates := map[string]interface{}{
+		"is_featured":    req.Featured,
+		"featured_from":  req.From,
+		"featured_until": req.Until,
+		"featured_rank":  req.Rank,
+	}
+	if !req.Featured {
+		updates["featured_from"] = nil
+		updates["featured_until"] = nil
+		updates["featured_rank"] = 0
+	}
+	if err := h.DB.Model(&agent).Updates(updates).Error; err != nil {
+		h.respondCatalogError(c, err, "设置推荐位失败")
+		return
+	}
+	h.DB.First(&agent, id)
+
+	event := newAuditEvent(c, models.AuditActionAgentFeature, "agent", strconv.FormatInt(id, 10))
+	event.Before = before
+	event.After = models.AuditSnapshot(gin.H{
+		"featured": agent.IsFeatured,
+		"from":     agent.FeaturedFrom,
+		"until":    agent.FeaturedUntil,
+		"rank":     agent.FeaturedRank,
+	})
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agent,
+	})
+}
+
+// SetAgentSale 设置或取消代理的限时特价，salePrice为空表示取消（管理员功能）
+func (h *GinCatalogHandler) SetAgentSale(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	var req models.AgentSaleInput
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+			return
+		}
+		h.respondCatalogError(c, err, "获取代理失败")
+		return
+	}
+
+	before := models.AuditSnapshot(gin.H{
+		"salePrice": agent.SalePrice,
+		"startsAt":  agent.SaleStartsAt,
+		"endsAt":    agent.SaleEndsAt,
+	})
+	if err := models.SetAgentSale(h.DB, &agent, req); err != nil {
+		h.respondCatalogError(c, err, "设置特价失败")
+		return
+	}
+	h.DB.First(&agent, id)
+
+	event := newAuditEvent(c, models.AuditActionAgentSale, "agent", strconv.FormatInt(id, 10))
+	event.Before = before
+	event.After = models.AuditSnapshot(gin.H{
+		"salePrice": agent.SalePrice,
+		"startsAt":  agent.SaleStartsAt,
+		"endsAt":    agent.SaleEndsAt,
+	})
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agent,
+	})
+}
+
+// GetCollections 获取已发布的合集
+func (h *GinCatalogHandler) GetCollections(c *gin.Context) {
+	collections, err := models.ListAgentCollections(h.DB, true)
+	if err != nil {
+		h.respondCatalogError(c, err, "获取合集列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   collections,
+	})
+}
+
+// GetCollection 获取已发布的合集及其中的代理，可使用ID或slug访问
+func (h *GinCatalogHandler) GetCollection(c *gin.Context) {
+	h.respondCollection(c, true)
+}
+
+// respondCollection 输出合集详情及按顺序排列的代理
+func (h *GinCatalogHandler) respondCollection(c *gin.Context, publishedOnly bool) {
+	collection, err := models.GetAgentCollection(h.DB, c.Param("id"), publishedOnly)
+	if err != nil {
+		h.respondCatalogError(c, err, "获取合集失败")
+		return
+	}
+	agents, err := models.ListCollectionAgents(h.DB, collection.ID)
+	if err == nil {
+		err = models.LoadAgentTags(h.DB, agents)
+	}
+	if err != nil {
+		h.respondCatalogError(c, err, "获取合集失败")
+		return
+	}
+	collection.AgentCount = int64(len(agents))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"collection": collection,
+			"agents":     agents,
+		},
+	})
+}
+
+// GetAdminCollections 获取全部合集，包括未发布的（管理员功能）
+func (h *GinCatalogHandler) GetAdminCollections(c *gin.Context) {
+	collections, err := models.ListAgentCollections(h.DB, false)
+	if err != nil {
+		h.respondCatalogError(c, err, "获取合集列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   collections,
+	})
+}
+
+// GetAdminCollection 获取合集详情，包括未发布的（管理员功能）
+func (h *GinCatalogHandler) GetAdminCollection(c *gin.Context) {
+	h.respondCollection(c, false)
+}
+
+// CollectionRequest 创建或修改合集请求结构
+type CollectionRequest struct {
+	Slug        string  `json:"slug" binding:"required,max=100"`
+	Name        string  `json:"name" binding:"required,max=100"`
+	Description string  `json:"description"`
+	CoverImage  string  `json:"coverImage" binding:"max=500"`
+	SortOrder   int     `json:"sortOrder"`
+	IsPublished bool    `json:"isPublished"`
+	AgentIDs    []int64 `json:"agentIds"` // 按顺序排列的代理ID，提供时替换合集中的全部代理
+}
+
+// saveCollection 在事务中保存合集及其代理
+func (h *GinCatalogHandler) saveCollection(collection *models.AgentCollection, agentIDs []int64) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(collection).Error; err != nil {
+			return err
+		}
+		if agentIDs == nil {
+			return nil
+		}
+		return models.SetCollectionAgents(tx, collection.ID, agentIDs)
+	})
+}
+
+// slugTaken slug是否已被其他合集使用
+func (h *GinCatalogHandler) slugTaken(slug string, excludeID int64) (bool, error) {
+	var count int64
+	err := h.DB.Model(&models.AgentCollection{}).Where("slug = ? AND id <> ?", slug, excludeID).Count(&count).Error
+	return count > 0, err
+}
+
+// bindCollection 解析合集请求并检查slug是否可用，失败时已写入响应
+func (h *GinCatalogHandler) bindCollection(c *gin.Context, excludeID int64) (*CollectionRequest, bool) {
+	var req CollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return nil, false
+	}
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	if _, err := strconv.ParseInt(req.Slug, 10, 64); err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "slug不能为纯数字",
+		})
+		return nil, false
+	}
+
+	taken, err := h.slugTaken(req.Slug, excludeID)
+	if err != nil {
+		h.respondCatalogError(c, err, "检查合集slug失败")
+		return nil, false
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "slug已被其他合集使用",
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// CreateCollection 创建合集（管理员功能）
+func (h *GinCatalogHandler) CreateCollection(c *gin.Context) {
+	req, ok := h.bindCollection(c, 0)
+	if !ok {
+		return
+	}
+
+	collection := models.AgentCollection{
+		Slug:        req.Slug,
+		Name:        req.Name,
+		Description: req.Description,
+		CoverImage:  req.CoverImage,
+		SortOrder:   req.SortOrder,
+		IsPublished: req.IsPublished,
+	}
+	if err := h.saveCollection(&collection, req.AgentIDs); err != nil {
+		h.respondCatalogError(c, err, "创建合集失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionCollectionUpdate, "agent_collection", strconv.FormatInt(collection.ID, 10))
+	event.After = models.AuditSnapshot(collection)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   collection,
+	})
+}
+
+// UpdateCollection 修改合集（管理员功能）
+func (h *GinCatalogHandler) UpdateCollection(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的合集ID")
+	if !ok {
+		return
+	}
+
+	var collection models.AgentCollection
+	if err := h.DB.First(&collection, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			err = models.ErrCollectionNotFound
+		}
+		h.respondCatalogError(c, err, "获取合集失败")
+		return
+	}
+
+	req, ok := h.bindCollection(c, id)
+	if !ok {
+		return
+	}
+
+	before := models.AuditSnapshot(collection)
+	collection.Slug = req.Slug
+	collection.Name = req.Name
+	collection.Description = req.Description
+	collection.CoverImage = req.CoverImage
+	collection.SortOrder = req.SortOrder
+	collection.IsPublished = req.IsPublished
+	if err := h.saveCollection(&collection, req.AgentIDs); err != nil {
+		h.respondCatalogError(c, err, "修改合集失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionCollectionUpdate, "agent_collection", strconv.FormatInt(id, 10))
+	event.Before = before
+	event.After = models.AuditSnapshot(collection)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   collection,
+	})
+}
+
+// DeleteCollection 删除合集，不影响其中的代理（管理员功能）
+func (h *GinCatalogHandler) DeleteCollection(c *gin.Context) {
+	id, ok := parseIDParam(c, "id", "无效的合集ID")
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteAgentCollection(h.DB, id); err != nil {
+		h.respondCatalogError(c, err, "删除合集失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionCollectionDelete, "agent_collection", strconv.FormatInt(id, 10))
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "合集删除成功",
+	})
+}
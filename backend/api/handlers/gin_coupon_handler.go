@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinCouponHandler 处理优惠码相关的请求
+type GinCouponHandler struct {
+	DB        *gorm.DB
+	Logger    *zap.Logger
+	Purchases *GinPurchaseHandler // 兑换免费代理类优惠码时按0元购买代理
+}
+
+// NewGinCouponHandler 创建一个新的GinCouponHandler实例
+func NewGinCouponHandler(db *gorm.DB, logger *zap.Logger, purchases *GinPurchaseHandler) *GinCouponHandler {
+	return &GinCouponHandler{
+		DB:        db,
+		Logger:    logger,
+		Purchases: purchases,
+	}
+}
+
+// RedeemCouponRequest 兑换优惠码请求结构
+type RedeemCouponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RedeemCoupon 兑换优惠码（点数类或免费代理类），点数加到当前工作空间的钱包，免费代理归属当前工作空间
+func (h *GinCouponHandler) RedeemCoupon(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionBilling)
+	if !ok {
+		return
+	}
+
+	// 解析请求
+	var req RedeemCouponRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	// 免费代理类优惠码按0元购买处理，与正常购买执行相同的检查和结算
+	var coupon models.Coupon
+	err := h.DB.Where("code = ?", models.NormalizeCouponCode(req.Code)).First(&coupon).Error
+	if err == nil && coupon.Type == models.CouponTypeFreeAgent && coupon.AgentID != nil {
+		h.redeemFreeAgent(c, principal, &coupon)
+		return
+	}
+
+	uid := principal.UserID
+	var redemption models.CouponRedemption
+
+	// 使用事务处理兑换流程
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		// 与购买相同，先锁定工作空间再锁定优惠码，保持一致的加锁顺序
+		if err := models.LockWorkspace(tx, principal.Workspace); err != nil {
+			return err
+		}
+
+		coupon, err := models.GetCouponByCodeForUpdate(tx, req.Code)
+		if err != nil {
+			return err
+		}
+		if err := coupon.CheckRedeemable(tx, uid, time.Now()); err != nil {
+			return err
+		}
+
+		if coupon.Type != models.CouponTypePoints {
+			return &PurchaseError{Message: "该优惠码需在购买代理时使用"}
+		}
+
+		// 点数加到当前工作空间的钱包
+		if _, err := models.ChangeWorkspacePoints(tx, principal.Workspace, models.WorkspacePointsChange{
+			Type:        models.TransactionTypeCoupon,
+			Amount:      coupon.Points,
+			Description: "兑换优惠码: " + coupon.Code,
+			RelatedID:   &coupon.ID,
+		}); err != nil {
+			return err
+		}
+
+		redemption = models.CouponRedemption{UserID: uid, Points: coupon.Points}
+		return coupon.Redeem(tx, &redemption)
+	})
+
+	if err != nil {
+		if msg, ok := couponErrorMessage(err); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": msg,
+			})
+		} else if purchaseErr, ok := err.(*PurchaseError); ok {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": purchaseErr.Message,
+			})
+		} else {
+			h.Logger.Error("兑换优惠码失败", zap.Error(err), zap.String("userId", uid))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "兑换优惠码失败",
+			})
+		}
+		return
+	}
+
+	// 返回兑换结果
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "兑换成功",
+		"data":    redemption,
+	})
+}
+
+// redeemFreeAgent 兑换免费代理类优惠码，代理归属当前工作空间
+func (h *GinCouponHandler) redeemFreeAgent(c *gin.Context, principal *middleware.Principal, coupon *models.Coupon) {
+	workflow, err := h.Purchases.purchaseAgent(c, principal, PurchaseAgentRequest{AgentID: *coupon.AgentID, CouponCode: coupon.Code})
+	if err != nil {
+		h.Purchases.respondPurchaseError(c, err, "兑换优惠码失败", zap.String("userId", principal.UserID), zap.String("code", coupon.Code))
+		return
+	}
+
+	var redemption models.CouponRedemption
+	if err := h.DB.Where("coupon_id = ? AND workflow_id = ?", coupon.ID, workflow.ID).First(&redemption).Error; err != nil {
+		h.Logger.Error("获取兑换记录失败", zap.Error(err), zap.Int64("workflowId", workflow.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取兑换记录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "兑换成功",
+		"data":    redemption,
+	})
+}
+
+// CouponBatchCreateRequest 批量生成优惠码请求结构
+type CouponBatchCreateRequest struct {
+	Type           string     `json:"type" binding:"required,oneof=points percent_off free_agent"`
+	Count          int        `json:"count" binding:"required,min=1,max=1000"`
+	Prefix         string     `json:"prefix" binding:"max=8"`
+	Points         int        `json:"points" binding:"min=0"`
+	PercentOff     int        `json:"percentOff" binding:"min=0,max=100"`
+	AgentID        *int64     `json:"agentId"`
+	MaxUses        *int       `json:"maxUses" binding:"omitempty,min=0"`        // 为空时默认1次
+	MaxUsesPerUser *int       `json:"maxUsesPerUser" binding:"omitempty,min=0"` // 为空时默认1次
+	ValidFrom      *time.Time `json:"validFrom"`
+	ValidUntil     *time.Time `json:"validUntil"`
+	Description    string     `json:"description"`
+}
+
+// CreateCouponBatch 批量生成优惠码（管理员及财务）
+func (h *GinCouponHandler) CreateCouponBatch(c *gin.Context) {
+	// 解析请求体
+	var req CouponBatchCreateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	input := models.CouponBatchCreateInput{
+		Type:           models.CouponType(req.Type),
+		Count:          req.Count,
+		Prefix:         req.Prefix,
+		Points:         req.Points,
+		PercentOff:     req.PercentOff,
+		AgentID:        req.AgentID,
+		MaxUses:        1,
+		MaxUsesPerUser: 1,
+		ValidFrom:      req.ValidFrom,
+		ValidUntil:     req.ValidUntil,
+		Description:    req.Description,
+	}
+	if req.MaxUses != nil {
+		input.MaxUses = *req.MaxUses
+	}
+	if req.MaxUsesPerUser != nil {
+		input.MaxUsesPerUser = *req.MaxUsesPerUser
+	}
+	if err := input.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 检查指定的代理是否存在
+	if input.AgentID != nil {
+		var count int64
+		h.DB.Model(&models.Agent{}).Where("id = ?", *input.AgentID).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "指定的代理不存在",
+			})
+			return
+		}
+	}
+
+	var createdBy string
+	if principal, ok := middleware.GetPrincipal(c); ok {
+		createdBy = principal.UserID
+	}
+
+	batchNo, coupons, err := models.CreateCouponBatch(h.DB, createdBy, input)
+	if err != nil {
+		h.Logger.Error("批量生成优惠码失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "批量生成优惠码失败",
+		})
+		return
+	}
+
+	h.Logger.Info("批量生成优惠码成功",
+		zap.String("batchNo", batchNo),
+		zap.String("type", req.Type),
+		zap.Int("count", len(coupons)),
+		zap.String("createdBy", createdBy),
+	)
+
+	// 返回生成结果
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"batchNo": batchNo,
+			"coupons": coupons,
+		},
+	})
+}
+
+// GetCoupons 获取优惠码列表（管理员及财务）
+func (h *GinCouponHandler) GetCoupons(c *gin.Context) {
+	// 获取分页参数
+	limit := 20
+	offset := 0
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+			if limit > 100 {
+				limit = 100
+			}
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	coupons, total, err := models.ListCoupons(h.DB, c.Query("batchNo"), limit, offset)
+	if err != nil {
+		h.Logger.Error("获取优惠码列表失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取优惠码列表失败",
+		})
+		return
+	}
+
+	// 返回结果
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"coupons": coupons,
+			"pagination": gin.H{
+				"total":     total,
+				"page":      offset/limit + 1,
+				"page_size": limit,
+				"pages":     (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// ExportCoupons 按批次导出优惠码CSV（管理员及财务）
+func (h *GinCouponHandler) ExportCoupons(c *gin.Context) {
+	batchNo := c.Query("batchNo")
+	if batchNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "批次号不能为空",
+		})
+		return
+	}
+
+	coupons, _, err := models.ListCoupons(h.DB, batchNo, 0, 0)
+	if err != nil {
+		h.Logger.Error("导出优惠码失败", zap.Error(err), zap.String("batchNo", batchNo))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "导出优惠码失败",
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=coupons_%s.csv", batchNo))
+	c.Status(http.StatusOK)
+
+	// 写入UTF-8 BOM，方便Excel正确识别中文
+	c.Writer.Write([]byte("\xEF\xBB\xBF"))
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write([]string{"code", "type", "points", "percent_off", "agent_id", "max_uses", "max_uses_per_user", "used_count", "valid_from", "valid_until", "is_active", "description"})
+	for _, coupon := range coupons {
+		agentID := ""
+		if coupon.AgentID != nil {
+			agentID = strconv.FormatInt(*coupon.AgentID, 10)
+		}
+		writer.Write([]string{
+			coupon.Code,
+			string(coupon.Type),
+			strconv.Itoa(coupon.Points),
+			strconv.Itoa(coupon.PercentOff),
+			agentID,
+			strconv.Itoa(coupon.MaxUses),
+			strconv.Itoa(coupon.MaxUsesPerUser),
+			strconv.Itoa(coupon.UsedCount),
+			formatOptionalTime(coupon.ValidFrom),
+			formatOptionalTime(coupon.ValidUntil),
+			strconv.FormatBool(coupon.IsActive),
+			csvCell(coupon.Description),
+		})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		h.Logger.Error("写入优惠码CSV失败", zap.Error(err), zap.String("batchNo", batchNo))
+	}
+}
+
+// UpdateCouponStatusRequest 更新优惠码状态请求结构
+type UpdateCouponStatusRequest struct {
+	IsActive *bool `json:"isActive" binding:"required"`
+}
+
+// UpdateCouponStatus 启用或停用优惠码（管理员及财务）
+func (h *GinCouponHandler) UpdateCouponStatus(c *gin.Context) {
+	// 获取路径参数
+	idStr := c.Param("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的优惠码ID",
+		})
+		return
+	}
+
+	var req UpdateCouponStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	var coupon models.Coupon
+	if err := h.DB.First(&coupon, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "优惠码不存在",
+			})
+		} else {
+			h.Logger.Error("获取优惠码失败", zap.Error(err), zap.Int64("id", id))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取优惠码失败",
+			})
+		}
+		return
+	}
+
+	if err := h.DB.Model(&coupon).Update("is_active", *req.IsActive).Error; err != nil {
+		h.Logger.Error("更新优惠码状态失败", zap.Error(err), zap.Int64("id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "更新优惠码状态失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   coupon,
+	})
+}
+
+// couponErrorMessage 将优惠码业务错误转换为提示信息
+func couponErrorMessage(err error) (string, bool) {
+	for _, target := range []error{
+		models.ErrCouponNotFound,
+		models.ErrCouponInactive,
+		models.ErrCouponNotStarted,
+		models.ErrCouponExpired,
+		models.ErrCouponUsedUp,
+		models.ErrCouponUserLimit,
+		models.ErrCouponNotApplicable,
+	} {
+		if errors.Is(err, target) {
+			return target.Error(), true
+		}
+	}
+	return "", false
+}
+
+// formatOptionalTime 格式化可为空的时间
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
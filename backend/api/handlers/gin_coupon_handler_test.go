@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// createFreeAgentCoupon 创建兑换指定代理的优惠码
+func createFreeAgentCoupon(t *testing.T, db *gorm.DB, agent *models.Agent) *models.Coupon {
+	t.Helper()
+	_, coupons, err := models.CreateCouponBatch(db, "", models.CouponBatchCreateInput{
+		Type:           models.CouponTypeFreeAgent,
+		Count:          1,
+		AgentID:        &agent.ID,
+		MaxUses:        1,
+		MaxUsesPerUser: 1,
+	})
+	if err != nil {
+		t.Fatalf("创建测试优惠码失败: %v", err)
+	}
+	return coupons[0]
+}
+
+func newTestCouponHandler(db *gorm.DB) *GinCouponHandler {
+	return NewGinCouponHandler(db, zap.NewNop(), NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{}))
+}
+
+func redeemBody(code string) string {
+	return fmt.Sprintf(`{"code":%q}`, code)
+}
+
+func TestRedeemFreeAgentCouponOrganizationWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	h := newTestCouponHandler(db)
+	owner := createTestUser(t, db, "owner", 0)
+	admin := createTestUser(t, db, "admin", 100)
+	org := createTestOrganization(t, db, owner, 50)
+	agent := createTestAgent(t, db, 30)
+	coupon := createFreeAgentCoupon(t, db, agent)
+
+	w := serveAs(h.RedeemCoupon, orgPrincipal(admin, org, models.OrgRoleAdmin), http.MethodPost, "/coupons/redeem", redeemBody(coupon.Code))
+	if w.Code != http.StatusOK {
+		t.Fatalf("兑换应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+	var redemption models.CouponRedemption
+	decodeResponse(t, w, &redemption)
+
+	// 代理归属组织空间，不扣除任何钱包的点数
+	var workflow models.Workflow
+	if err := db.Where("agent_id = ?", agent.ID).First(&workflow).Error; err != nil {
+		t.Fatalf("兑换后应创建工作流: %v", err)
+	}
+	if workflow.OrganizationID == nil || *workflow.OrganizationID != org.ID || workflow.PurchasedAt == nil {
+		t.Errorf("工作流应作为购买记录归属组织%d，实际为organization_id=%v", org.ID, workflow.OrganizationID)
+	}
+	if redemption.WorkflowID == nil || *redemption.WorkflowID != workflow.ID || redemption.Discount != 30 {
+		t.Errorf("兑换记录应关联工作流%d并抵扣30点，实际为%+v", workflow.ID, redemption)
+	}
+	var reloadedOrg models.Organization
+	db.First(&reloadedOrg, org.ID)
+	if reloadedOrg.Points != 50 {
+		t.Errorf("组织余额不应变化，实际为%d", reloadedOrg.Points)
+	}
+
+	var reloadedAgent models.Agent
+	db.First(&reloadedAgent, agent.ID)
+	if reloadedAgent.PurchaseCount != 1 {
+		t.Errorf("兑换应计入购买次数，实际为%d", reloadedAgent.PurchaseCount)
+	}
+	var audits int64
+	db.Model(&models.AuditEvent{}).Where("action = ?", models.AuditActionPurchase).Count(&audits)
+	if audits != 1 {
+		t.Errorf("兑换应记录购买审计事件，实际为%d条", audits)
+	}
+
+	// 管理员个人空间未拥有此代理，组织空间的兑换不影响个人空间
+	var personal int64
+	db.Model(&models.Workflow{}).Scopes(models.PersonalWorkspace(admin.UserID).Scope("workflows")).Count(&personal)
+	if personal != 0 {
+		t.Errorf("个人空间不应获得代理，实际有%d个工作流", personal)
+	}
+}
+
+func TestRedeemFreeAgentCouponChecks(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, db *gorm.DB, user *models.User, agent *models.Agent)
+	}{
+		{
+			name: "已拥有代理",
+			prepare: func(t *testing.T, db *gorm.DB, user *models.User, agent *models.Agent) {
+				h := NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{})
+				if w := serveAs(h.PurchaseAgent, jwtPrincipal(user), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID)); w.Code != http.StatusOK {
+					t.Fatalf("购买应成功，实际为%d: %s", w.Code, w.Body.String())
+				}
+			},
+		},
+		{
+			name: "代理未公开",
+			prepare: func(t *testing.T, db *gorm.DB, user *models.User, agent *models.Agent) {
+				db.Model(agent).Update("is_public", false)
+			},
+		},
+		{
+			name: "超出套餐可购买代理数量",
+			prepare: func(t *testing.T, db *gorm.DB, user *models.User, agent *models.Agent) {
+				plan, _ := models.GetSubscriptionPlan(models.PlanCodeFree)
+				for i := 0; i < plan.MaxPurchasedAgents; i++ {
+					if _, err := createPurchasedWorkflow(db, models.PersonalWorkspace(user.UserID), createTestAgent(t, db, i), nil); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			h := newTestCouponHandler(db)
+			user := createTestUser(t, db, "user", 100)
+			agent := createTestAgent(t, db, 30)
+			coupon := createFreeAgentCoupon(t, db, agent)
+			tt.prepare(t, db, user, agent)
+
+			w := serveAs(h.RedeemCoupon, jwtPrincipal(user), http.MethodPost, "/coupons/redeem", redeemBody(coupon.Code))
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("应返回400，实际为%d: %s", w.Code, w.Body.String())
+			}
+			var reloaded models.Coupon
+			db.First(&reloaded, coupon.ID)
+			if reloaded.UsedCount != 0 {
+				t.Errorf("兑换失败时不应消耗优惠码，实际已使用%d次", reloaded.UsedCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,705 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GinCreatorHandler 处理创作者上架代理、审核和收益提现相关的请求
+type GinCreatorHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+	Config config.MarketplaceConfig
+}
+
+// NewGinCreatorHandler 创建新的创作者处理程序
+func NewGinCreatorHandler(db *gorm.DB, logger *zap.Logger, cfg config.MarketplaceConfig) *GinCreatorHandler {
+	return &GinCreatorHandler{
+		DB:     db,
+		Logger: logger,
+		Config: cfg,
+	}
+}
+
+// creatorErrorStatus 创作者业务错误对应的HTTP状态码，非业务错误返回0
+func creatorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrPayoutNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrAgentStatusConflict),
+		errors.Is(err, models.ErrPayoutStatusInvalid):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrPayoutInsufficient),
+		errors.Is(err, models.ErrPayoutBelowMinimum),
+		errors.Is(err, models.ErrAgentVersionInvalid),
+		errors.Is(err, models.ErrTagInvalid),
+		errors.Is(err, models.ErrAgentVersionNotNewer):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondCreatorError 输出创作者相关错误，非业务错误记录日志并返回500
+func (h *GinCreatorHandler) respondCreatorError(c *gin.Context, err error, message string) {
+	if status := creatorErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// parsePagination 解析limit/offset分页参数，limit默认20、最大100
+func parsePagination(c *gin.Context) (int, int) {
+	limit := 20
+	offset := 0
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+			if limit > 100 {
+				limit = 100
+			}
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+	return limit, offset
+}
+
+// paginationData 分页信息
+func paginationData(total int64, limit, offset int) gin.H {
+	return gin.H{
+		"total":     total,
+		"page":      offset/limit + 1,
+		"page_size": limit,
+		"pages":     (total + int64(limit) - 1) / int64(limit),
+	}
+}
+
+// loadAgent 获取路径中的代理，creatorID不为空时只查找该创作者的代理，失败时已写入响应
+func (h *GinCreatorHandler) loadAgent(c *gin.Context, creatorID string) (*models.Agent, bool) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return nil, false
+	}
+
+	query := h.DB.Where("id = ?", id)
+	if creatorID != "" {
+		query = query.Where("creator_id = ?", creatorID)
+	}
+
+	var agent models.Agent
+	if err := query.First(&agent).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+		} else {
+			h.Logger.Error("获取代理失败", zap.Error(err), zap.Int64("id", id))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取代理失败",
+			})
+		}
+		return nil, false
+	}
+	return &agent, true
+}
+
+// GetCreatorAgents 获取当前创作者的代理，可按状态筛选
+func (h *GinCreatorHandler) GetCreatorAgents(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	agents, err := models.ListCreatorAgents(h.DB, principal.UserID, c.Query("status"))
+	if err == nil {
+		err = models.LoadAgentTags(h.DB, agents)
+	}
+	if err != nil {
+		h.respondCreatorError(c, err, "获取代理列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agents,
+	})
+}
+
+// CreatorAgentRequest 创作者创建代理请求结构
+type CreatorAgentRequest struct {
+	Name        string          `json:"name" binding:"required"`
+	Description string          `json:"description"`
+	Type        string          `json:"type" binding:"required"`
+	Category    string          `json:"category" binding:"required"`
+	Icon        string          `json:"icon"`
+	Definition  json.RawMessage `json:"definition" binding:"required"`
+	Price       int             `json:"price" binding:"min=0"`
+	Tags        []string        `json:"tags"`
+	Version     string          `json:"version"`   // 初始版本号，默认1.0.0
+	Changelog   string          `json:"changelog"` // 版本说明
+}
+
+// validAgentDefinition 代理定义必须是JSON对象，失败时已写入400响应
+func validAgentDefinition(c *gin.Context, definition json.RawMessage) bool {
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(definition, &parsed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "代理定义必须是有效的JSON格式",
+		})
+		return false
+	}
+	return true
+}
+
+// CreateCreatorAgent 创作者创建代理草稿，审核通过并发布前不会在商店展示
+func (h *GinCreatorHandler) CreateCreatorAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req CreatorAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	if !validAgentDefinition(c, req.Definition) || !validAgentTags(c, req.Tags) {
+		return
+	}
+
+	agent := models.Agent{
+		Name:        req.Name,
+		Description: req.Description,
+		Type:        req.Type,
+		Category:    req.Category,
+		Icon:        req.Icon,
+		Definition:  req.Definition,
+		Price:       req.Price,
+		IsPublic:    false,
+		CreatorID:   principal.UserID,
+		Status:      models.AgentStatusDraft,
+	}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.CreateVersionedAgent(tx, &agent, req.Version, req.Changelog, principal.UserID); err != nil {
+			return err
+		}
+		return models.SetAgentTags(tx, agent.ID, req.Tags)
+	})
+	if err != nil {
+		h.respondCreatorError(c, err, "创建代理失败")
+		return
+	}
+	agent.Tags, _ = models.NormalizeTags(req.Tags)
+
+	event := newAuditEvent(c, models.AuditActionAgentCreate, "agent", strconv.FormatInt(agent.ID, 10))
+	event.After = agentAuditSnapshot(&agent)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   agent,
+	})
+}
+
+// CreatorAgentUpdateRequest 创作者修改代理请求结构，未提供的字段保持不变
+type CreatorAgentUpdateRequest struct {
+	Name        string          `json:"name"`
+	Description *string         `json:"description"`
+	Type        string          `json:"type"`
+	Category    string          `json:"category"`
+	Icon        *string         `json:"icon"`
+	Definition  json.RawMessage `json:"definition"`
+	Price       *int            `json:"price" binding:"omitempty,min=0"`
+	Tags        []string        `json:"tags"`      // 提供时替换全部标签
+	Version     string          `json:"version"`   // 定义变更时记录的版本号，默认递增修订号
+	Changelog   string          `json:"changelog"` // 定义变更时的版本说明
+}
+
+// UpdateCreatorAgent 修改草稿或被拒绝的代理
+func (h *GinCreatorHandler) UpdateCreatorAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req CreatorAgentUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	if len(req.Definition) > 0 && !validAgentDefinition(c, req.Definition) {
+		return
+	}
+	if !validAgentTags(c, req.Tags) {
+		return
+	}
+
+	agent, ok := h.loadAgent(c, principal.UserID)
+	if !ok {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != "" {
+		updates["name"] = req.Name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if req.Type != "" {
+		updates["type"] = req.Type
+	}
+	if req.Category != "" {
+		updates["category"] = req.Category
+	}
+	if req.Icon != nil {
+		updates["icon"] = *req.Icon
+	}
+	if req.Price != nil {
+		updates["price"] = *req.Price
+	}
+
+	loadAgentTags(h.DB, h.Logger, agent)
+	before := agentAuditSnapshot(agent)
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 锁定后再检查状态，避免与提交审核并发
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(agent, agent.ID).Error; err != nil {
+			return err
+		}
+		if !agent.Status.IsEditableByCreator() {
+			return models.ErrAgentStatusConflict
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(agent).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.Tags != nil {
+			if err := models.SetAgentTags(tx, agent.ID, req.Tags); err != nil {
+				return err
+			}
+		}
+		if len(req.Definition) > 0 && !jsonEqual(req.Definition, agent.Definition) {
+			_, err := models.PublishAgentVersion(tx, agent, models.PublishAgentVersionInput{
+				Version:    req.Version,
+				Changelog:  req.Changelog,
+				Definition: req.Definition,
+				CreatedBy:  principal.UserID,
+			})
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrAgentStatusConflict) {
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": "只能修改草稿或被拒绝的代理",
+			})
+			return
+		}
+		h.respondCreatorError(c, err, "更新代理失败")
+		return
+	}
+
+	h.DB.First(agent, agent.ID)
+	loadAgentTags(h.DB, h.Logger, agent)
+
+	event := newAuditEvent(c, models.AuditActionAgentUpdate, "agent", strconv.FormatInt(agent.ID, 10))
+	event.Before = before
+	event.After = agentAuditSnapshot(agent)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agent,
+	})
+}
+
+// DeleteCreatorAgent 删除草稿或被拒绝的代理，这些代理从未发布，不会有购买记录
+func (h *GinCreatorHandler) DeleteCreatorAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	agent, ok := h.loadAgent(c, principal.UserID)
+	if !ok {
+		return
+	}
+
+	result := h.DB.Where("status IN ?", []models.AgentStatus{models.AgentStatusDraft, models.AgentStatusRejected}).Delete(agent)
+	if result.Error != nil {
+		h.respondCreatorError(c, result.Error, "删除代理失败")
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "只能删除草稿或被拒绝的代理",
+		})
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionAgentDelete, "agent", strconv.FormatInt(agent.ID, 10))
+	event.Before = agentAuditSnapshot(agent)
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "代理删除成功",
+	})
+}
+
+// transitionAgent 执行代理状态流转并记录审计事件
+func (h *GinCreatorHandler) transitionAgent(c *gin.Context, agent *models.Agent, action models.AuditAction, transition func() error, message string) {
+	before := agentAuditSnapshot(agent)
+	if err := transition(); err != nil {
+		h.respondCreatorError(c, err, message)
+		return
+	}
+
+	event := newAuditEvent(c, action, "agent", strconv.FormatInt(agent.ID, 10))
+	event.Before = before
+	event.After = agentAuditSnapshot(agent)
+	if agent.RejectReason != "" {
+		event.Description = agent.RejectReason
+	}
+	recordAudit(h.DB, h.Logger, event)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   agent,
+	})
+}
+
+// SubmitCreatorAgent 将草稿或被拒绝的代理提交审核
+func (h *GinCreatorHandler) SubmitCreatorAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	agent, ok := h.loadAgent(c, principal.UserID)
+	if !ok {
+		return
+	}
+
+	h.transitionAgent(c, agent, models.AuditActionAgentSubmit, func() error {
+		return agent.Submit(h.DB)
+	}, "提交审核失败")
+}
+
+// PublishCreatorAgent 发布审核通过的代理
+func (h *GinCreatorHandler) PublishCreatorAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	agent, ok := h.loadAgent(c, principal.UserID)
+	if !ok {
+		return
+	}
+
+	h.transitionAgent(c, agent, models.AuditActionAgentPublish, func() error {
+		return agent.Publish(h.DB)
+	}, "发布代理失败")
+}
+
+// GetAgentSubmissions 获取审核队列（管理员功能），默认返回待审核的代理
+func (h *GinCreatorHandler) GetAgentSubmissions(c *gin.Context) {
+	status := models.AgentStatus(c.DefaultQuery("status", string(models.AgentStatusSubmitted)))
+	switch status {
+	case models.AgentStatusSubmitted, models.AgentStatusApproved, models.AgentStatusRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的审核状态",
+		})
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	agents, total, err := models.ListAgentsByStatus(h.DB, status, limit, offset)
+	if err == nil {
+		err = models.LoadAgentTags(h.DB, agents)
+	}
+	if err != nil {
+		h.respondCreatorError(c, err, "获取审核队列失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"agents":     agents,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// ApproveAgent 审核通过创作者提交的代理（管理员功能）
+func (h *GinCreatorHandler) ApproveAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	agent, ok := h.loadAgent(c, "")
+	if !ok {
+		return
+	}
+
+	h.transitionAgent(c, agent, models.AuditActionAgentApprove, func() error {
+		return agent.Approve(h.DB, principal.UserID)
+	}, "审核代理失败")
+}
+
+// RejectAgentRequest 拒绝代理请求结构
+type RejectAgentRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// RejectAgent 拒绝创作者提交的代理（管理员功能）
+func (h *GinCreatorHandler) RejectAgent(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req RejectAgentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请填写拒绝原因",
+		})
+		return
+	}
+
+	agent, ok := h.loadAgent(c, "")
+	if !ok {
+		return
+	}
+
+	h.transitionAgent(c, agent, models.AuditActionAgentReject, func() error {
+		return agent.Reject(h.DB, principal.UserID, req.Reason)
+	}, "审核代理失败")
+}
+
+// GetCreatorWallet 获取当前创作者的收益钱包
+func (h *GinCreatorHandler) GetCreatorWallet(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	wallet, err := models.GetCreatorWallet(h.DB, principal.UserID)
+	if err != nil {
+		h.respondCreatorError(c, err, "获取创作者钱包失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"wallet":          wallet,
+			"sharePercent":    h.Config.CreatorSharePercent,
+			"minPayoutPoints": h.Config.MinPayoutPoints,
+		},
+	})
+}
+
+// GetCreatorEarnings 获取当前创作者的销售分成记录
+func (h *GinCreatorHandler) GetCreatorEarnings(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	earnings, total, err := models.ListCreatorEarnings(h.DB, principal.UserID, limit, offset)
+	if err != nil {
+		h.respondCreatorError(c, err, "获取收益记录失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"earnings":   earnings,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// PayoutRequest 申请提现请求结构
+type PayoutRequest struct {
+	Points  int    `json:"points" binding:"required,min=1"`
+	Account string `json:"account" binding:"required,max=255"` // 收款账户
+}
+
+// RequestPayout 申请提现，申请的点数从钱包中扣除，被拒绝时退回
+func (h *GinCreatorHandler) RequestPayout(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req PayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	var payout *models.CreatorPayout
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		payout, err = models.RequestCreatorPayout(tx, principal.UserID, req.Points, req.Account, h.Config.MinPayoutPoints)
+		if err != nil {
+			return err
+		}
+
+		event := newAuditEvent(c, models.AuditActionPayoutRequest, "creator_payout", strconv.FormatInt(payout.ID, 10))
+		event.After = models.AuditSnapshot(gin.H{
+			"points": payout.Points,
+			"status": payout.Status,
+		})
+		return models.CreateAuditEvent(tx, event)
+	})
+	if err != nil {
+		h.respondCreatorError(c, err, "申请提现失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   payout,
+	})
+}
+
+// GetCreatorPayouts 获取当前创作者的提现申请
+func (h *GinCreatorHandler) GetCreatorPayouts(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	h.listPayouts(c, principal.UserID)
+}
+
+// GetPayouts 获取全部创作者的提现申请（管理员功能），可按状态筛选
+func (h *GinCreatorHandler) GetPayouts(c *gin.Context) {
+	h.listPayouts(c, "")
+}
+
+// listPayouts 分页输出提现申请
+func (h *GinCreatorHandler) listPayouts(c *gin.Context, creatorID string) {
+	limit, offset := parsePagination(c)
+	payouts, total, err := models.ListCreatorPayouts(h.DB, creatorID, c.Query("status"), limit, offset)
+	if err != nil {
+		h.respondCreatorError(c, err, "获取提现申请失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"payouts":    payouts,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// ProcessPayoutRequest 处理提现申请请求结构
+type ProcessPayoutRequest struct {
+	Action string `json:"action" binding:"required,oneof=approve reject paid"`
+	Reason string `json:"reason"` // 拒绝时必填
+}
+
+// ProcessPayout 审核、拒绝提现申请或标记已打款（管理员功能）
+func (h *GinCreatorHandler) ProcessPayout(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	id, ok := parseIDParam(c, "id", "无效的提现申请ID")
+	if !ok {
+		return
+	}
+
+	var req ProcessPayoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	if req.Action == "reject" && req.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请填写拒绝原因",
+		})
+		return
+	}
+
+	payout := &models.CreatorPayout{ID: id}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		from, err := models.ProcessCreatorPayout(tx, payout, principal.UserID, req.Action, req.Reason)
+		if err != nil {
+			return err
+		}
+
+		event := newAuditEvent(c, models.AuditActionPayoutProcess, "creator_payout", strconv.FormatInt(payout.ID, 10))
+		event.Before = models.AuditSnapshot(gin.H{"status": from})
+		event.After = models.AuditSnapshot(gin.H{
+			"status":    payout.Status,
+			"points":    payout.Points,
+			"creatorId": payout.CreatorID,
+		})
+		event.Description = req.Reason
+		return models.CreateAuditEvent(tx, event)
+	})
+	if err != nil {
+		h.respondCreatorError(c, err, "处理提现申请失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   payout,
+	})
+}
@@ -6,7 +6,9 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/alexfaker/jilang-agent/config"
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -16,13 +18,17 @@ import (
 type GinExecutionHandler struct {
 	DB     *gorm.DB
 	Logger *zap.Logger
+	Quotas *quota.Enforcer
+	Trial  config.TrialConfig
 }
 
 // NewGinExecutionHandler 创建一个新的GinExecutionHandler实例
-func NewGinExecutionHandler(db *gorm.DB, logger *zap.Logger) *GinExecutionHandler {
+func NewGinExecutionHandler(db *gorm.DB, logger *zap.Logger, quotas *quota.Enforcer, trial config.TrialConfig) *GinExecutionHandler {
 	return &GinExecutionHandler{
 		DB:     db,
 		Logger: logger,
+		Quotas: quotas,
+		Trial:  trial,
 	}
 }
 
@@ -32,8 +38,13 @@ type ExecuteWorkflowRequest struct {
 	Inputs     json.RawMessage `json:"inputs"`
 }
 
-// GetExecutions 获取执行记录列表
+// GetExecutions 获取当前工作空间的执行记录列表
 func (h *GinExecutionHandler) GetExecutions(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
 	// 获取分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
@@ -52,7 +63,7 @@ func (h *GinExecutionHandler) GetExecutions(c *gin.Context) {
 	endTime := c.Query("end_time")
 
 	// 构建查询
-	query := h.DB.Model(&models.WorkflowExecution{})
+	query := h.DB.Model(&models.WorkflowExecution{}).Scopes(principal.Workspace.Scope("workflow_executions"))
 
 	// 应用筛选条件
 	if workflowID != "" {
@@ -101,6 +112,11 @@ func (h *GinExecutionHandler) GetExecutions(c *gin.Context) {
 
 // GetExecution 获取单个执行记录详情
 func (h *GinExecutionHandler) GetExecution(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
 	// 获取执行ID
 	id := c.Param("id")
 	if id == "" {
@@ -111,9 +127,9 @@ func (h *GinExecutionHandler) GetExecution(c *gin.Context) {
 		return
 	}
 
-	// 查询执行记录
+	// 查询执行记录，只能查看当前工作空间的执行记录
 	var execution models.WorkflowExecution
-	result := h.DB.First(&execution, id)
+	result := h.DB.Scopes(principal.Workspace.Scope("workflow_executions")).Where("id = ?", id).First(&execution)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -139,13 +155,8 @@ func (h *GinExecutionHandler) GetExecution(c *gin.Context) {
 
 // ExecuteWorkflow 执行工作流
 func (h *GinExecutionHandler) ExecuteWorkflow(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionExecute)
+	if !ok {
 		return
 	}
 
@@ -159,9 +170,9 @@ func (h *GinExecutionHandler) ExecuteWorkflow(c *gin.Context) {
 		return
 	}
 
-	// 查询工作流是否存在
+	// 查询工作流是否存在，只能执行当前工作空间的工作流
 	var workflow models.Workflow
-	result := h.DB.First(&workflow, req.WorkflowID)
+	result := h.DB.Scopes(principal.Workspace.Scope("workflows")).Where("id = ?", req.WorkflowID).First(&workflow)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -189,26 +200,33 @@ func (h *GinExecutionHandler) ExecuteWorkflow(c *gin.Context) {
 
 	// 创建执行记录
 	execution := models.WorkflowExecution{
-		WorkflowID: workflow.ID,
-		Status:     models.ExecutionStatusRunning,
-		StartedAt:  time.Now(),
-		UserID:     userID.(string),
-		InputData:  req.Inputs,
+		WorkflowID:     workflow.ID,
+		Status:         models.ExecutionStatusRunning,
+		StartedAt:      time.Now(),
+		UserID:         principal.UserID,
+		OrganizationID: workflow.OrganizationID,
+		InputData:      req.Inputs,
 	}
 
-	// 保存到数据库
-	result = h.DB.Create(&execution)
-	if result.Error != nil {
-		h.Logger.Error("创建执行记录失败", zap.Error(result.Error))
+	// 检查并发执行数和每日执行次数配额并保存到数据库
+	limits, err := h.Quotas.ReserveExecution(principal.Workspace, func(tx *gorm.DB) error {
+		return tx.Create(&execution).Error
+	})
+	if err != nil {
+		if quotaExceeded(err) {
+			respondQuotaError(c, h.Logger, err)
+			return
+		}
+		h.Logger.Error("创建执行记录失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "创建执行记录失败: " + result.Error.Error(),
+			"message": "创建执行记录失败: " + err.Error(),
 		})
 		return
 	}
 
 	// 异步执行工作流（这里只是示例，实际实现应该使用队列或后台任务）
-	go h.executeWorkflowAsync(uint(execution.ID), workflow)
+	go h.executeWorkflowAsync(uint(execution.ID), workflow, req.Inputs, limits.ExecutionOutputBytes)
 
 	// 返回执行记录
 	c.JSON(http.StatusAccepted, gin.H{
@@ -220,6 +238,11 @@ func (h *GinExecutionHandler) ExecuteWorkflow(c *gin.Context) {
 
 // CancelExecution 取消执行
 func (h *GinExecutionHandler) CancelExecution(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionExecute)
+	if !ok {
+		return
+	}
+
 	// 获取执行ID
 	id := c.Param("id")
 	if id == "" {
@@ -230,9 +253,9 @@ func (h *GinExecutionHandler) CancelExecution(c *gin.Context) {
 		return
 	}
 
-	// 查询执行记录
+	// 查询执行记录，只能查看当前工作空间的执行记录
 	var execution models.WorkflowExecution
-	result := h.DB.First(&execution, id)
+	result := h.DB.Scopes(principal.Workspace.Scope("workflow_executions")).Where("id = ?", id).First(&execution)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -282,20 +305,54 @@ func (h *GinExecutionHandler) CancelExecution(c *gin.Context) {
 	})
 }
 
-// DeleteExecution 删除/取消执行
-// 注意：此方法实际上调用的是CancelExecution方法，用于与路由配置匹配
+// DeleteExecution 删除已结束的执行记录，移至回收站，保留期内可恢复
 func (h *GinExecutionHandler) DeleteExecution(c *gin.Context) {
-	// 直接调用CancelExecution方法
-	h.CancelExecution(c)
+	principal, ok := requireWorkspace(c, models.OrgPermissionExecute)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的执行ID")
+	if !ok {
+		return
+	}
+
+	var execution models.WorkflowExecution
+	if err := h.DB.Scopes(principal.Workspace.Scope("workflow_executions")).Where("id = ?", id).First(&execution).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "执行记录不存在",
+			})
+			return
+		}
+		respondArchiveError(c, h.Logger, err, "获取执行记录失败")
+		return
+	}
+
+	if err := models.SoftDeleteExecution(h.DB, &execution); err != nil {
+		respondArchiveError(c, h.Logger, err, "删除执行记录失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "执行记录已移至回收站",
+	})
 }
 
-// executeWorkflowAsync 异步执行工作流
-func (h *GinExecutionHandler) executeWorkflowAsync(executionID uint, workflow models.Workflow) {
+// runDefinition 执行引擎入口，按定义运行工作流或代理并返回输出，工作流执行和代理试用共用
+func runDefinition(definition, inputs json.RawMessage) (json.RawMessage, error) {
 	// 这里应该是实际的工作流执行逻辑
 	// 在实际应用中，这可能涉及到调用外部服务、执行脚本等
 
 	// 模拟执行过程
 	time.Sleep(5 * time.Second)
+	return json.RawMessage(`{"result": "执行成功", "details": "这是一个模拟的执行结果"}`), nil
+}
+
+// executeWorkflowAsync 异步执行工作流，outputLimit为允许保存的最大输出字节数，0表示不限
+func (h *GinExecutionHandler) executeWorkflowAsync(executionID uint, workflow models.Workflow, inputs json.RawMessage, outputLimit int64) {
+	output, runErr := runDefinition(workflow.Definition, inputs)
 
 	// 更新执行记录
 	var execution models.WorkflowExecution
@@ -307,11 +364,23 @@ func (h *GinExecutionHandler) executeWorkflowAsync(executionID uint, workflow mo
 		return
 	}
 
-	// 更新执行状态为成功
+	// 更新执行状态
 	execution.Status = models.ExecutionStatusSuccess
 	now := time.Now()
 	execution.CompletedAt = &now
-	execution.OutputData = json.RawMessage(`{"result": "执行成功", "details": "这是一个模拟的执行结果"}`)
+	execution.OutputData = output
+	if runErr != nil {
+		execution.Status = models.ExecutionStatusFailed
+		execution.ErrorMessage = runErr.Error()
+		execution.OutputData = nil
+	}
+
+	// 输出超过配额时不保存结果，执行标记为失败
+	if err := quota.CheckExecutionOutput(outputLimit, len(execution.OutputData)); err != nil {
+		execution.Status = models.ExecutionStatusFailed
+		execution.ErrorMessage = err.Error()
+		execution.OutputData = nil
+	}
 
 	// 保存到数据库
 	result := h.DB.Save(&execution)
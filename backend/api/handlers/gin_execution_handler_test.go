@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// createTestWorkflow 在工作空间中创建工作流，userID为创建者
+func createTestWorkflow(t *testing.T, db *gorm.DB, ws models.Workspace, name string) *models.Workflow {
+	t.Helper()
+	workflow := &models.Workflow{
+		Name:           name,
+		UserID:         ws.UserID,
+		OrganizationID: ws.OrganizationID,
+		Status:         models.WorkflowStatusActive,
+		Definition:     []byte(`{"steps":[]}`),
+	}
+	if err := db.Create(workflow).Error; err != nil {
+		t.Fatalf("创建测试工作流失败: %v", err)
+	}
+	return workflow
+}
+
+// createTestExecution 为工作流创建指定状态的执行记录，userID为发起执行的用户
+func createTestExecution(t *testing.T, db *gorm.DB, workflow *models.Workflow, userID string, status models.ExecutionStatus) *models.WorkflowExecution {
+	t.Helper()
+	execution := &models.WorkflowExecution{
+		WorkflowID:     workflow.ID,
+		UserID:         userID,
+		OrganizationID: workflow.OrganizationID,
+		Status:         status,
+		StartedAt:      time.Now(),
+	}
+	if err := db.Create(execution).Error; err != nil {
+		t.Fatalf("创建测试执行记录失败: %v", err)
+	}
+	return execution
+}
+
+// executionList GetExecutions返回的数据
+type executionList struct {
+	Executions []models.WorkflowExecution `json:"executions"`
+	Pagination struct {
+		Total int64 `json:"total"`
+	} `json:"pagination"`
+}
+
+func executionIDs(list executionList) map[int64]bool {
+	ids := make(map[int64]bool, len(list.Executions))
+	for _, e := range list.Executions {
+		ids[e.ID] = true
+	}
+	return ids
+}
+
+func TestGetExecutionsScopedToWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	h := NewGinExecutionHandler(db, zap.NewNop(), nil, config.TrialConfig{})
+	alice := createTestUser(t, db, "alice", 0)
+	bob := createTestUser(t, db, "bob", 0)
+	org := createTestOrganization(t, db, alice, 0)
+	orgWS := models.Workspace{UserID: alice.UserID, OrganizationID: &org.ID, Role: models.OrgRoleOwner}
+
+	alicePersonal := createTestWorkflow(t, db, models.PersonalWorkspace(alice.UserID), "alice个人")
+	bobPersonal := createTestWorkflow(t, db, models.PersonalWorkspace(bob.UserID), "bob个人")
+	orgWorkflow := createTestWorkflow(t, db, orgWS, "组织")
+
+	own := createTestExecution(t, db, alicePersonal, alice.UserID, models.ExecutionStatusSuccess)
+	other := createTestExecution(t, db, bobPersonal, bob.UserID, models.ExecutionStatusSuccess)
+	orgByAlice := createTestExecution(t, db, orgWorkflow, alice.UserID, models.ExecutionStatusSuccess)
+	orgByBob := createTestExecution(t, db, orgWorkflow, bob.UserID, models.ExecutionStatusFailed)
+
+	t.Run("API密钥只能看到个人空间的执行", func(t *testing.T) {
+		principal := apiKeyPrincipal(alice, models.APIKeyScopeExecutionsRead)
+		w := serveAs(h.GetExecutions, principal, http.MethodGet, "/executions", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("应返回200，实际为%d: %s", w.Code, w.Body.String())
+		}
+		var list executionList
+		decodeResponse(t, w, &list)
+		ids := executionIDs(list)
+		if list.Pagination.Total != 1 || !ids[own.ID] {
+			t.Errorf("应只返回个人空间的执行%d，实际为%v（共%d条）", own.ID, ids, list.Pagination.Total)
+		}
+		if ids[other.ID] || ids[orgByAlice.ID] {
+			t.Errorf("不应返回其他用户或组织空间的执行，实际为%v", ids)
+		}
+	})
+
+	t.Run("组织成员可以看到组织内全部成员的执行", func(t *testing.T) {
+		principal := orgPrincipal(bob, org, models.OrgRoleViewer)
+		w := serveAs(h.GetExecutions, principal, http.MethodGet, "/executions", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("应返回200，实际为%d: %s", w.Code, w.Body.String())
+		}
+		var list executionList
+		decodeResponse(t, w, &list)
+		ids := executionIDs(list)
+		if list.Pagination.Total != 2 || !ids[orgByAlice.ID] || !ids[orgByBob.ID] {
+			t.Errorf("应返回组织的两条执行，实际为%v（共%d条）", ids, list.Pagination.Total)
+		}
+	})
+
+	t.Run("按状态筛选", func(t *testing.T) {
+		principal := orgPrincipal(alice, org, models.OrgRoleOwner)
+		w := serveAs(h.GetExecutions, principal, http.MethodGet, "/executions?status=failed", "")
+		var list executionList
+		decodeResponse(t, w, &list)
+		if ids := executionIDs(list); len(ids) != 1 || !ids[orgByBob.ID] {
+			t.Errorf("应只返回失败的执行%d，实际为%v", orgByBob.ID, ids)
+		}
+	})
+
+	t.Run("未认证返回401", func(t *testing.T) {
+		if w := serveAs(h.GetExecutions, nil, http.MethodGet, "/executions", ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("应返回401，实际为%d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/totp"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinMFAHandler 处理两步验证绑定相关的请求
+type GinMFAHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+	Config config.AuthConfig
+}
+
+// NewGinMFAHandler 创建新的两步验证处理程序
+func NewGinMFAHandler(db *gorm.DB, logger *zap.Logger, cfg config.AuthConfig) *GinMFAHandler {
+	return &GinMFAHandler{
+		DB:     db,
+		Logger: logger,
+		Config: cfg,
+	}
+}
+
+// GetMFAStatus 获取当前用户的两步验证状态
+func (h *GinMFAHandler) GetMFAStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	mfa, err := models.GetUserMFA(h.DB, user.UserID)
+	if err != nil {
+		h.Logger.Error("获取两步验证配置失败", zap.Error(err), zap.String("user_id", user.UserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取两步验证状态失败",
+		})
+		return
+	}
+
+	data := gin.H{
+		"enabled":  mfa.IsEnabled(),
+		"required": h.Config.MFARequiredForRole(user.Role),
+	}
+	if mfa.IsEnabled() {
+		remaining, err := models.CountUnusedMFARecoveryCodes(h.DB, user.UserID)
+		if err != nil {
+			h.Logger.Error("统计恢复码失败", zap.Error(err), zap.String("user_id", user.UserID))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取两步验证状态失败",
+			})
+			return
+		}
+		data["enabledAt"] = mfa.EnabledAt
+		data["recoveryCodesRemaining"] = remaining
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   data,
+	})
+}
+
+// EnrollMFARequest 开始绑定两步验证请求结构
+type EnrollMFARequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// EnrollMFA 开始绑定两步验证，返回密钥和供验证器应用扫描的配置URI
+func (h *GinMFAHandler) EnrollMFA(c *gin.Context) {
+	var req EnrollMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "密码不正确",
+		})
+		return
+	}
+
+	mfa, err := models.StartMFAEnrollment(h.DB, user.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("开始绑定两步验证失败", zap.Error(err), zap.String("user_id", user.UserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "开始绑定两步验证失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"secret":          mfa.Secret,
+			"provisioningUri": totp.ProvisioningURI(h.Config.MFAIssuer, user.Email, mfa.Secret),
+			"digits":          totp.Digits,
+			"period":          int(totp.Period.Seconds()),
+		},
+	})
+}
+
+// MFACodeRequest 提交两步验证码的请求结构
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// EnableMFA 提交验证器应用生成的验证码确认绑定，返回只显示一次的恢复码
+func (h *GinMFAHandler) EnableMFA(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	codes, err := models.EnableMFA(h.DB, user.UserID, req.Code)
+	if err != nil {
+		h.handleMFAError(c, user.UserID, err, "启用两步验证失败")
+		return
+	}
+
+	h.Logger.Info("用户已启用两步验证", zap.String("user_id", user.UserID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "两步验证已启用，请妥善保存恢复码，下次登录时生效",
+		"data": gin.H{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// DisableMFARequest 停用两步验证请求结构，验证码和恢复码二选一
+type DisableMFARequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// DisableMFA 停用两步验证，需同时验证密码和验证码
+func (h *GinMFAHandler) DisableMFA(c *gin.Context) {
+	var req DisableMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if h.Config.MFARequiredForRole(user.Role) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "当前角色必须启用两步验证，无法停用",
+		})
+		return
+	}
+
+	if !user.CheckPassword(req.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "密码不正确",
+		})
+		return
+	}
+
+	if err := models.VerifyMFA(h.DB, user.UserID, req.Code, req.RecoveryCode); err != nil {
+		h.handleMFAError(c, user.UserID, err, "停用两步验证失败")
+		return
+	}
+
+	if err := models.DisableMFA(h.DB, user.UserID); err != nil {
+		h.Logger.Error("停用两步验证失败", zap.Error(err), zap.String("user_id", user.UserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "停用两步验证失败",
+		})
+		return
+	}
+
+	h.Logger.Info("用户已停用两步验证", zap.String("user_id", user.UserID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "两步验证已停用",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，需提交当前验证码
+func (h *GinMFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if err := models.VerifyMFACode(h.DB, user.UserID, req.Code); err != nil {
+		h.handleMFAError(c, user.UserID, err, "重新生成恢复码失败")
+		return
+	}
+
+	codes, err := models.RegenerateMFARecoveryCodes(h.DB, user.UserID)
+	if err != nil {
+		h.handleMFAError(c, user.UserID, err, "重新生成恢复码失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "恢复码已重新生成，旧的恢复码已失效",
+		"data": gin.H{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// currentUser 获取当前登录用户，失败时已写入响应
+func (h *GinMFAHandler) currentUser(c *gin.Context) (*models.User, bool) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return nil, false
+	}
+	uid := principal.UserID
+
+	user, err := models.GetUserByID(h.DB, uid)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "用户不存在",
+			})
+		} else {
+			h.Logger.Error("获取用户失败", zap.Error(err), zap.String("user_id", uid))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取用户失败",
+			})
+		}
+		return nil, false
+	}
+
+	return user, true
+}
+
+// handleMFAError 将两步验证错误转换为响应
+func (h *GinMFAHandler) handleMFAError(c *gin.Context, userID string, err error, message string) {
+	switch {
+	case errors.Is(err, models.ErrMFACodeInvalid), errors.Is(err, models.ErrMFARecoveryInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrMFANotEnrolled), errors.Is(err, models.ErrMFANotEnabled):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	case errors.Is(err, models.ErrMFAAlreadyEnabled):
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+	default:
+		h.Logger.Error(message, zap.Error(err), zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": message,
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/oidc"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinOIDCHandler 处理第三方登录及账号绑定相关的请求
+type GinOIDCHandler struct {
+	DB        *gorm.DB
+	Logger    *zap.Logger
+	Config    config.AuthConfig
+	Providers *oidc.Registry
+	Auth      *GinAuthHandler // 复用登录完成后的令牌签发逻辑
+}
+
+// NewGinOIDCHandler 创建新的第三方登录处理程序
+func NewGinOIDCHandler(db *gorm.DB, logger *zap.Logger, cfg config.AuthConfig, providers *oidc.Registry, auth *GinAuthHandler) *GinOIDCHandler {
+	return &GinOIDCHandler{
+		DB:        db,
+		Logger:    logger,
+		Config:    cfg,
+		Providers: providers,
+		Auth:      auth,
+	}
+}
+
+// GetProviders 获取可用的第三方登录方式
+func (h *GinOIDCHandler) GetProviders(c *gin.Context) {
+	providers := h.Providers.List()
+	list := make([]gin.H, 0, len(providers))
+	for _, p := range providers {
+		list = append(list, gin.H{
+			"name":        p.Name(),
+			"displayName": p.DisplayName(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   list,
+	})
+}
+
+// Authorize 发起第三方登录，返回提供方的授权地址
+func (h *GinOIDCHandler) Authorize(c *gin.Context) {
+	h.startAuthorization(c, "")
+}
+
+// LinkIdentity 已登录用户发起第三方账号绑定，返回提供方的授权地址
+func (h *GinOIDCHandler) LinkIdentity(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	h.startAuthorization(c, principal.UserID)
+}
+
+// startAuthorization 生成state、nonce和PKCE校验值并返回授权地址，userID不为空时为绑定请求
+func (h *GinOIDCHandler) startAuthorization(c *gin.Context, userID string) {
+	provider, err := h.Providers.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	state, err := oidc.RandomString(32)
+	if err != nil {
+		h.Logger.Error("生成state失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "发起第三方登录失败",
+		})
+		return
+	}
+	nonce, err := oidc.RandomString(16)
+	if err != nil {
+		h.Logger.Error("生成nonce失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "发起第三方登录失败",
+		})
+		return
+	}
+	verifier, challenge, err := oidc.GeneratePKCE()
+	if err != nil {
+		h.Logger.Error("生成PKCE校验值失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "发起第三方登录失败",
+		})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, challenge)
+	if err != nil {
+		h.Logger.Error("生成授权地址失败", zap.Error(err), zap.String("provider", provider.Name()))
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  "error",
+			"message": "第三方登录服务暂不可用",
+		})
+		return
+	}
+
+	record := &models.OAuthState{
+		Provider:     provider.Name(),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userID,
+		ExpiresAt:    time.Now().Add(h.Config.OAuthStateTTL()),
+	}
+	if err := models.CreateOAuthState(h.DB, state, record); err != nil {
+		h.Logger.Error("保存登录请求失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "发起第三方登录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"authorizationUrl": authURL,
+			"state":            state,
+			"expiresIn":        int(h.Config.OAuthStateTTL().Seconds()),
+		},
+	})
+}
+
+// OIDCCallbackRequest 第三方授权回调请求结构，由前端回调页转发提供方返回的code和state
+type OIDCCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// Callback 处理第三方授权回调：登录（首次登录时创建账号）或完成绑定
+func (h *GinOIDCHandler) Callback(c *gin.Context) {
+	var req OIDCCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	provider, err := h.Providers.Get(c.Param("provider"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	state, err := models.ConsumeOAuthState(h.DB, req.State, provider.Name())
+	if err != nil {
+		if errors.Is(err, models.ErrOAuthStateInvalid) || errors.Is(err, models.ErrOAuthStateExpired) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("校验登录请求失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "第三方登录失败",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	token, err := provider.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		h.Logger.Warn("换取第三方令牌失败", zap.Error(err), zap.String("provider", provider.Name()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "第三方授权失败，请重新登录",
+		})
+		return
+	}
+
+	identity, err := provider.Identity(ctx, token, state.Nonce)
+	if err != nil {
+		h.Logger.Warn("获取第三方用户身份失败", zap.Error(err), zap.String("provider", provider.Name()))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "第三方授权失败，请重新登录",
+		})
+		return
+	}
+
+	if state.UserID != "" {
+		h.completeLink(c, state.UserID, provider.Name(), identity)
+		return
+	}
+	h.completeLogin(c, provider.Name(), identity)
+}
+
+// completeLink 将第三方身份绑定到发起绑定的用户
+func (h *GinOIDCHandler) completeLink(c *gin.Context, userID, provider string, identity *oidc.Identity) {
+	linked, err := models.LinkUserIdentity(h.DB, userID, provider, identity.Subject, identity.Email)
+	if err != nil {
+		if errors.Is(err, models.ErrIdentityLinkedToOther) || errors.Is(err, models.ErrIdentityProviderLinked) {
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("绑定第三方账号失败", zap.Error(err), zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "绑定第三方账号失败",
+		})
+		return
+	}
+
+	h.Logger.Info("用户已绑定第三方账号", zap.String("user_id", userID), zap.String("provider", provider))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "绑定成功",
+		"data": gin.H{
+			"linked":   true,
+			"identity": linked,
+		},
+	})
+}
+
+// completeLogin 使用第三方身份登录，首次登录时创建账号
+func (h *GinOIDCHandler) completeLogin(c *gin.Context, provider string, identity *oidc.Identity) {
+	user, linked, err := models.FindUserByIdentity(h.DB, provider, identity.Subject)
+	if err != nil {
+		h.Logger.Error("查找第三方账号绑定失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "第三方登录失败",
+		})
+		return
+	}
+
+	if user != nil {
+		if err := models.TouchUserIdentity(h.DB, linked); err != nil {
+			h.Logger.Warn("更新第三方账号登录时间失败", zap.Error(err))
+		}
+		h.Auth.beginLogin(c, *user)
+		return
+	}
+
+	if identity.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "第三方账号未提供邮箱，无法创建账号",
+		})
+		return
+	}
+
+	// 邮箱已注册时不自动绑定，避免通过第三方账号接管已有账号
+	var count int64
+	if err := h.DB.Model(&models.User{}).Where("email = ?", identity.Email).Count(&count).Error; err != nil {
+		h.Logger.Error("检查邮箱失败", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "第三方登录失败",
+		})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"code":    "account_exists",
+			"message": "该邮箱已注册，请使用密码登录后在账号设置中绑定",
+		})
+		return
+	}
+
+	user, _, err = models.CreateUserFromIdentity(h.DB, models.IdentityUserInput{
+		Provider:      provider,
+		Subject:       identity.Subject,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+		Username:      identity.Username,
+		FullName:      identity.Name,
+		Avatar:        identity.Avatar,
+	})
+	if err != nil {
+		h.Logger.Error("通过第三方账号创建用户失败", zap.Error(err), zap.String("provider", provider))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "第三方登录失败",
+		})
+		return
+	}
+
+	h.Logger.Info("通过第三方账号创建用户", zap.String("user_id", user.UserID), zap.String("provider", provider))
+
+	if !user.IsEmailVerified() {
+		if err := h.Auth.sendVerificationEmail(c.Request.Context(), user); err != nil {
+			h.Logger.Warn("发送验证邮件失败", zap.Error(err), zap.String("user_id", user.UserID))
+		}
+	}
+
+	h.Auth.beginLogin(c, *user)
+}
+
+// GetIdentities 获取当前用户绑定的第三方账号
+func (h *GinOIDCHandler) GetIdentities(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	identities, err := models.GetUserIdentities(h.DB, uid)
+	if err != nil {
+		h.Logger.Error("获取第三方账号绑定失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取第三方账号绑定失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   identities,
+	})
+}
+
+// UnlinkIdentity 解除第三方账号绑定
+func (h *GinOIDCHandler) UnlinkIdentity(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	identityID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的绑定ID",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.DB.Where("user_id = ?", uid).First(&user).Error; err != nil {
+		h.Logger.Error("获取用户失败", zap.Error(err), zap.String("user_id", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取用户失败",
+		})
+		return
+	}
+
+	if err := models.UnlinkUserIdentity(h.DB, &user, identityID); err != nil {
+		switch {
+		case errors.Is(err, models.ErrIdentityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		case errors.Is(err, models.ErrLastLoginMethod):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		default:
+			h.Logger.Error("解除第三方账号绑定失败", zap.Error(err), zap.String("user_id", uid))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "解除绑定失败",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "已解除绑定",
+	})
+}
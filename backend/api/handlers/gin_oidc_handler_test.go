@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/loginguard"
+	"github.com/alexfaker/jilang-agent/pkg/oidc"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	oidcAuthorizePattern = "/auth/oidc/:provider/authorize"
+	oidcLinkPattern      = "/user/identities/:provider/link"
+	oidcCallbackPattern  = "/auth/oidc/:provider/callback"
+)
+
+// newTestOIDCHandler 创建连接到模拟提供方mock的第三方登录处理程序
+func newTestOIDCHandler(t *testing.T, db *gorm.DB) *GinOIDCHandler {
+	t.Helper()
+	mock, err := oidc.NewMockServer("http://placeholder")
+	if err != nil {
+		t.Fatalf("创建模拟提供方失败: %v", err)
+	}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	mock.Issuer = srv.URL
+
+	providers, err := oidc.NewRegistry([]config.OIDCProviderConfig{{
+		Name:        "mock",
+		Issuer:      srv.URL,
+		ClientID:    "test-client",
+		RedirectURL: "http://app.example.com/oauth/callback",
+	}})
+	if err != nil {
+		t.Fatalf("创建登录提供方失败: %v", err)
+	}
+
+	cfg := config.AuthConfig{
+		JWTSecret:              "oidc-test-secret",
+		AccessTokenExpiration:  15,
+		RefreshTokenExpiration: 7,
+		OAuthStateExpiration:   10,
+	}
+	guard := loginguard.New(loginguard.NewMemoryStore(time.Minute), loginguard.LimitsFromConfig(cfg))
+	auth := NewGinAuthHandler(db, zap.NewNop(), cfg, nil, guard)
+	return NewGinOIDCHandler(db, zap.NewNop(), cfg, providers, auth)
+}
+
+// startOIDC 发起登录（principal为空）或绑定，返回授权地址和state
+func startOIDC(t *testing.T, h *GinOIDCHandler, principal *middleware.Principal) (string, string) {
+	t.Helper()
+	var w *httptest.ResponseRecorder
+	if principal == nil {
+		w = serveRoute(h.Authorize, nil, http.MethodPost, oidcAuthorizePattern, "/auth/oidc/mock/authorize", "")
+	} else {
+		w = serveRoute(h.LinkIdentity, principal, http.MethodPost, oidcLinkPattern, "/user/identities/mock/link", "")
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("发起授权应返回200，实际为%d: %s", w.Code, w.Body.String())
+	}
+
+	var data struct {
+		AuthorizationURL string `json:"authorizationUrl"`
+		State            string `json:"state"`
+	}
+	decodeResponse(t, w, &data)
+	return data.AuthorizationURL, data.State
+}
+
+// providerCode 在模拟提供方完成授权，loginHint为模拟用户的邮箱，为空时使用默认用户，返回授权码
+func providerCode(t *testing.T, authURL, loginHint string) string {
+	t.Helper()
+	if loginHint != "" {
+		authURL += "&login_hint=" + url.QueryEscape(loginHint)
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("请求授权端点失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Query().Get("code") == "" {
+		t.Fatalf("授权端点应重定向并带回授权码，实际为%d %s", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return location.Query().Get("code")
+}
+
+// oidcCallback 以前端回调页的身份提交code和state
+func oidcCallback(h *GinOIDCHandler, code, state string) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"code":%q,"state":%q}`, code, state)
+	return serveRoute(h.Callback, nil, http.MethodPost, oidcCallbackPattern, "/auth/oidc/mock/callback", body)
+}
+
+func countRows(t *testing.T, db *gorm.DB, model interface{}) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(model).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestOIDCLoginCreatesUserAndIdentity(t *testing.T) {
+	db := newTestDB(t)
+	h := newTestOIDCHandler(t, db)
+
+	authURL, state := startOIDC(t, h, nil)
+	w := oidcCallback(h, providerCode(t, authURL, "carol@example.com"), state)
+	if w.Code != http.StatusOK {
+		t.Fatalf("首次登录应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+	var data struct {
+		Token string `json:"token"`
+	}
+	decodeResponse(t, w, &data)
+	if data.Token == "" {
+		t.Error("登录成功应返回访问令牌")
+	}
+
+	var user models.User
+	if err := db.Where("email = ?", "carol@example.com").First(&user).Error; err != nil {
+		t.Fatalf("首次登录应创建用户: %v", err)
+	}
+	if !user.IsEmailVerified() {
+		t.Error("提供方已验证的邮箱应视为已验证")
+	}
+	var identity models.UserIdentity
+	if err := db.Where("provider = ? AND subject = ?", "mock", "mock-carol@example.com").First(&identity).Error; err != nil {
+		t.Fatalf("首次登录应创建绑定: %v", err)
+	}
+	if identity.UserID != user.UserID {
+		t.Errorf("绑定应属于新用户%s，实际为%s", user.UserID, identity.UserID)
+	}
+
+	// 再次登录使用已有绑定，不再创建用户
+	authURL, state = startOIDC(t, h, nil)
+	if w := oidcCallback(h, providerCode(t, authURL, "carol@example.com"), state); w.Code != http.StatusOK {
+		t.Fatalf("再次登录应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+	if users, identities := countRows(t, db, &models.User{}), countRows(t, db, &models.UserIdentity{}); users != 1 || identities != 1 {
+		t.Errorf("再次登录不应新建用户或绑定，实际有%d个用户、%d个绑定", users, identities)
+	}
+}
+
+func TestOIDCLinkExistingUser(t *testing.T) {
+	db := newTestDB(t)
+	h := newTestOIDCHandler(t, db)
+	dave := createTestUser(t, db, "dave", 0)
+
+	// 第三方账号的邮箱与本地账号不同也可以绑定
+	authURL, state := startOIDC(t, h, jwtPrincipal(dave))
+	w := oidcCallback(h, providerCode(t, authURL, "dave.work@example.com"), state)
+	if w.Code != http.StatusOK {
+		t.Fatalf("绑定应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+
+	var identity models.UserIdentity
+	if err := db.Where("provider = ? AND subject = ?", "mock", "mock-dave.work@example.com").First(&identity).Error; err != nil {
+		t.Fatalf("绑定后应保存第三方身份: %v", err)
+	}
+	if identity.UserID != dave.UserID {
+		t.Errorf("绑定应属于发起绑定的用户%s，实际为%s", dave.UserID, identity.UserID)
+	}
+	if users := countRows(t, db, &models.User{}); users != 1 {
+		t.Errorf("绑定不应创建新用户，实际有%d个用户", users)
+	}
+
+	// 绑定后使用第三方账号登录到已有用户
+	authURL, state = startOIDC(t, h, nil)
+	w = oidcCallback(h, providerCode(t, authURL, "dave.work@example.com"), state)
+	if w.Code != http.StatusOK {
+		t.Fatalf("绑定后登录应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+	var data struct {
+		User struct {
+			ID int64 `json:"id"`
+		} `json:"user"`
+	}
+	decodeResponse(t, w, &data)
+	if data.User.ID != dave.ID {
+		t.Errorf("应登录到已绑定的用户%d，实际为%d", dave.ID, data.User.ID)
+	}
+}
+
+func TestOIDCLoginDoesNotTakeOverExistingEmail(t *testing.T) {
+	db := newTestDB(t)
+	h := newTestOIDCHandler(t, db)
+	createTestUser(t, db, "erin", 0)
+
+	authURL, state := startOIDC(t, h, nil)
+	w := oidcCallback(h, providerCode(t, authURL, "erin@example.com"), state)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("邮箱已注册时应返回409，实际为%d: %s", w.Code, w.Body.String())
+	}
+	if _, code := decodeResponse(t, w, nil); code != "account_exists" {
+		t.Errorf("错误码应为account_exists，实际为%q", code)
+	}
+	if identities := countRows(t, db, &models.UserIdentity{}); identities != 0 {
+		t.Errorf("不应自动绑定已注册的邮箱，实际有%d个绑定", identities)
+	}
+}
+
+func TestOIDCCallbackRejectsStateMismatch(t *testing.T) {
+	db := newTestDB(t)
+	h := newTestOIDCHandler(t, db)
+
+	authURL, state := startOIDC(t, h, nil)
+	code := providerCode(t, authURL, "")
+
+	if w := oidcCallback(h, code, state+"x"); w.Code != http.StatusBadRequest {
+		t.Fatalf("state不匹配时应返回400，实际为%d: %s", w.Code, w.Body.String())
+	}
+	if users := countRows(t, db, &models.User{}); users != 0 {
+		t.Fatalf("state不匹配时不应创建用户，实际有%d个用户", users)
+	}
+
+	// state只能使用一次
+	if w := oidcCallback(h, code, state); w.Code != http.StatusOK {
+		t.Fatalf("正确的state应登录成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+	authURL, _ = startOIDC(t, h, nil)
+	if w := oidcCallback(h, providerCode(t, authURL, ""), state); w.Code != http.StatusBadRequest {
+		t.Errorf("重复使用state应返回400，实际为%d", w.Code)
+	}
+}
+
+func TestOIDCCallbackRejectsCodeFromOtherRequest(t *testing.T) {
+	db := newTestDB(t)
+	h := newTestOIDCHandler(t, db)
+
+	// 授权码与state来自不同的授权请求时，PKCE校验值不匹配，提供方拒绝换取令牌
+	firstURL, _ := startOIDC(t, h, nil)
+	_, secondState := startOIDC(t, h, nil)
+	if w := oidcCallback(h, providerCode(t, firstURL, ""), secondState); w.Code != http.StatusBadRequest {
+		t.Fatalf("PKCE校验失败时应返回400，实际为%d: %s", w.Code, w.Body.String())
+	}
+	if users := countRows(t, db, &models.User{}); users != 0 {
+		t.Errorf("PKCE校验失败时不应创建用户，实际有%d个用户", users)
+	}
+}
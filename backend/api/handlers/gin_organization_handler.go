@@ -0,0 +1,517 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/mailer"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinOrganizationHandler 处理组织、成员和邀请相关的请求
+type GinOrganizationHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+	Config config.AuthConfig
+	Mailer mailer.Mailer
+}
+
+// NewGinOrganizationHandler 创建新的组织处理程序
+func NewGinOrganizationHandler(db *gorm.DB, logger *zap.Logger, cfg config.AuthConfig, m mailer.Mailer) *GinOrganizationHandler {
+	return &GinOrganizationHandler{
+		DB:     db,
+		Logger: logger,
+		Config: cfg,
+		Mailer: m,
+	}
+}
+
+// organizationErrorStatus 组织相关业务错误对应的HTTP状态码，非业务错误返回0
+func organizationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrOrganizationNotFound),
+		errors.Is(err, models.ErrOrgMemberNotFound),
+		errors.Is(err, models.ErrOrgInvitationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrNotOrganizationMember),
+		errors.Is(err, models.ErrOrgOwnerImmutable),
+		errors.Is(err, models.ErrOrgRoleNotAllowed),
+		errors.Is(err, models.ErrOrgInvitationEmail):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrOrgMemberExists),
+		errors.Is(err, models.ErrOrgInvitationDuplicate):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrOrgRoleInvalid),
+		errors.Is(err, models.ErrOrgInvitationInvalid),
+		errors.Is(err, models.ErrOrgInvitationExpired):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondOrganizationError 输出组织相关错误，非业务错误记录日志并返回500
+func (h *GinOrganizationHandler) respondOrganizationError(c *gin.Context, err error, message string) {
+	if status := organizationErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// requireOrgMember 校验当前用户是路径中组织的成员并拥有指定权限
+func (h *GinOrganizationHandler) requireOrgMember(c *gin.Context, permission models.OrgPermission) (*middleware.Principal, *models.OrganizationMember, bool) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return nil, nil, false
+	}
+
+	orgID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的组织ID",
+		})
+		return nil, nil, false
+	}
+
+	member, err := models.GetOrganizationMember(h.DB, orgID, principal.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNotOrganizationMember) {
+			// 不向非成员暴露组织是否存在
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": models.ErrOrganizationNotFound.Error(),
+			})
+		} else {
+			h.respondOrganizationError(c, err, "获取组织成员失败")
+		}
+		return nil, nil, false
+	}
+
+	if !models.HasOrgPermission(member.Role, permission) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"code":    "workspace_forbidden",
+			"message": "您在该组织中没有此操作的权限",
+		})
+		return nil, nil, false
+	}
+
+	return principal, member, true
+}
+
+// GetOrganizations 获取当前用户所在的组织列表
+func (h *GinOrganizationHandler) GetOrganizations(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	orgs, err := models.GetUserOrganizations(h.DB, principal.UserID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "获取组织列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   orgs,
+	})
+}
+
+// OrganizationRequest 创建或修改组织请求结构
+type OrganizationRequest struct {
+	Name string `json:"name" binding:"required,max=100"`
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func (h *GinOrganizationHandler) CreateOrganization(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "组织名称不能为空",
+		})
+		return
+	}
+
+	org, err := models.CreateOrganization(h.DB, principal.UserID, name)
+	if err != nil {
+		h.respondOrganizationError(c, err, "创建组织失败")
+		return
+	}
+
+	h.Logger.Info("用户创建了组织", zap.String("user_id", principal.UserID), zap.Int64("organization_id", org.ID))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data": models.UserOrganization{
+			Organization: *org,
+			Role:         models.OrgRoleOwner,
+		},
+	})
+}
+
+// GetOrganization 获取组织详情
+func (h *GinOrganizationHandler) GetOrganization(c *gin.Context) {
+	_, member, ok := h.requireOrgMember(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	org, err := models.GetOrganization(h.DB, member.OrganizationID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "获取组织失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": models.UserOrganization{
+			Organization: *org,
+			Role:         member.Role,
+		},
+	})
+}
+
+// UpdateOrganization 修改组织名称
+func (h *GinOrganizationHandler) UpdateOrganization(c *gin.Context) {
+	_, member, ok := h.requireOrgMember(c, models.OrgPermissionSettingsWrite)
+	if !ok {
+		return
+	}
+
+	var req OrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "组织名称不能为空",
+		})
+		return
+	}
+
+	if err := h.DB.Model(&models.Organization{}).Where("id = ?", member.OrganizationID).Update("name", name).Error; err != nil {
+		h.respondOrganizationError(c, err, "更新组织失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "组织已更新",
+	})
+}
+
+// GetOrganizationMembers 获取组织成员列表
+func (h *GinOrganizationHandler) GetOrganizationMembers(c *gin.Context) {
+	_, member, ok := h.requireOrgMember(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	members, err := models.GetOrganizationMembers(h.DB, member.OrganizationID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "获取组织成员失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   members,
+	})
+}
+
+// UpdateMemberRoleRequest 修改成员角色请求结构
+type UpdateMemberRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// getTargetMember 获取被操作的成员，用于记录审计快照；目标不是成员时返回成员不存在
+func getTargetMember(db *gorm.DB, orgID int64, userID string) (*models.OrganizationMember, error) {
+	member, err := models.GetOrganizationMember(db, orgID, userID)
+	if errors.Is(err, models.ErrNotOrganizationMember) {
+		return nil, models.ErrOrgMemberNotFound
+	}
+	return member, err
+}
+
+// UpdateOrganizationMember 修改成员角色
+func (h *GinOrganizationHandler) UpdateOrganizationMember(c *gin.Context) {
+	principal, actor, ok := h.requireOrgMember(c, models.OrgPermissionMemberManage)
+	if !ok {
+		return
+	}
+
+	var req UpdateMemberRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	userID := c.Param("userId")
+	previous, err := getTargetMember(h.DB, actor.OrganizationID, userID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "修改成员角色失败")
+		return
+	}
+	member, err := models.UpdateOrganizationMemberRole(h.DB, actor.OrganizationID, actor.Role, userID, req.Role)
+	if err != nil {
+		h.respondOrganizationError(c, err, "修改成员角色失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionOrgRoleChange, "organization_member", userID)
+	event.OrganizationID = &actor.OrganizationID
+	event.Before = models.AuditSnapshot(gin.H{"role": previous.Role})
+	event.After = models.AuditSnapshot(gin.H{"role": member.Role})
+	recordAudit(h.DB, h.Logger, event)
+
+	h.Logger.Info("组织成员角色已修改",
+		zap.Int64("organization_id", actor.OrganizationID),
+		zap.String("operator", principal.UserID),
+		zap.String("user_id", userID),
+		zap.String("role", req.Role),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   member,
+	})
+}
+
+// RemoveOrganizationMember 移除成员，成员可以移除自己以退出组织
+func (h *GinOrganizationHandler) RemoveOrganizationMember(c *gin.Context) {
+	userID := c.Param("userId")
+
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	// 退出组织只需是成员，移除他人需要成员管理权限
+	permission := models.OrgPermissionMemberManage
+	if userID == principal.UserID {
+		permission = models.OrgPermissionRead
+	}
+
+	_, actor, ok := h.requireOrgMember(c, permission)
+	if !ok {
+		return
+	}
+
+	previous, err := getTargetMember(h.DB, actor.OrganizationID, userID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "移除成员失败")
+		return
+	}
+	if err := models.RemoveOrganizationMember(h.DB, actor.OrganizationID, principal.UserID, actor.Role, userID); err != nil {
+		h.respondOrganizationError(c, err, "移除成员失败")
+		return
+	}
+
+	event := newAuditEvent(c, models.AuditActionOrgMemberRemove, "organization_member", userID)
+	event.OrganizationID = &actor.OrganizationID
+	event.Before = models.AuditSnapshot(gin.H{"role": previous.Role})
+	recordAudit(h.DB, h.Logger, event)
+
+	h.Logger.Info("组织成员已移除",
+		zap.Int64("organization_id", actor.OrganizationID),
+		zap.String("operator", principal.UserID),
+		zap.String("user_id", userID),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "成员已移除",
+	})
+}
+
+// CreateInvitationRequest 邀请成员请求结构
+type CreateInvitationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// CreateOrganizationInvitation 通过邮件邀请成员加入组织
+func (h *GinOrganizationHandler) CreateOrganizationInvitation(c *gin.Context) {
+	principal, actor, ok := h.requireOrgMember(c, models.OrgPermissionMemberManage)
+	if !ok {
+		return
+	}
+
+	var req CreateInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	org, err := models.GetOrganization(h.DB, actor.OrganizationID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "获取组织失败")
+		return
+	}
+
+	token, invitation, err := models.CreateOrganizationInvitation(h.DB, org.ID, actor.Role, req.Email, req.Role, principal.UserID, h.Config.OrgInvitationTTL())
+	if err != nil {
+		h.respondOrganizationError(c, err, "创建邀请失败")
+		return
+	}
+
+	if err := h.sendInvitationEmail(c.Request.Context(), org, principal.Username, invitation, token); err != nil {
+		// 邀请已保存，邮件发送失败时可撤销后重新邀请
+		h.Logger.Error("发送组织邀请邮件失败", zap.Error(err), zap.Int64("invitation_id", invitation.ID))
+	}
+
+	h.Logger.Info("组织邀请已创建",
+		zap.Int64("organization_id", org.ID),
+		zap.String("operator", principal.UserID),
+		zap.Int64("invitation_id", invitation.ID),
+	)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status":  "success",
+		"message": "邀请已发送",
+		"data":    invitation,
+	})
+}
+
+// sendInvitationEmail 发送组织邀请邮件
+func (h *GinOrganizationHandler) sendInvitationEmail(ctx context.Context, org *models.Organization, inviter string, invitation *models.OrganizationInvitation, token string) error {
+	link := strings.TrimRight(h.Config.FrontendURL, "/") + "/org-invitations?token=" + url.QueryEscape(token)
+	return h.Mailer.Send(ctx, mailer.Message{
+		To:      invitation.Email,
+		Subject: fmt.Sprintf("%s 邀请您加入组织「%s」", inviter, org.Name),
+		Body: fmt.Sprintf("您好：\n\n%s 邀请您以%s身份加入组织「%s」。请在%d小时内使用该邮箱对应的账号登录后点击以下链接接受邀请：\n%s\n\n如果您不认识邀请人，请忽略此邮件。",
+			inviter, invitation.Role, org.Name, h.Config.OrgInvitationExpiration, link),
+	})
+}
+
+// GetOrganizationInvitations 获取组织待处理的邀请
+func (h *GinOrganizationHandler) GetOrganizationInvitations(c *gin.Context) {
+	_, actor, ok := h.requireOrgMember(c, models.OrgPermissionMemberManage)
+	if !ok {
+		return
+	}
+
+	invitations, err := models.GetOrganizationInvitations(h.DB, actor.OrganizationID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "获取邀请列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   invitations,
+	})
+}
+
+// RevokeOrganizationInvitation 撤销邀请
+func (h *GinOrganizationHandler) RevokeOrganizationInvitation(c *gin.Context) {
+	_, actor, ok := h.requireOrgMember(c, models.OrgPermissionMemberManage)
+	if !ok {
+		return
+	}
+
+	invitationID, err := strconv.ParseInt(c.Param("invitationId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的邀请ID",
+		})
+		return
+	}
+
+	if err := models.RevokeOrganizationInvitation(h.DB, actor.OrganizationID, invitationID); err != nil {
+		h.respondOrganizationError(c, err, "撤销邀请失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "邀请已撤销",
+	})
+}
+
+// AcceptInvitationRequest 接受邀请请求结构
+type AcceptInvitationRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// AcceptOrganizationInvitation 接受邀请加入组织，需使用受邀邮箱对应的已验证账号
+func (h *GinOrganizationHandler) AcceptOrganizationInvitation(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var req AcceptInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	user, err := models.GetUserByID(h.DB, principal.UserID)
+	if err != nil {
+		h.respondOrganizationError(c, err, "获取用户信息失败")
+		return
+	}
+
+	member, err := models.AcceptOrganizationInvitation(h.DB, req.Token, user)
+	if err != nil {
+		h.respondOrganizationError(c, err, "接受邀请失败")
+		return
+	}
+
+	h.Logger.Info("用户加入了组织", zap.String("user_id", principal.UserID), zap.Int64("organization_id", member.OrganizationID))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "已加入组织",
+		"data":    member,
+	})
+}
@@ -25,34 +25,20 @@ func NewGinPointsHandler(db *gorm.DB, logger *zap.Logger) *GinPointsHandler {
 	}
 }
 
-// GetPointsBalance 获取用户点数余额
+// GetPointsBalance 获取当前工作空间的点数余额
 func (h *GinPointsHandler) GetPointsBalance(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
-	// 查询用户信息
-	var user models.User
-	result := h.DB.Where("user_id = ?", userID.(string)).First(&user)
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"status":  "error",
-				"message": "用户不存在",
-			})
-		} else {
-			h.Logger.Error("获取用户信息失败", zap.Error(result.Error), zap.String("userId", userID.(string)))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "获取用户信息失败",
-			})
-		}
+	balance, err := models.GetWorkspaceBalance(h.DB, principal.Workspace)
+	if err != nil {
+		h.Logger.Error("获取点数余额失败", zap.Error(err), zap.String("userId", principal.UserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取点数余额失败",
+		})
 		return
 	}
 
@@ -60,21 +46,17 @@ func (h *GinPointsHandler) GetPointsBalance(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data": gin.H{
-			"points": user.Points,
-			"userId": user.ID,
+			"points":         balance,
+			"userId":         principal.ID,
+			"organizationId": principal.Workspace.OrganizationID,
 		},
 	})
 }
 
-// GetPointsTransactions 获取点数交易历史
+// GetPointsTransactions 获取当前工作空间的点数交易历史
 func (h *GinPointsHandler) GetPointsTransactions(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
@@ -101,7 +83,7 @@ func (h *GinPointsHandler) GetPointsTransactions(c *gin.Context) {
 	typeFilter := c.Query("type")
 
 	// 构建查询
-	query := h.DB.Model(&models.PointsTransaction{}).Where("user_id = ?", userID.(string))
+	query := h.DB.Model(&models.PointsTransaction{}).Scopes(principal.Workspace.Scope("points_transactions"))
 
 	// 应用类型筛选
 	if typeFilter != "" {
@@ -141,13 +123,8 @@ func (h *GinPointsHandler) GetPointsTransactions(c *gin.Context) {
 
 // GetPointsTransaction 获取单个交易详情
 func (h *GinPointsHandler) GetPointsTransaction(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
@@ -164,7 +141,7 @@ func (h *GinPointsHandler) GetPointsTransaction(c *gin.Context) {
 
 	// 查询交易 - 验证所有权
 	var transaction models.PointsTransaction
-	result := h.DB.Where("id = ? AND user_id = ?", id, userID.(string)).First(&transaction)
+	result := h.DB.Scopes(principal.Workspace.Scope("points_transactions")).Where("id = ?", id).First(&transaction)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -188,27 +165,22 @@ func (h *GinPointsHandler) GetPointsTransaction(c *gin.Context) {
 	})
 }
 
-// GetPointsStatistics 获取点数统计信息
+// GetPointsStatistics 获取当前工作空间的点数统计信息
 func (h *GinPointsHandler) GetPointsStatistics(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
-	uid := userID.(string)
+	ws := principal.Workspace
 
-	// 查询用户当前余额
-	var user models.User
-	if err := h.DB.Where("user_id = ?", uid).First(&user).Error; err != nil {
-		h.Logger.Error("获取用户信息失败", zap.Error(err), zap.String("userId", uid))
+	// 查询当前余额
+	balance, err := models.GetWorkspaceBalance(h.DB, ws)
+	if err != nil {
+		h.Logger.Error("获取点数余额失败", zap.Error(err), zap.String("userId", principal.UserID))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "获取用户信息失败",
+			"message": "获取点数余额失败",
 		})
 		return
 	}
@@ -216,14 +188,16 @@ func (h *GinPointsHandler) GetPointsStatistics(c *gin.Context) {
 	// 统计总充值
 	var totalRecharge int64
 	h.DB.Model(&models.PointsTransaction{}).
-		Where("user_id = ? AND type = ?", uid, models.TransactionTypeRecharge).
+		Scopes(ws.Scope("points_transactions")).
+		Where("type = ?", models.TransactionTypeRecharge).
 		Select("COALESCE(SUM(amount), 0)").
 		Scan(&totalRecharge)
 
 	// 统计总消费
 	var totalSpent int64
 	h.DB.Model(&models.PointsTransaction{}).
-		Where("user_id = ? AND type IN ?", uid, []models.TransactionType{
+		Scopes(ws.Scope("points_transactions")).
+		Where("type IN ?", []models.TransactionType{
 			models.TransactionTypePurchase,
 			models.TransactionTypeExecution,
 		}).
@@ -232,22 +206,23 @@ func (h *GinPointsHandler) GetPointsStatistics(c *gin.Context) {
 
 	// 统计购买的工作流数量
 	var workflowCount int64
-	h.DB.Model(&models.Workflow{}).
-		Where("user_id = ? AND agent_id IS NOT NULL", uid).
+	h.DB.Unscoped().Model(&models.Workflow{}).
+		Scopes(ws.Scope("workflows"), models.PurchasedWorkflows).
 		Count(&workflowCount)
 
 	// 统计最近30天的交易 - 使用GORM标准方法
 	thirtyDaysAgo := time.Now().AddDate(0, 0, -30)
 	var recentTransactionCount int64
 	h.DB.Model(&models.PointsTransaction{}).
-		Where("user_id = ? AND created_at >= ?", uid, thirtyDaysAgo).
+		Scopes(ws.Scope("points_transactions")).
+		Where("created_at >= ?", thirtyDaysAgo).
 		Count(&recentTransactionCount)
 
 	// 返回统计信息
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data": gin.H{
-			"currentBalance":         user.Points,
+			"currentBalance":         balance,
 			"totalRecharge":          totalRecharge,
 			"totalSpent":             totalSpent,
 			"purchasedWorkflowCount": workflowCount,
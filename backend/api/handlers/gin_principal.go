@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+)
+
+// requirePrincipal 获取当前请求的身份主体，未认证时写入401响应
+func requirePrincipal(c *gin.Context) (*middleware.Principal, bool) {
+	principal, ok := middleware.GetPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "无效的用户身份",
+		})
+		return nil, false
+	}
+	return principal, true
+}
+
+// requireWorkspace 获取当前请求主体，并校验其在当前工作空间中拥有指定权限，失败时已写入响应
+func requireWorkspace(c *gin.Context, permission models.OrgPermission) (*middleware.Principal, bool) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return nil, false
+	}
+	if !principal.Workspace.Can(permission) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"code":    "workspace_forbidden",
+			"message": "您在当前工作空间中没有此操作的权限",
+		})
+		return nil, false
+	}
+	return principal, true
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/database"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建已迁移的临时SQLite数据库
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "handlers.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db
+}
+
+// createTestUser 创建拥有指定点数的普通用户
+func createTestUser(t *testing.T, db *gorm.DB, name string, points int) *models.User {
+	t.Helper()
+	user := &models.User{
+		UserID:       "USER_" + name,
+		Username:     name,
+		Email:        name + "@example.com",
+		PasswordHash: "x",
+		Role:         models.RoleUser,
+		Points:       points,
+	}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+	return user
+}
+
+// createTestOrganization 创建拥有指定点数的组织，owner为所有者
+func createTestOrganization(t *testing.T, db *gorm.DB, owner *models.User, points int) *models.Organization {
+	t.Helper()
+	org := &models.Organization{Name: owner.Username + "的组织", OwnerID: owner.UserID, Points: points}
+	if err := db.Create(org).Error; err != nil {
+		t.Fatalf("创建测试组织失败: %v", err)
+	}
+	return org
+}
+
+// jwtPrincipal 通过JWT登录、操作个人空间的主体
+func jwtPrincipal(user *models.User) *middleware.Principal {
+	return &middleware.Principal{
+		ID:         user.ID,
+		UserID:     user.UserID,
+		Username:   user.Username,
+		Role:       user.Role,
+		AuthMethod: middleware.AuthMethodJWT,
+		Workspace:  models.PersonalWorkspace(user.UserID),
+	}
+}
+
+// apiKeyPrincipal 通过API密钥认证、操作个人空间的主体
+func apiKeyPrincipal(user *models.User, scopes ...models.APIKeyScope) *middleware.Principal {
+	principal := jwtPrincipal(user)
+	principal.AuthMethod = middleware.AuthMethodAPIKey
+	principal.APIKeyID = 1
+	principal.Scopes = scopes
+	return principal
+}
+
+// orgPrincipal 以指定组织角色操作组织空间的主体
+func orgPrincipal(user *models.User, org *models.Organization, role string) *middleware.Principal {
+	principal := jwtPrincipal(user)
+	principal.Workspace = models.Workspace{UserID: user.UserID, OrganizationID: &org.ID, Role: role}
+	return principal
+}
+
+// serveAs 以指定主体调用处理函数，principal为空时模拟未认证的请求
+func serveAs(handler gin.HandlerFunc, principal *middleware.Principal, method, target, body string) *httptest.ResponseRecorder {
+	return serveRoute(handler, principal, method, strings.SplitN(target, "?", 2)[0], target, body)
+}
+
+// serveRoute 与serveAs相同，处理函数注册在带路径参数的pattern上
+func serveRoute(handler gin.HandlerFunc, principal *middleware.Principal, method, pattern, target, body string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Handle(method, pattern, func(c *gin.Context) {
+		if principal != nil {
+			middleware.SetPrincipal(c, principal)
+		}
+		c.Next()
+	}, handler)
+
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// decodeResponse 解析统一格式的响应，data解析到out中，out为空时只返回status和code
+func decodeResponse(t *testing.T, w *httptest.ResponseRecorder, out interface{}) (status, code string) {
+	t.Helper()
+	var body struct {
+		Status string          `json:"status"`
+		Code   string          `json:"code"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("解析响应失败: %v，响应为 %s", err, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(body.Data, out); err != nil {
+			t.Fatalf("解析响应数据失败: %v，响应为 %s", err, w.Body.String())
+		}
+	}
+	return body.Status, body.Code
+}
+
+func TestRequireWorkspaceRejectsMissingPrincipal(t *testing.T) {
+	handler := func(c *gin.Context) {
+		if _, ok := requireWorkspace(c, models.OrgPermissionRead); ok {
+			c.Status(http.StatusNoContent)
+		}
+	}
+
+	if w := serveAs(handler, nil, http.MethodGet, "/", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("未认证的请求应返回401，实际为%d", w.Code)
+	}
+}
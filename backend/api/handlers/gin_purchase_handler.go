@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/config"
 	"github.com/alexfaker/jilang-agent/models"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -13,21 +17,24 @@ import (
 
 // GinPurchaseHandler 处理购买相关的请求
 type GinPurchaseHandler struct {
-	DB     *gorm.DB
-	Logger *zap.Logger
+	DB          *gorm.DB
+	Logger      *zap.Logger
+	Marketplace config.MarketplaceConfig
 }
 
 // NewGinPurchaseHandler 创建一个新的GinPurchaseHandler实例
-func NewGinPurchaseHandler(db *gorm.DB, logger *zap.Logger) *GinPurchaseHandler {
+func NewGinPurchaseHandler(db *gorm.DB, logger *zap.Logger, marketplace config.MarketplaceConfig) *GinPurchaseHandler {
 	return &GinPurchaseHandler{
-		DB:     db,
-		Logger: logger,
+		DB:          db,
+		Logger:      logger,
+		Marketplace: marketplace,
 	}
 }
 
 // PurchaseAgentRequest 购买代理请求结构
 type PurchaseAgentRequest struct {
-	AgentID int64 `json:"agentId" binding:"required"`
+	AgentID    int64  `json:"agentId" binding:"required"`
+	CouponCode string `json:"couponCode"` // 优惠码（可选）
 }
 
 // PurchaseError 购买错误
@@ -39,15 +46,10 @@ func (e *PurchaseError) Error() string {
 	return e.Message
 }
 
-// PurchaseAgent 购买代理
+// PurchaseAgent 购买代理，使用当前工作空间的钱包支付，购买的工作流归属当前工作空间
 func (h *GinPurchaseHandler) PurchaseAgent(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionBilling)
+	if !ok {
 		return
 	}
 
@@ -61,10 +63,47 @@ func (h *GinPurchaseHandler) PurchaseAgent(c *gin.Context) {
 		return
 	}
 
-	uid := userID.(string)
+	if _, err := h.purchaseAgent(c, principal, req); err != nil {
+		h.respondPurchaseError(c, err, "购买代理失败", zap.Int64("agentId", req.AgentID), zap.String("userId", principal.UserID))
+		return
+	}
+
+	// 返回成功响应
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "购买成功",
+	})
+}
+
+// respondPurchaseError 输出购买错误，非业务错误记录日志并返回500
+func (h *GinPurchaseHandler) respondPurchaseError(c *gin.Context, err error, message string, fields ...zap.Field) {
+	if purchaseErr, ok := err.(*PurchaseError); ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": purchaseErr.Message,
+		})
+		return
+	}
+
+	h.Logger.Error(message, append(fields, zap.Error(err))...)
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
 
-	// 使用事务处理购买流程
+// purchaseAgent 在事务中完成代理购买，返回购买生成的工作流
+func (h *GinPurchaseHandler) purchaseAgent(c *gin.Context, principal *middleware.Principal, req PurchaseAgentRequest) (*models.Workflow, error) {
+	uid := principal.UserID
+	ws := principal.Workspace
+
+	var workflow *models.Workflow
 	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 先锁定工作空间，同一工作空间的并发购买依次执行，避免重复购买和重复扣款
+		if err := models.LockWorkspace(tx, ws); err != nil {
+			return err
+		}
+
 		// 获取代理信息
 		var agent models.Agent
 		if err := tx.Where("id = ? AND is_public = ?", req.AgentID, true).First(&agent).Error; err != nil {
@@ -74,100 +113,381 @@ func (h *GinPurchaseHandler) PurchaseAgent(c *gin.Context) {
 			return err
 		}
 
-		// 检查用户是否已经购买过此代理
+		// 检查当前工作空间是否已经购买过此代理，已删除的工作流在保留期内可以恢复，不能重复购买
 		var existingWorkflow models.Workflow
-		err := tx.Where("user_id = ? AND agent_id = ?", uid, req.AgentID).First(&existingWorkflow).Error
+		err := tx.Unscoped().Scopes(ws.Scope("workflows")).Where("agent_id = ?", req.AgentID).First(&existingWorkflow).Error
 		if err == nil {
+			if existingWorkflow.DeletedAt.Valid {
+				return &PurchaseError{Message: "您已经购买过此代理，可在回收站中恢复"}
+			}
 			return &PurchaseError{Message: "您已经购买过此代理"}
 		} else if err != gorm.ErrRecordNotFound {
 			return err
 		}
 
-		// 获取用户信息
-		var user models.User
-		if err := tx.First(&user, uid).Error; err != nil {
+		// 检查套餐可购买代理数量限制
+		if err := checkPurchaseLimit(tx, ws, 1); err != nil {
 			return err
 		}
 
-		// 检查用户余额
-		if user.Points < agent.Price {
-			return &PurchaseError{Message: "余额不足，请先充值"}
+		// 计算实际支付价格，限时特价期间按特价，优惠码在特价基础上抵扣
+		listPrice := agent.PriceAt(time.Now())
+		price := listPrice
+		var coupon *models.Coupon
+		if req.CouponCode != "" {
+			coupon, err = models.GetCouponByCodeForUpdate(tx, req.CouponCode)
+			if err != nil {
+				return couponPurchaseError(err)
+			}
+			if err := coupon.CheckRedeemable(tx, uid, time.Now()); err != nil {
+				return couponPurchaseError(err)
+			}
+			if !coupon.AppliesToAgent(agent.ID) {
+				return couponPurchaseError(models.ErrCouponNotApplicable)
+			}
+			price -= coupon.DiscountFor(listPrice)
 		}
 
-		// 扣除用户点数
-		if err := tx.Model(&user).Update("points", user.Points-agent.Price).Error; err != nil {
-			return err
+		// 从当前工作空间的钱包扣除点数
+		description := "购买工作流: " + agent.Name
+		if coupon != nil {
+			description += "（优惠码: " + coupon.Code + "）"
 		}
-
-		// 创建点数交易记录
-		transaction := models.PointsTransaction{
-			UserID:      uid,
+		if _, err := models.ChangeWorkspacePoints(tx, ws, models.WorkspacePointsChange{
 			Type:        models.TransactionTypePurchase,
-			Amount:      -agent.Price,
-			Balance:     user.Points - agent.Price,
-			Description: "购买工作流: " + agent.Name,
+			Amount:      -price,
+			Description: description,
 			RelatedID:   &agent.ID,
-		}
-		if err := tx.Create(&transaction).Error; err != nil {
+		}); err != nil {
+			if errors.Is(err, models.ErrInsufficientPoints) {
+				return &PurchaseError{Message: err.Error()}
+			}
 			return err
 		}
 
-		// 创建工作流实例
-		now := time.Now()
-		workflow := models.Workflow{
-			Name:        agent.Name,
-			Description: agent.Description,
-			UserID:      uid,
-			AgentID:     &agent.ID,
-			Status:      models.WorkflowStatusActive,
-			Definition:  agent.Definition,
-			PurchasedAt: &now,
-			RunCount:    0,
-		}
-		if err := tx.Create(&workflow).Error; err != nil {
+		// 创建工作流实例并结算创作者分成
+		var creatorShare int
+		workflow, creatorShare, err = h.grantPurchasedAgent(tx, ws, uid, &agent, price, nil)
+		if err != nil {
 			return err
 		}
 
-		// 增加代理购买次数
-		if err := tx.Model(&agent).Update("purchase_count", gorm.Expr("purchase_count + ?", 1)).Error; err != nil {
+		// 试用过该代理时记录转化
+		if err := models.MarkTrialConverted(tx, uid, agent.ID, workflow.ID); err != nil {
 			return err
 		}
 
-		return nil
+		// 记录优惠码使用
+		if coupon != nil {
+			redemption := models.CouponRedemption{
+				UserID:     uid,
+				Discount:   listPrice - price,
+				AgentID:    &agent.ID,
+				WorkflowID: &workflow.ID,
+			}
+			if err := coupon.Redeem(tx, &redemption); err != nil {
+				return couponPurchaseError(err)
+			}
+		}
+
+		event := newAuditEvent(c, models.AuditActionPurchase, "agent", strconv.FormatInt(agent.ID, 10))
+		event.After = models.AuditSnapshot(gin.H{
+			"price":        price,
+			"listPrice":    agent.Price,
+			"onSale":       listPrice < agent.Price,
+			"workflowId":   workflow.ID,
+			"creatorId":    agent.CreatorID,
+			"creatorShare": creatorShare,
+		})
+		event.Description = description
+		return models.CreateAuditEvent(tx, event)
 	})
+	if err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
 
+// checkPurchaseLimit 检查工作空间的订阅套餐是否还能再购买count个代理，已删除但未彻底清除的工作流仍计入，需在事务中调用
+func checkPurchaseLimit(tx *gorm.DB, ws models.Workspace, count int) error {
+	plan, err := models.GetWorkspacePlan(tx, ws)
 	if err != nil {
-		if purchaseErr, ok := err.(*PurchaseError); ok {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"status":  "error",
-				"message": purchaseErr.Message,
-			})
-		} else {
-			h.Logger.Error("购买代理失败", zap.Error(err), zap.Int64("agentId", req.AgentID), zap.String("userId", uid))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "购买代理失败",
+		return err
+	}
+	if plan.MaxPurchasedAgents <= 0 {
+		return nil
+	}
+	var purchased int64
+	if err := tx.Unscoped().Model(&models.Workflow{}).
+		Scopes(ws.Scope("workflows"), models.PurchasedWorkflows).
+		Count(&purchased).Error; err != nil {
+		return err
+	}
+	if purchased+int64(count) > int64(plan.MaxPurchasedAgents) {
+		return &PurchaseError{Message: fmt.Sprintf("当前套餐（%s）最多可购买%d个代理，请升级套餐", plan.Name, plan.MaxPurchasedAgents)}
+	}
+	return nil
+}
+
+// grantPurchasedAgent 为已付款的代理创建工作流、增加购买次数并按实付价格结算创作者分成，需在事务中调用
+func (h *GinPurchaseHandler) grantPurchasedAgent(tx *gorm.DB, ws models.Workspace, uid string, agent *models.Agent, price int, bundleID *int64) (*models.Workflow, int, error) {
+	workflow, err := createPurchasedWorkflow(tx, ws, agent, bundleID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// 增加代理购买次数
+	if err := tx.Model(agent).Update("purchase_count", gorm.Expr("purchase_count + ?", 1)).Error; err != nil {
+		return nil, 0, err
+	}
+
+	// 创作者上架的代理按比例分成，创作者购买自己的代理不计收益
+	creatorShare := 0
+	if agent.CreatorID != "" && agent.CreatorID != uid && price > 0 {
+		creatorShare = models.CreatorShare(price, h.Marketplace.CreatorSharePercent)
+		if creatorShare > 0 {
+			if err := models.CreditCreatorEarning(tx, &models.CreatorEarning{
+				CreatorID:    agent.CreatorID,
+				AgentID:      agent.ID,
+				BuyerID:      uid,
+				WorkflowID:   &workflow.ID,
+				SalePrice:    price,
+				SharePercent: h.Marketplace.CreatorSharePercent,
+				Points:       creatorShare,
+			}); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	return workflow, creatorShare, nil
+}
+
+// createPurchasedWorkflow 根据代理在工作空间中创建已购买的工作流实例，bundleID为通过套餐购买时的套餐，需在事务中调用
+func createPurchasedWorkflow(tx *gorm.DB, ws models.Workspace, agent *models.Agent, bundleID *int64) (*models.Workflow, error) {
+	now := time.Now()
+	workflow := &models.Workflow{
+		Name:           agent.Name,
+		Description:    agent.Description,
+		UserID:         ws.UserID,
+		OrganizationID: ws.OrganizationID,
+		AgentID:        &agent.ID,
+		BundleID:       bundleID,
+		AgentVersion:   agent.LatestVersion,
+		Status:         models.WorkflowStatusActive,
+		Definition:     agent.Definition,
+		PurchasedAt:    &now,
+		RunCount:       0,
+	}
+	if err := tx.Create(workflow).Error; err != nil {
+		return nil, err
+	}
+	return workflow, nil
+}
+
+// couponPurchaseError 将优惠码错误转换为购买错误
+func couponPurchaseError(err error) error {
+	if msg, ok := couponErrorMessage(err); ok {
+		return &PurchaseError{Message: msg}
+	}
+	return err
+}
+
+// PurchaseBundleRequest 购买套餐请求结构
+type PurchaseBundleRequest struct {
+	BundleID      int64 `json:"bundleId" binding:"required"`
+	ExpectedPrice *int  `json:"expectedPrice"` // 报价接口返回的应付价格（可选），与实际价格不一致时拒绝购买
+}
+
+// quoteBundle 获取公开套餐并按当前工作空间已拥有的代理计算报价
+func quoteBundle(db *gorm.DB, ws models.Workspace, bundleID int64) (*models.AgentBundle, *models.BundleQuote, error) {
+	bundle, err := models.GetAgentBundle(db, bundleID, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	agentIDs := make([]int64, len(bundle.Agents))
+	for i, agent := range bundle.Agents {
+		agentIDs[i] = agent.ID
+	}
+	var ownedIDs []int64
+	if err := db.Unscoped().Model(&models.Workflow{}).
+		Scopes(ws.Scope("workflows")).
+		Where("agent_id IN ?", agentIDs).
+		Pluck("agent_id", &ownedIDs).Error; err != nil {
+		return nil, nil, err
+	}
+	owned := make(map[int64]bool, len(ownedIDs))
+	for _, id := range ownedIDs {
+		owned[id] = true
+	}
+
+	quote, err := models.QuoteBundle(bundle, owned)
+	if err != nil {
+		return nil, nil, err
+	}
+	return bundle, quote, nil
+}
+
+// bundlePurchaseError 将套餐错误转换为购买错误
+func bundlePurchaseError(err error) error {
+	switch {
+	case errors.Is(err, models.ErrBundleNotFound),
+		errors.Is(err, models.ErrBundleAgents),
+		errors.Is(err, models.ErrBundleOwned):
+		return &PurchaseError{Message: err.Error()}
+	}
+	return err
+}
+
+// GetBundleQuote 获取当前工作空间购买套餐的报价，已拥有的代理会被跳过并相应扣减价格
+func (h *GinPurchaseHandler) GetBundleQuote(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的套餐ID")
+	if !ok {
+		return
+	}
+
+	_, quote, err := quoteBundle(h.DB, principal.Workspace, id)
+	if err != nil {
+		status := http.StatusInternalServerError
+		message := "获取套餐报价失败"
+		switch {
+		case errors.Is(err, models.ErrBundleNotFound):
+			status, message = http.StatusNotFound, err.Error()
+		case errors.Is(err, models.ErrBundleAgents), errors.Is(err, models.ErrBundleOwned):
+			status, message = http.StatusBadRequest, err.Error()
+		default:
+			h.Logger.Error("获取套餐报价失败", zap.Error(err), zap.Int64("bundleId", id))
+		}
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": message,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   quote,
+	})
+}
+
+// PurchaseBundle 购买套餐，在一个事务中为套餐内尚未拥有的代理创建工作流，使用当前工作空间的钱包支付
+func (h *GinPurchaseHandler) PurchaseBundle(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionBilling)
+	if !ok {
+		return
+	}
+
+	var req PurchaseBundleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	uid := principal.UserID
+	ws := principal.Workspace
+
+	var quote *models.BundleQuote
+	workflowIDs := []int64{}
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 先锁定工作空间再计算报价，并发购买时按已拥有的代理重新报价，避免重复扣款
+		if err := models.LockWorkspace(tx, ws); err != nil {
+			return err
+		}
+
+		bundle, q, err := quoteBundle(tx, ws, req.BundleID)
+		if err != nil {
+			return bundlePurchaseError(err)
+		}
+		quote = q
+		if req.ExpectedPrice != nil && *req.ExpectedPrice != quote.Price {
+			return &PurchaseError{Message: "套餐价格已变化，请重新确认后购买"}
+		}
+
+		pending := 0
+		for _, item := range quote.Items {
+			if !item.Owned {
+				pending++
+			}
+		}
+		if err := checkPurchaseLimit(tx, ws, pending); err != nil {
+			return err
+		}
+
+		// 一次性扣除套餐应付点数
+		description := "购买套餐: " + bundle.Name
+		if skipped := len(quote.Items) - pending; skipped > 0 {
+			description += fmt.Sprintf("（跳过已拥有的%d个代理）", skipped)
+		}
+		if _, err := models.ChangeWorkspacePoints(tx, ws, models.WorkspacePointsChange{
+			Type:        models.TransactionTypePurchase,
+			Amount:      -quote.Price,
+			Description: description,
+			RelatedID:   &bundle.ID,
+		}); err != nil {
+			if errors.Is(err, models.ErrInsufficientPoints) {
+				return &PurchaseError{Message: err.Error()}
+			}
+			return err
+		}
+
+		// 按分摊价格逐个创建工作流并结算创作者分成
+		items := make([]gin.H, 0, pending)
+		for i, item := range quote.Items {
+			if item.Owned {
+				continue
+			}
+			workflow, creatorShare, err := h.grantPurchasedAgent(tx, ws, uid, &bundle.Agents[i], item.Price, &bundle.ID)
+			if err != nil {
+				return err
+			}
+			workflowIDs = append(workflowIDs, workflow.ID)
+			items = append(items, gin.H{
+				"agentId":      item.AgentID,
+				"price":        item.Price,
+				"workflowId":   workflow.ID,
+				"creatorId":    bundle.Agents[i].CreatorID,
+				"creatorShare": creatorShare,
 			})
 		}
+
+		event := newAuditEvent(c, models.AuditActionBundlePurchase, "agent_bundle", strconv.FormatInt(bundle.ID, 10))
+		event.After = models.AuditSnapshot(gin.H{
+			"price":       quote.Price,
+			"bundlePrice": quote.BundlePrice,
+			"valuePrice":  quote.ValuePrice,
+			"items":       items,
+		})
+		event.Description = description
+		return models.CreateAuditEvent(tx, event)
+	})
+
+	if err != nil {
+		h.respondPurchaseError(c, err, "购买套餐失败", zap.Int64("bundleId", req.BundleID), zap.String("userId", uid))
 		return
 	}
 
-	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
 		"message": "购买成功",
+		"data": gin.H{
+			"quote":       quote,
+			"workflowIds": workflowIDs,
+		},
 	})
 }
 
-// GetPurchaseHistory 获取购买历史
+// GetPurchaseHistory 获取当前工作空间的购买历史
 func (h *GinPurchaseHandler) GetPurchaseHistory(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
@@ -190,9 +510,10 @@ func (h *GinPurchaseHandler) GetPurchaseHistory(c *gin.Context) {
 		}
 	}
 
-	// 查询用户购买的工作流（只查询从代理购买的）
+	// 查询当前工作空间购买的工作流（只查询从代理购买的，不含克隆的），已删除的工作流也保留购买记录
 	var workflows []models.Workflow
-	query := h.DB.Where("user_id = ? AND agent_id IS NOT NULL", userID.(int64))
+	query := h.DB.Unscoped().Model(&models.Workflow{}).
+		Scopes(principal.Workspace.Scope("workflows"), models.PurchasedWorkflows)
 
 	// 获取总记录数
 	var total int64
@@ -244,3 +565,57 @@ func (h *GinPurchaseHandler) GetPurchaseHistory(c *gin.Context) {
 		},
 	})
 }
+
+// PurchaseTrialAgentRequest 试用后购买请求结构
+type PurchaseTrialAgentRequest struct {
+	CouponCode string `json:"couponCode"` // 优惠码（可选）
+}
+
+// PurchaseTrialAgent 根据试用记录一键购买试用的代理，使用当前工作空间的钱包支付
+func (h *GinPurchaseHandler) PurchaseTrialAgent(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionBilling)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的试用记录ID")
+	if !ok {
+		return
+	}
+
+	// 请求体可为空
+	var req PurchaseTrialAgentRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "请求数据格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	run, err := models.GetAgentTrialRun(h.DB, principal.UserID, id)
+	if err != nil {
+		if errors.Is(err, models.ErrTrialNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.respondPurchaseError(c, err, "获取试用记录失败")
+		return
+	}
+
+	workflow, err := h.purchaseAgent(c, principal, PurchaseAgentRequest{AgentID: run.AgentID, CouponCode: req.CouponCode})
+	if err != nil {
+		h.respondPurchaseError(c, err, "购买代理失败", zap.Int64("agentId", run.AgentID), zap.String("userId", principal.UserID))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "购买成功",
+		"data":    workflow,
+	})
+}
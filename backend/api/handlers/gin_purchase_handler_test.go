@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// createTestAgent 创建公开销售的代理
+func createTestAgent(t *testing.T, db *gorm.DB, price int) *models.Agent {
+	t.Helper()
+	agent := &models.Agent{
+		Name:       fmt.Sprintf("代理%d", price),
+		Type:       "chat",
+		Definition: []byte(`{"steps":[]}`),
+		Price:      price,
+		Status:     models.AgentStatusPublished,
+		IsPublic:   true,
+	}
+	if err := db.Create(agent).Error; err != nil {
+		t.Fatalf("创建测试代理失败: %v", err)
+	}
+	return agent
+}
+
+func purchaseBody(agentID int64) string {
+	return fmt.Sprintf(`{"agentId":%d}`, agentID)
+}
+
+func TestPurchaseAgentPersonalWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	h := NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{})
+	user := createTestUser(t, db, "buyer", 100)
+	agent := createTestAgent(t, db, 30)
+
+	w := serveAs(h.PurchaseAgent, jwtPrincipal(user), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("购买应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+
+	var reloaded models.User
+	db.First(&reloaded, user.ID)
+	if reloaded.Points != 70 {
+		t.Errorf("购买后余额应为70，实际为%d", reloaded.Points)
+	}
+
+	var workflow models.Workflow
+	if err := db.Where("agent_id = ?", agent.ID).First(&workflow).Error; err != nil {
+		t.Fatalf("购买后应创建工作流: %v", err)
+	}
+	if workflow.UserID != user.UserID || workflow.OrganizationID != nil || workflow.PurchasedAt == nil {
+		t.Errorf("工作流应归属购买者的个人空间，实际为user_id=%s organization_id=%v", workflow.UserID, workflow.OrganizationID)
+	}
+
+	var tx models.PointsTransaction
+	if err := db.Where("user_id = ? AND type = ?", user.UserID, models.TransactionTypePurchase).First(&tx).Error; err != nil {
+		t.Fatalf("购买后应记录交易: %v", err)
+	}
+	if tx.Amount != -30 || tx.Balance != 70 {
+		t.Errorf("交易记录应为-30、余额70，实际为%d、%d", tx.Amount, tx.Balance)
+	}
+
+	// 同一工作空间不能重复购买
+	w = serveAs(h.PurchaseAgent, jwtPrincipal(user), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("重复购买应返回400，实际为%d", w.Code)
+	}
+}
+
+func TestPurchaseAgentInsufficientPoints(t *testing.T) {
+	db := newTestDB(t)
+	h := NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{})
+	user := createTestUser(t, db, "poor", 10)
+	agent := createTestAgent(t, db, 30)
+
+	w := serveAs(h.PurchaseAgent, jwtPrincipal(user), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("余额不足应返回400，实际为%d: %s", w.Code, w.Body.String())
+	}
+
+	var count int64
+	db.Model(&models.Workflow{}).Count(&count)
+	if count != 0 {
+		t.Errorf("余额不足时不应创建工作流，实际创建了%d个", count)
+	}
+}
+
+func TestPurchaseAgentOrganizationWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	h := NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{})
+	owner := createTestUser(t, db, "owner", 0)
+	admin := createTestUser(t, db, "admin", 100)
+	org := createTestOrganization(t, db, owner, 50)
+	agent := createTestAgent(t, db, 30)
+
+	w := serveAs(h.PurchaseAgent, orgPrincipal(admin, org, models.OrgRoleAdmin), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID))
+	if w.Code != http.StatusOK {
+		t.Fatalf("组织管理员购买应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+
+	// 点数从组织钱包扣除，成员个人余额不变
+	var reloadedOrg models.Organization
+	db.First(&reloadedOrg, org.ID)
+	if reloadedOrg.Points != 20 {
+		t.Errorf("组织余额应为20，实际为%d", reloadedOrg.Points)
+	}
+	var reloadedAdmin models.User
+	db.First(&reloadedAdmin, admin.ID)
+	if reloadedAdmin.Points != 100 {
+		t.Errorf("成员个人余额不应变化，实际为%d", reloadedAdmin.Points)
+	}
+
+	var workflow models.Workflow
+	if err := db.Where("agent_id = ?", agent.ID).First(&workflow).Error; err != nil {
+		t.Fatalf("购买后应创建工作流: %v", err)
+	}
+	if workflow.OrganizationID == nil || *workflow.OrganizationID != org.ID {
+		t.Errorf("工作流应归属组织%d，实际为%v", org.ID, workflow.OrganizationID)
+	}
+
+	// 组织已购买的代理不影响成员在个人空间购买
+	w = serveAs(h.PurchaseAgent, jwtPrincipal(admin), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID))
+	if w.Code != http.StatusOK {
+		t.Errorf("个人空间购买应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestPurchaseAgentRequiresBillingPermission(t *testing.T) {
+	db := newTestDB(t)
+	h := NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{})
+	owner := createTestUser(t, db, "owner", 0)
+	member := createTestUser(t, db, "member", 100)
+	org := createTestOrganization(t, db, owner, 50)
+	agent := createTestAgent(t, db, 30)
+
+	w := serveAs(h.PurchaseAgent, orgPrincipal(member, org, models.OrgRoleMember), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("没有billing权限的成员应返回403，实际为%d", w.Code)
+	}
+	if _, code := decodeResponse(t, w, nil); code != "workspace_forbidden" {
+		t.Errorf("错误码应为workspace_forbidden，实际为%q", code)
+	}
+
+	var reloadedOrg models.Organization
+	db.First(&reloadedOrg, org.ID)
+	if reloadedOrg.Points != 50 {
+		t.Errorf("组织余额不应变化，实际为%d", reloadedOrg.Points)
+	}
+}
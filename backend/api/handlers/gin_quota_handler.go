@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinQuotaHandler 处理配额查询请求
+type GinQuotaHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+	Quotas *quota.Enforcer
+}
+
+// NewGinQuotaHandler 创建新的配额处理程序
+func NewGinQuotaHandler(db *gorm.DB, logger *zap.Logger, quotas *quota.Enforcer) *GinQuotaHandler {
+	return &GinQuotaHandler{
+		DB:     db,
+		Logger: logger,
+		Quotas: quotas,
+	}
+}
+
+// quotaExceeded 是否为超出配额错误
+func quotaExceeded(err error) bool {
+	var exceeded *quota.ExceededError
+	return errors.As(err, &exceeded)
+}
+
+// respondQuotaError 输出配额检查错误，超出配额时返回429或403，其他错误记录日志并返回500
+func respondQuotaError(c *gin.Context, logger *zap.Logger, err error) {
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		c.JSON(exceeded.HTTPStatus(), gin.H{
+			"status":  "error",
+			"code":    "quota_exceeded",
+			"quota":   exceeded.Quota,
+			"limit":   exceeded.Limit,
+			"message": exceeded.Message,
+		})
+		return
+	}
+
+	logger.Error("检查配额失败", zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": "检查配额失败",
+	})
+}
+
+// GetQuotas 获取当前工作空间的配额及使用情况
+func (h *GinQuotaHandler) GetQuotas(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	plan, usage, err := h.Quotas.Usage(principal.Workspace)
+	if err != nil {
+		h.Logger.Error("获取配额失败", zap.Error(err), zap.String("userId", principal.UserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取配额失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"plan":           plan.Code,
+			"planName":       plan.Name,
+			"organizationId": principal.Workspace.OrganizationID,
+			"quotas":         usage,
+		},
+	})
+}
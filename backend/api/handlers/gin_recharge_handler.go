@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/alexfaker/jilang-agent/config"
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/invoice"
+	"github.com/alexfaker/jilang-agent/pkg/payment"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -13,15 +19,19 @@ import (
 
 // GinRechargeHandler 处理充值相关的请求
 type GinRechargeHandler struct {
-	DB     *gorm.DB
-	Logger *zap.Logger
+	DB       *gorm.DB
+	Logger   *zap.Logger
+	Payments payment.Provider
+	Invoice  config.InvoiceConfig
 }
 
 // NewGinRechargeHandler 创建一个新的GinRechargeHandler实例
-func NewGinRechargeHandler(db *gorm.DB, logger *zap.Logger) *GinRechargeHandler {
+func NewGinRechargeHandler(db *gorm.DB, logger *zap.Logger, payments payment.Provider, invoiceConfig config.InvoiceConfig) *GinRechargeHandler {
 	return &GinRechargeHandler{
-		DB:     db,
-		Logger: logger,
+		DB:       db,
+		Logger:   logger,
+		Payments: payments,
+		Invoice:  invoiceConfig,
 	}
 }
 
@@ -42,15 +52,10 @@ type CreateRechargeRequest struct {
 	PackageID     *int   `json:"packageId,omitempty"`               // 套餐ID（可选）
 }
 
-// CreateRecharge 创建充值订单
+// CreateRecharge 为当前工作空间创建充值订单
 func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionBilling)
+	if !ok {
 		return
 	}
 
@@ -64,7 +69,7 @@ func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
 		return
 	}
 
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 验证支付方式
 	validPaymentMethods := map[string]models.PaymentMethod{
@@ -84,13 +89,14 @@ func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
 
 	// 创建充值订单
 	order := &models.RechargeOrder{
-		UserID:        uid,
-		Amount:        req.Amount,
-		Points:        req.Points,
-		PaymentMethod: paymentMethod,
-		Status:        models.OrderStatusPending,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+		UserID:         uid,
+		OrganizationID: principal.Workspace.OrganizationID,
+		Amount:         req.Amount,
+		Points:         req.Points,
+		PaymentMethod:  paymentMethod,
+		Status:         models.OrderStatusPending,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
 	}
 
 	// 生成订单号
@@ -106,6 +112,14 @@ func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
 		return
 	}
 
+	event := newAuditEvent(c, models.AuditActionRechargeCreate, "recharge_order", order.OrderNo)
+	event.After = models.AuditSnapshot(gin.H{
+		"amount":        order.Amount,
+		"points":        order.Points,
+		"paymentMethod": order.PaymentMethod,
+	})
+	recordAudit(h.DB, h.Logger, event)
+
 	h.Logger.Info("充值订单创建成功",
 		zap.String("orderNo", order.OrderNo),
 		zap.String("userId", uid),
@@ -113,6 +127,23 @@ func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
 		zap.Int("points", req.Points),
 	)
 
+	// 通过支付提供方发起支付
+	paymentResult, err := h.Payments.CreatePayment(c.Request.Context(), payment.PaymentRequest{
+		OrderNo: order.OrderNo,
+		Amount:  order.Amount,
+		Method:  string(order.PaymentMethod),
+		Subject: "积分充值",
+		UserID:  uid,
+	})
+	if err != nil {
+		h.Logger.Error("发起支付失败", zap.Error(err), zap.String("orderNo", order.OrderNo))
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  "error",
+			"message": "发起支付失败",
+		})
+		return
+	}
+
 	// 返回订单信息
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
@@ -124,8 +155,7 @@ func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
 			"paymentMethod": order.PaymentMethod,
 			"status":        order.Status,
 			"createdAt":     order.CreatedAt,
-			// 在实际环境中，这里应该返回支付链接或支付参数
-			"paymentUrl": generatePaymentUrl(order),
+			"paymentUrl":    paymentResult.PaymentURL,
 		},
 	})
 }
@@ -133,12 +163,8 @@ func (h *GinRechargeHandler) CreateRecharge(c *gin.Context) {
 // GetRechargeHistory 获取充值历史
 func (h *GinRechargeHandler) GetRechargeHistory(c *gin.Context) {
 	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
@@ -161,10 +187,8 @@ func (h *GinRechargeHandler) GetRechargeHistory(c *gin.Context) {
 		}
 	}
 
-	uid := userID.(string)
-
 	// 构建查询
-	query := h.DB.Model(&models.RechargeOrder{}).Where("user_id = ?", uid)
+	query := h.DB.Model(&models.RechargeOrder{}).Scopes(principal.Workspace.Scope("recharge_orders"))
 
 	// 获取总记录数
 	var total int64
@@ -200,12 +224,8 @@ func (h *GinRechargeHandler) GetRechargeHistory(c *gin.Context) {
 // GetRechargeStatus 获取充值状态
 func (h *GinRechargeHandler) GetRechargeStatus(c *gin.Context) {
 	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
 
@@ -220,11 +240,9 @@ func (h *GinRechargeHandler) GetRechargeStatus(c *gin.Context) {
 		return
 	}
 
-	uid := userID.(string)
-
 	// 查询订单 - 验证所有权
 	var order models.RechargeOrder
-	result := h.DB.Where("id = ? AND user_id = ?", id, uid).First(&order)
+	result := h.DB.Scopes(principal.Workspace.Scope("recharge_orders")).Where("id = ?", id).First(&order)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -248,7 +266,114 @@ func (h *GinRechargeHandler) GetRechargeStatus(c *gin.Context) {
 	})
 }
 
+// GetRechargeInvoice 下载充值订单发票（format=pdf|html，默认pdf）
+func (h *GinRechargeHandler) GetRechargeInvoice(c *gin.Context) {
+	// 获取用户ID
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	// 获取订单ID
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的订单ID",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "pdf")
+	if format != "pdf" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "不支持的发票格式",
+		})
+		return
+	}
+
+	// 查询订单 - 验证所有权
+	var order models.RechargeOrder
+	if err := h.DB.Scopes(principal.Workspace.Scope("recharge_orders")).Where("id = ?", id).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "订单不存在",
+			})
+		} else {
+			h.Logger.Error("获取订单失败", zap.Error(err), zap.Int64("id", id))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取订单失败",
+			})
+		}
+		return
+	}
+
+	// 获取或开具发票
+	inv, err := models.GetOrCreateInvoice(h.DB, &order, models.InvoiceOptions{
+		Prefix:  h.Invoice.Prefix,
+		TaxRate: h.Invoice.TaxRate,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrInvoiceOrderNotCompleted) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			return
+		}
+		h.Logger.Error("开具发票失败", zap.Error(err), zap.Int64("orderId", order.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "开具发票失败",
+		})
+		return
+	}
+
+	doc, err := invoice.NewDocument(inv, order.OrderNo, h.Invoice)
+	if err != nil {
+		h.Logger.Error("生成发票文档失败", zap.Error(err), zap.Int64("invoiceId", inv.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "生成发票失败",
+		})
+		return
+	}
+
+	// 渲染发票
+	var buf bytes.Buffer
+	contentType := "application/pdf"
+	if format == "html" {
+		contentType = "text/html; charset=utf-8"
+		err = doc.RenderHTML(&buf)
+	} else {
+		err = doc.RenderPDF(&buf)
+	}
+	if err != nil {
+		h.Logger.Error("渲染发票失败", zap.Error(err), zap.Int64("invoiceId", inv.ID), zap.String("format", format))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "生成发票失败",
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", "attachment; filename="+doc.Filename(format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// PaymentCallbackRequest 支付网关回调通知
+type PaymentCallbackRequest struct {
+	PaymentID string `json:"paymentId" binding:"required"` // 第三方支付流水号
+	Amount    int    `json:"amount" binding:"required"`    // 实际支付金额（分）
+	Signature string `json:"signature" binding:"required"` // 支付网关签名
+}
+
 // ProcessPaymentCallback 处理支付回调（用于支付网关回调）
+//
+// 回调接口不需要登录，只有通过支付提供方签名校验、且支付金额与订单一致的通知才会完成订单。
 func (h *GinRechargeHandler) ProcessPaymentCallback(c *gin.Context) {
 	// 获取订单号
 	orderNo := c.Param("orderNo")
@@ -260,6 +385,30 @@ func (h *GinRechargeHandler) ProcessPaymentCallback(c *gin.Context) {
 		return
 	}
 
+	var req PaymentCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求参数错误：" + err.Error(),
+		})
+		return
+	}
+
+	// 校验回调签名，防止伪造的通知完成订单
+	if err := h.Payments.VerifyCallback(c.Request.Context(), payment.CallbackRequest{
+		OrderNo:   orderNo,
+		PaymentID: req.PaymentID,
+		Amount:    req.Amount,
+		Signature: req.Signature,
+	}); err != nil {
+		h.Logger.Warn("支付回调签名校验失败", zap.Error(err), zap.String("orderNo", orderNo), zap.String("ip", c.ClientIP()))
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"status":  "error",
+			"message": "支付回调签名无效",
+		})
+		return
+	}
+
 	// 查询订单
 	var order models.RechargeOrder
 	result := h.DB.Where("order_no = ?", orderNo).First(&order)
@@ -272,79 +421,101 @@ func (h *GinRechargeHandler) ProcessPaymentCallback(c *gin.Context) {
 		return
 	}
 
-	// 验证支付状态（这里应该调用支付网关API验证）
-	// 模拟支付成功
-	if order.Status == models.OrderStatusPending {
-		// 开始事务
-		tx := h.DB.Begin()
-
-		// 更新订单状态
-		order.Status = models.OrderStatusCompleted
-		order.PaymentID = "MOCK_PAYMENT_" + strconv.FormatInt(time.Now().Unix(), 10)
-		order.UpdatedAt = time.Now()
-
-		if err := tx.Save(&order).Error; err != nil {
-			tx.Rollback()
-			h.Logger.Error("更新订单状态失败", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "更新订单状态失败",
-			})
-			return
-		}
+	if req.Amount != order.Amount {
+		h.Logger.Warn("支付回调金额与订单不一致",
+			zap.String("orderNo", orderNo),
+			zap.Int("amount", req.Amount),
+			zap.Int("orderAmount", order.Amount),
+		)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "支付金额与订单不一致",
+		})
+		return
+	}
 
-		// 更新用户积分
-		var user models.User
-		if err := tx.Where("user_id = ?", order.UserID).First(&user).Error; err != nil {
-			tx.Rollback()
-			h.Logger.Error("查询用户失败", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "查询用户失败",
+	paid := false
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 在事务内把订单从待支付改为已完成，只有改成功的回调继续到账，重复或并发的回调不会重复增加积分和订阅周期
+		paymentID := req.PaymentID
+		result := tx.Model(&models.RechargeOrder{}).
+			Where("id = ? AND status = ?", order.ID, models.OrderStatusPending).
+			Updates(map[string]interface{}{
+				"status":     models.OrderStatusCompleted,
+				"payment_id": paymentID,
+				"updated_at": time.Now(),
 			})
-			return
+		if result.Error != nil {
+			return fmt.Errorf("更新订单状态失败: %w", result.Error)
 		}
-
-		user.Points += order.Points
-		if err := tx.Save(&user).Error; err != nil {
-			tx.Rollback()
-			h.Logger.Error("更新用户积分失败", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "更新用户积分失败",
-			})
-			return
+		if result.RowsAffected != 1 {
+			return nil
 		}
+		paid = true
+		order.Status = models.OrderStatusCompleted
+		order.PaymentID = paymentID
 
-		// 创建积分交易记录
-		transaction := &models.PointsTransaction{
-			UserID:      order.UserID,
+		// 充值到订单所属的工作空间钱包
+		change := models.WorkspacePointsChange{
 			Type:        models.TransactionTypeRecharge,
 			Amount:      order.Points,
-			Balance:     user.Points, // 交易后的余额
 			Description: "充值获得积分",
 			RelatedID:   &order.ID,
-			CreatedAt:   time.Now(),
+		}
+		if order.SubscriptionID != nil {
+			change.Type = models.TransactionTypeSubscription
+			change.Description = "订阅套餐每月赠送积分"
 		}
 
-		if err := tx.Create(transaction).Error; err != nil {
-			tx.Rollback()
-			h.Logger.Error("创建积分交易记录失败", zap.Error(err))
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"status":  "error",
-				"message": "创建积分交易记录失败",
-			})
-			return
+		ws := models.Workspace{UserID: order.UserID, OrganizationID: order.OrganizationID}
+		if _, err := models.ChangeWorkspacePoints(tx, ws, change); err != nil {
+			return fmt.Errorf("更新积分失败: %w", err)
 		}
 
-		// 提交事务
-		tx.Commit()
+		// 订阅订单：开启新的计费周期
+		if order.SubscriptionID != nil {
+			var subscription models.UserSubscription
+			if err := tx.First(&subscription, *order.SubscriptionID).Error; err != nil {
+				return fmt.Errorf("查询订阅失败: %w", err)
+			}
+			if err := subscription.ActivatePeriod(tx, time.Now()); err != nil {
+				return fmt.Errorf("更新订阅周期失败: %w", err)
+			}
+		}
+
+		// 支付回调没有登录用户，操作人记为订单所属用户
+		event := newAuditEvent(c, models.AuditActionRecharge, "recharge_order", order.OrderNo)
+		event.ActorID = order.UserID
+		event.OrganizationID = order.OrganizationID
+		event.After = models.AuditSnapshot(gin.H{
+			"amount":    order.Amount,
+			"points":    order.Points,
+			"paymentId": order.PaymentID,
+		})
+		event.Description = change.Description
+		if err := models.CreateAuditEvent(tx, event); err != nil {
+			return fmt.Errorf("记录审计事件失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		h.Logger.Error("处理支付回调失败", zap.Error(err), zap.String("orderNo", orderNo))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "处理支付回调失败",
+		})
+		return
+	}
 
+	if paid {
 		h.Logger.Info("充值支付成功",
 			zap.String("orderNo", orderNo),
 			zap.String("userId", order.UserID),
 			zap.Int("points", order.Points),
 		)
+	} else if err := h.DB.First(&order, order.ID).Error; err != nil {
+		// 订单已被其他回调处理，返回最新状态
+		h.Logger.Error("查询订单失败", zap.Error(err), zap.String("orderNo", orderNo))
 	}
 
 	// 返回成功响应
@@ -353,9 +524,3 @@ func (h *GinRechargeHandler) ProcessPaymentCallback(c *gin.Context) {
 		"data":   order,
 	})
 }
-
-// generatePaymentUrl 生成支付链接（模拟）
-func generatePaymentUrl(order *models.RechargeOrder) string {
-	// 在实际环境中，这里应该调用支付网关API生成支付链接
-	return "https://pay.example.com/pay?orderNo=" + order.OrderNo
-}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/payment"
+	"go.uber.org/zap"
+)
+
+func callbackBody(provider *payment.MockProvider, orderNo, paymentID string, amount int) string {
+	signature := provider.Sign(payment.CallbackRequest{OrderNo: orderNo, PaymentID: paymentID, Amount: amount})
+	return fmt.Sprintf(`{"paymentId":%q,"amount":%d,"signature":%q}`, paymentID, amount, signature)
+}
+
+func TestProcessPaymentCallbackVerifiesSignature(t *testing.T) {
+	provider := payment.NewMockProvider("", "callback-secret")
+	forger := payment.NewMockProvider("", "guessed-secret")
+
+	tests := []struct {
+		name       string
+		body       func(orderNo string) string
+		wantStatus int
+		wantPaid   bool
+	}{
+		{"签名有效", func(orderNo string) string { return callbackBody(provider, orderNo, "PAY_1", 1000) }, http.StatusOK, true},
+		{"缺少签名", func(orderNo string) string { return `{"paymentId":"PAY_1","amount":1000}` }, http.StatusBadRequest, false},
+		{"密钥错误", func(orderNo string) string { return callbackBody(forger, orderNo, "PAY_1", 1000) }, http.StatusUnauthorized, false},
+		{"签名与金额不符", func(orderNo string) string {
+			signature := provider.Sign(payment.CallbackRequest{OrderNo: orderNo, PaymentID: "PAY_1", Amount: 1})
+			return fmt.Sprintf(`{"paymentId":"PAY_1","amount":1000,"signature":%q}`, signature)
+		}, http.StatusUnauthorized, false},
+		{"金额与订单不一致", func(orderNo string) string { return callbackBody(provider, orderNo, "PAY_1", 1) }, http.StatusBadRequest, false},
+		{"签名属于其他订单", func(orderNo string) string { return callbackBody(provider, "RC_OTHER", "PAY_1", 1000) }, http.StatusUnauthorized, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			h := NewGinRechargeHandler(db, zap.NewNop(), provider, config.InvoiceConfig{})
+			user := createTestUser(t, db, "payer", 0)
+			order := models.RechargeOrder{
+				UserID:        user.UserID,
+				OrderNo:       "RC_TEST",
+				Amount:        1000,
+				Points:        1000,
+				PaymentMethod: models.PaymentMethodAlipay,
+				Status:        models.OrderStatusPending,
+			}
+			if err := db.Create(&order).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			w := serveRoute(h.ProcessPaymentCallback, nil, http.MethodPost, "/payment/callback/:orderNo", "/payment/callback/"+order.OrderNo, tt.body(order.OrderNo))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("应返回%d，实际为%d: %s", tt.wantStatus, w.Code, w.Body.String())
+			}
+
+			db.First(&order, order.ID)
+			var reloaded models.User
+			db.First(&reloaded, user.ID)
+			if tt.wantPaid {
+				if order.Status != models.OrderStatusCompleted || order.PaymentID != "PAY_1" || reloaded.Points != 1000 {
+					t.Errorf("订单应完成并到账，实际为status=%s payment_id=%s points=%d", order.Status, order.PaymentID, reloaded.Points)
+				}
+			} else if order.Status != models.OrderStatusPending || reloaded.Points != 0 {
+				t.Errorf("订单应保持待支付且不到账，实际为status=%s points=%d", order.Status, reloaded.Points)
+			}
+		})
+	}
+}
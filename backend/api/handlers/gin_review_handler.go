@@ -0,0 +1,527 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinReviewHandler 处理代理评分和评价相关的请求
+type GinReviewHandler struct {
+	DB     *gorm.DB
+	Logger *zap.Logger
+}
+
+// NewGinReviewHandler 创建新的评价处理程序
+func NewGinReviewHandler(db *gorm.DB, logger *zap.Logger) *GinReviewHandler {
+	return &GinReviewHandler{
+		DB:     db,
+		Logger: logger,
+	}
+}
+
+// reviewErrorStatus 评价业务错误对应的HTTP状态码，非业务错误返回0
+func reviewErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrReviewNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrReviewNotBuyer),
+		errors.Is(err, models.ErrReviewOwnVote):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrReviewExists),
+		errors.Is(err, models.ErrReviewVoteExists),
+		errors.Is(err, models.ErrReviewFlagExists):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrReviewRatingInvalid):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondReviewError 输出评价相关错误，非业务错误记录日志并返回500
+func (h *GinReviewHandler) respondReviewError(c *gin.Context, err error, message string) {
+	if status := reviewErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// parseIDParam 解析路径中的ID参数，失败时已写入400响应
+func parseIDParam(c *gin.Context, name, message string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": message,
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// loadReview 获取路径中的评价，visibleOnly为true时隐藏的评价视为不存在，失败时已写入响应
+func (h *GinReviewHandler) loadReview(c *gin.Context, visibleOnly bool) (*models.AgentReview, bool) {
+	id, ok := parseIDParam(c, "id", "无效的评价ID")
+	if !ok {
+		return nil, false
+	}
+
+	review, err := models.GetAgentReview(h.DB, id)
+	if err == nil && visibleOnly && review.Status != models.ReviewStatusVisible {
+		err = models.ErrReviewNotFound
+	}
+	if err != nil {
+		h.respondReviewError(c, err, "获取评价失败")
+		return nil, false
+	}
+	return review, true
+}
+
+// requireReviewAuthor 校验当前用户是评价作者，失败时已写入响应
+func requireReviewAuthor(c *gin.Context, principal *middleware.Principal, review *models.AgentReview) bool {
+	if review.UserID != principal.UserID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "只能修改自己的评价",
+		})
+		return false
+	}
+	return true
+}
+
+// GetAgentReviews 获取公开代理的评价列表及评分概况
+func (h *GinReviewHandler) GetAgentReviews(c *gin.Context) {
+	agentID, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	var agent models.Agent
+	if err := h.DB.Where("is_public = ?", true).First(&agent, agentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+		} else {
+			h.Logger.Error("获取代理失败", zap.Error(err), zap.Int64("id", agentID))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取代理失败",
+			})
+		}
+		return
+	}
+
+	// 获取分页参数
+	limit := 20
+	offset := 0
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+			if limit > 100 {
+				limit = 100
+			}
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	reviews, total, err := models.ListAgentReviews(h.DB, agentID, c.DefaultQuery("sort", models.ReviewSortNewest), limit, offset)
+	if err != nil {
+		h.respondReviewError(c, err, "获取评价列表失败")
+		return
+	}
+	distribution, err := models.GetAgentRatingDistribution(h.DB, agentID)
+	if err != nil {
+		h.respondReviewError(c, err, "获取评价列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"summary": gin.H{
+				"rating":       agent.Rating,
+				"reviewCount":  agent.ReviewCount,
+				"distribution": distribution,
+			},
+			"reviews": reviews,
+			"pagination": gin.H{
+				"total":     total,
+				"page":      offset/limit + 1,
+				"page_size": limit,
+				"pages":     (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// ReviewRequest 发表或修改评价请求结构
+type ReviewRequest struct {
+	Rating  int    `json:"rating" binding:"required"`
+	Content string `json:"content" binding:"max=2000"`
+}
+
+// CreateAgentReview 发表评价，只有购买过此代理的用户可以评价
+func (h *GinReviewHandler) CreateAgentReview(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	agentID, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return
+	}
+
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	review := models.AgentReview{
+		AgentID: agentID,
+		UserID:  principal.UserID,
+		Rating:  req.Rating,
+		Content: req.Content,
+	}
+	if err := models.CreateAgentReview(h.DB, &review); err != nil {
+		h.respondReviewError(c, err, "发表评价失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   review,
+	})
+}
+
+// UpdateReview 修改自己的评价
+func (h *GinReviewHandler) UpdateReview(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, false)
+	if !ok || !requireReviewAuthor(c, principal, review) {
+		return
+	}
+
+	var req ReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := review.Update(h.DB, req.Rating, req.Content); err != nil {
+		h.respondReviewError(c, err, "修改评价失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   review,
+	})
+}
+
+// DeleteReview 删除自己的评价
+func (h *GinReviewHandler) DeleteReview(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, false)
+	if !ok || !requireReviewAuthor(c, principal, review) {
+		return
+	}
+
+	if err := review.Delete(h.DB); err != nil {
+		h.respondReviewError(c, err, "删除评价失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "评价已删除",
+	})
+}
+
+// ReplyRequest 创作者回复请求结构
+type ReplyRequest struct {
+	Content string `json:"content" binding:"required,max=2000"`
+}
+
+// canReplyReview 代理的创作者和有代理管理权限的用户可以回复评价
+func (h *GinReviewHandler) canReplyReview(principal *middleware.Principal, review *models.AgentReview) bool {
+	if models.HasPermission(principal.Role, models.PermissionAgentManage) {
+		return true
+	}
+
+	var agent models.Agent
+	if err := h.DB.Select("id", "creator_id").First(&agent, review.AgentID).Error; err != nil {
+		if err != gorm.ErrRecordNotFound {
+			h.Logger.Error("获取代理失败", zap.Error(err), zap.Int64("id", review.AgentID))
+		}
+		return false
+	}
+	return agent.CreatorID != "" && agent.CreatorID == principal.UserID
+}
+
+// setReviewReply 设置或清除回复，content为空时清除
+func (h *GinReviewHandler) setReviewReply(c *gin.Context, content string) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, true)
+	if !ok {
+		return
+	}
+	if !h.canReplyReview(principal, review) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"message": "只有代理的创作者可以回复评价",
+		})
+		return
+	}
+
+	if err := review.SetReply(h.DB, principal.UserID, content); err != nil {
+		h.respondReviewError(c, err, "回复评价失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   review,
+	})
+}
+
+// ReplyReview 创作者回复评价，重复回复会覆盖原回复
+func (h *GinReviewHandler) ReplyReview(c *gin.Context) {
+	var req ReplyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+	h.setReviewReply(c, req.Content)
+}
+
+// DeleteReviewReply 删除创作者回复
+func (h *GinReviewHandler) DeleteReviewReply(c *gin.Context) {
+	h.setReviewReply(c, "")
+}
+
+// AddHelpfulVote 标记评价有帮助
+func (h *GinReviewHandler) AddHelpfulVote(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, true)
+	if !ok {
+		return
+	}
+
+	if err := review.AddHelpfulVote(h.DB, principal.UserID); err != nil {
+		h.respondReviewError(c, err, "投票失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "已标记为有帮助",
+	})
+}
+
+// RemoveHelpfulVote 取消有帮助标记
+func (h *GinReviewHandler) RemoveHelpfulVote(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, true)
+	if !ok {
+		return
+	}
+
+	if err := review.RemoveHelpfulVote(h.DB, principal.UserID); err != nil {
+		h.respondReviewError(c, err, "取消投票失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "已取消标记",
+	})
+}
+
+// FlagReviewRequest 举报评价请求结构
+type FlagReviewRequest struct {
+	Reason string `json:"reason" binding:"required,max=255"`
+}
+
+// FlagReview 举报评价，由管理员审核
+func (h *GinReviewHandler) FlagReview(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, true)
+	if !ok {
+		return
+	}
+
+	var req FlagReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	if _, err := review.Flag(h.DB, principal.UserID, req.Reason); err != nil {
+		h.respondReviewError(c, err, "举报评价失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "举报已提交，我们会尽快处理",
+	})
+}
+
+// GetFlaggedReviews 获取有待处理举报的评价（管理员）
+func (h *GinReviewHandler) GetFlaggedReviews(c *gin.Context) {
+	// 获取分页参数
+	limit := 20
+	offset := 0
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+			if limit > 100 {
+				limit = 100
+			}
+		}
+	}
+
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	reviews, total, err := models.ListFlaggedReviews(h.DB, limit, offset)
+	if err != nil {
+		h.respondReviewError(c, err, "获取被举报评价失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"reviews": reviews,
+			"pagination": gin.H{
+				"total":     total,
+				"page":      offset/limit + 1,
+				"page_size": limit,
+				"pages":     (total + int64(limit) - 1) / int64(limit),
+			},
+		},
+	})
+}
+
+// GetReviewFlags 获取评价的举报记录（管理员）
+func (h *GinReviewHandler) GetReviewFlags(c *gin.Context) {
+	review, ok := h.loadReview(c, false)
+	if !ok {
+		return
+	}
+
+	flags, err := models.GetReviewFlags(h.DB, review.ID)
+	if err != nil {
+		h.respondReviewError(c, err, "获取举报记录失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"review": review,
+			"flags":  flags,
+		},
+	})
+}
+
+// ModerateReviewRequest 审核评价请求结构
+type ModerateReviewRequest struct {
+	Action string `json:"action" binding:"required,oneof=hide restore"` // hide隐藏评价，restore恢复展示并驳回举报
+}
+
+// ModerateReview 隐藏或恢复评价（管理员）
+func (h *GinReviewHandler) ModerateReview(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	review, ok := h.loadReview(c, false)
+	if !ok {
+		return
+	}
+
+	var req ModerateReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求数据格式错误: " + err.Error(),
+		})
+		return
+	}
+
+	if err := review.Moderate(h.DB, principal.UserID, req.Action == "hide"); err != nil {
+		h.respondReviewError(c, err, "审核评价失败")
+		return
+	}
+
+	h.Logger.Info("评价已审核",
+		zap.Int64("reviewId", review.ID),
+		zap.String("action", req.Action),
+		zap.String("operator", principal.UserID),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   review,
+	})
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
+	"go.uber.org/zap"
+)
+
+const agentReviewsPattern = "/agents/:id/reviews"
+
+func reviewBody() string {
+	return `{"rating":5,"content":"很好用"}`
+}
+
+func TestCreateAgentReviewRequiresPurchase(t *testing.T) {
+	db := newTestDB(t)
+	purchases := NewGinPurchaseHandler(db, zap.NewNop(), config.MarketplaceConfig{})
+	workflows := NewGinWorkflowHandler(db, zap.NewNop(), quota.New(db, config.QuotaConfig{}))
+	reviews := NewGinReviewHandler(db, zap.NewNop())
+	buyer := createTestUser(t, db, "buyer", 100)
+	forger := createTestUser(t, db, "forger", 100)
+	agent := createTestAgent(t, db, 30)
+	target := fmt.Sprintf("/agents/%d/reviews", agent.ID)
+
+	// 客户端不能直接创建关联代理的工作流来伪造购买记录
+	body := fmt.Sprintf(`{"name":"伪造","definition":{"steps":[]},"agentId":%d}`, agent.ID)
+	if w := serveAs(workflows.CreateWorkflow, jwtPrincipal(forger), http.MethodPost, "/workflows", body); w.Code != http.StatusBadRequest {
+		t.Fatalf("指定agentId创建工作流应返回400，实际为%d: %s", w.Code, w.Body.String())
+	}
+	var forged int64
+	db.Model(&models.Workflow{}).Where("agent_id = ?", agent.ID).Count(&forged)
+	if forged != 0 {
+		t.Fatalf("不应创建关联代理的工作流，实际创建了%d个", forged)
+	}
+
+	w := serveRoute(reviews.CreateAgentReview, jwtPrincipal(forger), http.MethodPost, agentReviewsPattern, target, reviewBody())
+	if w.Code != http.StatusForbidden {
+		t.Errorf("未购买的用户评价应返回403，实际为%d: %s", w.Code, w.Body.String())
+	}
+
+	if w := serveAs(purchases.PurchaseAgent, jwtPrincipal(buyer), http.MethodPost, "/purchase/agent", purchaseBody(agent.ID)); w.Code != http.StatusOK {
+		t.Fatalf("购买应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+	w = serveRoute(reviews.CreateAgentReview, jwtPrincipal(buyer), http.MethodPost, agentReviewsPattern, target, reviewBody())
+	if w.Code != http.StatusCreated {
+		t.Errorf("购买者评价应成功，实际为%d: %s", w.Code, w.Body.String())
+	}
+}
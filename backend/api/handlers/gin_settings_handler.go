@@ -5,6 +5,7 @@ import (
 	"strconv"
 
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -14,13 +15,15 @@ import (
 type GinSettingsHandler struct {
 	DB     *gorm.DB
 	Logger *zap.Logger
+	Quotas *quota.Enforcer
 }
 
 // NewGinSettingsHandler 创建新的设置处理程序
-func NewGinSettingsHandler(db *gorm.DB, logger *zap.Logger) *GinSettingsHandler {
+func NewGinSettingsHandler(db *gorm.DB, logger *zap.Logger, quotas *quota.Enforcer) *GinSettingsHandler {
 	return &GinSettingsHandler{
 		DB:     db,
 		Logger: logger,
+		Quotas: quotas,
 	}
 }
 
@@ -251,6 +254,11 @@ func (h *GinSettingsHandler) UpdateSettings(c *gin.Context) {
 	// 目前只是返回成功响应
 	h.Logger.Info("用户设置已更新", zap.Any("settings", settings))
 
+	event := newAuditEvent(c, models.AuditActionSettingsChange, "settings", "")
+	event.TargetID = event.ActorID
+	event.After = models.AuditSnapshot(settings)
+	recordAudit(h.DB, h.Logger, event)
+
 	c.JSON(http.StatusOK, gin.H{
 		"status": "success",
 		"data":   settings,
@@ -260,15 +268,11 @@ func (h *GinSettingsHandler) UpdateSettings(c *gin.Context) {
 // GetUserProfile 获取用户资料
 func (h *GinSettingsHandler) GetUserProfile(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 根据UserID获取用户信息
 	var user models.User
@@ -313,15 +317,11 @@ func (h *GinSettingsHandler) GetUserProfile(c *gin.Context) {
 // UpdateUserProfile 更新用户资料
 func (h *GinSettingsHandler) UpdateUserProfile(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 解析请求数据
 	var input models.UserUpdateInput
@@ -394,15 +394,11 @@ func (h *GinSettingsHandler) UpdateUserProfile(c *gin.Context) {
 // UploadAvatar 上传头像
 func (h *GinSettingsHandler) UploadAvatar(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 获取上传的文件
 	file, err := c.FormFile("avatar")
@@ -414,12 +410,9 @@ func (h *GinSettingsHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// 检查文件大小（最大2MB）
-	if file.Size > 2*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "文件大小不能超过2MB",
-		})
+	// 检查文件大小配额，头像属于个人资料，按个人空间的套餐计算
+	if err := h.Quotas.CheckUpload(models.PersonalWorkspace(uid), file.Size); err != nil {
+		respondQuotaError(c, h.Logger, err)
 		return
 	}
 
@@ -480,15 +473,11 @@ func (h *GinSettingsHandler) UploadAvatar(c *gin.Context) {
 // ChangePassword 修改密码
 func (h *GinSettingsHandler) ChangePassword(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 解析请求数据
 	var input models.PasswordChangeInput
@@ -44,19 +44,15 @@ type DailyExecutionStat struct {
 // GetDashboardStats 获取仪表盘统计数据
 func (h *GinStatsHandler) GetDashboardStats(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	ws := principal.Workspace
 
 	// 获取工作流总数
 	var totalWorkflows int64
-	if err := h.DB.Model(&models.Workflow{}).Where("user_id = ?", uid).Count(&totalWorkflows).Error; err != nil {
+	if err := h.DB.Model(&models.Workflow{}).Scopes(ws.Scope("workflows")).Count(&totalWorkflows).Error; err != nil {
 		h.Logger.Error("获取工作流总数失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
@@ -69,7 +65,7 @@ func (h *GinStatsHandler) GetDashboardStats(c *gin.Context) {
 	var totalExecutions int64
 	if err := h.DB.Model(&models.WorkflowExecution{}).
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ?", uid).
+		Scopes(ws.Scope("workflows")).
 		Count(&totalExecutions).Error; err != nil {
 		h.Logger.Error("获取执行总数失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -83,7 +79,7 @@ func (h *GinStatsHandler) GetDashboardStats(c *gin.Context) {
 	var succeededExecutions int64
 	if err := h.DB.Model(&models.WorkflowExecution{}).
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ? AND workflow_executions.status = ?", uid, "succeeded").
+		Scopes(ws.Scope("workflows")).Where("workflow_executions.status = ?", models.ExecutionStatusSuccess).
 		Count(&succeededExecutions).Error; err != nil {
 		h.Logger.Error("获取成功执行数量失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -104,7 +100,7 @@ func (h *GinStatsHandler) GetDashboardStats(c *gin.Context) {
 	if err := h.DB.
 		Preload("Workflow").
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ?", uid).
+		Scopes(ws.Scope("workflows")).
 		Order("workflow_executions.created_at DESC").
 		Limit(5).
 		Find(&recentExecutions).Error; err != nil {
@@ -143,9 +139,9 @@ func (h *GinStatsHandler) GetDashboardStats(c *gin.Context) {
 
 	// 使用GORM查询每日总数和成功数
 	if err := h.DB.Model(&models.WorkflowExecution{}).
-		Select("DATE(workflow_executions.created_at) as date, COUNT(*) as count, SUM(CASE WHEN workflow_executions.status = 'succeeded' THEN 1 ELSE 0 END) as succeeded").
+		Select("DATE(workflow_executions.created_at) as date, COUNT(*) as count, SUM(CASE WHEN workflow_executions.status = 'success' THEN 1 ELSE 0 END) as succeeded").
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ? AND workflow_executions.created_at BETWEEN ? AND ?", uid, startDate, endDate.AddDate(0, 0, 1)).
+		Scopes(ws.Scope("workflows")).Where("workflow_executions.created_at BETWEEN ? AND ?", startDate, endDate.AddDate(0, 0, 1)).
 		Group("DATE(workflow_executions.created_at)").
 		Scan(&dailyResults).Error; err != nil {
 		h.Logger.Error("获取每日执行统计失败", zap.Error(err))
@@ -204,15 +200,11 @@ type WorkflowStats struct {
 // GetWorkflowStats 获取工作流统计数据
 func (h *GinStatsHandler) GetWorkflowStats(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	ws := principal.Workspace
 
 	// 查询工作流统计数据
 	var stats []WorkflowStats
@@ -223,17 +215,17 @@ func (h *GinStatsHandler) GetWorkflowStats(c *gin.Context) {
 			workflows.id as workflow_id,
 			workflows.name as workflow_name,
 			COUNT(workflow_executions.id) as total_runs,
-			SUM(CASE WHEN workflow_executions.status = 'succeeded' THEN 1 ELSE 0 END) as success_runs,
+			SUM(CASE WHEN workflow_executions.status = 'success' THEN 1 ELSE 0 END) as success_runs,
 			SUM(CASE WHEN workflow_executions.status = 'failed' THEN 1 ELSE 0 END) as failure_runs,
 			CASE WHEN COUNT(workflow_executions.id) > 0 THEN 
-				(SUM(CASE WHEN workflow_executions.status = 'succeeded' THEN 1 ELSE 0 END) * 100.0 / COUNT(workflow_executions.id)) 
+				(SUM(CASE WHEN workflow_executions.status = 'success' THEN 1 ELSE 0 END) * 100.0 / COUNT(workflow_executions.id)) 
 			ELSE 0 END as success_rate,
 			COALESCE(AVG(CASE WHEN workflow_executions.completed_at IS NOT NULL AND workflow_executions.started_at IS NOT NULL THEN 
 				TIMESTAMPDIFF(MICROSECOND, workflow_executions.started_at, workflow_executions.completed_at) / 1000
 			ELSE NULL END), 0) as avg_duration_ms
 		`).
-		Joins("LEFT JOIN workflow_executions ON workflows.id = workflow_executions.workflow_id").
-		Where("workflows.user_id = ?", uid).
+		Joins("LEFT JOIN workflow_executions ON workflows.id = workflow_executions.workflow_id AND workflow_executions.deleted_at IS NULL").
+		Scopes(ws.Scope("workflows")).
 		Group("workflows.id, workflows.name").
 		Order("total_runs DESC").
 		Scan(&stats).Error; err != nil {
@@ -272,15 +264,11 @@ type TimelineExecutionStat struct {
 // GetExecutionStats 获取执行统计数据
 func (h *GinStatsHandler) GetExecutionStats(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	ws := principal.Workspace
 
 	// 获取时间范围参数
 	startDateStr := c.DefaultQuery("start_date", time.Now().AddDate(0, 0, -30).Format("2006-01-02"))
@@ -311,7 +299,7 @@ func (h *GinStatsHandler) GetExecutionStats(c *gin.Context) {
 	var totalExecutions int64
 	if err := h.DB.Model(&models.WorkflowExecution{}).
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ? AND workflow_executions.created_at BETWEEN ? AND ?", uid, startDate, endDate).
+		Scopes(ws.Scope("workflows")).Where("workflow_executions.created_at BETWEEN ? AND ?", startDate, endDate).
 		Count(&totalExecutions).Error; err != nil {
 		h.Logger.Error("获取执行总数失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -330,7 +318,7 @@ func (h *GinStatsHandler) GetExecutionStats(c *gin.Context) {
 	if err := h.DB.Model(&models.WorkflowExecution{}).
 		Select("workflow_executions.status, COUNT(*) as count").
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ? AND workflow_executions.created_at BETWEEN ? AND ?", uid, startDate, endDate).
+		Scopes(ws.Scope("workflows")).Where("workflow_executions.created_at BETWEEN ? AND ?", startDate, endDate).
 		Group("workflow_executions.status").
 		Scan(&statusCounts).Error; err != nil {
 		h.Logger.Error("获取状态计数失败", zap.Error(err))
@@ -346,7 +334,7 @@ func (h *GinStatsHandler) GetExecutionStats(c *gin.Context) {
 	var succeededCount int64
 	for _, sc := range statusCounts {
 		statusCountMap[sc.Status] = sc.Count
-		if sc.Status == "succeeded" {
+		if sc.Status == string(models.ExecutionStatusSuccess) {
 			succeededCount = sc.Count
 		}
 	}
@@ -362,7 +350,7 @@ func (h *GinStatsHandler) GetExecutionStats(c *gin.Context) {
 	if err := h.DB.Model(&models.WorkflowExecution{}).
 		Select("COALESCE(AVG(TIMESTAMPDIFF(MICROSECOND, workflow_executions.started_at, workflow_executions.completed_at) / 1000), 0) as avg_duration").
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ? AND workflow_executions.created_at BETWEEN ? AND ? AND workflow_executions.completed_at IS NOT NULL AND workflow_executions.started_at IS NOT NULL", uid, startDate, endDate).
+		Scopes(ws.Scope("workflows")).Where("workflow_executions.created_at BETWEEN ? AND ? AND workflow_executions.completed_at IS NOT NULL AND workflow_executions.started_at IS NOT NULL", startDate, endDate).
 		Scan(&avgDuration).Error; err != nil {
 		h.Logger.Error("获取平均执行时间失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -380,11 +368,11 @@ func (h *GinStatsHandler) GetExecutionStats(c *gin.Context) {
 		Select(`
 			DATE(workflow_executions.created_at) as date,
 			COUNT(*) as count,
-			SUM(CASE WHEN workflow_executions.status = 'succeeded' THEN 1 ELSE 0 END) as succeeded,
+			SUM(CASE WHEN workflow_executions.status = 'success' THEN 1 ELSE 0 END) as succeeded,
 			SUM(CASE WHEN workflow_executions.status = 'failed' THEN 1 ELSE 0 END) as failed
 		`).
 		Joins("JOIN workflows ON workflow_executions.workflow_id = workflows.id").
-		Where("workflows.user_id = ? AND workflow_executions.created_at BETWEEN ? AND ?", uid, startDate, endDate).
+		Scopes(ws.Scope("workflows")).Where("workflow_executions.created_at BETWEEN ? AND ?", startDate, endDate).
 		Group("DATE(workflow_executions.created_at)").
 		Order("date").
 		Scan(&timeline).Error; err != nil {
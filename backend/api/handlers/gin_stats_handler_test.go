@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/api/middleware"
+	"github.com/alexfaker/jilang-agent/models"
+	"go.uber.org/zap"
+)
+
+func TestGetDashboardStatsScopedToWorkspace(t *testing.T) {
+	db := newTestDB(t)
+	h := NewGinStatsHandler(db, zap.NewNop())
+	alice := createTestUser(t, db, "alice", 0)
+	bob := createTestUser(t, db, "bob", 0)
+	org := createTestOrganization(t, db, alice, 0)
+	orgWS := models.Workspace{UserID: alice.UserID, OrganizationID: &org.ID, Role: models.OrgRoleOwner}
+
+	personal := models.PersonalWorkspace(alice.UserID)
+	first := createTestWorkflow(t, db, personal, "个人一")
+	second := createTestWorkflow(t, db, personal, "个人二")
+	createTestExecution(t, db, first, alice.UserID, models.ExecutionStatusSuccess)
+	createTestExecution(t, db, first, alice.UserID, models.ExecutionStatusSuccess)
+	createTestExecution(t, db, second, alice.UserID, models.ExecutionStatusFailed)
+
+	orgWorkflow := createTestWorkflow(t, db, orgWS, "组织")
+	createTestExecution(t, db, orgWorkflow, bob.UserID, models.ExecutionStatusSuccess)
+
+	bobWorkflow := createTestWorkflow(t, db, models.PersonalWorkspace(bob.UserID), "bob个人")
+	createTestExecution(t, db, bobWorkflow, bob.UserID, models.ExecutionStatusFailed)
+
+	cases := []struct {
+		name       string
+		principal  *middleware.Principal
+		workflows  int64
+		executions int64
+		rate       float64
+	}{
+		{"JWT个人空间", jwtPrincipal(alice), 2, 3, 200.0 / 3},
+		{"API密钥个人空间", apiKeyPrincipal(alice, models.APIKeyScopeExecutionsRead), 2, 3, 200.0 / 3},
+		{"组织空间", orgPrincipal(bob, org, models.OrgRoleViewer), 1, 1, 100},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveAs(h.GetDashboardStats, tc.principal, http.MethodGet, "/stats/dashboard", "")
+			if w.Code != http.StatusOK {
+				t.Fatalf("应返回200，实际为%d: %s", w.Code, w.Body.String())
+			}
+			var stats GinDashboardStats
+			decodeResponse(t, w, &stats)
+
+			if stats.TotalWorkflows != tc.workflows || stats.TotalExecutions != tc.executions {
+				t.Errorf("应有%d个工作流、%d次执行，实际为%d、%d", tc.workflows, tc.executions, stats.TotalWorkflows, stats.TotalExecutions)
+			}
+			if math.Abs(stats.SuccessRate-tc.rate) > 0.01 {
+				t.Errorf("成功率应为%.2f，实际为%.2f", tc.rate, stats.SuccessRate)
+			}
+			if int64(len(stats.RecentExecutions)) != tc.executions {
+				t.Errorf("最近执行应有%d条，实际为%d条", tc.executions, len(stats.RecentExecutions))
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/payment"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// GinSubscriptionHandler 处理订阅相关的请求
+type GinSubscriptionHandler struct {
+	DB       *gorm.DB
+	Logger   *zap.Logger
+	Payments payment.Provider
+}
+
+// NewGinSubscriptionHandler 创建一个新的GinSubscriptionHandler实例
+func NewGinSubscriptionHandler(db *gorm.DB, logger *zap.Logger, payments payment.Provider) *GinSubscriptionHandler {
+	return &GinSubscriptionHandler{
+		DB:       db,
+		Logger:   logger,
+		Payments: payments,
+	}
+}
+
+// GetSubscriptionPlans 获取订阅套餐列表
+func (h *GinSubscriptionHandler) GetSubscriptionPlans(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   models.GetSubscriptionPlans(),
+	})
+}
+
+// GetCurrentSubscription 获取当前用户的订阅和适用套餐
+func (h *GinSubscriptionHandler) GetCurrentSubscription(c *gin.Context) {
+	// 获取用户ID
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	subscription, err := models.GetActiveSubscription(h.DB, uid)
+	if err != nil {
+		h.Logger.Error("获取用户订阅失败", zap.Error(err), zap.String("userId", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取用户订阅失败",
+		})
+		return
+	}
+
+	plan, err := models.GetUserPlan(h.DB, uid)
+	if err != nil {
+		h.Logger.Error("获取用户套餐失败", zap.Error(err), zap.String("userId", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取用户套餐失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"subscription": subscription,
+			"plan":         plan,
+		},
+	})
+}
+
+// SubscribeRequest 订阅请求结构
+type SubscribeRequest struct {
+	PlanCode      string `json:"planCode" binding:"required"`
+	PaymentMethod string `json:"paymentMethod" binding:"required"`
+}
+
+// Subscribe 订阅套餐，创建首期支付订单
+func (h *GinSubscriptionHandler) Subscribe(c *gin.Context) {
+	// 获取用户ID
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	// 解析请求体
+	var req SubscribeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "请求参数错误：" + err.Error(),
+		})
+		return
+	}
+
+	plan, ok := models.GetSubscriptionPlan(req.PlanCode)
+	if !ok || plan.Price <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的订阅套餐",
+		})
+		return
+	}
+
+	// 验证支付方式
+	validPaymentMethods := map[string]models.PaymentMethod{
+		"alipay": models.PaymentMethodAlipay,
+		"wechat": models.PaymentMethodWechat,
+		"credit": models.PaymentMethodCredit,
+	}
+
+	paymentMethod, ok := validPaymentMethods[req.PaymentMethod]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "不支持的支付方式",
+		})
+		return
+	}
+
+	var subscription models.UserSubscription
+	var order *models.RechargeOrder
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		// 已有生效中的订阅时不允许重复订阅
+		active, err := models.GetActiveSubscription(tx, uid)
+		if err != nil {
+			return err
+		}
+		if active != nil {
+			return &PurchaseError{Message: "您已有生效中的订阅，请在当前订阅结束后再订阅"}
+		}
+
+		// 取消之前未支付的订阅
+		if err := tx.Model(&models.UserSubscription{}).
+			Where("user_id = ? AND status = ?", uid, models.SubscriptionStatusPending).
+			Update("status", models.SubscriptionStatusCancelled).Error; err != nil {
+			return err
+		}
+
+		subscription = models.UserSubscription{
+			UserID:        uid,
+			PlanCode:      plan.Code,
+			Status:        models.SubscriptionStatusPending,
+			PaymentMethod: paymentMethod,
+		}
+		if err := tx.Create(&subscription).Error; err != nil {
+			return err
+		}
+
+		order, err = models.CreateSubscriptionOrder(tx, &subscription, plan)
+		return err
+	})
+	if err != nil {
+		if purchaseErr, ok := err.(*PurchaseError); ok {
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": purchaseErr.Message,
+			})
+		} else {
+			h.Logger.Error("创建订阅失败", zap.Error(err), zap.String("userId", uid))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "创建订阅失败",
+			})
+		}
+		return
+	}
+
+	// 通过支付提供方发起支付
+	paymentResult, err := h.Payments.CreatePayment(c.Request.Context(), payment.PaymentRequest{
+		OrderNo: order.OrderNo,
+		Amount:  order.Amount,
+		Method:  string(order.PaymentMethod),
+		Subject: "订阅" + plan.Name,
+		UserID:  uid,
+	})
+	if err != nil {
+		h.Logger.Error("发起支付失败", zap.Error(err), zap.String("orderNo", order.OrderNo))
+		c.JSON(http.StatusBadGateway, gin.H{
+			"status":  "error",
+			"message": "发起支付失败",
+		})
+		return
+	}
+
+	h.Logger.Info("订阅订单创建成功",
+		zap.String("orderNo", order.OrderNo),
+		zap.String("userId", uid),
+		zap.String("plan", plan.Code),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"subscription": subscription,
+			"orderId":      order.ID,
+			"orderNo":      order.OrderNo,
+			"amount":       order.Amount,
+			"paymentUrl":   paymentResult.PaymentURL,
+		},
+	})
+}
+
+// CancelSubscription 取消订阅（当前周期结束后失效）
+func (h *GinSubscriptionHandler) CancelSubscription(c *gin.Context) {
+	// 获取用户ID
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	uid := principal.UserID
+
+	subscription, err := models.GetActiveSubscription(h.DB, uid)
+	if err != nil {
+		h.Logger.Error("获取用户订阅失败", zap.Error(err), zap.String("userId", uid))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取用户订阅失败",
+		})
+		return
+	}
+	if subscription == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"status":  "error",
+			"message": "当前没有生效中的订阅",
+		})
+		return
+	}
+
+	if err := subscription.Cancel(h.DB); err != nil {
+		h.Logger.Error("取消订阅失败", zap.Error(err), zap.Int64("subscriptionId", subscription.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "取消订阅失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "订阅已取消，将在当前周期结束后失效",
+		"data":    subscription,
+	})
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// StartAgentTrialRequest 试用代理请求结构
+type StartAgentTrialRequest struct {
+	Inputs json.RawMessage `json:"inputs"`
+}
+
+// trialErrorStatus 试用业务错误对应的HTTP状态码，非业务错误返回0
+func trialErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrTrialNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrTrialUnavailable),
+		errors.Is(err, models.ErrTrialOwned):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrTrialExhausted):
+		return http.StatusForbidden
+	}
+	return 0
+}
+
+// respondTrialError 输出试用相关错误，非业务错误记录日志并返回500
+func (h *GinExecutionHandler) respondTrialError(c *gin.Context, err error, message string) {
+	if status := trialErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// loadTrialAgent 获取路径中可试用的代理：公开、当前售价大于0且试用功能已开启，失败时已写入响应
+func (h *GinExecutionHandler) loadTrialAgent(c *gin.Context) (*models.Agent, bool) {
+	id, ok := parseIDParam(c, "id", "无效的代理ID")
+	if !ok {
+		return nil, false
+	}
+
+	var agent models.Agent
+	if err := h.DB.Where("id = ? AND is_public = ?", id, true).First(&agent).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "代理不存在",
+			})
+			return nil, false
+		}
+		h.respondTrialError(c, err, "获取代理失败")
+		return nil, false
+	}
+	if h.Trial.Runs <= 0 || agent.CurrentPrice <= 0 {
+		h.respondTrialError(c, models.ErrTrialUnavailable, "获取代理失败")
+		return nil, false
+	}
+	return &agent, true
+}
+
+// GetAgentTrial 获取当前用户对代理的试用次数
+func (h *GinExecutionHandler) GetAgentTrial(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	agent, ok := h.loadTrialAgent(c)
+	if !ok {
+		return
+	}
+
+	status, err := models.GetTrialStatus(h.DB, principal.UserID, agent.ID, h.Trial.Runs)
+	if err != nil {
+		h.respondTrialError(c, err, "获取试用次数失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   status,
+	})
+}
+
+// StartAgentTrial 免费试用付费代理，直接运行代理定义，结果截断并附加水印，不创建工作流
+func (h *GinExecutionHandler) StartAgentTrial(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionExecute)
+	if !ok {
+		return
+	}
+
+	var req StartAgentTrialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	agent, ok := h.loadTrialAgent(c)
+	if !ok {
+		return
+	}
+
+	// 已拥有的代理直接使用工作流执行，已删除的工作流可在回收站中恢复
+	var owned int64
+	if err := h.DB.Unscoped().Model(&models.Workflow{}).
+		Scopes(principal.Workspace.Scope("workflows")).
+		Where("agent_id = ?", agent.ID).
+		Count(&owned).Error; err != nil {
+		h.respondTrialError(c, err, "检查代理购买状态失败")
+		return
+	}
+	if owned > 0 {
+		h.respondTrialError(c, models.ErrTrialOwned, "")
+		return
+	}
+
+	run := models.AgentTrialRun{
+		AgentID:        agent.ID,
+		AgentVersion:   agent.LatestVersion,
+		UserID:         principal.UserID,
+		OrganizationID: principal.Workspace.OrganizationID,
+		Status:         models.ExecutionStatusRunning,
+		InputData:      req.Inputs,
+		StartedAt:      time.Now(),
+	}
+	// 工作空间的并发执行数或每日执行次数已达上限时不允许试用
+	_, err := h.Quotas.ReserveExecution(principal.Workspace, func(tx *gorm.DB) error {
+		if err := models.UseAgentTrial(tx, principal.UserID, agent.ID, h.Trial.Runs); err != nil {
+			return err
+		}
+		return tx.Create(&run).Error
+	})
+	if err != nil {
+		if quotaExceeded(err) {
+			respondQuotaError(c, h.Logger, err)
+			return
+		}
+		h.respondTrialError(c, err, "创建试用失败")
+		return
+	}
+
+	status, err := models.GetTrialStatus(h.DB, principal.UserID, agent.ID, h.Trial.Runs)
+	if err != nil {
+		h.respondTrialError(c, err, "获取试用次数失败")
+		return
+	}
+
+	go h.executeTrialAsync(run.ID, agent.Definition, req.Inputs)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":  "success",
+		"message": "试用已启动",
+		"data": gin.H{
+			"run":   run,
+			"trial": status,
+		},
+	})
+}
+
+// executeTrialAsync 异步执行试用，输出超过试用大小限制时截断，并附加水印
+func (h *GinExecutionHandler) executeTrialAsync(runID int64, definition, inputs json.RawMessage) {
+	output, runErr := runDefinition(definition, inputs)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":       models.ExecutionStatusSuccess,
+		"completed_at": now,
+	}
+	if runErr != nil {
+		updates["status"] = models.ExecutionStatusFailed
+		updates["error_message"] = runErr.Error()
+	} else {
+		watermarked, truncated, err := models.WatermarkTrialOutput(output, h.Trial.MaxOutputBytes, h.Trial.Watermark)
+		if err != nil {
+			updates["status"] = models.ExecutionStatusFailed
+			updates["error_message"] = err.Error()
+		} else {
+			updates["output_data"] = watermarked
+			updates["truncated"] = truncated
+		}
+	}
+
+	if err := h.DB.Model(&models.AgentTrialRun{}).Where("id = ?", runID).Updates(updates).Error; err != nil {
+		h.Logger.Error("更新试用记录失败", zap.Error(err), zap.Int64("run_id", runID))
+	}
+}
+
+// GetAgentTrialRuns 获取当前用户的试用记录，可按agent_id筛选
+func (h *GinExecutionHandler) GetAgentTrialRuns(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+
+	var agentID int64
+	if v := c.Query("agent_id"); v != "" {
+		id, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "无效的代理ID",
+			})
+			return
+		}
+		agentID = id
+	}
+
+	limit, offset := parsePagination(c)
+	runs, total, err := models.ListAgentTrialRuns(h.DB, principal.UserID, agentID, limit, offset)
+	if err != nil {
+		h.respondTrialError(c, err, "获取试用记录失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"runs":       runs,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// GetAgentTrialRun 获取试用记录详情，用于轮询试用结果
+func (h *GinExecutionHandler) GetAgentTrialRun(c *gin.Context) {
+	principal, ok := requirePrincipal(c)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的试用记录ID")
+	if !ok {
+		return
+	}
+
+	run, err := models.GetAgentTrialRun(h.DB, principal.UserID, id)
+	if err != nil {
+		h.respondTrialError(c, err, "获取试用记录失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   run,
+	})
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/alexfaker/jilang-agent/api/middleware"
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"go.uber.org/zap"
@@ -17,14 +21,16 @@ type GinUserHandler struct {
 	DB        *gorm.DB
 	Logger    *zap.Logger
 	Validator *validator.Validate
+	Quotas    *quota.Enforcer
 }
 
 // NewGinUserHandler 创建一个新的GinUserHandler实例
-func NewGinUserHandler(db *gorm.DB, logger *zap.Logger) *GinUserHandler {
+func NewGinUserHandler(db *gorm.DB, logger *zap.Logger, quotas *quota.Enforcer) *GinUserHandler {
 	return &GinUserHandler{
 		DB:        db,
 		Logger:    logger,
 		Validator: validator.New(),
+		Quotas:    quotas,
 	}
 }
 
@@ -45,20 +51,26 @@ type GinUserProfileResponse struct {
 	CreatedAt   time.Time  `json:"createdAt"`
 	UpdatedAt   time.Time  `json:"updatedAt"`
 	LastLoginAt *time.Time `json:"lastLoginAt"`
+
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"` // 邮箱验证时间，为空表示未验证
+
+	// 开票信息
+	BillingCompanyName string `json:"billingCompanyName,omitempty"`
+	BillingTaxID       string `json:"billingTaxId,omitempty"`
+	BillingAddress     string `json:"billingAddress,omitempty"`
+	BillingPhone       string `json:"billingPhone,omitempty"`
+	BillingBankName    string `json:"billingBankName,omitempty"`
+	BillingBankAccount string `json:"billingBankAccount,omitempty"`
 }
 
 // GetUserProfile 获取用户资料
 func (h *GinUserHandler) GetUserProfile(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 根据UserID获取用户信息
 	var user models.User
@@ -95,6 +107,15 @@ func (h *GinUserHandler) GetUserProfile(c *gin.Context) {
 		CreatedAt:   user.CreatedAt,
 		UpdatedAt:   user.UpdatedAt,
 		LastLoginAt: user.LastLoginAt,
+
+		EmailVerifiedAt: user.EmailVerifiedAt,
+
+		BillingCompanyName: user.BillingCompanyName,
+		BillingTaxID:       user.BillingTaxID,
+		BillingAddress:     user.BillingAddress,
+		BillingPhone:       user.BillingPhone,
+		BillingBankName:    user.BillingBankName,
+		BillingBankAccount: user.BillingBankAccount,
 	}
 
 	// 返回用户信息
@@ -104,29 +125,21 @@ func (h *GinUserHandler) GetUserProfile(c *gin.Context) {
 	})
 }
 
-// GetUserByID 根据ID获取用户
+// GetUserByID 根据公开的用户ID获取用户
 func (h *GinUserHandler) GetUserByID(c *gin.Context) {
-	// 获取路径参数
-	idStr := c.Param("id")
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "无效的用户ID",
-		})
-		return
-	}
+	// 路径参数为公开的用户ID（USER_...）
+	id := c.Param("id")
 
 	// 获取用户信息
-	var user models.User
-	if err := h.DB.First(&user, id).Error; err != nil {
+	user, err := models.GetUserByID(h.DB, id)
+	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"status":  "error",
 				"message": "用户不存在",
 			})
 		} else {
-			h.Logger.Error("获取用户失败", zap.Error(err), zap.Int64("user_id", id))
+			h.Logger.Error("获取用户失败", zap.Error(err), zap.String("user_id", id))
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"status":  "error",
 				"message": "获取用户失败: " + err.Error(),
@@ -138,6 +151,7 @@ func (h *GinUserHandler) GetUserByID(c *gin.Context) {
 	// 移除敏感信息
 	profile := GinUserProfileResponse{
 		ID:          user.ID,
+		UserID:      user.UserID,
 		Username:    user.Username,
 		Email:       user.Email,
 		CreatedAt:   user.CreatedAt,
@@ -161,20 +175,43 @@ type GinUpdateProfileRequest struct {
 	Timezone string `json:"timezone"`
 	Language string `json:"language"`
 	Theme    string `json:"theme"`
+
+	// 开票信息
+	BillingCompanyName *string `json:"billingCompanyName" validate:"omitempty,max=100"`
+	BillingTaxID       *string `json:"billingTaxId" validate:"omitempty,max=50"`
+	BillingAddress     *string `json:"billingAddress" validate:"omitempty,max=255"`
+	BillingPhone       *string `json:"billingPhone" validate:"omitempty,max=50"`
+	BillingBankName    *string `json:"billingBankName" validate:"omitempty,max=100"`
+	BillingBankAccount *string `json:"billingBankAccount" validate:"omitempty,max=50"`
+}
+
+// profileAuditSnapshot 用户资料和设置的审计快照
+func profileAuditSnapshot(user *models.User) json.RawMessage {
+	return models.AuditSnapshot(gin.H{
+		"email":              user.Email,
+		"fullName":           user.FullName,
+		"avatar":             user.Avatar,
+		"bio":                user.Bio,
+		"timezone":           user.Timezone,
+		"language":           user.Language,
+		"theme":              user.Theme,
+		"billingCompanyName": user.BillingCompanyName,
+		"billingTaxId":       user.BillingTaxID,
+		"billingAddress":     user.BillingAddress,
+		"billingPhone":       user.BillingPhone,
+		"billingBankName":    user.BillingBankName,
+		"billingBankAccount": user.BillingBankAccount,
+	})
 }
 
 // UpdateUserProfile 更新用户资料
 func (h *GinUserHandler) UpdateUserProfile(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 解析请求体
 	var req GinUpdateProfileRequest
@@ -222,8 +259,16 @@ func (h *GinUserHandler) UpdateUserProfile(c *gin.Context) {
 		Timezone: req.Timezone,
 		Language: req.Language,
 		Theme:    req.Theme,
+
+		BillingCompanyName: req.BillingCompanyName,
+		BillingTaxID:       req.BillingTaxID,
+		BillingAddress:     req.BillingAddress,
+		BillingPhone:       req.BillingPhone,
+		BillingBankName:    req.BillingBankName,
+		BillingBankAccount: req.BillingBankAccount,
 	}
 
+	before := profileAuditSnapshot(&user)
 	if err := user.Update(h.DB, input); err != nil {
 		if err == gorm.ErrDuplicatedKey {
 			c.JSON(http.StatusConflict, gin.H{
@@ -240,6 +285,11 @@ func (h *GinUserHandler) UpdateUserProfile(c *gin.Context) {
 		return
 	}
 
+	event := newAuditEvent(c, models.AuditActionSettingsChange, "user", uid)
+	event.Before = before
+	event.After = profileAuditSnapshot(&user)
+	recordAudit(h.DB, h.Logger, event)
+
 	// 构建完整的用户资料响应
 	profile := GinUserProfileResponse{
 		ID:          user.ID,
@@ -257,6 +307,15 @@ func (h *GinUserHandler) UpdateUserProfile(c *gin.Context) {
 		CreatedAt:   user.CreatedAt,
 		UpdatedAt:   user.UpdatedAt,
 		LastLoginAt: user.LastLoginAt,
+
+		EmailVerifiedAt: user.EmailVerifiedAt,
+
+		BillingCompanyName: user.BillingCompanyName,
+		BillingTaxID:       user.BillingTaxID,
+		BillingAddress:     user.BillingAddress,
+		BillingPhone:       user.BillingPhone,
+		BillingBankName:    user.BillingBankName,
+		BillingBankAccount: user.BillingBankAccount,
 	}
 
 	// 返回更新后的用户信息
@@ -270,15 +329,11 @@ func (h *GinUserHandler) UpdateUserProfile(c *gin.Context) {
 // GetCurrentUser 获取当前用户信息
 func (h *GinUserHandler) GetCurrentUser(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 根据UserID获取用户信息
 	var user models.User
@@ -315,6 +370,15 @@ func (h *GinUserHandler) GetCurrentUser(c *gin.Context) {
 		CreatedAt:   user.CreatedAt,
 		UpdatedAt:   user.UpdatedAt,
 		LastLoginAt: user.LastLoginAt,
+
+		EmailVerifiedAt: user.EmailVerifiedAt,
+
+		BillingCompanyName: user.BillingCompanyName,
+		BillingTaxID:       user.BillingTaxID,
+		BillingAddress:     user.BillingAddress,
+		BillingPhone:       user.BillingPhone,
+		BillingBankName:    user.BillingBankName,
+		BillingBankAccount: user.BillingBankAccount,
 	}
 
 	// 返回用户信息
@@ -327,15 +391,11 @@ func (h *GinUserHandler) GetCurrentUser(c *gin.Context) {
 // UpdateUser 更新用户信息
 func (h *GinUserHandler) UpdateUser(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(int64)
+	uid := principal.ID
 
 	// 解析请求体
 	var req GinUpdateProfileRequest
@@ -384,6 +444,7 @@ func (h *GinUserHandler) UpdateUser(c *gin.Context) {
 	// 移除敏感信息
 	profile := GinUserProfileResponse{
 		ID:          user.ID,
+		UserID:      user.UserID,
 		Username:    user.Username,
 		Email:       user.Email,
 		CreatedAt:   user.CreatedAt,
@@ -408,15 +469,11 @@ type GinChangePasswordRequest struct {
 // ChangePassword 修改密码
 func (h *GinUserHandler) ChangePassword(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 解析请求体
 	var req GinChangePasswordRequest
@@ -468,6 +525,8 @@ func (h *GinUserHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	recordAudit(h.DB, h.Logger, newAuditEvent(c, models.AuditActionPasswordChange, "user", uid))
+
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -478,15 +537,11 @@ func (h *GinUserHandler) ChangePassword(c *gin.Context) {
 // UploadAvatar 上传头像
 func (h *GinUserHandler) UploadAvatar(c *gin.Context) {
 	// 从请求上下文中获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "无效的用户身份",
-		})
+	principal, ok := requirePrincipal(c)
+	if !ok {
 		return
 	}
-	uid := userID.(string)
+	uid := principal.UserID
 
 	// 获取上传的文件
 	file, err := c.FormFile("avatar")
@@ -498,12 +553,9 @@ func (h *GinUserHandler) UploadAvatar(c *gin.Context) {
 		return
 	}
 
-	// 检查文件大小（最大2MB）
-	if file.Size > 2*1024*1024 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"status":  "error",
-			"message": "文件大小不能超过2MB",
-		})
+	// 检查文件大小配额，头像属于个人资料，按个人空间的套餐计算
+	if err := h.Quotas.CheckUpload(models.PersonalWorkspace(uid), file.Size); err != nil {
+		respondQuotaError(c, h.Logger, err)
 		return
 	}
 
@@ -577,3 +629,88 @@ func (h *GinUserHandler) UploadAvatar(c *gin.Context) {
 		"data":    profile,
 	})
 }
+
+// GinUpdateUserRoleRequest 修改用户角色请求结构
+type GinUpdateUserRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// UpdateUserRole 修改用户角色（管理员功能）
+func (h *GinUserHandler) UpdateUserRole(c *gin.Context) {
+	targetUserID := c.Param("userId")
+
+	// 解析请求体
+	var req GinUpdateUserRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的请求数据: " + err.Error(),
+		})
+		return
+	}
+
+	if !models.IsValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的角色",
+		})
+		return
+	}
+
+	// 不允许修改自己的角色，避免管理员误操作失去权限
+	if principal, ok := middleware.GetPrincipal(c); ok && principal.UserID == targetUserID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "不能修改自己的角色",
+		})
+		return
+	}
+
+	target, err := models.GetUserByID(h.DB, targetUserID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "用户不存在",
+			})
+			return
+		}
+		h.Logger.Error("查询用户失败", zap.Error(err), zap.String("user_id", targetUserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "修改用户角色失败",
+		})
+		return
+	}
+
+	// 角色变更与审计记录在同一事务中生效
+	event := newAuditEvent(c, models.AuditActionRoleChange, "user", targetUserID)
+	event.Before = models.AuditSnapshot(gin.H{"role": target.Role})
+	event.After = models.AuditSnapshot(gin.H{"role": req.Role})
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(target).Update("role", req.Role).Error; err != nil {
+			return err
+		}
+		return models.CreateAuditEvent(tx, event)
+	})
+	if err != nil {
+		h.Logger.Error("修改用户角色失败", zap.Error(err), zap.String("user_id", targetUserID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "修改用户角色失败",
+		})
+		return
+	}
+
+	h.Logger.Info("用户角色已修改", zap.String("user_id", targetUserID), zap.String("role", req.Role))
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "用户角色修改成功",
+		"data": gin.H{
+			"userID":      targetUserID,
+			"role":        req.Role,
+			"permissions": models.GetRolePermissions(req.Role),
+		},
+	})
+}
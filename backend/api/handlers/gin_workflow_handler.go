@@ -4,9 +4,9 @@ import (
 	"encoding/json"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -16,13 +16,15 @@ import (
 type GinWorkflowHandler struct {
 	DB     *gorm.DB
 	Logger *zap.Logger
+	Quotas *quota.Enforcer
 }
 
 // NewGinWorkflowHandler 创建一个新的GinWorkflowHandler实例
-func NewGinWorkflowHandler(db *gorm.DB, logger *zap.Logger) *GinWorkflowHandler {
+func NewGinWorkflowHandler(db *gorm.DB, logger *zap.Logger, quotas *quota.Enforcer) *GinWorkflowHandler {
 	return &GinWorkflowHandler{
 		DB:     db,
 		Logger: logger,
+		Quotas: quotas,
 	}
 }
 
@@ -32,20 +34,16 @@ type CreateWorkflowRequest struct {
 	Description string          `json:"description"`
 	Definition  json.RawMessage `json:"definition" binding:"required"`
 	Status      string          `json:"status"`
-	AgentID     *int64          `json:"agentId"` // 关联的代理ID
+	AgentID     *int64          `json:"agentId"` // 不允许指定，代理工作流只能通过购买创建
 }
 
-// GetWorkflows 获取用户的工作流列表
+// GetWorkflows 获取当前工作空间的工作流列表
 func (h *GinWorkflowHandler) GetWorkflows(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
+	ws := principal.Workspace
 
 	// 获取分页参数
 	limit := 20
@@ -69,8 +67,8 @@ func (h *GinWorkflowHandler) GetWorkflows(c *gin.Context) {
 	// 获取筛选参数
 	statusFilter := c.Query("status")
 
-	// 构建查询 - 只查询当前用户的工作流
-	query := h.DB.Model(&models.Workflow{}).Where("user_id = ?", userID.(string))
+	// 构建查询 - 只查询当前工作空间的工作流
+	query := h.DB.Model(&models.Workflow{}).Scopes(ws.Scope("workflows"))
 
 	// 应用状态筛选
 	if statusFilter != "" {
@@ -110,15 +108,11 @@ func (h *GinWorkflowHandler) GetWorkflows(c *gin.Context) {
 
 // GetWorkflow 获取单个工作流详情
 func (h *GinWorkflowHandler) GetWorkflow(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
 		return
 	}
+	ws := principal.Workspace
 
 	// 获取工作流ID
 	idStr := c.Param("id")
@@ -133,7 +127,7 @@ func (h *GinWorkflowHandler) GetWorkflow(c *gin.Context) {
 
 	// 查询工作流 - 验证所有权
 	var workflow models.Workflow
-	result := h.DB.Where("id = ? AND user_id = ?", id, userID.(string)).First(&workflow)
+	result := h.DB.Scopes(ws.Scope("workflows")).Where("id = ?", id).First(&workflow)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -159,15 +153,11 @@ func (h *GinWorkflowHandler) GetWorkflow(c *gin.Context) {
 
 // CreateWorkflow 创建新工作流
 func (h *GinWorkflowHandler) CreateWorkflow(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
 		return
 	}
+	ws := principal.Workspace
 
 	// 解析请求
 	var req CreateWorkflowRequest
@@ -179,6 +169,15 @@ func (h *GinWorkflowHandler) CreateWorkflow(c *gin.Context) {
 		return
 	}
 
+	// 关联代理的工作流代表购买记录，只能由购买流程创建
+	if req.AgentID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "不能直接创建代理工作流，请通过购买获取",
+		})
+		return
+	}
+
 	// 验证 JSON 定义
 	var definition map[string]interface{}
 	if err := json.Unmarshal(req.Definition, &definition); err != nil {
@@ -197,28 +196,28 @@ func (h *GinWorkflowHandler) CreateWorkflow(c *gin.Context) {
 
 	// 创建工作流
 	workflow := models.Workflow{
-		Name:        req.Name,
-		Description: req.Description,
-		Definition:  req.Definition,
-		Status:      workflowStatus,
-		UserID:      userID.(string),
-		AgentID:     req.AgentID,
-		RunCount:    0,
+		Name:           req.Name,
+		Description:    req.Description,
+		Definition:     req.Definition,
+		Status:         workflowStatus,
+		UserID:         principal.UserID,
+		OrganizationID: ws.OrganizationID,
+		RunCount:       0,
 	}
 
-	// 如果是从代理购买的，设置购买时间
-	if req.AgentID != nil {
-		now := time.Now()
-		workflow.PurchasedAt = &now
-	}
-
-	// 保存到数据库
-	result := h.DB.Create(&workflow)
-	if result.Error != nil {
-		h.Logger.Error("创建工作流失败", zap.Error(result.Error))
+	// 检查工作流数量配额并保存到数据库
+	err := h.Quotas.ReserveWorkflow(ws, func(tx *gorm.DB) error {
+		return tx.Create(&workflow).Error
+	})
+	if err != nil {
+		if quotaExceeded(err) {
+			respondQuotaError(c, h.Logger, err)
+			return
+		}
+		h.Logger.Error("创建工作流失败", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"status":  "error",
-			"message": "创建工作流失败: " + result.Error.Error(),
+			"message": "创建工作流失败: " + err.Error(),
 		})
 		return
 	}
@@ -240,15 +239,11 @@ type UpdateWorkflowRequest struct {
 
 // UpdateWorkflow 更新工作流
 func (h *GinWorkflowHandler) UpdateWorkflow(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
 		return
 	}
+	ws := principal.Workspace
 
 	// 获取工作流ID
 	idStr := c.Param("id")
@@ -263,7 +258,7 @@ func (h *GinWorkflowHandler) UpdateWorkflow(c *gin.Context) {
 
 	// 查询工作流 - 验证所有权
 	var workflow models.Workflow
-	result := h.DB.Where("id = ? AND user_id = ?", id, userID.(string)).First(&workflow)
+	result := h.DB.Scopes(ws.Scope("workflows")).Where("id = ?", id).First(&workflow)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -350,7 +345,7 @@ func (h *GinWorkflowHandler) UpdateWorkflow(c *gin.Context) {
 	}
 
 	// 重新获取更新后的工作流
-	h.DB.Where("id = ? AND user_id = ?", id, userID.(string)).First(&workflow)
+	h.DB.Scopes(ws.Scope("workflows")).Where("id = ?", id).First(&workflow)
 
 	// 返回更新后的工作流
 	c.JSON(http.StatusOK, gin.H{
@@ -359,17 +354,13 @@ func (h *GinWorkflowHandler) UpdateWorkflow(c *gin.Context) {
 	})
 }
 
-// DeleteWorkflow 删除工作流
+// DeleteWorkflow 删除工作流，移至回收站，保留期内可恢复
 func (h *GinWorkflowHandler) DeleteWorkflow(c *gin.Context) {
-	// 获取用户ID
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{
-			"status":  "error",
-			"message": "未找到用户信息",
-		})
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
 		return
 	}
+	ws := principal.Workspace
 
 	// 获取工作流ID
 	idStr := c.Param("id")
@@ -384,7 +375,7 @@ func (h *GinWorkflowHandler) DeleteWorkflow(c *gin.Context) {
 
 	// 查询工作流 - 验证所有权
 	var workflow models.Workflow
-	result := h.DB.Where("id = ? AND user_id = ?", id, userID.(string)).First(&workflow)
+	result := h.DB.Scopes(ws.Scope("workflows")).Where("id = ?", id).First(&workflow)
 	if result.Error != nil {
 		if result.Error == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
@@ -401,32 +392,15 @@ func (h *GinWorkflowHandler) DeleteWorkflow(c *gin.Context) {
 		return
 	}
 
-	// 使用事务删除工作流及其执行记录
-	err = h.DB.Transaction(func(tx *gorm.DB) error {
-		// 删除关联的执行记录
-		if err := tx.Where("workflow_id = ?", id).Delete(&models.WorkflowExecution{}).Error; err != nil {
-			return err
-		}
-
-		// 删除工作流
-		if err := tx.Delete(&workflow).Error; err != nil {
-			return err
-		}
-
-		return nil
-	})
-	if err != nil {
-		h.Logger.Error("删除工作流失败", zap.Error(err), zap.Int64("id", id))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"status":  "error",
-			"message": "删除工作流失败: " + err.Error(),
-		})
+	// 软删除工作流及其执行记录，保留期内可在回收站中恢复
+	if err := models.SoftDeleteWorkflow(h.DB, &workflow); err != nil {
+		respondArchiveError(c, h.Logger, err, "删除工作流失败")
 		return
 	}
 
 	// 返回成功响应
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
-		"message": "工作流删除成功",
+		"message": "工作流已移至回收站",
 	})
 }
@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// WorkflowCopyRequest 克隆工作流、保存模板或从模板创建工作流请求结构，字段为空时沿用来源
+type WorkflowCopyRequest struct {
+	Name        string `json:"name" binding:"max=100"`
+	Description string `json:"description"`
+}
+
+// workflowTemplateErrorStatus 模板业务错误对应的HTTP状态码，非业务错误返回0
+func workflowTemplateErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrTemplateNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrTemplateLimit):
+		return http.StatusBadRequest
+	}
+	return 0
+}
+
+// respondWorkflowTemplateError 输出克隆和模板相关错误，非业务错误记录日志并返回500
+func (h *GinWorkflowHandler) respondWorkflowTemplateError(c *gin.Context, err error, message string) {
+	if status := workflowTemplateErrorStatus(err); status != 0 {
+		c.JSON(status, gin.H{
+			"status":  "error",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	h.Logger.Error(message, zap.Error(err))
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"status":  "error",
+		"message": message,
+	})
+}
+
+// bindWorkflowCopyRequest 解析可选的请求体，没有请求体时使用来源的名称和描述
+func bindWorkflowCopyRequest(c *gin.Context) (models.WorkflowCopyInput, bool) {
+	var req WorkflowCopyRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "请求数据格式错误: " + err.Error(),
+			})
+			return models.WorkflowCopyInput{}, false
+		}
+	}
+	return models.WorkflowCopyInput{Name: req.Name, Description: req.Description}, true
+}
+
+// findWorkspaceWorkflow 获取当前工作空间中的工作流，不存在时返回404
+func (h *GinWorkflowHandler) findWorkspaceWorkflow(c *gin.Context, ws models.Workspace, id int64) (*models.Workflow, bool) {
+	var workflow models.Workflow
+	if err := h.DB.Scopes(ws.Scope("workflows")).Where("id = ?", id).First(&workflow).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "工作流不存在",
+			})
+			return nil, false
+		}
+		h.Logger.Error("获取工作流失败", zap.Error(err), zap.Int64("id", id))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取工作流失败",
+		})
+		return nil, false
+	}
+	return &workflow, true
+}
+
+// CloneWorkflow 克隆工作流，新工作流为草稿，记录来源工作流并保留代理来源
+func (h *GinWorkflowHandler) CloneWorkflow(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
+		return
+	}
+	ws := principal.Workspace
+	id, ok := parseIDParam(c, "id", "无效的工作流ID")
+	if !ok {
+		return
+	}
+	input, ok := bindWorkflowCopyRequest(c)
+	if !ok {
+		return
+	}
+
+	source, ok := h.findWorkspaceWorkflow(c, ws, id)
+	if !ok {
+		return
+	}
+	var workflow *models.Workflow
+	err := h.Quotas.ReserveWorkflow(ws, func(tx *gorm.DB) error {
+		var err error
+		workflow, err = models.CloneWorkflow(tx, ws, source, input)
+		return err
+	})
+	if err != nil {
+		if quotaExceeded(err) {
+			respondQuotaError(c, h.Logger, err)
+			return
+		}
+		h.respondWorkflowTemplateError(c, err, "克隆工作流失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   workflow,
+	})
+}
+
+// SaveWorkflowTemplate 将工作流保存为个人模板，模板只有保存者本人可见
+func (h *GinWorkflowHandler) SaveWorkflowTemplate(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
+		return
+	}
+	ws := principal.Workspace
+	id, ok := parseIDParam(c, "id", "无效的工作流ID")
+	if !ok {
+		return
+	}
+	input, ok := bindWorkflowCopyRequest(c)
+	if !ok {
+		return
+	}
+
+	source, ok := h.findWorkspaceWorkflow(c, ws, id)
+	if !ok {
+		return
+	}
+
+	template, err := models.SaveWorkflowTemplate(h.DB, ws, source, input)
+	if err != nil {
+		h.respondWorkflowTemplateError(c, err, "保存模板失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   template,
+	})
+}
+
+// GetWorkflowTemplates 获取当前用户在工作空间中保存的模板
+func (h *GinWorkflowHandler) GetWorkflowTemplates(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	limit, offset := parsePagination(c)
+	templates, total, err := models.ListWorkflowTemplates(h.DB, principal.Workspace, limit, offset)
+	if err != nil {
+		h.respondWorkflowTemplateError(c, err, "获取模板列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"templates":  templates,
+			"pagination": paginationData(total, limit, offset),
+		},
+	})
+}
+
+// GetWorkflowTemplate 获取模板详情
+func (h *GinWorkflowHandler) GetWorkflowTemplate(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的模板ID")
+	if !ok {
+		return
+	}
+
+	template, err := models.GetWorkflowTemplate(h.DB, principal.Workspace, id)
+	if err != nil {
+		h.respondWorkflowTemplateError(c, err, "获取模板失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   template,
+	})
+}
+
+// DeleteWorkflowTemplate 删除模板，已从模板创建的工作流不受影响
+func (h *GinWorkflowHandler) DeleteWorkflowTemplate(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
+		return
+	}
+	id, ok := parseIDParam(c, "id", "无效的模板ID")
+	if !ok {
+		return
+	}
+
+	if err := models.DeleteWorkflowTemplate(h.DB, principal.Workspace, id); err != nil {
+		h.respondWorkflowTemplateError(c, err, "删除模板失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"message": "模板已删除",
+	})
+}
+
+// CreateWorkflowFromTemplate 从模板创建草稿工作流
+func (h *GinWorkflowHandler) CreateWorkflowFromTemplate(c *gin.Context) {
+	principal, ok := requireWorkspace(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
+		return
+	}
+	ws := principal.Workspace
+	id, ok := parseIDParam(c, "id", "无效的模板ID")
+	if !ok {
+		return
+	}
+	input, ok := bindWorkflowCopyRequest(c)
+	if !ok {
+		return
+	}
+
+	template, err := models.GetWorkflowTemplate(h.DB, ws, id)
+	if err != nil {
+		h.respondWorkflowTemplateError(c, err, "获取模板失败")
+		return
+	}
+	var workflow *models.Workflow
+	err = h.Quotas.ReserveWorkflow(ws, func(tx *gorm.DB) error {
+		var err error
+		workflow, err = models.CreateWorkflowFromTemplate(tx, ws, template, input)
+		return err
+	})
+	if err != nil {
+		if quotaExceeded(err) {
+			respondQuotaError(c, h.Logger, err)
+			return
+		}
+		h.respondWorkflowTemplateError(c, err, "从模板创建工作流失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"status": "success",
+		"data":   workflow,
+	})
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/jsonmerge"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// workflowUpgrade 已购买工作流升级到代理最新版本的预览
+type workflowUpgrade struct {
+	Workflow *models.Workflow
+	Base     *models.AgentVersion // 工作流来源版本，为空表示无需升级
+	Latest   *models.AgentVersion // 代理最新版本
+}
+
+// loadWorkflowUpgrade 加载当前工作空间中的工作流及其升级所需的版本，失败时已写入响应
+func (h *GinWorkflowHandler) loadWorkflowUpgrade(c *gin.Context, permission models.OrgPermission) (*workflowUpgrade, bool) {
+	principal, ok := requireWorkspace(c, permission)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "无效的工作流ID",
+		})
+		return nil, false
+	}
+
+	var workflow models.Workflow
+	if err := h.DB.Scopes(principal.Workspace.Scope("workflows")).Where("id = ?", id).First(&workflow).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "工作流不存在",
+			})
+		} else {
+			h.Logger.Error("获取工作流失败", zap.Error(err), zap.Int64("id", id))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取工作流失败",
+			})
+		}
+		return nil, false
+	}
+	if workflow.AgentID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "该工作流不是从商店购买的，无法升级",
+		})
+		return nil, false
+	}
+
+	var agent models.Agent
+	if err := h.DB.First(&agent, *workflow.AgentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"status":  "error",
+				"message": "来源代理已下架删除，无法升级",
+			})
+		} else {
+			h.Logger.Error("获取代理失败", zap.Error(err), zap.Int64("agentId", *workflow.AgentID))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "获取代理失败",
+			})
+		}
+		return nil, false
+	}
+
+	upgrade := &workflowUpgrade{Workflow: &workflow}
+	if agent.LatestVersion == "" || agent.LatestVersion == workflow.AgentVersion {
+		return upgrade, true
+	}
+
+	upgrade.Base, err = models.GetAgentVersion(h.DB, agent.ID, workflow.AgentVersion)
+	if err == nil {
+		upgrade.Latest, err = models.GetAgentVersion(h.DB, agent.ID, agent.LatestVersion)
+	}
+	if err != nil {
+		h.Logger.Error("获取代理版本失败", zap.Error(err), zap.Int64("agentId", agent.ID), zap.String("version", workflow.AgentVersion))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "获取代理版本失败",
+		})
+		return nil, false
+	}
+	return upgrade, true
+}
+
+// Available 是否有可用的升级
+func (u *workflowUpgrade) Available() bool {
+	return u.Latest != nil
+}
+
+// preview 计算升级预览：上游变更、用户的自定义修改、冲突和合并后的定义
+func (u *workflowUpgrade) preview(db *gorm.DB) (gin.H, error) {
+	workflow := u.Workflow
+	upstreamChanges, err := jsonmerge.Diff(u.Base.Definition, u.Latest.Definition)
+	if err != nil {
+		return nil, err
+	}
+	customizations, err := jsonmerge.Diff(u.Base.Definition, workflow.Definition)
+	if err != nil {
+		return nil, err
+	}
+	merged, conflicts, err := jsonmerge.Merge(u.Base.Definition, workflow.Definition, u.Latest.Definition, false)
+	if err != nil {
+		return nil, err
+	}
+	changelog, err := models.AgentVersionsSince(db, *workflow.AgentID, workflow.AgentVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"workflowId":       workflow.ID,
+		"currentVersion":   workflow.AgentVersion,
+		"baseVersion":      u.Base.Version,
+		"latestVersion":    u.Latest.Version,
+		"upgradeAvailable": true,
+		"changelog":        summarizeAgentVersions(changelog),
+		"upstreamChanges":  upstreamChanges,
+		"customizations":   customizations,
+		"conflicts":        conflicts,
+		"mergedDefinition": merged,
+	}, nil
+}
+
+// GetWorkflowUpgrade 预览已购买工作流升级到代理最新版本的变更
+func (h *GinWorkflowHandler) GetWorkflowUpgrade(c *gin.Context) {
+	upgrade, ok := h.loadWorkflowUpgrade(c, models.OrgPermissionRead)
+	if !ok {
+		return
+	}
+
+	workflow := upgrade.Workflow
+	if !upgrade.Available() {
+		c.JSON(http.StatusOK, gin.H{
+			"status": "success",
+			"data": gin.H{
+				"workflowId":       workflow.ID,
+				"currentVersion":   workflow.AgentVersion,
+				"upgradeAvailable": false,
+			},
+		})
+		return
+	}
+
+	preview, err := upgrade.preview(h.DB)
+	if err != nil {
+		h.Logger.Error("比较工作流定义失败", zap.Error(err), zap.Int64("workflowId", workflow.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "比较工作流定义失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data":   preview,
+	})
+}
+
+// UpgradeWorkflowRequest 升级工作流请求结构
+type UpgradeWorkflowRequest struct {
+	Version   string `json:"version"`   // 预览时看到的目标版本，与最新版本不一致时拒绝升级
+	Overwrite bool   `json:"overwrite"` // 放弃自定义修改，直接使用新版本的定义
+}
+
+// UpgradeWorkflow 将已购买的工作流升级到代理最新版本，默认保留用户的自定义修改
+func (h *GinWorkflowHandler) UpgradeWorkflow(c *gin.Context) {
+	var req UpgradeWorkflowRequest
+	// 请求体可选
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "请求数据格式错误: " + err.Error(),
+			})
+			return
+		}
+	}
+
+	upgrade, ok := h.loadWorkflowUpgrade(c, models.OrgPermissionWorkflowEdit)
+	if !ok {
+		return
+	}
+	workflow := upgrade.Workflow
+	if !upgrade.Available() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"status":  "error",
+			"message": "工作流已是最新版本",
+		})
+		return
+	}
+	if req.Version != "" && req.Version != upgrade.Latest.Version {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "代理已发布更新的版本，请重新预览后再升级",
+		})
+		return
+	}
+
+	merged, conflicts, err := jsonmerge.Merge(upgrade.Base.Definition, workflow.Definition, upgrade.Latest.Definition, req.Overwrite)
+	if err != nil {
+		h.Logger.Error("合并工作流定义失败", zap.Error(err), zap.Int64("workflowId", workflow.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "合并工作流定义失败",
+		})
+		return
+	}
+
+	// 仅当工作流仍停留在预览时的版本时更新，避免并发升级互相覆盖
+	fromVersion := workflow.AgentVersion
+	result := h.DB.Model(&models.Workflow{}).
+		Where("id = ? AND agent_version = ?", workflow.ID, fromVersion).
+		Updates(map[string]interface{}{
+			"definition":    merged,
+			"agent_version": upgrade.Latest.Version,
+		})
+	if result.Error != nil {
+		h.Logger.Error("升级工作流失败", zap.Error(result.Error), zap.Int64("workflowId", workflow.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"status":  "error",
+			"message": "升级工作流失败",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"status":  "error",
+			"message": "工作流已被其他请求升级，请刷新后重试",
+		})
+		return
+	}
+
+	h.Logger.Info("工作流已升级",
+		zap.Int64("workflowId", workflow.ID),
+		zap.String("from", fromVersion),
+		zap.String("to", upgrade.Latest.Version),
+		zap.Int("conflicts", len(conflicts)),
+		zap.Bool("overwrite", req.Overwrite),
+	)
+
+	workflow.Definition = merged
+	workflow.AgentVersion = upgrade.Latest.Version
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+		"data": gin.H{
+			"workflow":  workflow,
+			"conflicts": conflicts,
+		},
+	})
+}
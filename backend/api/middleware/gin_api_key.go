@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 认证方式，保存在Principal.AuthMethod中
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// apiKeyRouteScopes API密钥可访问的接口及所需的权限范围
+//
+// 未列出的接口一律不接受API密钥，新增可供脚本调用的接口时需在此登记。
+var apiKeyRouteScopes = map[string]models.APIKeyScope{
+	"POST /api/workflows/:id/execute":  models.APIKeyScopeWorkflowsExecute,
+	"GET /api/executions":              models.APIKeyScopeExecutionsRead,
+	"GET /api/executions/:id":          models.APIKeyScopeExecutionsRead,
+	"GET /api/points/balance":          models.APIKeyScopePointsRead,
+	"GET /api/points/transactions":     models.APIKeyScopePointsRead,
+	"GET /api/points/transactions/:id": models.APIKeyScopePointsRead,
+	"GET /api/points/statistics":       models.APIKeyScopePointsRead,
+}
+
+// apiKeyFromRequest 从请求中提取API密钥，未使用API密钥时返回空字符串
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := strings.TrimSpace(c.GetHeader("X-API-Key")); key != "" {
+		return key
+	}
+
+	parts := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(parts) == 2 && parts[0] == "Bearer" && strings.HasPrefix(parts[1], models.APIKeyPrefix) {
+		return parts[1]
+	}
+	return ""
+}
+
+// authenticateAPIKey 校验API密钥及其权限范围，通过后将密钥所属用户作为主体设置到上下文
+func authenticateAPIKey(c *gin.Context, db *gorm.DB, plain string) {
+	key, err := models.AuthenticateAPIKey(db, plain)
+	if err != nil {
+		if errors.Is(err, models.ErrAPIKeyInvalid) || errors.Is(err, models.ErrAPIKeyExpired) || errors.Is(err, models.ErrAPIKeyRevoked) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "验证API密钥失败",
+			})
+		}
+		c.Abort()
+		return
+	}
+
+	required, ok := apiKeyRouteScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"code":    "api_key_not_allowed",
+			"message": "该接口不支持使用API密钥访问",
+		})
+		c.Abort()
+		return
+	}
+	if !key.HasScope(required) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"status":  "error",
+			"code":    "insufficient_scope",
+			"message": "API密钥缺少权限范围: " + string(required),
+		})
+		c.Abort()
+		return
+	}
+
+	principal, err := loadPrincipal(db, key.UserID)
+	if err != nil {
+		if errors.Is(err, errPrincipalNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的用户身份",
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "验证API密钥失败",
+			})
+		}
+		c.Abort()
+		return
+	}
+
+	// 使用记录写入失败不影响本次请求
+	_ = models.TouchAPIKey(db, key, c.ClientIP())
+
+	principal.AuthMethod = AuthMethodAPIKey
+	principal.APIKeyID = key.ID
+	for _, scope := range key.ScopeList() {
+		principal.Scopes = append(principal.Scopes, models.APIKeyScope(scope))
+	}
+	SetPrincipal(c, principal)
+
+	c.Next()
+}
@@ -1,12 +1,15 @@
 package middleware
 
 import (
+	"errors"
 	"net/http"
 	"strings"
 
+	"github.com/alexfaker/jilang-agent/models"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 // RequestIDMiddleware 为请求添加请求ID
@@ -19,16 +22,22 @@ func RequestIDMiddleware() gin.HandlerFunc {
 		}
 
 		// 设置请求ID到上下文和响应头
-		c.Set("requestID", requestID)
+		c.Set(RequestIDKey, requestID)
 		c.Header("X-Request-ID", requestID)
 
 		c.Next()
 	}
 }
 
-// GinAuthMiddleware 验证JWT令牌的中间件
-func GinAuthMiddleware(jwtSecret string) gin.HandlerFunc {
+// GinAuthMiddleware 验证JWT访问令牌或个人API密钥的中间件，并检查令牌是否已被吊销
+func GinAuthMiddleware(jwtSecret string, db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// API密钥可通过X-API-Key请求头或Bearer方式提交
+		if apiKey := apiKeyFromRequest(c); apiKey != "" {
+			authenticateAPIKey(c, db, apiKey)
+			return
+		}
+
 		// 从请求头获取Authorization
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
@@ -94,7 +103,7 @@ func GinAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		// 获取用户ID和用户名
+		// 获取用户ID，用户名等信息以数据库为准
 		userID, ok := claims["user_id"].(string)
 		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{
@@ -105,8 +114,7 @@ func GinAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		username, ok := claims["username"].(string)
-		if !ok {
+		if _, ok := claims["username"].(string); !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"status":  "error",
 				"message": "无效的用户名",
@@ -115,9 +123,108 @@ func GinAuthMiddleware(jwtSecret string) gin.HandlerFunc {
 			return
 		}
 
-		// 将用户信息设置到上下文
-		c.Set("userID", userID)
-		c.Set("username", username)
+		// 仅接受访问令牌，并检查吊销列表
+		jti, _ := claims["jti"].(string)
+		issuedAt, iatErr := claims.GetIssuedAt()
+		expiresAt, expErr := claims.GetExpirationTime()
+		if claims["typ"] != "access" || jti == "" || iatErr != nil || issuedAt == nil || expErr != nil || expiresAt == nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的访问令牌",
+			})
+			c.Abort()
+			return
+		}
+
+		revoked, err := models.IsAccessTokenRevoked(db, userID, jti, issuedAt.Time)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "验证令牌失败",
+			})
+			c.Abort()
+			return
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "令牌已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
+		principal, err := loadPrincipal(db, userID)
+		if err != nil {
+			if errors.Is(err, errPrincipalNotFound) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"status":  "error",
+					"message": "无效的用户身份",
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"status":  "error",
+					"message": "验证令牌失败",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		// 退出全部设备或重置密码后令牌代数递增，之前签发的访问令牌全部失效；旧令牌没有gen视为0
+		generation, _ := claims["gen"].(float64)
+		if int(generation) != principal.tokenGeneration {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "令牌已失效，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+		principal.AuthMethod = AuthMethodJWT
+		principal.TokenID = jti
+		principal.TokenExpiresAt = expiresAt.Time
+		// 本次登录是否通过了两步验证
+		principal.MFAVerified, _ = claims["mfa"].(bool)
+
+		SetPrincipal(c, principal)
+
+		c.Next()
+	}
+}
+
+// RequireVerifiedEmail 要求当前用户已验证邮箱的中间件，需在GinAuthMiddleware之后使用
+func RequireVerifiedEmail(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的用户身份",
+			})
+			c.Abort()
+			return
+		}
+
+		var user models.User
+		if err := db.Select("email_verified_at").Where("id = ?", principal.ID).First(&user).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的用户身份",
+			})
+			c.Abort()
+			return
+		}
+
+		if !user.IsEmailVerified() {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"code":    "email_not_verified",
+				"message": "请先验证邮箱后再进行此操作",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -27,7 +27,7 @@ func GinLogger(logger *zap.Logger) gin.HandlerFunc {
 		end := time.Now()
 		latency := end.Sub(start)
 		statusCode := c.Writer.Status()
-		requestID, _ := c.Get("requestID")
+		requestID, _ := c.Get(RequestIDKey)
 
 		// 构建日志字段
 		fields := []zap.Field{
@@ -58,17 +58,28 @@ func GinLogger(logger *zap.Logger) gin.HandlerFunc {
 	}
 }
 
+// RequestIDKey 请求ID在上下文中的键
+const RequestIDKey = "requestID"
+
+// maxRequestIDLength 客户端传入的请求ID最大长度，超过时重新生成
+const maxRequestIDLength = 64
+
+// GetRequestID 获取当前请求的请求ID
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(RequestIDKey)
+}
+
 // LoggerRequestIDMiddleware 生成并添加请求ID到上下文
 func LoggerRequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 从请求头获取请求ID，如果没有则生成一个新的
 		requestID := c.GetHeader("X-Request-ID")
-		if requestID == "" {
+		if requestID == "" || len(requestID) > maxRequestIDLength {
 			requestID = uuid.New().String()
 		}
 
 		// 添加请求ID到上下文
-		c.Set("RequestID", requestID)
+		c.Set(RequestIDKey, requestID)
 		c.Header("X-Request-ID", requestID)
 
 		c.Next()
@@ -79,11 +90,7 @@ func LoggerRequestIDMiddleware() gin.HandlerFunc {
 func GinRecoveryMiddleware(logger *zap.Logger) gin.HandlerFunc {
 	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err interface{}) {
 		// 获取请求ID
-		requestID, exists := c.Get("requestID")
-		requestIDStr := ""
-		if exists {
-			requestIDStr = requestID.(string)
-		}
+		requestIDStr := GetRequestID(c)
 
 		// 记录panic日志
 		logger.Error("Panic recovered",
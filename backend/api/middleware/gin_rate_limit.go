@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/pkg/ratelimit"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// rateLimitKey 按策略的限流维度确定请求的限流键
+//
+// 按用户或API密钥限流的策略需放在认证中间件之后，未认证的请求退回按IP限流。
+func rateLimitKey(c *gin.Context, keyBy string) string {
+	if keyBy != ratelimit.KeyByIP {
+		if principal, ok := GetPrincipal(c); ok {
+			if keyBy == ratelimit.KeyByAPIKey && principal.IsAPIKey() {
+				return "api_key:" + strconv.FormatInt(principal.APIKeyID, 10)
+			}
+			return "user:" + principal.UserID
+		}
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// ceilSeconds 时长向上取整为秒，用于响应头
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit 按路由组策略限流的中间件，响应中附带RateLimit-*头，被限流时返回429和Retry-After
+//
+// limiter为nil或路由组未配置策略时不限流；限流存储出错时放行请求，避免存储故障导致接口不可用。
+func RateLimit(limiter *ratelimit.Limiter, group string, logger *zap.Logger) gin.HandlerFunc {
+	if limiter == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	policy, ok := limiter.Policy(group)
+	if !ok {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	return func(c *gin.Context) {
+		result, err := limiter.Allow(c.Request.Context(), policy, rateLimitKey(c, policy.KeyBy))
+		if err != nil {
+			logger.Error("限流检查失败", zap.Error(err), zap.String("policy", policy.Name))
+			c.Next()
+			return
+		}
+
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", ceilSeconds(result.Reset))
+		c.Header("RateLimit-Policy", strconv.Itoa(policy.Burst)+";w="+ceilSeconds(policy.FillDuration()))
+
+		if !result.Allowed {
+			c.Header("Retry-After", ceilSeconds(result.RetryAfter))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"status":  "error",
+				"code":    "rate_limited",
+				"message": "请求过于频繁，请稍后再试",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission 校验当前用户角色是否拥有指定权限的中间件，需在GinAuthMiddleware之后使用
+//
+// 角色取自认证时从数据库加载的Principal，修改角色后无需重新登录即可生效。
+func RequirePermission(permission models.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的用户身份",
+			})
+			c.Abort()
+			return
+		}
+
+		if !models.HasPermission(principal.Role, permission) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"message": "权限不足",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireMFA 要求指定角色通过两步验证登录的中间件，需在GinAuthMiddleware之后使用
+//
+// 角色不在requiredRoles中时直接放行；否则要求当前访问令牌是在通过两步验证后签发的，
+// 尚未启用两步验证的用户需先完成绑定并重新登录。
+func RequireMFA(requiredRoles []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(requiredRoles) == 0 {
+			c.Next()
+			return
+		}
+
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的用户身份",
+			})
+			c.Abort()
+			return
+		}
+
+		required := false
+		for _, r := range requiredRoles {
+			if r == principal.Role {
+				required = true
+				break
+			}
+		}
+
+		if required && !principal.MFAVerified {
+			c.JSON(http.StatusForbidden, gin.H{
+				"status":  "error",
+				"code":    "mfa_required",
+				"message": "当前角色需启用两步验证，并通过两步验证登录后才能进行此操作",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// WorkspaceHeader 指定当前工作空间的请求头，值为组织ID，为空或personal表示个人空间
+const WorkspaceHeader = "X-Workspace-ID"
+
+// ResolveWorkspace 根据请求头确定当前工作空间的中间件，需在GinAuthMiddleware之后使用
+//
+// 指定组织时校验当前用户是否为组织成员，并将成员角色写入Principal.Workspace。
+func ResolveWorkspace(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := GetPrincipal(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "无效的用户身份",
+			})
+			c.Abort()
+			return
+		}
+
+		value := strings.TrimSpace(c.GetHeader(WorkspaceHeader))
+		if value == "" || value == "personal" {
+			c.Next()
+			return
+		}
+
+		orgID, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || orgID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "无效的工作空间",
+			})
+			c.Abort()
+			return
+		}
+
+		member, err := models.GetOrganizationMember(db, orgID, principal.UserID)
+		if err != nil {
+			if errors.Is(err, models.ErrNotOrganizationMember) {
+				c.JSON(http.StatusForbidden, gin.H{
+					"status":  "error",
+					"code":    "not_workspace_member",
+					"message": err.Error(),
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"status":  "error",
+					"message": "获取工作空间失败",
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		principal.Workspace = models.Workspace{
+			UserID:         principal.UserID,
+			OrganizationID: &member.OrganizationID,
+			Role:           member.Role,
+		}
+
+		c.Next()
+	}
+}
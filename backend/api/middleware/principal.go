@@ -0,0 +1,93 @@
+package middleware
+
+import (
+	"errors"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// principalKey 当前请求主体在上下文中的键
+const principalKey = "principal"
+
+// Principal 当前请求的身份主体，由GinAuthMiddleware在认证通过后设置
+//
+// ID为users表主键，UserID为对外公开的用户ID（USER_...），业务表中的user_id列均保存UserID。
+type Principal struct {
+	ID             int64
+	UserID         string
+	Username       string
+	Role           string
+	AuthMethod     string               // 认证方式：jwt或api_key
+	Scopes         []models.APIKeyScope // API密钥的权限范围，JWT认证时为空
+	APIKeyID       int64                // 仅API密钥认证时有值
+	TokenID        string               // 访问令牌jti，仅JWT认证时有值
+	TokenExpiresAt time.Time            // 访问令牌过期时间，仅JWT认证时有值
+	MFAVerified    bool                 // 本次登录是否通过了两步验证
+	Workspace      models.Workspace     // 当前操作的工作空间，默认为个人空间，见ResolveWorkspace
+
+	tokenGeneration int // 用户当前的令牌代数，与访问令牌中的gen不一致时令牌已被整体吊销
+}
+
+// IsAPIKey 是否通过API密钥认证
+func (p *Principal) IsAPIKey() bool {
+	return p.AuthMethod == AuthMethodAPIKey
+}
+
+// HasScope 检查主体是否拥有指定权限范围，JWT认证的主体拥有全部权限范围
+func (p *Principal) HasScope(scope models.APIKeyScope) bool {
+	if !p.IsAPIKey() {
+		return true
+	}
+	for _, s := range p.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// errPrincipalNotFound 令牌或密钥对应的用户不存在
+var errPrincipalNotFound = errors.New("用户不存在")
+
+// loadPrincipal 根据公开用户ID从数据库加载主体的基础信息
+func loadPrincipal(db *gorm.DB, userID string) (*Principal, error) {
+	user, err := models.GetUserByID(db, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errPrincipalNotFound
+		}
+		return nil, err
+	}
+
+	role := user.Role
+	if role == "" {
+		role = models.RoleUser
+	}
+	return &Principal{
+		ID:        user.ID,
+		UserID:    user.UserID,
+		Username:  user.Username,
+		Role:      role,
+		Workspace: models.PersonalWorkspace(user.UserID),
+
+		tokenGeneration: user.TokenGeneration,
+	}, nil
+}
+
+// SetPrincipal 将主体设置到请求上下文
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// GetPrincipal 获取当前请求的主体，未认证时返回false
+func GetPrincipal(c *gin.Context) (*Principal, bool) {
+	v, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	p, ok := v.(*Principal)
+	return p, ok && p != nil
+}
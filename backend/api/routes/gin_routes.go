@@ -7,6 +7,14 @@ import (
 	"github.com/alexfaker/jilang-agent/api/handlers"
 	"github.com/alexfaker/jilang-agent/api/middleware"
 	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/loginguard"
+	"github.com/alexfaker/jilang-agent/pkg/mailer"
+	"github.com/alexfaker/jilang-agent/pkg/oidc"
+	"github.com/alexfaker/jilang-agent/pkg/payment"
+	"github.com/alexfaker/jilang-agent/pkg/quota"
+	"github.com/alexfaker/jilang-agent/pkg/ratelimit"
+	"github.com/alexfaker/jilang-agent/pkg/search"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -14,10 +22,15 @@ import (
 )
 
 // InitGinRoutes 初始化Gin路由
-func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Engine {
+func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config, payments payment.Provider, mail mailer.Mailer, loginGuard *loginguard.Guard, oidcProviders *oidc.Registry, rateLimiter *ratelimit.Limiter, searchEngine search.Engine) *gin.Engine {
 	// 创建Gin引擎
 	r := gin.New()
 
+	// 只信任配置的反向代理，否则客户端可以伪造X-Forwarded-For绕过按IP的限流和登录保护
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Fatal("可信代理配置无效", zap.Error(err))
+	}
+
 	// 使用Gin的Recovery中间件
 	r.Use(gin.Recovery())
 
@@ -32,7 +45,7 @@ func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Eng
 		AllowOrigins:     cfg.Server.Cors.AllowedOrigins,
 		AllowMethods:     cfg.Server.Cors.AllowedMethods,
 		AllowHeaders:     cfg.Server.Cors.AllowedHeaders,
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -44,16 +57,29 @@ func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Eng
 	}
 
 	// 创建处理程序实例
-	authHandler := handlers.NewGinAuthHandler(db, logger, cfg.Auth)
-	userHandler := handlers.NewGinUserHandler(db, logger)
-	workflowHandler := handlers.NewGinWorkflowHandler(db, logger)
-	executionHandler := handlers.NewGinExecutionHandler(db, logger)
-	agentHandler := handlers.NewGinAgentHandler(db, logger)
+	quotas := quota.New(db, cfg.Quota)
+
+	authHandler := handlers.NewGinAuthHandler(db, logger, cfg.Auth, mail, loginGuard)
+	userHandler := handlers.NewGinUserHandler(db, logger, quotas)
+	workflowHandler := handlers.NewGinWorkflowHandler(db, logger, quotas)
+	executionHandler := handlers.NewGinExecutionHandler(db, logger, quotas, cfg.Trial)
+	agentHandler := handlers.NewGinAgentHandler(db, logger, searchEngine)
 	statsHandler := handlers.NewGinStatsHandler(db, logger)
-	purchaseHandler := handlers.NewGinPurchaseHandler(db, logger)
-	rechargeHandler := handlers.NewGinRechargeHandler(db, logger)
+	purchaseHandler := handlers.NewGinPurchaseHandler(db, logger, cfg.Marketplace)
+	rechargeHandler := handlers.NewGinRechargeHandler(db, logger, payments, cfg.Invoice)
 	pointsHandler := handlers.NewGinPointsHandler(db, logger)
-	settingsHandler := handlers.NewGinSettingsHandler(db, logger)
+	settingsHandler := handlers.NewGinSettingsHandler(db, logger, quotas)
+	couponHandler := handlers.NewGinCouponHandler(db, logger, purchaseHandler)
+	subscriptionHandler := handlers.NewGinSubscriptionHandler(db, logger, payments)
+	mfaHandler := handlers.NewGinMFAHandler(db, logger, cfg.Auth)
+	oidcHandler := handlers.NewGinOIDCHandler(db, logger, cfg.Auth, oidcProviders, authHandler)
+	apiKeyHandler := handlers.NewGinAPIKeyHandler(db, logger)
+	organizationHandler := handlers.NewGinOrganizationHandler(db, logger, cfg.Auth, mail)
+	quotaHandler := handlers.NewGinQuotaHandler(db, logger, quotas)
+	auditHandler := handlers.NewGinAuditHandler(db, logger)
+	reviewHandler := handlers.NewGinReviewHandler(db, logger)
+	creatorHandler := handlers.NewGinCreatorHandler(db, logger, cfg.Marketplace)
+	catalogHandler := handlers.NewGinCatalogHandler(db, logger, searchEngine)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
@@ -68,20 +94,60 @@ func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Eng
 	// API路由组
 	api := r.Group("/api")
 	{
-		// 公开路由
-		api.POST("/auth/register", authHandler.Register)
-		api.POST("/auth/login", authHandler.Login)
-		api.POST("/auth/refresh", authHandler.RefreshToken)
+		// 公开的认证路由，按IP限流
+		authRoutes := api.Group("")
+		authRoutes.Use(middleware.RateLimit(rateLimiter, config.RateLimitGroupAuth, logger))
+		{
+			authRoutes.POST("/auth/register", authHandler.Register)
+			authRoutes.POST("/auth/login", authHandler.Login)
+			authRoutes.POST("/auth/login/mfa", authHandler.LoginMFA)
+			authRoutes.POST("/auth/refresh", authHandler.RefreshToken)
+			authRoutes.POST("/auth/verify-email", authHandler.VerifyEmail)
+			authRoutes.POST("/auth/forgot-password", authHandler.ForgotPassword)
+			authRoutes.POST("/auth/reset-password", authHandler.ResetPassword)
+
+			// 第三方登录
+			authRoutes.GET("/auth/oidc/providers", oidcHandler.GetProviders)         // 获取可用的第三方登录方式
+			authRoutes.POST("/auth/oidc/:provider/authorize", oidcHandler.Authorize) // 发起第三方登录
+			authRoutes.POST("/auth/oidc/:provider/callback", oidcHandler.Callback)   // 第三方授权回调（登录或绑定）
+		}
+
+		// 其他公开路由，按IP限流
+		public := api.Group("")
+		public.Use(middleware.RateLimit(rateLimiter, config.RateLimitGroupPublic, logger))
+		{
+			// 工作流商店 - 公开的代理列表
+			public.GET("/agents", agentHandler.GetAgents)                     // 搜索公开代理，返回分面统计
+			public.GET("/agents/:id", agentHandler.GetAgent)                  // 获取代理详情
+			public.GET("/agents/:id/versions", agentHandler.GetAgentVersions) // 获取代理版本历史
+			public.GET("/agents/:id/reviews", reviewHandler.GetAgentReviews)  // 获取代理评价及评分概况
+			public.GET("/agent-categories", agentHandler.GetAgentCategories)  // 获取代理分类
+			public.GET("/agents/featured", catalogHandler.GetFeaturedAgents)  // 获取推荐代理
 
-		// 工作流商店 - 公开的代理列表
-		api.GET("/agents", agentHandler.GetAgents)                    // 获取公开代理列表
-		api.GET("/agents/:id", agentHandler.GetAgent)                 // 获取代理详情
-		api.GET("/agent-categories", agentHandler.GetAgentCategories) // 获取代理分类
+			// 标签和合集
+			public.GET("/agent-tags", catalogHandler.GetTags)                       // 获取热门标签，支持前缀补全
+			public.GET("/agent-tags/suggestions", catalogHandler.GetTagSuggestions) // 创建代理时推荐标签
+			public.GET("/agent-tags/:name/agents", catalogHandler.GetTagAgents)     // 按标签浏览代理
+			public.GET("/collections", catalogHandler.GetCollections)               // 获取已发布的合集
+			public.GET("/collections/:id", catalogHandler.GetCollection)            // 获取合集及其代理（ID或slug）
+			public.GET("/bundles", catalogHandler.GetBundles)                       // 获取公开的套餐
+			public.GET("/bundles/:id", catalogHandler.GetBundle)                    // 获取套餐及其代理
+
+			// 订阅套餐列表
+			public.GET("/subscription/plans", subscriptionHandler.GetSubscriptionPlans)
+		}
 
 		// 需要认证的路由
 		authorized := api.Group("")
-		authorized.Use(middleware.GinAuthMiddleware(cfg.Auth.JWTSecret))
+		authorized.Use(middleware.GinAuthMiddleware(cfg.Auth.JWTSecret, db))
+		authorized.Use(middleware.ResolveWorkspace(db)) // 根据X-Workspace-ID切换到组织工作空间
+		authorized.Use(middleware.RateLimit(rateLimiter, config.RateLimitGroupAPI, logger))
 		{
+			// 退出登录
+			authorized.POST("/auth/logout", authHandler.Logout)                          // 退出当前会话
+			authorized.POST("/auth/logout-all", authHandler.LogoutAll)                   // 退出全部设备
+			authorized.POST("/auth/resend-verification", authHandler.ResendVerification) // 重新发送验证邮件
+
 			// 用户相关
 			authorized.GET("/user/profile", userHandler.GetUserProfile)
 			authorized.PUT("/user/profile", userHandler.UpdateUserProfile)
@@ -94,31 +160,105 @@ func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Eng
 			authorized.POST("/users/me/avatar", userHandler.UploadAvatar)
 			authorized.PUT("/users/me/password", userHandler.ChangePassword)
 
+			// 两步验证
+			authorized.GET("/user/mfa", mfaHandler.GetMFAStatus)                            // 获取两步验证状态
+			authorized.POST("/user/mfa/enroll", mfaHandler.EnrollMFA)                       // 开始绑定，返回密钥和配置URI
+			authorized.POST("/user/mfa/enable", mfaHandler.EnableMFA)                       // 提交验证码确认绑定
+			authorized.POST("/user/mfa/disable", mfaHandler.DisableMFA)                     // 停用两步验证
+			authorized.POST("/user/mfa/recovery-codes", mfaHandler.RegenerateRecoveryCodes) // 重新生成恢复码
+
+			// 第三方账号绑定
+			authorized.GET("/user/identities", oidcHandler.GetIdentities)                // 获取已绑定的第三方账号
+			authorized.POST("/user/identities/:provider/link", oidcHandler.LinkIdentity) // 发起绑定，返回授权地址
+			authorized.DELETE("/user/identities/:id", oidcHandler.UnlinkIdentity)        // 解除绑定
+
+			// 个人API密钥（API密钥可访问的接口见 middleware.apiKeyRouteScopes）
+			authorized.GET("/user/api-keys", apiKeyHandler.GetAPIKeys)             // 获取API密钥列表
+			authorized.GET("/user/api-keys/scopes", apiKeyHandler.GetAPIKeyScopes) // 获取可用的权限范围
+			authorized.POST("/user/api-keys", apiKeyHandler.CreateAPIKey)          // 创建API密钥
+			authorized.DELETE("/user/api-keys/:id", apiKeyHandler.RevokeAPIKey)    // 吊销API密钥
+
+			// 组织相关（组织接口按路径中的组织ID校验成员身份，不受X-Workspace-ID影响）
+			authorized.GET("/organizations", organizationHandler.GetOrganizations)                                                                     // 获取所在组织列表
+			authorized.POST("/organizations", organizationHandler.CreateOrganization)                                                                  // 创建组织
+			authorized.GET("/organizations/:id", organizationHandler.GetOrganization)                                                                  // 获取组织详情
+			authorized.PUT("/organizations/:id", organizationHandler.UpdateOrganization)                                                               // 修改组织名称
+			authorized.GET("/organizations/:id/members", organizationHandler.GetOrganizationMembers)                                                   // 获取成员列表
+			authorized.PUT("/organizations/:id/members/:userId", organizationHandler.UpdateOrganizationMember)                                         // 修改成员角色
+			authorized.DELETE("/organizations/:id/members/:userId", organizationHandler.RemoveOrganizationMember)                                      // 移除成员或退出组织
+			authorized.GET("/organizations/:id/invitations", organizationHandler.GetOrganizationInvitations)                                           // 获取待处理邀请
+			authorized.POST("/organizations/:id/invitations", organizationHandler.CreateOrganizationInvitation)                                        // 邀请成员
+			authorized.DELETE("/organizations/:id/invitations/:invitationId", organizationHandler.RevokeOrganizationInvitation)                        // 撤销邀请
+			authorized.POST("/organization-invitations/accept", middleware.RequireVerifiedEmail(db), organizationHandler.AcceptOrganizationInvitation) // 接受邀请（需验证邮箱）
+
 			// 设置相关
 			authorized.GET("/settings", settingsHandler.GetSettings)
 			authorized.PUT("/settings", settingsHandler.UpdateSettings)
 
 			// 用户工作流相关 - 用户购买的工作流实例
 			authorized.GET("/workflows", workflowHandler.GetWorkflows)
+			authorized.GET("/workflows/trash", workflowHandler.GetDeletedWorkflows) // 回收站
 			authorized.POST("/workflows", workflowHandler.CreateWorkflow)
 			authorized.GET("/workflows/:id", workflowHandler.GetWorkflow)
 			authorized.PUT("/workflows/:id", workflowHandler.UpdateWorkflow)
 			authorized.DELETE("/workflows/:id", workflowHandler.DeleteWorkflow)
+			authorized.GET("/workflows/:id/upgrade", workflowHandler.GetWorkflowUpgrade)     // 预览升级到代理最新版本
+			authorized.POST("/workflows/:id/upgrade", workflowHandler.UpgradeWorkflow)       // 升级到代理最新版本
+			authorized.POST("/workflows/:id/restore", workflowHandler.RestoreWorkflow)       // 从回收站恢复
+			authorized.POST("/workflows/:id/clone", workflowHandler.CloneWorkflow)           // 克隆工作流
+			authorized.POST("/workflows/:id/template", workflowHandler.SaveWorkflowTemplate) // 保存为个人模板
+
+			// 个人工作流模板
+			authorized.GET("/workflow-templates", workflowHandler.GetWorkflowTemplates)
+			authorized.GET("/workflow-templates/:id", workflowHandler.GetWorkflowTemplate)
+			authorized.DELETE("/workflow-templates/:id", workflowHandler.DeleteWorkflowTemplate)
+			authorized.POST("/workflow-templates/:id/workflows", workflowHandler.CreateWorkflowFromTemplate) // 从模板创建工作流
 
 			// 执行相关
 			authorized.GET("/executions", executionHandler.GetExecutions)
 			authorized.POST("/workflows/:id/execute", executionHandler.ExecuteWorkflow)
 			authorized.GET("/executions/:id", executionHandler.GetExecution)
 			authorized.DELETE("/executions/:id", executionHandler.DeleteExecution)
+			authorized.POST("/executions/:id/cancel", executionHandler.CancelExecution)
+			authorized.GET("/executions/trash", executionHandler.GetDeletedExecutions)    // 回收站
+			authorized.POST("/executions/:id/restore", executionHandler.RestoreExecution) // 从回收站恢复
+
+			// 付费代理免费试用
+			authorized.GET("/agents/:id/trial", executionHandler.GetAgentTrial)                                         // 获取试用次数
+			authorized.POST("/agents/:id/trial", middleware.RequireVerifiedEmail(db), executionHandler.StartAgentTrial) // 试用代理（需验证邮箱）
+			authorized.GET("/agent-trials", executionHandler.GetAgentTrialRuns)                                         // 获取试用记录
+			authorized.GET("/agent-trials/:id", executionHandler.GetAgentTrialRun)                                      // 获取试用结果
+
+			// 评价相关
+			authorized.POST("/agents/:id/reviews", reviewHandler.CreateAgentReview)    // 发表评价（仅购买者）
+			authorized.PUT("/reviews/:id", reviewHandler.UpdateReview)                 // 修改自己的评价
+			authorized.DELETE("/reviews/:id", reviewHandler.DeleteReview)              // 删除自己的评价
+			authorized.PUT("/reviews/:id/reply", reviewHandler.ReplyReview)            // 创作者回复
+			authorized.DELETE("/reviews/:id/reply", reviewHandler.DeleteReviewReply)   // 删除创作者回复
+			authorized.POST("/reviews/:id/helpful", reviewHandler.AddHelpfulVote)      // 标记有帮助
+			authorized.DELETE("/reviews/:id/helpful", reviewHandler.RemoveHelpfulVote) // 取消有帮助标记
+			authorized.POST("/reviews/:id/flag", reviewHandler.FlagReview)             // 举报评价
 
 			// 购买相关
-			authorized.POST("/purchase/agent", purchaseHandler.PurchaseAgent)       // 购买代理
-			authorized.GET("/purchase/history", purchaseHandler.GetPurchaseHistory) // 购买历史
+			authorized.POST("/purchase/agent", middleware.RequireVerifiedEmail(db), purchaseHandler.PurchaseAgent)                 // 购买代理（需验证邮箱）
+			authorized.POST("/purchase/bundle", middleware.RequireVerifiedEmail(db), purchaseHandler.PurchaseBundle)               // 购买套餐（需验证邮箱）
+			authorized.GET("/bundles/:id/quote", purchaseHandler.GetBundleQuote)                                                   // 获取当前工作空间购买套餐的报价
+			authorized.POST("/agent-trials/:id/purchase", middleware.RequireVerifiedEmail(db), purchaseHandler.PurchaseTrialAgent) // 试用后一键购买（需验证邮箱）
+			authorized.GET("/purchase/history", purchaseHandler.GetPurchaseHistory)                                                // 购买历史
 
 			// 充值相关
-			authorized.POST("/recharge", rechargeHandler.CreateRecharge)              // 创建充值订单
-			authorized.GET("/recharge/history", rechargeHandler.GetRechargeHistory)   // 获取充值历史
-			authorized.GET("/recharge/:id/status", rechargeHandler.GetRechargeStatus) // 获取充值状态
+			authorized.POST("/recharge", middleware.RequireVerifiedEmail(db), rechargeHandler.CreateRecharge) // 创建充值订单（需验证邮箱）
+			authorized.GET("/recharge/history", rechargeHandler.GetRechargeHistory)                           // 获取充值历史
+			authorized.GET("/recharge/:id/status", rechargeHandler.GetRechargeStatus)                         // 获取充值状态
+			authorized.GET("/recharge/:id/invoice", rechargeHandler.GetRechargeInvoice)                       // 下载充值发票
+
+			// 订阅相关
+			authorized.GET("/subscription", subscriptionHandler.GetCurrentSubscription)                          // 获取当前订阅
+			authorized.POST("/subscription", middleware.RequireVerifiedEmail(db), subscriptionHandler.Subscribe) // 订阅套餐（需验证邮箱）
+			authorized.POST("/subscription/cancel", subscriptionHandler.CancelSubscription)                      // 取消订阅
+
+			// 优惠码相关
+			authorized.POST("/coupons/redeem", couponHandler.RedeemCoupon) // 兑换优惠码
 
 			// 点数相关
 			authorized.GET("/points/balance", pointsHandler.GetPointsBalance)              // 获取点数余额
@@ -126,10 +266,82 @@ func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Eng
 			authorized.GET("/points/transactions/:id", pointsHandler.GetPointsTransaction) // 获取交易详情
 			authorized.GET("/points/statistics", pointsHandler.GetPointsStatistics)        // 获取统计信息
 
-			// 代理管理（管理员或高级用户）
-			authorized.POST("/admin/agents", agentHandler.CreateAgent)       // 创建代理（管理员）
-			authorized.PUT("/admin/agents/:id", agentHandler.UpdateAgent)    // 更新代理（管理员）
-			authorized.DELETE("/admin/agents/:id", agentHandler.DeleteAgent) // 删除代理（管理员）
+			// 创作者相关，上架的代理须经管理员审核后才能发布
+			creator := authorized.Group("/creator")
+			creator.Use(middleware.RequirePermission(models.PermissionAgentPublish))
+			{
+				creator.GET("/agents", creatorHandler.GetCreatorAgents)                 // 获取自己的代理
+				creator.POST("/agents", creatorHandler.CreateCreatorAgent)              // 创建代理草稿
+				creator.PUT("/agents/:id", creatorHandler.UpdateCreatorAgent)           // 修改草稿或被拒绝的代理
+				creator.DELETE("/agents/:id", creatorHandler.DeleteCreatorAgent)        // 删除草稿或被拒绝的代理
+				creator.POST("/agents/:id/submit", creatorHandler.SubmitCreatorAgent)   // 提交审核
+				creator.POST("/agents/:id/publish", creatorHandler.PublishCreatorAgent) // 发布审核通过的代理
+				creator.GET("/wallet", creatorHandler.GetCreatorWallet)                 // 获取收益钱包
+				creator.GET("/earnings", creatorHandler.GetCreatorEarnings)             // 获取销售分成记录
+				creator.GET("/payouts", creatorHandler.GetCreatorPayouts)               // 获取提现申请
+				creator.POST("/payouts", creatorHandler.RequestPayout)                  // 申请提现
+			}
+
+			// 管理后台路由，按权限逐一校验，指定角色须通过两步验证登录
+			admin := authorized.Group("/admin")
+			admin.Use(middleware.RequireMFA(cfg.Auth.MFARequiredRoles))
+			{
+				// 代理管理
+				admin.POST("/agents", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.CreateAgent)                // 创建代理
+				admin.PUT("/agents/:id", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.UpdateAgent)             // 更新代理
+				admin.DELETE("/agents/:id", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.DeleteAgent)          // 删除代理
+				admin.PUT("/agents/:id/featured", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.FeatureAgent) // 设置推荐位
+				admin.PUT("/agents/:id/sale", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.SetAgentSale)     // 设置限时特价
+				admin.GET("/agents/:id/export", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.ExportAgent)      // 导出代理包
+				admin.POST("/agents/import", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.ImportAgent)         // 导入代理包
+				admin.GET("/agents/trash", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.GetDeletedAgents)      // 代理回收站
+				admin.POST("/agents/:id/restore", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.RestoreAgent)   // 从回收站恢复代理
+				admin.PUT("/agents/:id/listing", middleware.RequirePermission(models.PermissionAgentManage), agentHandler.SetAgentListing) // 上架或下架代理
+
+				// 合集管理
+				admin.GET("/collections", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.GetAdminCollections)     // 获取全部合集
+				admin.GET("/collections/:id", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.GetAdminCollection)  // 获取合集详情
+				admin.POST("/collections", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.CreateCollection)       // 创建合集
+				admin.PUT("/collections/:id", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.UpdateCollection)    // 修改合集及其代理顺序
+				admin.DELETE("/collections/:id", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.DeleteCollection) // 删除合集
+
+				// 套餐管理
+				admin.GET("/bundles", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.GetAdminBundles)     // 获取全部套餐
+				admin.GET("/bundles/:id", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.GetAdminBundle)  // 获取套餐详情
+				admin.POST("/bundles", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.CreateBundle)       // 创建套餐
+				admin.PUT("/bundles/:id", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.UpdateBundle)    // 修改套餐及其代理
+				admin.DELETE("/bundles/:id", middleware.RequirePermission(models.PermissionAgentManage), catalogHandler.DeleteBundle) // 删除套餐
+
+				// 创作者代理审核
+				admin.GET("/agent-submissions", middleware.RequirePermission(models.PermissionAgentManage), creatorHandler.GetAgentSubmissions) // 获取审核队列
+				admin.POST("/agents/:id/approve", middleware.RequirePermission(models.PermissionAgentManage), creatorHandler.ApproveAgent)      // 审核通过
+				admin.POST("/agents/:id/reject", middleware.RequirePermission(models.PermissionAgentManage), creatorHandler.RejectAgent)        // 审核拒绝
+
+				// 创作者提现
+				admin.GET("/payouts", middleware.RequirePermission(models.PermissionPayoutManage), creatorHandler.GetPayouts)        // 获取提现申请
+				admin.PUT("/payouts/:id", middleware.RequirePermission(models.PermissionPayoutManage), creatorHandler.ProcessPayout) // 审核、拒绝或标记已打款
+
+				// 优惠码管理
+				admin.POST("/coupons/batch", middleware.RequirePermission(models.PermissionCouponManage), couponHandler.CreateCouponBatch)      // 批量生成优惠码
+				admin.GET("/coupons", middleware.RequirePermission(models.PermissionCouponManage), couponHandler.GetCoupons)                    // 获取优惠码列表
+				admin.GET("/coupons/export", middleware.RequirePermission(models.PermissionCouponManage), couponHandler.ExportCoupons)          // 导出优惠码CSV
+				admin.PUT("/coupons/:id/status", middleware.RequirePermission(models.PermissionCouponManage), couponHandler.UpdateCouponStatus) // 启用/停用优惠码
+
+				// 用户管理
+				admin.PUT("/users/:userId/role", middleware.RequirePermission(models.PermissionUserManage), userHandler.UpdateUserRole) // 修改用户角色
+
+				// 评价审核
+				admin.GET("/reviews/flagged", middleware.RequirePermission(models.PermissionReviewModerate), reviewHandler.GetFlaggedReviews)     // 获取被举报的评价
+				admin.GET("/reviews/:id/flags", middleware.RequirePermission(models.PermissionReviewModerate), reviewHandler.GetReviewFlags)      // 获取评价的举报记录
+				admin.PUT("/reviews/:id/moderation", middleware.RequirePermission(models.PermissionReviewModerate), reviewHandler.ModerateReview) // 隐藏或恢复评价
+
+				// 审计日志
+				admin.GET("/audit-events", middleware.RequirePermission(models.PermissionAuditView), auditHandler.GetAuditEvents)           // 查询审计事件
+				admin.GET("/audit-events/export", middleware.RequirePermission(models.PermissionAuditView), auditHandler.ExportAuditEvents) // 导出审计事件CSV
+			}
+
+			// 配额相关
+			authorized.GET("/quotas", quotaHandler.GetQuotas) // 获取当前工作空间的配额及使用情况
 
 			// 统计相关
 			authorized.GET("/stats/dashboard", statsHandler.GetDashboardStats)
@@ -138,7 +350,7 @@ func InitGinRoutes(db *gorm.DB, logger *zap.Logger, cfg *config.Config) *gin.Eng
 		}
 
 		// 支付回调（不需要认证，由支付网关调用）
-		api.POST("/payment/callback/:orderNo", rechargeHandler.ProcessPaymentCallback) // 支付回调
+		api.POST("/payment/callback/:orderNo", middleware.RateLimit(rateLimiter, config.RateLimitGroupPaymentCallback, logger), rechargeHandler.ProcessPaymentCallback) // 支付回调
 	}
 
 	return r
@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/database"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const testJWTSecret = "routes-test-secret"
+
+// routeParam 路由中的路径参数，测试时统一替换为1
+var routeParam = regexp.MustCompile(`:[A-Za-z]+`)
+
+// newTestRouter 使用临时SQLite数据库初始化完整路由，管理后台要求admin和finance角色通过两步验证
+func newTestRouter(t *testing.T) (*gin.Engine, *gorm.DB) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	// 部分依赖为空，处理程序内的panic由Recovery转为500，不输出堆栈
+	gin.DefaultErrorWriter = io.Discard
+
+	dsn := filepath.Join(t.TempDir(), "routes.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+
+	cfg := &config.Config{}
+	cfg.Server.Cors.AllowedOrigins = []string{"*"}
+	cfg.Auth.JWTSecret = testJWTSecret
+	cfg.Auth.MFARequiredRoles = []string{models.RoleAdmin, models.RoleFinance}
+	return InitGinRoutes(db, zap.NewNop(), cfg, nil, nil, nil, nil, nil, nil), db
+}
+
+// createTestUser 创建指定角色的用户，返回签发的访问令牌
+func createTestUser(t *testing.T, db *gorm.DB, role string, mfa bool) string {
+	t.Helper()
+	name := role + "_" + uuid.NewString()[:8]
+	user := models.User{
+		UserID:       "USER_" + name,
+		Username:     name,
+		Email:        name + "@example.com",
+		PasswordHash: "x",
+		Role:         role,
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("创建测试用户失败: %v", err)
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  user.UserID,
+		"username": user.Username,
+		"role":     user.Role,
+		"typ":      "access",
+		"jti":      uuid.NewString(),
+		"iat":      now.Add(-time.Minute).Unix(),
+		"exp":      now.Add(time.Hour).Unix(),
+		"gen":      user.TokenGeneration,
+		"mfa":      mfa,
+	})
+	signed, err := token.SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("签发测试令牌失败: %v", err)
+	}
+	return signed
+}
+
+// adminRoutes 路由表中的全部管理后台路由
+func adminRoutes(t *testing.T, r *gin.Engine) gin.RoutesInfo {
+	t.Helper()
+	var routes gin.RoutesInfo
+	for _, route := range r.Routes() {
+		if strings.HasPrefix(route.Path, "/api/admin/") {
+			routes = append(routes, route)
+		}
+	}
+	if len(routes) == 0 {
+		t.Fatal("路由表中没有管理后台路由")
+	}
+	return routes
+}
+
+// callRoute 使用访问令牌请求路由，返回状态码和响应中的code
+func callRoute(r *gin.Engine, route gin.RouteInfo, token string) (int, string) {
+	path := routeParam.ReplaceAllString(route.Path, "1")
+	req := httptest.NewRequest(route.Method, path, strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body struct {
+		Code string `json:"code"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body.Code
+}
+
+func TestAdminRoutesRequirePermission(t *testing.T) {
+	r, db := newTestRouter(t)
+	token := createTestUser(t, db, models.RoleUser, true)
+
+	for _, route := range adminRoutes(t, r) {
+		if status, _ := callRoute(r, route, token); status != http.StatusForbidden {
+			t.Errorf("%s %s: 普通用户应返回403，实际为%d", route.Method, route.Path, status)
+		}
+	}
+}
+
+func TestAdminRoutesAllowAdmin(t *testing.T) {
+	r, db := newTestRouter(t)
+	token := createTestUser(t, db, models.RoleAdmin, true)
+
+	for _, route := range adminRoutes(t, r) {
+		status, _ := callRoute(r, route, token)
+		if status == http.StatusUnauthorized || status == http.StatusForbidden {
+			t.Errorf("%s %s: 管理员应通过权限检查，实际为%d", route.Method, route.Path, status)
+		}
+	}
+}
+
+func TestAdminRoutesRequireMFA(t *testing.T) {
+	r, db := newTestRouter(t)
+	tokens := map[string]string{
+		models.RoleAdmin:   createTestUser(t, db, models.RoleAdmin, false),
+		models.RoleFinance: createTestUser(t, db, models.RoleFinance, false),
+	}
+
+	for role, token := range tokens {
+		for _, route := range adminRoutes(t, r) {
+			status, code := callRoute(r, route, token)
+			if status != http.StatusForbidden || code != "mfa_required" {
+				t.Errorf("%s %s: 未通过两步验证的%s应返回403 mfa_required，实际为%d %q", route.Method, route.Path, role, status, code)
+			}
+		}
+	}
+}
+
+func TestAdminRoutesFinancePermissions(t *testing.T) {
+	r, db := newTestRouter(t)
+	token := createTestUser(t, db, models.RoleFinance, true)
+
+	for _, route := range adminRoutes(t, r) {
+		// 财务只能管理优惠码和处理提现
+		allowed := strings.HasPrefix(route.Path, "/api/admin/coupons") || strings.HasPrefix(route.Path, "/api/admin/payouts")
+		status, _ := callRoute(r, route, token)
+		denied := status == http.StatusUnauthorized || status == http.StatusForbidden
+		if allowed == denied {
+			t.Errorf("%s %s: 财务角色%s，实际为%d", route.Method, route.Path, map[bool]string{true: "应通过", false: "应返回403"}[allowed], status)
+		}
+	}
+}
@@ -5,16 +5,27 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Config 应用程序配置结构
 type Config struct {
-	Environment string         `json:"environment"`
-	Server      ServerConfig   `json:"server"`
-	Database    DatabaseConfig `json:"database"`
-	Auth        AuthConfig     `json:"auth"`
-	Storage     StorageConfig  `json:"storage"`
-	Logging     LoggingConfig  `json:"logging"`
+	Environment  string             `json:"environment"`
+	Server       ServerConfig       `json:"server"`
+	Database     DatabaseConfig     `json:"database"`
+	Auth         AuthConfig         `json:"auth"`
+	Storage      StorageConfig      `json:"storage"`
+	Logging      LoggingConfig      `json:"logging"`
+	Payment      PaymentConfig      `json:"payment"`
+	Subscription SubscriptionConfig `json:"subscription"`
+	Invoice      InvoiceConfig      `json:"invoice"`
+	Mail         MailConfig         `json:"mail"`
+	Quota        QuotaConfig        `json:"quota"`
+	RateLimit    RateLimitConfig    `json:"rateLimit"`
+	Marketplace  MarketplaceConfig  `json:"marketplace"`
+	Search       SearchConfig       `json:"search"`
+	Trial        TrialConfig        `json:"trial"`
+	Retention    RetentionConfig    `json:"retention"`
 }
 
 // ServerConfig 服务器配置
@@ -26,6 +37,8 @@ type ServerConfig struct {
 	Secure      bool          `json:"secure"`      // 是否使用HTTPS
 	ServeStatic bool          `json:"serveStatic"` // 是否提供静态文件服务
 	StaticDir   string        `json:"staticDir"`   // 静态文件目录
+	// 可信反向代理的IP或CIDR，只有来自这些地址的请求才按X-Forwarded-For等头确定客户端IP，为空时不信任任何代理
+	TrustedProxies []string `json:"trustedProxies"`
 }
 
 // TimeoutConfig 服务器超时配置
@@ -61,8 +74,104 @@ type DatabaseConfig struct {
 
 // AuthConfig 认证配置
 type AuthConfig struct {
-	JWTSecret       string `json:"jwtSecret"`
-	TokenExpiration int    `json:"tokenExpiration"` // 小时
+	JWTSecret               string `json:"jwtSecret"`
+	AccessTokenExpiration   int    `json:"accessTokenExpiration"`   // 访问令牌有效期，分钟
+	RefreshTokenExpiration  int    `json:"refreshTokenExpiration"`  // 刷新令牌有效期，天
+	EmailVerifyExpiration   int    `json:"emailVerifyExpiration"`   // 邮箱验证链接有效期，小时
+	PasswordResetExpiration int    `json:"passwordResetExpiration"` // 密码重置链接有效期，分钟
+	FrontendURL             string `json:"frontendUrl"`             // 前端地址，用于生成邮件中的链接
+
+	// 登录防暴力破解
+	MaxLoginFailuresPerAccount int    `json:"maxLoginFailuresPerAccount"` // 统计窗口内单个账号允许的失败次数，超过后锁定
+	MaxLoginFailuresPerIP      int    `json:"maxLoginFailuresPerIP"`      // 统计窗口内单个IP允许的失败次数，超过后锁定
+	LoginFailureWindow         int    `json:"loginFailureWindow"`         // 失败次数统计窗口，分钟
+	LoginLockoutDuration       int    `json:"loginLockoutDuration"`       // 锁定时长，分钟
+	LoginDelayAfter            int    `json:"loginDelayAfter"`            // 连续失败多少次后开始递增等待
+	LoginDelayBase             int    `json:"loginDelayBase"`             // 首次等待时长，秒，之后每次翻倍
+	LoginDelayMax              int    `json:"loginDelayMax"`              // 最长等待时长，秒
+	LoginAttemptStore          string `json:"loginAttemptStore"`          // 失败计数存储：memory（单节点）, database（多副本）
+
+	// 两步验证
+	MFAIssuer              string   `json:"mfaIssuer"`              // 验证器应用中显示的发行方名称
+	MFAChallengeExpiration int      `json:"mfaChallengeExpiration"` // 登录第二步挑战令牌有效期，分钟
+	MFARequiredRoles       []string `json:"mfaRequiredRoles"`       // 必须启用两步验证才能访问管理后台的角色
+
+	// 第三方登录
+	OIDCProviders        []OIDCProviderConfig `json:"oidcProviders"`        // OIDC/OAuth2登录提供方
+	OAuthStateExpiration int                  `json:"oauthStateExpiration"` // 授权请求state有效期，分钟
+
+	// 组织
+	OrgInvitationExpiration int `json:"orgInvitationExpiration"` // 组织邀请链接有效期，小时
+}
+
+// OIDCProviderConfig OIDC/OAuth2登录提供方配置
+//
+// 配置Issuer时通过 /.well-known/openid-configuration 自动发现端点，显式配置的端点优先；
+// GitHub等不支持OIDC的OAuth2提供方需显式配置AuthURL、TokenURL和UserInfoURL。
+type OIDCProviderConfig struct {
+	Name         string   `json:"name"`         // 提供方标识，用于路由，如 google、github
+	DisplayName  string   `json:"displayName"`  // 登录按钮上显示的名称
+	Issuer       string   `json:"issuer"`       // OIDC发行方地址，同时用于校验ID令牌的iss
+	AuthURL      string   `json:"authUrl"`      // 授权端点
+	TokenURL     string   `json:"tokenUrl"`     // 令牌端点
+	UserInfoURL  string   `json:"userInfoUrl"`  // 用户信息端点
+	JWKSURL      string   `json:"jwksUrl"`      // ID令牌签名公钥地址
+	ClientID     string   `json:"clientId"`     // 客户端ID
+	ClientSecret string   `json:"clientSecret"` // 客户端密钥，公共客户端可为空（仅使用PKCE）
+	RedirectURL  string   `json:"redirectUrl"`  // 回调地址，一般为前端回调页
+	Scopes       []string `json:"scopes"`       // 授权范围，默认 openid email profile
+
+	// 用户信息字段映射，默认使用OIDC标准声明
+	SubjectClaim  string `json:"subjectClaim"`  // 默认 sub，GitHub为 id
+	EmailClaim    string `json:"emailClaim"`    // 默认 email
+	UsernameClaim string `json:"usernameClaim"` // 默认 preferred_username，GitHub为 login
+	NameClaim     string `json:"nameClaim"`     // 默认 name
+	AvatarClaim   string `json:"avatarClaim"`   // 默认 picture，GitHub为 avatar_url
+}
+
+// AccessTokenTTL 访问令牌有效期
+func (c AuthConfig) AccessTokenTTL() time.Duration {
+	return time.Duration(c.AccessTokenExpiration) * time.Minute
+}
+
+// RefreshTokenTTL 刷新令牌有效期
+func (c AuthConfig) RefreshTokenTTL() time.Duration {
+	return time.Duration(c.RefreshTokenExpiration) * 24 * time.Hour
+}
+
+// EmailVerifyTTL 邮箱验证链接有效期
+func (c AuthConfig) EmailVerifyTTL() time.Duration {
+	return time.Duration(c.EmailVerifyExpiration) * time.Hour
+}
+
+// PasswordResetTTL 密码重置链接有效期
+func (c AuthConfig) PasswordResetTTL() time.Duration {
+	return time.Duration(c.PasswordResetExpiration) * time.Minute
+}
+
+// MFAChallengeTTL 登录第二步挑战令牌有效期
+func (c AuthConfig) MFAChallengeTTL() time.Duration {
+	return time.Duration(c.MFAChallengeExpiration) * time.Minute
+}
+
+// MFARequiredForRole 指定角色是否必须启用两步验证
+func (c AuthConfig) MFARequiredForRole(role string) bool {
+	for _, r := range c.MFARequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthStateTTL 授权请求state有效期
+func (c AuthConfig) OAuthStateTTL() time.Duration {
+	return time.Duration(c.OAuthStateExpiration) * time.Minute
+}
+
+// OrgInvitationTTL 组织邀请链接有效期
+func (c AuthConfig) OrgInvitationTTL() time.Duration {
+	return time.Duration(c.OrgInvitationExpiration) * time.Hour
 }
 
 // StorageConfig 存储配置
@@ -91,6 +200,120 @@ type LoggingConfig struct {
 	Compress   bool   `json:"compress"`   // 是否压缩
 }
 
+// PaymentConfig 支付配置
+type PaymentConfig struct {
+	Provider       string `json:"provider"`       // 支付提供方：mock
+	PayURL         string `json:"payUrl"`         // 支付跳转地址
+	CallbackSecret string `json:"callbackSecret"` // 支付回调签名密钥，为空时拒绝所有回调
+}
+
+// SubscriptionConfig 订阅配置
+type SubscriptionConfig struct {
+	RenewalCheckInterval int `json:"renewalCheckInterval"` // 续费任务检查间隔，分钟
+	RenewAheadHours      int `json:"renewAheadHours"`      // 到期前多少小时生成续费订单
+	GracePeriodDays      int `json:"gracePeriodDays"`      // 到期后未支付的宽限期，天
+}
+
+// InvoiceConfig 发票配置
+type InvoiceConfig struct {
+	Prefix        string  `json:"prefix"`        // 发票号前缀
+	TaxRate       float64 `json:"taxRate"`       // 税率（百分比），充值金额视为含税价
+	SellerName    string  `json:"sellerName"`    // 销售方名称
+	SellerTaxID   string  `json:"sellerTaxId"`   // 销售方纳税人识别号
+	SellerAddress string  `json:"sellerAddress"` // 销售方地址
+	SellerPhone   string  `json:"sellerPhone"`   // 销售方电话
+}
+
+// QuotaConfig 配额配置，按订阅套餐编码配置，未配置的套餐使用内置默认值
+type QuotaConfig struct {
+	Plans map[string]PlanQuotaConfig `json:"plans"`
+}
+
+// PlanQuotaConfig 单个套餐的配额，0表示不限
+type PlanQuotaConfig struct {
+	MaxConcurrentExecutions int `json:"maxConcurrentExecutions"` // 最大并发执行数
+	MaxExecutionsPerDay     int `json:"maxExecutionsPerDay"`     // 每天最多执行次数
+	MaxWorkflows            int `json:"maxWorkflows"`            // 最多工作流数量
+	MaxExecutionOutputKB    int `json:"maxExecutionOutputKB"`    // 单次执行输出最大保存大小，KB
+	MaxUploadSizeMB         int `json:"maxUploadSizeMB"`         // 单个上传文件最大大小，MB
+}
+
+// defaultPlanQuotas 内置的套餐配额
+var defaultPlanQuotas = map[string]PlanQuotaConfig{
+	"free": {MaxConcurrentExecutions: 2, MaxExecutionsPerDay: 100, MaxWorkflows: 20, MaxExecutionOutputKB: 256, MaxUploadSizeMB: 2},
+	"pro":  {MaxConcurrentExecutions: 20, MaxExecutionsPerDay: 2000, MaxWorkflows: 200, MaxExecutionOutputKB: 1024, MaxUploadSizeMB: 5},
+	"team": {MaxConcurrentExecutions: 50, MaxExecutionsPerDay: 10000, MaxWorkflows: 0, MaxExecutionOutputKB: 4096, MaxUploadSizeMB: 10},
+}
+
+// RateLimitConfig 接口限流配置
+type RateLimitConfig struct {
+	Disabled bool                             `json:"disabled"` // 关闭全部接口限流
+	Store    string                           `json:"store"`    // 令牌桶存储：memory（单节点）, database（多副本）
+	Policies map[string]RateLimitPolicyConfig `json:"policies"` // 按路由组配置的限流策略：auth, public, payment_callback, api
+}
+
+// RateLimitPolicyConfig 令牌桶限流策略
+type RateLimitPolicyConfig struct {
+	RequestsPerMinute int    `json:"requestsPerMinute"` // 每分钟补充的令牌数，即持续请求速率
+	Burst             int    `json:"burst"`             // 桶容量，允许的突发请求数
+	KeyBy             string `json:"keyBy"`             // 限流维度：ip, user, api_key
+}
+
+// 限流路由组
+const (
+	RateLimitGroupAuth            = "auth"             // 登录、注册、找回密码等认证接口
+	RateLimitGroupPublic          = "public"           // 代理列表等无需登录的公开接口
+	RateLimitGroupPaymentCallback = "payment_callback" // 支付回调
+	RateLimitGroupAPI             = "api"              // 需要认证的接口
+)
+
+// defaultRateLimitPolicies 内置的限流策略
+var defaultRateLimitPolicies = map[string]RateLimitPolicyConfig{
+	RateLimitGroupAuth:            {RequestsPerMinute: 20, Burst: 10, KeyBy: "ip"},
+	RateLimitGroupPublic:          {RequestsPerMinute: 120, Burst: 60, KeyBy: "ip"},
+	RateLimitGroupPaymentCallback: {RequestsPerMinute: 60, Burst: 30, KeyBy: "ip"},
+	RateLimitGroupAPI:             {RequestsPerMinute: 300, Burst: 100, KeyBy: "api_key"},
+}
+
+// MarketplaceConfig 创作者市场配置
+type MarketplaceConfig struct {
+	CreatorSharePercent int `json:"creatorSharePercent"` // 创作者从每笔代理销售中获得的分成比例（1-100的百分比），按实付点数计算
+	MinPayoutPoints     int `json:"minPayoutPoints"`     // 单次申请提现的最少点数
+}
+
+// SearchConfig 代理商店搜索配置
+type SearchConfig struct {
+	Backend          string `json:"backend"`          // 搜索后端：database（MySQL ngram全文索引或Postgres tsvector）, like（模糊匹配，不支持相关度排序）
+	TextSearchConfig string `json:"textSearchConfig"` // Postgres全文检索配置名，默认simple；安装zhparser等中文分词扩展后可改为对应配置
+}
+
+// TrialConfig 付费代理免费试用配置
+type TrialConfig struct {
+	Runs           int    `json:"runs"`           // 每个用户对每个付费代理的免费试用次数，0使用默认值，小于0表示关闭试用
+	MaxOutputBytes int    `json:"maxOutputBytes"` // 试用结果保留的最大字节数，超出部分截断
+	Watermark      string `json:"watermark"`      // 附加在试用结果中的水印文字
+}
+
+// RetentionConfig 已删除数据的保留配置
+type RetentionConfig struct {
+	DeletedDays int `json:"deletedDays"` // 已删除的代理、工作流和执行记录保留天数，期间可恢复，到期后彻底删除
+}
+
+// DeletedTTL 已删除数据的保留时长
+func (c RetentionConfig) DeletedTTL() time.Duration {
+	return time.Duration(c.DeletedDays) * 24 * time.Hour
+}
+
+// MailConfig 邮件配置
+type MailConfig struct {
+	Provider     string `json:"provider"` // 邮件提供方：log, smtp, memory
+	From         string `json:"from"`     // 发件人地址
+	SMTPHost     string `json:"smtpHost"`
+	SMTPPort     int    `json:"smtpPort"`
+	SMTPUsername string `json:"smtpUsername"`
+	SMTPPassword string `json:"smtpPassword"`
+}
+
 // LoadConfig 从配置文件加载配置
 func LoadConfig() (*Config, error) {
 	env := os.Getenv("APP_ENV")
@@ -154,6 +377,11 @@ func applyEnvironmentOverrides(config *Config) {
 	if os.Getenv("JWT_SECRET") != "" {
 		config.Auth.JWTSecret = os.Getenv("JWT_SECRET")
 	}
+
+	// 支付回调签名密钥
+	if os.Getenv("PAYMENT_CALLBACK_SECRET") != "" {
+		config.Payment.CallbackSecret = os.Getenv("PAYMENT_CALLBACK_SECRET")
+	}
 }
 
 // setDefaults 设置默认配置
@@ -222,8 +450,146 @@ func setDefaults(config *Config, env string) {
 	}
 
 	// 认证默认值
-	if config.Auth.TokenExpiration == 0 {
-		config.Auth.TokenExpiration = 24
+	if config.Auth.AccessTokenExpiration == 0 {
+		config.Auth.AccessTokenExpiration = 15
+	}
+	if config.Auth.RefreshTokenExpiration == 0 {
+		config.Auth.RefreshTokenExpiration = 30
+	}
+	if config.Auth.EmailVerifyExpiration == 0 {
+		config.Auth.EmailVerifyExpiration = 24
+	}
+	if config.Auth.PasswordResetExpiration == 0 {
+		config.Auth.PasswordResetExpiration = 30
+	}
+	if config.Auth.MaxLoginFailuresPerAccount == 0 {
+		config.Auth.MaxLoginFailuresPerAccount = 5
+	}
+	if config.Auth.MaxLoginFailuresPerIP == 0 {
+		config.Auth.MaxLoginFailuresPerIP = 50
+	}
+	if config.Auth.LoginFailureWindow == 0 {
+		config.Auth.LoginFailureWindow = 15
+	}
+	if config.Auth.LoginLockoutDuration == 0 {
+		config.Auth.LoginLockoutDuration = 15
+	}
+	if config.Auth.LoginDelayAfter == 0 {
+		config.Auth.LoginDelayAfter = 3
+	}
+	if config.Auth.LoginDelayBase == 0 {
+		config.Auth.LoginDelayBase = 1
+	}
+	if config.Auth.LoginDelayMax == 0 {
+		config.Auth.LoginDelayMax = 30
+	}
+	if config.Auth.LoginAttemptStore == "" {
+		config.Auth.LoginAttemptStore = "memory"
+	}
+	if config.Auth.MFAIssuer == "" {
+		config.Auth.MFAIssuer = "Jilang Agent"
+	}
+	if config.Auth.MFAChallengeExpiration == 0 {
+		config.Auth.MFAChallengeExpiration = 5
+	}
+	if config.Auth.OAuthStateExpiration == 0 {
+		config.Auth.OAuthStateExpiration = 10
+	}
+	if config.Auth.OrgInvitationExpiration == 0 {
+		config.Auth.OrgInvitationExpiration = 72
+	}
+	if config.Auth.FrontendURL == "" {
+		config.Auth.FrontendURL = "http://localhost:5173"
+	}
+
+	// 配额默认值，只补充未配置的套餐
+	if config.Quota.Plans == nil {
+		config.Quota.Plans = make(map[string]PlanQuotaConfig)
+	}
+	for plan, quota := range defaultPlanQuotas {
+		if _, ok := config.Quota.Plans[plan]; !ok {
+			config.Quota.Plans[plan] = quota
+		}
+	}
+
+	// 限流默认值，只补充未配置的路由组
+	if config.RateLimit.Store == "" {
+		config.RateLimit.Store = "memory"
+	}
+	if config.RateLimit.Policies == nil {
+		config.RateLimit.Policies = make(map[string]RateLimitPolicyConfig)
+	}
+	for group, policy := range defaultRateLimitPolicies {
+		if _, ok := config.RateLimit.Policies[group]; !ok {
+			config.RateLimit.Policies[group] = policy
+		}
+	}
+
+	// 创作者市场默认值
+	if config.Marketplace.CreatorSharePercent <= 0 || config.Marketplace.CreatorSharePercent > 100 {
+		config.Marketplace.CreatorSharePercent = 70
+	}
+	if config.Marketplace.MinPayoutPoints == 0 {
+		config.Marketplace.MinPayoutPoints = 1000
+	}
+
+	// 搜索默认值
+	if config.Search.Backend == "" {
+		config.Search.Backend = "database"
+	}
+	if config.Search.TextSearchConfig == "" {
+		config.Search.TextSearchConfig = "simple"
+	}
+
+	// 试用默认值
+	if config.Trial.Runs == 0 {
+		config.Trial.Runs = 3
+	}
+	if config.Trial.MaxOutputBytes <= 0 {
+		config.Trial.MaxOutputBytes = 2048
+	}
+	if config.Trial.Watermark == "" {
+		config.Trial.Watermark = "试用结果，购买后可获得完整输出"
+	}
+
+	// 删除保留默认值
+	if config.Retention.DeletedDays <= 0 {
+		config.Retention.DeletedDays = 30
+	}
+
+	// 邮件默认值
+	if config.Mail.Provider == "" {
+		config.Mail.Provider = "log"
+	}
+	if config.Mail.From == "" {
+		config.Mail.From = "no-reply@example.com"
+	}
+	if config.Mail.SMTPPort == 0 {
+		config.Mail.SMTPPort = 587
+	}
+
+	// 支付默认值
+	if config.Payment.Provider == "" {
+		config.Payment.Provider = "mock"
+	}
+
+	// 订阅默认值
+	if config.Subscription.RenewalCheckInterval == 0 {
+		config.Subscription.RenewalCheckInterval = 60
+	}
+	if config.Subscription.RenewAheadHours == 0 {
+		config.Subscription.RenewAheadHours = 24
+	}
+	if config.Subscription.GracePeriodDays == 0 {
+		config.Subscription.GracePeriodDays = 3
+	}
+
+	// 发票默认值
+	if config.Invoice.Prefix == "" {
+		config.Invoice.Prefix = "INV"
+	}
+	if config.Invoice.SellerName == "" {
+		config.Invoice.SellerName = "Jilang Agent"
 	}
 
 	// 日志默认值
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -10,7 +11,14 @@ import (
 	"github.com/alexfaker/jilang-agent/api/routes"
 	"github.com/alexfaker/jilang-agent/config"
 	"github.com/alexfaker/jilang-agent/pkg/database"
+	"github.com/alexfaker/jilang-agent/pkg/jobs"
 	"github.com/alexfaker/jilang-agent/pkg/logger"
+	"github.com/alexfaker/jilang-agent/pkg/loginguard"
+	"github.com/alexfaker/jilang-agent/pkg/mailer"
+	"github.com/alexfaker/jilang-agent/pkg/oidc"
+	"github.com/alexfaker/jilang-agent/pkg/payment"
+	"github.com/alexfaker/jilang-agent/pkg/ratelimit"
+	"github.com/alexfaker/jilang-agent/pkg/search"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
@@ -51,8 +59,64 @@ func main() {
 		}
 	}
 
+	// 初始化支付提供方
+	payments, err := payment.NewProvider(cfg.Payment)
+	if err != nil {
+		logger.Fatal("支付提供方初始化失败", zap.Error(err))
+	}
+
+	// 初始化邮件发送器
+	mail, err := mailer.NewMailer(cfg.Mail, logger)
+	if err != nil {
+		logger.Fatal("邮件发送器初始化失败", zap.Error(err))
+	}
+
+	// 初始化登录防暴力破解
+	loginLimits := loginguard.LimitsFromConfig(cfg.Auth)
+	loginStore, err := loginguard.NewStore(cfg.Auth.LoginAttemptStore, db, loginLimits)
+	if err != nil {
+		logger.Fatal("登录失败计数存储初始化失败", zap.Error(err))
+	}
+	loginGuard := loginguard.New(loginStore, loginLimits)
+
+	// 初始化接口限流
+	var rateLimiter *ratelimit.Limiter
+	if !cfg.RateLimit.Disabled {
+		policies, err := ratelimit.PoliciesFromConfig(cfg.RateLimit)
+		if err != nil {
+			logger.Fatal("限流策略配置无效", zap.Error(err))
+		}
+		rateStore, err := ratelimit.NewStore(cfg.RateLimit.Store, db, ratelimit.MaxFillDuration(policies))
+		if err != nil {
+			logger.Fatal("限流令牌桶存储初始化失败", zap.Error(err))
+		}
+		rateLimiter = ratelimit.New(rateStore, policies)
+	}
+
+	// 初始化代理搜索，全文索引不可用时退化为模糊匹配
+	searchEngine, err := search.NewEngine(db, cfg.Search)
+	if err != nil {
+		logger.Fatal("搜索配置无效", zap.Error(err))
+	}
+	if err := searchEngine.EnsureIndex(); err != nil {
+		logger.Warn("创建全文索引失败，代理搜索将使用模糊匹配", zap.Error(err))
+	}
+
+	// 初始化第三方登录提供方
+	oidcProviders, err := oidc.NewRegistry(cfg.Auth.OIDCProviders)
+	if err != nil {
+		logger.Fatal("第三方登录提供方初始化失败", zap.Error(err))
+	}
+
+	// 启动后台任务
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go jobs.NewSubscriptionRenewalJob(db, logger, payments, cfg.Subscription).Start(ctx)
+	go jobs.NewTokenCleanupJob(db, logger, loginLimits.Window).Start(ctx)
+	go jobs.NewDeletedPurgeJob(db, logger, cfg.Retention.DeletedTTL()).Start(ctx)
+
 	// 初始化Gin路由
-	router := routes.InitGinRoutes(db, logger, cfg)
+	router := routes.InitGinRoutes(db, logger, cfg, payments, mail, loginGuard, oidcProviders, rateLimiter, searchEngine)
 
 	// 配置服务器
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
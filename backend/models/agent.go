@@ -17,12 +17,35 @@ type Agent struct {
 	Icon          string          `json:"icon" gorm:"type:varchar(255)"`
 	CoverImage    string          `json:"coverImage" gorm:"column:cover_image;type:varchar(500)"` // 封面图URL
 	Definition    json.RawMessage `json:"definition" gorm:"type:json"`                            // 代理定义JSON
+	InputSchema   json.RawMessage `json:"inputSchema" gorm:"column:input_schema;type:json"`       // 输入参数的JSON Schema，为空表示不限制输入
 	Price         int             `json:"price" gorm:"not null;default:0"`                        // 价格（点数）
+	SalePrice     *int            `json:"salePrice" gorm:"column:sale_price"`                     // 限时特价（点数），为空表示无特价
+	SaleStartsAt  *time.Time      `json:"saleStartsAt" gorm:"column:sale_starts_at"`              // 特价开始时间，为空表示立即开始
+	SaleEndsAt    *time.Time      `json:"saleEndsAt" gorm:"column:sale_ends_at"`                  // 特价结束时间，为空表示不结束
+	CurrentPrice  int             `json:"currentPrice" gorm:"-"`                                  // 当前实际售价，查询后计算
+	OnSale        bool            `json:"onSale" gorm:"-"`                                        // 当前是否处于特价中
 	PurchaseCount int             `json:"purchaseCount" gorm:"column:purchase_count;default:0"`   // 购买次数
-	Rating        float64         `json:"rating" gorm:"default:0.0"`                              // 评分
+	Rating        float64         `json:"rating" gorm:"default:0.0"`                              // 平均评分，由展示中的评价重新计算
+	ReviewCount   int             `json:"reviewCount" gorm:"column:review_count;default:0"`       // 展示中的评价数
+	CreatorID     string          `json:"creatorId" gorm:"column:creator_id;index"`               // 创作者用户ID，平台自营代理为空
+	Status        AgentStatus     `json:"status" gorm:"type:varchar(20);default:'published';not null;index"`
+	RejectReason  string          `json:"rejectReason" gorm:"column:reject_reason;type:varchar(500)"` // 审核拒绝原因
+	SubmittedAt   *time.Time      `json:"submittedAt" gorm:"column:submitted_at"`                     // 最近一次提交审核时间
+	ModeratorID   string          `json:"moderatorId" gorm:"column:moderator_id"`                     // 审核人用户ID
+	ModeratedAt   *time.Time      `json:"moderatedAt" gorm:"column:moderated_at"`                     // 审核时间
+	PublishedAt   *time.Time      `json:"publishedAt" gorm:"column:published_at"`                     // 发布时间
 	IsPublic      bool            `json:"isPublic" gorm:"column:is_public;default:false"`
+	UnlistedAt    *time.Time      `json:"unlistedAt" gorm:"column:unlisted_at"`                                            // 管理员下架时间，下架后不在商店展示，已购买的用户不受影响
+	UnlistReason  string          `json:"unlistReason" gorm:"column:unlist_reason;type:varchar(500)"`                      // 下架原因
+	IsFeatured    bool            `json:"isFeatured" gorm:"column:is_featured;not null;default:false;index"`               // 推荐位，在推荐时间段内展示
+	FeaturedFrom  *time.Time      `json:"featuredFrom" gorm:"column:featured_from"`                                        // 推荐开始时间，为空表示立即开始
+	FeaturedUntil *time.Time      `json:"featuredUntil" gorm:"column:featured_until"`                                      // 推荐结束时间，为空表示不结束
+	FeaturedRank  int             `json:"featuredRank" gorm:"column:featured_rank;not null;default:0"`                     // 推荐位顺序，越小越靠前
+	Tags          []string        `json:"tags,omitempty" gorm:"-"`                                                         // 标签，由LoadAgentTags填充
+	LatestVersion string          `json:"latestVersion" gorm:"column:latest_version;type:varchar(32);not null;default:''"` // 当前定义对应的版本号
 	CreatedAt     time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt     time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	DeletedAt     gorm.DeletedAt  `json:"deletedAt" gorm:"column:deleted_at;index"` // 软删除时间，保留期内可恢复
 }
 
 // TableName 指定表名
@@ -162,3 +185,15 @@ func GetAgentCategories(db *gorm.DB) ([]string, error) {
 		Distinct().Pluck("category", &categories).Error
 	return categories, err
 }
+
+// ListFeaturedAgents 获取指定时间处于推荐中的公开代理，按推荐位顺序排列
+func ListFeaturedAgents(db *gorm.DB, now time.Time, limit int) ([]Agent, error) {
+	agents := []Agent{}
+	err := db.Where("is_public = ? AND is_featured = ?", true, true).
+		Where("featured_from IS NULL OR featured_from <= ?", now).
+		Where("featured_until IS NULL OR featured_until > ?", now).
+		Order("featured_rank ASC, purchase_count DESC, id DESC").
+		Limit(limit).
+		Find(&agents).Error
+	return agents, err
+}
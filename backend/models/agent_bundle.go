@@ -0,0 +1,271 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MinBundleAgents 套餐至少包含的代理数
+const MinBundleAgents = 2
+
+var (
+	ErrBundleNotFound = errors.New("套餐不存在或不可购买")
+	ErrBundleAgents   = fmt.Errorf("套餐至少包含%d个公开的代理", MinBundleAgents)
+	ErrBundleOwned    = errors.New("您已拥有套餐中的全部代理")
+)
+
+// AgentBundle 以套餐价格打包出售的一组代理
+type AgentBundle struct {
+	ID          int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	CoverImage  string    `json:"coverImage" gorm:"column:cover_image;type:varchar(500)"`
+	Price       int       `json:"price" gorm:"not null;default:0"` // 套餐价格（点数）
+	IsPublic    bool      `json:"isPublic" gorm:"column:is_public;not null;default:false"`
+	Agents      []Agent   `json:"agents,omitempty" gorm:"-"` // 套餐中的代理，由LoadBundleAgents填充
+	ValuePrice  int       `json:"valuePrice" gorm:"-"`       // 套餐中代理单独购买的当前总价
+	CreatedAt   time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AgentBundle) TableName() string {
+	return "agent_bundles"
+}
+
+// AgentBundleItem 套餐中的代理及其顺序
+type AgentBundleItem struct {
+	BundleID int64 `json:"bundleId" gorm:"column:bundle_id;primaryKey"`
+	AgentID  int64 `json:"agentId" gorm:"column:agent_id;primaryKey;index"`
+	Position int   `json:"position" gorm:"not null;default:0"`
+}
+
+// TableName 指定表名
+func (AgentBundleItem) TableName() string {
+	return "agent_bundle_items"
+}
+
+// BundleQuoteItem 套餐报价中的单个代理
+type BundleQuoteItem struct {
+	AgentID      int64  `json:"agentId"`
+	Name         string `json:"name"`
+	CurrentPrice int    `json:"currentPrice"` // 单独购买的当前售价
+	Owned        bool   `json:"owned"`        // 已拥有，购买套餐时跳过
+	Price        int    `json:"price"`        // 分摊到该代理的套餐价格，已拥有的为0
+}
+
+// BundleQuote 套餐报价，已拥有的代理按其售价占比从套餐价格中扣除
+type BundleQuote struct {
+	BundleID    int64             `json:"bundleId"`
+	BundlePrice int               `json:"bundlePrice"` // 套餐标价
+	ValuePrice  int               `json:"valuePrice"`  // 未拥有代理单独购买的总价
+	Price       int               `json:"price"`       // 实际应付
+	Savings     int               `json:"savings"`     // 相比单独购买节省的点数
+	Items       []BundleQuoteItem `json:"items"`
+}
+
+// ListAgentBundles 获取套餐列表，publicOnly为true时只返回公开套餐
+func ListAgentBundles(db *gorm.DB, publicOnly bool) ([]AgentBundle, error) {
+	query := db.Model(&AgentBundle{})
+	if publicOnly {
+		query = query.Where("is_public = ?", true)
+	}
+
+	bundles := []AgentBundle{}
+	if err := query.Order("id DESC").Find(&bundles).Error; err != nil {
+		return nil, fmt.Errorf("获取套餐列表失败: %w", err)
+	}
+	if err := LoadBundleAgents(db, bundles); err != nil {
+		return nil, err
+	}
+	return bundles, nil
+}
+
+// GetAgentBundle 获取套餐及其代理
+func GetAgentBundle(db *gorm.DB, id int64, publicOnly bool) (*AgentBundle, error) {
+	query := db.Where("id = ?", id)
+	if publicOnly {
+		query = query.Where("is_public = ?", true)
+	}
+
+	var bundle AgentBundle
+	if err := query.First(&bundle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrBundleNotFound
+		}
+		return nil, fmt.Errorf("获取套餐失败: %w", err)
+	}
+	bundles := []AgentBundle{bundle}
+	if err := LoadBundleAgents(db, bundles); err != nil {
+		return nil, err
+	}
+	return &bundles[0], nil
+}
+
+// LoadBundleAgents 按套餐中的顺序填充公开代理并计算单独购买的总价
+func LoadBundleAgents(db *gorm.DB, bundles []AgentBundle) error {
+	if len(bundles) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(bundles))
+	for i := range bundles {
+		ids[i] = bundles[i].ID
+	}
+
+	var rows []struct {
+		Agent
+		BundleID int64
+	}
+	err := db.Model(&Agent{}).
+		Select("agents.*, agent_bundle_items.bundle_id").
+		Joins("JOIN agent_bundle_items ON agent_bundle_items.agent_id = agents.id").
+		Where("agent_bundle_items.bundle_id IN ? AND agents.is_public = ?", ids, true).
+		Order("agent_bundle_items.position ASC, agents.id ASC").
+		Find(&rows).Error
+	if err != nil {
+		return fmt.Errorf("获取套餐代理失败: %w", err)
+	}
+
+	byBundle := make(map[int64][]Agent, len(bundles))
+	for _, row := range rows {
+		byBundle[row.BundleID] = append(byBundle[row.BundleID], row.Agent)
+	}
+	now := time.Now()
+	for i := range bundles {
+		bundles[i].Agents = byBundle[bundles[i].ID]
+		if bundles[i].Agents == nil {
+			bundles[i].Agents = []Agent{}
+		}
+		bundles[i].ValuePrice = 0
+		for j := range bundles[i].Agents {
+			bundles[i].Agents[j].applyPricing(now)
+			bundles[i].ValuePrice += bundles[i].Agents[j].CurrentPrice
+		}
+	}
+	return nil
+}
+
+// SetBundleAgents 按给定顺序替换套餐中的代理，只能加入公开的代理，需在事务中调用
+func SetBundleAgents(tx *gorm.DB, bundleID int64, agentIDs []int64) error {
+	seen := make(map[int64]bool, len(agentIDs))
+	items := make([]AgentBundleItem, 0, len(agentIDs))
+	ids := make([]int64, 0, len(agentIDs))
+	for _, id := range agentIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		items = append(items, AgentBundleItem{BundleID: bundleID, AgentID: id, Position: len(items)})
+		ids = append(ids, id)
+	}
+	if len(items) < MinBundleAgents {
+		return ErrBundleAgents
+	}
+
+	var count int64
+	if err := tx.Model(&Agent{}).Where("id IN ? AND is_public = ?", ids, true).Count(&count).Error; err != nil {
+		return fmt.Errorf("检查套餐代理失败: %w", err)
+	}
+	if count != int64(len(ids)) {
+		return ErrBundleAgents
+	}
+
+	if err := tx.Where("bundle_id = ?", bundleID).Delete(&AgentBundleItem{}).Error; err != nil {
+		return fmt.Errorf("清除套餐代理失败: %w", err)
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return fmt.Errorf("保存套餐代理失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteAgentBundle 删除套餐及其代理关联，已通过套餐购买的工作流不受影响
+func DeleteAgentBundle(db *gorm.DB, bundleID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("bundle_id = ?", bundleID).Delete(&AgentBundleItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&AgentBundle{}, bundleID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrBundleNotFound
+		}
+		return nil
+	})
+}
+
+// QuoteBundle 计算购买套餐的实际价格
+//
+// 已拥有的代理不再购买，应付价格按未拥有代理的当前售价占比折算套餐价格，且不超过单独购买它们的总价；
+// 应付价格再按售价占比分摊到各代理，用于创作者分成和审计。
+func QuoteBundle(bundle *AgentBundle, owned map[int64]bool) (*BundleQuote, error) {
+	if len(bundle.Agents) < MinBundleAgents {
+		return nil, ErrBundleAgents
+	}
+
+	quote := &BundleQuote{
+		BundleID:    bundle.ID,
+		BundlePrice: bundle.Price,
+		Items:       make([]BundleQuoteItem, len(bundle.Agents)),
+	}
+	totalValue := 0
+	var pending []int
+	for i, agent := range bundle.Agents {
+		quote.Items[i] = BundleQuoteItem{
+			AgentID:      agent.ID,
+			Name:         agent.Name,
+			CurrentPrice: agent.CurrentPrice,
+			Owned:        owned[agent.ID],
+		}
+		totalValue += agent.CurrentPrice
+		if !owned[agent.ID] {
+			quote.ValuePrice += agent.CurrentPrice
+			pending = append(pending, i)
+		}
+	}
+	if len(pending) == 0 {
+		return nil, ErrBundleOwned
+	}
+
+	quote.Price = bundle.Price
+	if len(pending) < len(bundle.Agents) && totalValue > 0 {
+		quote.Price = int(int64(bundle.Price) * int64(quote.ValuePrice) / int64(totalValue))
+	}
+	if quote.Price > quote.ValuePrice {
+		quote.Price = quote.ValuePrice
+	}
+	quote.Savings = quote.ValuePrice - quote.Price
+
+	allocateBundlePrice(quote, pending)
+	return quote, nil
+}
+
+// allocateBundlePrice 按售价占比将应付价格分摊到未拥有的代理，余数按最大余额法分配，保证合计等于应付价格
+func allocateBundlePrice(quote *BundleQuote, pending []int) {
+	if quote.ValuePrice == 0 {
+		return
+	}
+	remainders := make([]int64, len(quote.Items))
+	allocated := 0
+	for _, i := range pending {
+		share := int64(quote.Price) * int64(quote.Items[i].CurrentPrice)
+		quote.Items[i].Price = int(share / int64(quote.ValuePrice))
+		remainders[i] = share % int64(quote.ValuePrice)
+		allocated += quote.Items[i].Price
+	}
+
+	order := append([]int(nil), pending...)
+	sort.SliceStable(order, func(a, b int) bool {
+		return remainders[order[a]] > remainders[order[b]]
+	})
+	for k := 0; allocated < quote.Price; k++ {
+		quote.Items[order[k%len(order)]].Price++
+		allocated++
+	}
+}
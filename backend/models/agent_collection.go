@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCollectionNotFound = errors.New("合集不存在")
+	ErrCollectionAgents   = errors.New("合集中包含不存在或未公开的代理")
+)
+
+// AgentCollection 管理员编排的代理合集，例如"新手必备"、"营销套件"
+type AgentCollection struct {
+	ID          int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Slug        string    `json:"slug" gorm:"type:varchar(100);not null;uniqueIndex"` // 用于访问地址的唯一标识
+	Name        string    `json:"name" gorm:"type:varchar(100);not null"`
+	Description string    `json:"description" gorm:"type:text"`
+	CoverImage  string    `json:"coverImage" gorm:"column:cover_image;type:varchar(500)"`
+	SortOrder   int       `json:"sortOrder" gorm:"column:sort_order;not null;default:0"` // 合集列表中的顺序，越小越靠前
+	IsPublished bool      `json:"isPublished" gorm:"column:is_published;not null;default:false"`
+	AgentCount  int64     `json:"agentCount" gorm:"-"` // 合集中公开代理的数量，列表接口填充
+	CreatedAt   time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AgentCollection) TableName() string {
+	return "agent_collections"
+}
+
+// AgentCollectionItem 合集中的代理及其顺序
+type AgentCollectionItem struct {
+	CollectionID int64 `json:"collectionId" gorm:"column:collection_id;primaryKey"`
+	AgentID      int64 `json:"agentId" gorm:"column:agent_id;primaryKey;index"`
+	Position     int   `json:"position" gorm:"not null;default:0"`
+}
+
+// TableName 指定表名
+func (AgentCollectionItem) TableName() string {
+	return "agent_collection_items"
+}
+
+// ListAgentCollections 获取合集列表，publishedOnly为true时只返回已发布的合集
+func ListAgentCollections(db *gorm.DB, publishedOnly bool) ([]AgentCollection, error) {
+	query := db.Model(&AgentCollection{})
+	if publishedOnly {
+		query = query.Where("is_published = ?", true)
+	}
+
+	collections := []AgentCollection{}
+	if err := query.Order("sort_order ASC, id ASC").Find(&collections).Error; err != nil {
+		return nil, fmt.Errorf("获取合集列表失败: %w", err)
+	}
+	if len(collections) == 0 {
+		return collections, nil
+	}
+
+	ids := make([]int64, len(collections))
+	for i := range collections {
+		ids[i] = collections[i].ID
+	}
+	var counts []struct {
+		CollectionID int64
+		Count        int64
+	}
+	err := db.Table("agent_collection_items").
+		Select("agent_collection_items.collection_id, COUNT(*) AS count").
+		Joins("JOIN agents ON agents.id = agent_collection_items.agent_id AND agents.is_public = ? AND agents.deleted_at IS NULL", true).
+		Where("agent_collection_items.collection_id IN ?", ids).
+		Group("agent_collection_items.collection_id").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计合集代理数失败: %w", err)
+	}
+	byID := make(map[int64]int64, len(counts))
+	for _, c := range counts {
+		byID[c.CollectionID] = c.Count
+	}
+	for i := range collections {
+		collections[i].AgentCount = byID[collections[i].ID]
+	}
+	return collections, nil
+}
+
+// GetAgentCollection 按ID或slug获取合集
+func GetAgentCollection(db *gorm.DB, idOrSlug string, publishedOnly bool) (*AgentCollection, error) {
+	query := db.Where("slug = ?", idOrSlug)
+	var id int64
+	if _, err := fmt.Sscan(idOrSlug, &id); err == nil {
+		query = db.Where("id = ? OR slug = ?", id, idOrSlug)
+	}
+	if publishedOnly {
+		query = query.Where("is_published = ?", true)
+	}
+
+	var collection AgentCollection
+	if err := query.First(&collection).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCollectionNotFound
+		}
+		return nil, fmt.Errorf("获取合集失败: %w", err)
+	}
+	return &collection, nil
+}
+
+// ListCollectionAgents 按合集中的顺序获取公开代理
+func ListCollectionAgents(db *gorm.DB, collectionID int64) ([]Agent, error) {
+	agents := []Agent{}
+	err := db.Model(&Agent{}).
+		Joins("JOIN agent_collection_items ON agent_collection_items.agent_id = agents.id").
+		Where("agent_collection_items.collection_id = ? AND agents.is_public = ?", collectionID, true).
+		Order("agent_collection_items.position ASC, agents.id ASC").
+		Find(&agents).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取合集代理失败: %w", err)
+	}
+	return agents, nil
+}
+
+// SetCollectionAgents 按给定顺序替换合集中的代理，只能加入公开的代理，需在事务中调用
+func SetCollectionAgents(tx *gorm.DB, collectionID int64, agentIDs []int64) error {
+	seen := make(map[int64]bool, len(agentIDs))
+	items := make([]AgentCollectionItem, 0, len(agentIDs))
+	for _, id := range agentIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		items = append(items, AgentCollectionItem{CollectionID: collectionID, AgentID: id, Position: len(items)})
+	}
+
+	if len(items) > 0 {
+		var count int64
+		ids := make([]int64, len(items))
+		for i, item := range items {
+			ids[i] = item.AgentID
+		}
+		if err := tx.Model(&Agent{}).Where("id IN ? AND is_public = ?", ids, true).Count(&count).Error; err != nil {
+			return fmt.Errorf("检查合集代理失败: %w", err)
+		}
+		if count != int64(len(ids)) {
+			return ErrCollectionAgents
+		}
+	}
+
+	if err := tx.Where("collection_id = ?", collectionID).Delete(&AgentCollectionItem{}).Error; err != nil {
+		return fmt.Errorf("清除合集代理失败: %w", err)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	if err := tx.Create(&items).Error; err != nil {
+		return fmt.Errorf("保存合集代理失败: %w", err)
+	}
+	return nil
+}
+
+// DeleteAgentCollection 删除合集及其代理关联
+func DeleteAgentCollection(db *gorm.DB, collectionID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("collection_id = ?", collectionID).Delete(&AgentCollectionItem{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&AgentCollection{}, collectionID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCollectionNotFound
+		}
+		return nil
+	})
+}
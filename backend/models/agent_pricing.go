@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrSaleInvalid = errors.New("特价必须不低于0且低于原价，结束时间必须晚于开始时间")
+
+// SaleActive 代理在指定时间是否处于特价中
+func (a *Agent) SaleActive(now time.Time) bool {
+	if a.SalePrice == nil || *a.SalePrice >= a.Price {
+		return false
+	}
+	if a.SaleStartsAt != nil && now.Before(*a.SaleStartsAt) {
+		return false
+	}
+	if a.SaleEndsAt != nil && !now.Before(*a.SaleEndsAt) {
+		return false
+	}
+	return true
+}
+
+// PriceAt 代理在指定时间的实际售价
+func (a *Agent) PriceAt(now time.Time) int {
+	if a.SaleActive(now) {
+		return *a.SalePrice
+	}
+	return a.Price
+}
+
+// applyPricing 按指定时间填充CurrentPrice和OnSale
+func (a *Agent) applyPricing(now time.Time) {
+	a.OnSale = a.SaleActive(now)
+	a.CurrentPrice = a.PriceAt(now)
+}
+
+// AfterFind 查询后计算当前售价，保证列表和详情响应中的价格一致
+func (a *Agent) AfterFind(tx *gorm.DB) error {
+	a.applyPricing(time.Now())
+	return nil
+}
+
+// AfterSave 保存后重新计算当前售价
+func (a *Agent) AfterSave(tx *gorm.DB) error {
+	a.applyPricing(time.Now())
+	return nil
+}
+
+// CurrentPriceExpr 当前售价的SQL表达式，用于按实际售价筛选和排序，与PriceAt的规则一致
+func CurrentPriceExpr(now time.Time) clause.Expr {
+	return clause.Expr{
+		SQL: "(CASE WHEN sale_price IS NOT NULL AND sale_price < price" +
+			" AND (sale_starts_at IS NULL OR sale_starts_at <= ?)" +
+			" AND (sale_ends_at IS NULL OR sale_ends_at > ?)" +
+			" THEN sale_price ELSE price END)",
+		Vars: []interface{}{now, now},
+	}
+}
+
+// AgentSaleInput 设置限时特价输入，SalePrice为空表示取消特价
+type AgentSaleInput struct {
+	SalePrice *int       `json:"salePrice"`
+	StartsAt  *time.Time `json:"startsAt"`
+	EndsAt    *time.Time `json:"endsAt"`
+}
+
+// SetAgentSale 设置或取消代理的限时特价
+func SetAgentSale(db *gorm.DB, agent *Agent, input AgentSaleInput) error {
+	updates := map[string]interface{}{
+		"sale_price":     nil,
+		"sale_starts_at": nil,
+		"sale_ends_at":   nil,
+	}
+	if input.SalePrice != nil {
+		if *input.SalePrice < 0 || *input.SalePrice >= agent.Price {
+			return ErrSaleInvalid
+		}
+		if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+			return ErrSaleInvalid
+		}
+		updates["sale_price"] = *input.SalePrice
+		updates["sale_starts_at"] = input.StartsAt
+		updates["sale_ends_at"] = input.EndsAt
+	}
+	return db.Model(agent).Updates(updates).Error
+}
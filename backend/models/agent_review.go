@@ -0,0 +1,403 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReviewStatus 评价的审核状态
+type ReviewStatus string
+
+const (
+	ReviewStatusVisible ReviewStatus = "visible" // 正常展示
+	ReviewStatusHidden  ReviewStatus = "hidden"  // 被管理员隐藏，不展示也不计入评分
+)
+
+// ReviewFlagStatus 举报的处理状态
+type ReviewFlagStatus string
+
+const (
+	ReviewFlagStatusPending   ReviewFlagStatus = "pending"   // 待处理
+	ReviewFlagStatusResolved  ReviewFlagStatus = "resolved"  // 已处理（评价被隐藏）
+	ReviewFlagStatusDismissed ReviewFlagStatus = "dismissed" // 已驳回
+)
+
+// 评价排序方式
+const (
+	ReviewSortNewest     = "newest"      // 最新
+	ReviewSortHelpful    = "helpful"     // 最有帮助
+	ReviewSortRatingHigh = "rating_high" // 评分从高到低
+	ReviewSortRatingLow  = "rating_low"  // 评分从低到高
+)
+
+var (
+	ErrReviewNotFound      = errors.New("评价不存在")
+	ErrReviewNotBuyer      = errors.New("只有购买过此代理的用户才能评价")
+	ErrReviewExists        = errors.New("您已评价过此代理，请修改原有评价")
+	ErrReviewRatingInvalid = errors.New("评分必须为1到5之间的整数")
+	ErrReviewOwnVote       = errors.New("不能给自己的评价投票")
+	ErrReviewVoteExists    = errors.New("您已标记过此评价有帮助")
+	ErrReviewFlagExists    = errors.New("您已举报过此评价，请等待处理")
+)
+
+// AgentReview 用户对已购买代理的评分和评价，每个用户对每个代理只能评价一次
+type AgentReview struct {
+	ID           int64        `json:"id" gorm:"primaryKey;autoIncrement"`
+	AgentID      int64        `json:"agentId" gorm:"column:agent_id;not null;uniqueIndex:idx_agent_review_user"`
+	UserID       string       `json:"userId" gorm:"column:user_id;not null;uniqueIndex:idx_agent_review_user"`
+	Rating       int          `json:"rating" gorm:"not null"` // 1-5分
+	Content      string       `json:"content" gorm:"type:text"`
+	Reply        string       `json:"reply" gorm:"type:text"`             // 创作者回复
+	ReplyBy      string       `json:"replyBy" gorm:"column:reply_by"`     // 回复人用户ID
+	RepliedAt    *time.Time   `json:"repliedAt" gorm:"column:replied_at"` // 回复时间
+	HelpfulCount int          `json:"helpfulCount" gorm:"column:helpful_count;default:0"`
+	Status       ReviewStatus `json:"status" gorm:"type:varchar(20);default:'visible';not null;index"`
+	CreatedAt    time.Time    `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time    `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AgentReview) TableName() string {
+	return "agent_reviews"
+}
+
+// AgentReviewVote 用户对评价的"有帮助"投票
+type AgentReviewVote struct {
+	ReviewID  int64     `json:"reviewId" gorm:"column:review_id;primaryKey"`
+	UserID    string    `json:"userId" gorm:"column:user_id;primaryKey"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AgentReviewVote) TableName() string {
+	return "agent_review_votes"
+}
+
+// AgentReviewFlag 用户对评价的举报，由管理员审核处理
+type AgentReviewFlag struct {
+	ID         int64            `json:"id" gorm:"primaryKey;autoIncrement"`
+	ReviewID   int64            `json:"reviewId" gorm:"column:review_id;not null;uniqueIndex:idx_review_flag_user"`
+	UserID     string           `json:"userId" gorm:"column:user_id;not null;uniqueIndex:idx_review_flag_user"`
+	Reason     string           `json:"reason" gorm:"type:varchar(255)"`
+	Status     ReviewFlagStatus `json:"status" gorm:"type:varchar(20);default:'pending';not null;index"`
+	ResolvedBy string           `json:"resolvedBy" gorm:"column:resolved_by"`
+	ResolvedAt *time.Time       `json:"resolvedAt" gorm:"column:resolved_at"`
+	CreatedAt  time.Time        `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AgentReviewFlag) TableName() string {
+	return "agent_review_flags"
+}
+
+// IsValidReviewRating 检查评分是否在1-5之间
+func IsValidReviewRating(rating int) bool {
+	return rating >= 1 && rating <= 5
+}
+
+// HasPurchasedAgent 检查用户是否购买过代理（拥有购买该代理获得的工作流，包括已删除的，不含克隆的）
+func HasPurchasedAgent(db *gorm.DB, userID string, agentID int64) (bool, error) {
+	var count int64
+	if err := db.Unscoped().Model(&Workflow{}).Scopes(PurchasedWorkflows).Where("user_id = ? AND agent_id = ?", userID, agentID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("检查购买记录失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecomputeAgentRating 根据展示中的评价重新计算代理的平均评分和评价数，需在修改评价的事务中调用
+func RecomputeAgentRating(tx *gorm.DB, agentID int64) error {
+	var stats struct {
+		Average float64
+		Count   int64
+	}
+	if err := tx.Model(&AgentReview{}).
+		Select("COALESCE(AVG(rating), 0) AS average, COUNT(*) AS count").
+		Where("agent_id = ? AND status = ?", agentID, ReviewStatusVisible).
+		Scan(&stats).Error; err != nil {
+		return fmt.Errorf("统计代理评分失败: %w", err)
+	}
+
+	return tx.Model(&Agent{}).Where("id = ?", agentID).Updates(map[string]interface{}{
+		"rating":       math.Round(stats.Average*10) / 10,
+		"review_count": stats.Count,
+	}).Error
+}
+
+// CreateAgentReview 购买者发表评价并更新代理评分
+func CreateAgentReview(db *gorm.DB, review *AgentReview) error {
+	if !IsValidReviewRating(review.Rating) {
+		return ErrReviewRatingInvalid
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		purchased, err := HasPurchasedAgent(tx, review.UserID, review.AgentID)
+		if err != nil {
+			return err
+		}
+		if !purchased {
+			return ErrReviewNotBuyer
+		}
+
+		var count int64
+		if err := tx.Model(&AgentReview{}).Where("agent_id = ? AND user_id = ?", review.AgentID, review.UserID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrReviewExists
+		}
+
+		review.Status = ReviewStatusVisible
+		if err := tx.Create(review).Error; err != nil {
+			return fmt.Errorf("创建评价失败: %w", err)
+		}
+		return RecomputeAgentRating(tx, review.AgentID)
+	})
+}
+
+// GetAgentReview 获取评价
+func GetAgentReview(db *gorm.DB, id int64) (*AgentReview, error) {
+	var review AgentReview
+	if err := db.First(&review, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReviewNotFound
+		}
+		return nil, fmt.Errorf("获取评价失败: %w", err)
+	}
+	return &review, nil
+}
+
+// Update 修改评分和评价内容并更新代理评分
+func (r *AgentReview) Update(db *gorm.DB, rating int, content string) error {
+	if !IsValidReviewRating(rating) {
+		return ErrReviewRatingInvalid
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(r).Updates(map[string]interface{}{
+			"rating":  rating,
+			"content": content,
+		}).Error; err != nil {
+			return err
+		}
+		r.Rating = rating
+		r.Content = content
+		return RecomputeAgentRating(tx, r.AgentID)
+	})
+}
+
+// Delete 删除评价及其投票和举报，并更新代理评分
+func (r *AgentReview) Delete(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("review_id = ?", r.ID).Delete(&AgentReviewVote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("review_id = ?", r.ID).Delete(&AgentReviewFlag{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(r).Error; err != nil {
+			return err
+		}
+		return RecomputeAgentRating(tx, r.AgentID)
+	})
+}
+
+// SetReply 设置或清除创作者回复，content为空时清除
+func (r *AgentReview) SetReply(db *gorm.DB, userID, content string) error {
+	var repliedAt *time.Time
+	if content == "" {
+		userID = ""
+	} else {
+		now := time.Now()
+		repliedAt = &now
+	}
+	if err := db.Model(r).Updates(map[string]interface{}{
+		"reply":      content,
+		"reply_by":   userID,
+		"replied_at": repliedAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	r.Reply = content
+	r.ReplyBy = userID
+	r.RepliedAt = repliedAt
+	return nil
+}
+
+// AddHelpfulVote 标记评价有帮助
+func (r *AgentReview) AddHelpfulVote(db *gorm.DB, userID string) error {
+	if r.UserID == userID {
+		return ErrReviewOwnVote
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&AgentReviewVote{ReviewID: r.ID, UserID: userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrReviewVoteExists
+		}
+		return tx.Model(r).Update("helpful_count", gorm.Expr("helpful_count + 1")).Error
+	})
+}
+
+// RemoveHelpfulVote 取消有帮助标记，未投票时不做任何操作
+func (r *AgentReview) RemoveHelpfulVote(db *gorm.DB, userID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("review_id = ? AND user_id = ?", r.ID, userID).Delete(&AgentReviewVote{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(r).Update("helpful_count", gorm.Expr("helpful_count - 1")).Error
+	})
+}
+
+// Flag 举报评价
+func (r *AgentReview) Flag(db *gorm.DB, userID, reason string) (*AgentReviewFlag, error) {
+	flag := AgentReviewFlag{
+		ReviewID: r.ID,
+		UserID:   userID,
+		Reason:   truncateString(reason, 255),
+		Status:   ReviewFlagStatusPending,
+	}
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&flag)
+	if result.Error != nil {
+		return nil, fmt.Errorf("举报评价失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrReviewFlagExists
+	}
+	return &flag, nil
+}
+
+// Moderate 管理员审核评价：hidden为true时隐藏评价，否则恢复展示
+//
+// 隐藏时将待处理的举报标记为已处理，恢复时标记为已驳回，并重新计算代理评分。
+func (r *AgentReview) Moderate(db *gorm.DB, moderatorID string, hidden bool) error {
+	status := ReviewStatusVisible
+	flagStatus := ReviewFlagStatusDismissed
+	if hidden {
+		status = ReviewStatusHidden
+		flagStatus = ReviewFlagStatusResolved
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(r).Update("status", status).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&AgentReviewFlag{}).
+			Where("review_id = ? AND status = ?", r.ID, ReviewFlagStatusPending).
+			Updates(map[string]interface{}{
+				"status":      flagStatus,
+				"resolved_by": moderatorID,
+				"resolved_at": time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		r.Status = status
+		return RecomputeAgentRating(tx, r.AgentID)
+	})
+}
+
+// AgentReviewInfo 评价及评价人信息
+type AgentReviewInfo struct {
+	AgentReview
+	Username string `json:"username"`
+	Avatar   string `json:"avatar"`
+}
+
+// ListAgentReviews 分页获取代理展示中的评价
+func ListAgentReviews(db *gorm.DB, agentID int64, sort string, limit, offset int) ([]AgentReviewInfo, int64, error) {
+	base := db.Model(&AgentReview{}).Where("agent_reviews.agent_id = ? AND agent_reviews.status = ?", agentID, ReviewStatusVisible)
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计评价失败: %w", err)
+	}
+
+	order := "agent_reviews.created_at DESC"
+	switch sort {
+	case ReviewSortHelpful:
+		order = "agent_reviews.helpful_count DESC, agent_reviews.created_at DESC"
+	case ReviewSortRatingHigh:
+		order = "agent_reviews.rating DESC, agent_reviews.created_at DESC"
+	case ReviewSortRatingLow:
+		order = "agent_reviews.rating ASC, agent_reviews.created_at DESC"
+	}
+
+	var reviews []AgentReviewInfo
+	if err := base.
+		Select("agent_reviews.*, users.username, users.avatar").
+		Joins("LEFT JOIN users ON users.user_id = agent_reviews.user_id").
+		Order(order).Limit(limit).Offset(offset).
+		Scan(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取评价列表失败: %w", err)
+	}
+	return reviews, total, nil
+}
+
+// GetAgentRatingDistribution 获取代理展示中评价的各分值数量，键为1-5
+func GetAgentRatingDistribution(db *gorm.DB, agentID int64) (map[int]int64, error) {
+	var rows []struct {
+		Rating int
+		Count  int64
+	}
+	if err := db.Model(&AgentReview{}).
+		Select("rating, COUNT(*) AS count").
+		Where("agent_id = ? AND status = ?", agentID, ReviewStatusVisible).
+		Group("rating").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("统计评分分布失败: %w", err)
+	}
+
+	distribution := map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}
+	for _, row := range rows {
+		distribution[row.Rating] = row.Count
+	}
+	return distribution, nil
+}
+
+// FlaggedReview 有待处理举报的评价
+type FlaggedReview struct {
+	AgentReview
+	PendingFlags int64 `json:"pendingFlags"`
+}
+
+// ListFlaggedReviews 分页获取有待处理举报的评价，按举报数量倒序
+func ListFlaggedReviews(db *gorm.DB, limit, offset int) ([]FlaggedReview, int64, error) {
+	pending := db.Model(&AgentReviewFlag{}).
+		Select("review_id, COUNT(*) AS pending_flags").
+		Where("status = ?", ReviewFlagStatusPending).
+		Group("review_id")
+
+	var total int64
+	if err := db.Table("(?) AS f", pending).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计被举报评价失败: %w", err)
+	}
+
+	var reviews []FlaggedReview
+	if err := db.Model(&AgentReview{}).
+		Select("agent_reviews.*, f.pending_flags").
+		Joins("JOIN (?) AS f ON f.review_id = agent_reviews.id", pending).
+		Order("f.pending_flags DESC, agent_reviews.id DESC").
+		Limit(limit).Offset(offset).
+		Scan(&reviews).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取被举报评价失败: %w", err)
+	}
+	return reviews, total, nil
+}
+
+// GetReviewFlags 获取评价的全部举报
+func GetReviewFlags(db *gorm.DB, reviewID int64) ([]AgentReviewFlag, error) {
+	var flags []AgentReviewFlag
+	if err := db.Where("review_id = ?", reviewID).Order("id DESC").Find(&flags).Error; err != nil {
+		return nil, fmt.Errorf("获取举报记录失败: %w", err)
+	}
+	return flags, nil
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AgentStatus 代理的上架审核状态
+//
+// 创作者提交的代理流程：draft -> submitted -> approved/rejected -> published，被拒绝后可修改并重新提交。
+// 管理员直接创建的代理为published，是否在商店展示仍由IsPublic决定。
+type AgentStatus string
+
+const (
+	AgentStatusDraft     AgentStatus = "draft"     // 草稿，创作者可编辑
+	AgentStatusSubmitted AgentStatus = "submitted" // 已提交，等待管理员审核
+	AgentStatusApproved  AgentStatus = "approved"  // 审核通过，等待创作者发布
+	AgentStatusRejected  AgentStatus = "rejected"  // 审核被拒绝，创作者可修改后重新提交
+	AgentStatusPublished AgentStatus = "published" // 已发布
+)
+
+// ErrAgentStatusConflict 代理当前状态不允许此操作
+var ErrAgentStatusConflict = errors.New("代理当前状态不允许此操作")
+
+// IsEditableByCreator 创作者只能编辑草稿和被拒绝的代理
+func (s AgentStatus) IsEditableByCreator() bool {
+	return s == AgentStatusDraft || s == AgentStatusRejected
+}
+
+// transitionAgentStatus 仅当代理处于from中的某个状态时更新状态，避免并发审核互相覆盖
+func transitionAgentStatus(db *gorm.DB, agent *Agent, from []AgentStatus, updates map[string]interface{}) error {
+	result := db.Model(&Agent{}).Where("id = ? AND status IN ?", agent.ID, from).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("更新代理状态失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAgentStatusConflict
+	}
+	return db.First(agent, agent.ID).Error
+}
+
+// Submit 创作者提交审核
+func (a *Agent) Submit(db *gorm.DB) error {
+	return transitionAgentStatus(db, a, []AgentStatus{AgentStatusDraft, AgentStatusRejected}, map[string]interface{}{
+		"status":        AgentStatusSubmitted,
+		"submitted_at":  time.Now(),
+		"reject_reason": "",
+	})
+}
+
+// Approve 管理员审核通过
+func (a *Agent) Approve(db *gorm.DB, moderatorID string) error {
+	return transitionAgentStatus(db, a, []AgentStatus{AgentStatusSubmitted}, map[string]interface{}{
+		"status":       AgentStatusApproved,
+		"moderator_id": moderatorID,
+		"moderated_at": time.Now(),
+	})
+}
+
+// Reject 管理员拒绝，需说明原因
+func (a *Agent) Reject(db *gorm.DB, moderatorID, reason string) error {
+	return transitionAgentStatus(db, a, []AgentStatus{AgentStatusSubmitted}, map[string]interface{}{
+		"status":        AgentStatusRejected,
+		"reject_reason": truncateString(reason, 500),
+		"moderator_id":  moderatorID,
+		"moderated_at":  time.Now(),
+	})
+}
+
+// Publish 创作者发布审核通过的代理，发布后在商店展示并可购买
+func (a *Agent) Publish(db *gorm.DB) error {
+	return transitionAgentStatus(db, a, []AgentStatus{AgentStatusApproved}, map[string]interface{}{
+		"status":       AgentStatusPublished,
+		"is_public":    true,
+		"published_at": time.Now(),
+	})
+}
+
+// ListAgentsByStatus 按审核状态分页获取代理，按提交时间先后排列，用于审核队列
+func ListAgentsByStatus(db *gorm.DB, status AgentStatus, limit, offset int) ([]Agent, int64, error) {
+	query := db.Model(&Agent{}).Where("status = ?", status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计代理失败: %w", err)
+	}
+
+	var agents []Agent
+	if err := query.Order("submitted_at ASC, id ASC").Limit(limit).Offset(offset).Find(&agents).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取代理列表失败: %w", err)
+	}
+	return agents, total, nil
+}
+
+// ListCreatorAgents 获取创作者的全部代理，按更新时间倒序
+func ListCreatorAgents(db *gorm.DB, creatorID string, status string) ([]Agent, error) {
+	query := db.Where("creator_id = ?", creatorID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var agents []Agent
+	if err := query.Order("updated_at DESC").Find(&agents).Error; err != nil {
+		return nil, fmt.Errorf("获取创作者代理失败: %w", err)
+	}
+	return agents, nil
+}
@@ -0,0 +1,251 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	MaxAgentTags    = 10 // 每个代理最多标签数
+	MaxTagNameRunes = 20 // 标签名最大字数
+)
+
+var ErrTagInvalid = fmt.Errorf("标签不能为空且不超过%d个字，每个代理最多%d个标签", MaxTagNameRunes, MaxAgentTags)
+
+// Tag 代理标签
+type Tag struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name      string    `json:"name" gorm:"type:varchar(50);not null;uniqueIndex"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (Tag) TableName() string {
+	return "tags"
+}
+
+// AgentTag 代理与标签的多对多关联
+type AgentTag struct {
+	AgentID int64 `json:"agentId" gorm:"column:agent_id;primaryKey"`
+	TagID   int64 `json:"tagId" gorm:"column:tag_id;primaryKey;index"`
+}
+
+// TableName 指定表名
+func (AgentTag) TableName() string {
+	return "agent_tags"
+}
+
+// TagCount 标签及使用该标签的公开代理数
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
+// NormalizeTags 去除首尾空白、统一小写并去重，保留首次出现的顺序
+func NormalizeTags(names []string) ([]string, error) {
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+		if name == "" || utf8.RuneCountInString(name) > MaxTagNameRunes {
+			return nil, ErrTagInvalid
+		}
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	if len(tags) > MaxAgentTags {
+		return nil, ErrTagInvalid
+	}
+	return tags, nil
+}
+
+// SetAgentTags 替换代理的标签，不存在的标签自动创建，需在事务中调用
+func SetAgentTags(tx *gorm.DB, agentID int64, names []string) error {
+	tags, err := NormalizeTags(names)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Where("agent_id = ?", agentID).Delete(&AgentTag{}).Error; err != nil {
+		return fmt.Errorf("清除代理标签失败: %w", err)
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	records := make([]Tag, len(tags))
+	for i, name := range tags {
+		records[i] = Tag{Name: name}
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&records).Error; err != nil {
+		return fmt.Errorf("创建标签失败: %w", err)
+	}
+
+	var ids []int64
+	if err := tx.Model(&Tag{}).Where("name IN ?", tags).Pluck("id", &ids).Error; err != nil {
+		return fmt.Errorf("获取标签失败: %w", err)
+	}
+	links := make([]AgentTag, len(ids))
+	for i, id := range ids {
+		links[i] = AgentTag{AgentID: agentID, TagID: id}
+	}
+	if err := tx.Create(&links).Error; err != nil {
+		return fmt.Errorf("关联代理标签失败: %w", err)
+	}
+	return nil
+}
+
+// LoadAgentTags 为代理列表填充Tags字段
+func LoadAgentTags(db *gorm.DB, agents []Agent) error {
+	if len(agents) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(agents))
+	for i := range agents {
+		ids[i] = agents[i].ID
+	}
+
+	var rows []struct {
+		AgentID int64
+		Name    string
+	}
+	err := db.Table("agent_tags").
+		Select("agent_tags.agent_id, tags.name").
+		Joins("JOIN tags ON tags.id = agent_tags.tag_id").
+		Where("agent_tags.agent_id IN ?", ids).
+		Order("tags.name").
+		Scan(&rows).Error
+	if err != nil {
+		return fmt.Errorf("获取代理标签失败: %w", err)
+	}
+
+	byAgent := make(map[int64][]string, len(agents))
+	for _, row := range rows {
+		byAgent[row.AgentID] = append(byAgent[row.AgentID], row.Name)
+	}
+	for i := range agents {
+		agents[i].Tags = byAgent[agents[i].ID]
+		if agents[i].Tags == nil {
+			agents[i].Tags = []string{}
+		}
+	}
+	return nil
+}
+
+// LoadTags 填充单个代理的Tags字段
+func (a *Agent) LoadTags(db *gorm.DB) error {
+	agents := []Agent{{ID: a.ID}}
+	if err := LoadAgentTags(db, agents); err != nil {
+		return err
+	}
+	a.Tags = agents[0].Tags
+	return nil
+}
+
+// tagCounts 统计标签被公开代理使用的次数，按使用次数倒序
+func tagCounts(db *gorm.DB) *gorm.DB {
+	return db.Table("tags").
+		Select("tags.name, COUNT(*) AS count").
+		Joins("JOIN agent_tags ON agent_tags.tag_id = tags.id").
+		Joins("JOIN agents ON agents.id = agent_tags.agent_id AND agents.is_public = ? AND agents.deleted_at IS NULL", true).
+		Group("tags.id, tags.name").
+		Order("COUNT(*) DESC, tags.name")
+}
+
+// ListPopularTags 获取公开代理使用的标签，prefix不为空时只返回以其开头的标签
+func ListPopularTags(db *gorm.DB, prefix string, limit int) ([]TagCount, error) {
+	query := tagCounts(db)
+	if prefix = strings.ToLower(strings.TrimSpace(prefix)); prefix != "" {
+		query = query.Where("tags.name LIKE ?", escapeLike(prefix)+"%")
+	}
+
+	tags := []TagCount{}
+	if err := query.Limit(limit).Scan(&tags).Error; err != nil {
+		return nil, fmt.Errorf("获取标签失败: %w", err)
+	}
+	return tags, nil
+}
+
+// SuggestTagsInput 标签推荐的依据，均可为空
+type SuggestTagsInput struct {
+	Prefix   string // 正在输入的标签前缀
+	Text     string // 代理名称和描述，出现在其中的已有标签优先推荐
+	Category string // 代理分类，推荐同分类代理常用的标签
+	Exclude  []string
+}
+
+// SuggestTags 创建代理时推荐标签
+//
+// 有前缀时按前缀补全；否则依次推荐名称描述中出现的已有标签、同分类常用标签和全站热门标签。
+func SuggestTags(db *gorm.DB, in SuggestTagsInput, limit int) ([]string, error) {
+	exclude := make(map[string]bool, len(in.Exclude))
+	for _, name := range in.Exclude {
+		exclude[strings.ToLower(strings.TrimSpace(name))] = true
+	}
+	suggestions := make([]string, 0, limit)
+	add := func(tags []TagCount) {
+		for _, tag := range tags {
+			if len(suggestions) >= limit {
+				return
+			}
+			if !exclude[tag.Name] {
+				exclude[tag.Name] = true
+				suggestions = append(suggestions, tag.Name)
+			}
+		}
+	}
+
+	if strings.TrimSpace(in.Prefix) != "" {
+		tags, err := ListPopularTags(db, in.Prefix, limit+len(in.Exclude))
+		if err != nil {
+			return nil, err
+		}
+		add(tags)
+		return suggestions, nil
+	}
+
+	// 候选范围为使用最多的标签，标签库较小时即为全部标签
+	popular, err := ListPopularTags(db, "", 200)
+	if err != nil {
+		return nil, err
+	}
+
+	if text := strings.ToLower(in.Text); text != "" {
+		var matched []TagCount
+		for _, tag := range popular {
+			if strings.Contains(text, tag.Name) {
+				matched = append(matched, tag)
+			}
+		}
+		// 较长的标签更具体，优先推荐
+		sort.SliceStable(matched, func(i, j int) bool {
+			return utf8.RuneCountInString(matched[i].Name) > utf8.RuneCountInString(matched[j].Name)
+		})
+		add(matched)
+	}
+
+	if in.Category != "" {
+		var inCategory []TagCount
+		if err := tagCounts(db).Where("agents.category = ?", in.Category).Limit(limit * 2).Scan(&inCategory).Error; err != nil {
+			return nil, fmt.Errorf("获取分类标签失败: %w", err)
+		}
+		add(inCategory)
+	}
+
+	add(popular)
+	return suggestions, nil
+}
+
+// escapeLike 转义LIKE通配符
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
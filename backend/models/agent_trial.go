@@ -0,0 +1,198 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrTrialUnavailable = errors.New("该代理不支持试用")
+	ErrTrialOwned       = errors.New("您已拥有此代理，无需试用")
+	ErrTrialExhausted   = errors.New("试用次数已用完，请购买后使用")
+	ErrTrialNotFound    = errors.New("试用记录不存在")
+)
+
+// AgentTrialUsage 用户对付费代理的试用次数计数
+type AgentTrialUsage struct {
+	UserID      string     `json:"userId" gorm:"column:user_id;primaryKey"`
+	AgentID     int64      `json:"agentId" gorm:"column:agent_id;primaryKey;index"`
+	Used        int        `json:"used" gorm:"not null;default:0"`
+	ConvertedAt *time.Time `json:"convertedAt" gorm:"column:converted_at"` // 试用后购买的时间
+	WorkflowID  *int64     `json:"workflowId" gorm:"column:workflow_id"`   // 试用后购买生成的工作流
+	CreatedAt   time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (AgentTrialUsage) TableName() string {
+	return "agent_trial_usages"
+}
+
+// AgentTrialRun 一次试用执行，直接运行代理定义，不创建工作流
+type AgentTrialRun struct {
+	ID             int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	AgentID        int64           `json:"agentId" gorm:"column:agent_id;index;not null"`
+	AgentVersion   string          `json:"agentVersion" gorm:"column:agent_version;type:varchar(32);not null;default:''"`
+	UserID         string          `json:"userId" gorm:"column:user_id;index;not null"`
+	OrganizationID *int64          `json:"organizationId" gorm:"column:organization_id;index"` // 发起试用时所在的组织，计入该工作空间的执行配额
+	Status         ExecutionStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	InputData      json.RawMessage `json:"inputData" gorm:"column:input_data;type:json"`
+	OutputData     json.RawMessage `json:"outputData" gorm:"column:output_data;type:json"` // 带水印的试用结果
+	Truncated      bool            `json:"truncated" gorm:"not null;default:false"`        // 结果超出试用大小限制被截断
+	ErrorMessage   string          `json:"errorMessage" gorm:"column:error_message;type:text"`
+	StartedAt      time.Time       `json:"startedAt" gorm:"column:started_at;not null"`
+	CompletedAt    *time.Time      `json:"completedAt" gorm:"column:completed_at"`
+	CreatedAt      time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AgentTrialRun) TableName() string {
+	return "agent_trial_runs"
+}
+
+// TrialStatus 用户对代理的试用情况
+type TrialStatus struct {
+	AgentID   int64 `json:"agentId"`
+	Limit     int   `json:"limit"`
+	Used      int   `json:"used"`
+	Remaining int   `json:"remaining"`
+}
+
+// GetTrialStatus 获取用户对代理的试用情况，limit为每个代理允许的试用次数
+func GetTrialStatus(db *gorm.DB, userID string, agentID int64, limit int) (*TrialStatus, error) {
+	var usage AgentTrialUsage
+	err := db.Where("user_id = ? AND agent_id = ?", userID, agentID).First(&usage).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取试用次数失败: %w", err)
+	}
+
+	status := &TrialStatus{AgentID: agentID, Limit: limit, Used: usage.Used}
+	if remaining := limit - usage.Used; remaining > 0 {
+		status.Remaining = remaining
+	}
+	return status, nil
+}
+
+// UseAgentTrial 占用一次试用次数，次数用完时返回ErrTrialExhausted，需在事务中调用
+func UseAgentTrial(tx *gorm.DB, userID string, agentID int64, limit int) error {
+	usage := AgentTrialUsage{UserID: userID, AgentID: agentID}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&usage).Error; err != nil {
+		return fmt.Errorf("创建试用计数失败: %w", err)
+	}
+
+	// 条件更新保证并发试用不会超出次数
+	result := tx.Model(&AgentTrialUsage{}).
+		Where("user_id = ? AND agent_id = ? AND used < ?", userID, agentID, limit).
+		Update("used", gorm.Expr("used + ?", 1))
+	if result.Error != nil {
+		return fmt.Errorf("更新试用次数失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTrialExhausted
+	}
+	return nil
+}
+
+// MarkTrialConverted 记录试用后购买，用户未试用过该代理时不做处理，需在事务中调用
+func MarkTrialConverted(tx *gorm.DB, userID string, agentID, workflowID int64) error {
+	now := time.Now()
+	err := tx.Model(&AgentTrialUsage{}).
+		Where("user_id = ? AND agent_id = ? AND converted_at IS NULL", userID, agentID).
+		Updates(map[string]interface{}{
+			"converted_at": now,
+			"workflow_id":  workflowID,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("记录试用转化失败: %w", err)
+	}
+	return nil
+}
+
+// GetAgentTrialRun 获取用户自己的试用记录
+func GetAgentTrialRun(db *gorm.DB, userID string, id int64) (*AgentTrialRun, error) {
+	var run AgentTrialRun
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTrialNotFound
+		}
+		return nil, fmt.Errorf("获取试用记录失败: %w", err)
+	}
+	return &run, nil
+}
+
+// ListAgentTrialRuns 获取用户的试用记录，agentID为0时返回全部代理的记录
+func ListAgentTrialRuns(db *gorm.DB, userID string, agentID int64, limit, offset int) ([]AgentTrialRun, int64, error) {
+	query := db.Model(&AgentTrialRun{}).Where("user_id = ?", userID)
+	if agentID > 0 {
+		query = query.Where("agent_id = ?", agentID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计试用记录失败: %w", err)
+	}
+	runs := []AgentTrialRun{}
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&runs).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取试用记录失败: %w", err)
+	}
+	return runs, total, nil
+}
+
+// CountActiveTrialRuns 统计工作空间中运行中的试用数量
+func CountActiveTrialRuns(db *gorm.DB, ws Workspace) (int64, error) {
+	var count int64
+	err := db.Model(&AgentTrialRun{}).
+		Scopes(ws.Scope("agent_trial_runs")).
+		Where("status IN ?", []ExecutionStatus{ExecutionStatusPending, ExecutionStatusRunning}).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计运行中的试用失败: %w", err)
+	}
+	return count, nil
+}
+
+// CountTrialRunsSince 统计工作空间自指定时间以来发起的试用次数
+func CountTrialRunsSince(db *gorm.DB, ws Workspace, since time.Time) (int64, error) {
+	var count int64
+	err := db.Model(&AgentTrialRun{}).
+		Scopes(ws.Scope("agent_trial_runs")).
+		Where("created_at >= ?", since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计试用次数失败: %w", err)
+	}
+	return count, nil
+}
+
+// WatermarkTrialOutput 为试用结果附加水印，结果超过maxBytes时截断为文本预览
+func WatermarkTrialOutput(output json.RawMessage, maxBytes int, watermark string) (json.RawMessage, bool, error) {
+	var result interface{} = output
+	truncated := false
+	if len(output) == 0 {
+		result = nil
+	} else if maxBytes > 0 && len(output) > maxBytes {
+		cut := maxBytes
+		for cut > 0 && !utf8.RuneStart(output[cut]) {
+			cut--
+		}
+		result = string(output[:cut])
+		truncated = true
+	}
+
+	data, err := json.Marshal(map[string]interface{}{
+		"trial":     true,
+		"watermark": watermark,
+		"truncated": truncated,
+		"result":    result,
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("生成试用结果失败: %w", err)
+	}
+	return data, truncated, nil
+}
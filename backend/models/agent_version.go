@@ -0,0 +1,249 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// InitialAgentVersion 代理的初始版本号
+const InitialAgentVersion = "1.0.0"
+
+var (
+	ErrAgentVersionInvalid   = errors.New("版本号格式无效，应为 主版本.次版本.修订号，例如 1.2.0")
+	ErrAgentVersionNotNewer  = errors.New("新版本号必须大于当前版本号")
+	ErrAgentVersionNotFound  = errors.New("代理版本不存在")
+	ErrAgentVersionImmutable = errors.New("代理版本发布后不可修改或删除")
+)
+
+// Semver 语义化版本号
+type Semver struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// ParseSemver 解析 主版本.次版本.修订号 格式的版本号，允许v前缀
+func ParseSemver(s string) (Semver, error) {
+	parts := strings.Split(strings.TrimPrefix(strings.TrimSpace(s), "v"), ".")
+	if len(parts) != 3 {
+		return Semver{}, ErrAgentVersionInvalid
+	}
+	var nums [3]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (len(part) > 1 && part[0] == '0') {
+			return Semver{}, ErrAgentVersionInvalid
+		}
+		nums[i] = n
+	}
+	return Semver{Major: nums[0], Minor: nums[1], Patch: nums[2]}, nil
+}
+
+// String 格式化版本号
+func (v Semver) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Compare 比较版本号，小于、等于、大于other时分别返回-1、0、1
+func (v Semver) Compare(other Semver) int {
+	switch {
+	case v.Major != other.Major:
+		return compareInt(v.Major, other.Major)
+	case v.Minor != other.Minor:
+		return compareInt(v.Minor, other.Minor)
+	default:
+		return compareInt(v.Patch, other.Patch)
+	}
+}
+
+func compareInt(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// NextPatch 下一个修订版本号
+func (v Semver) NextPatch() Semver {
+	return Semver{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1}
+}
+
+// AgentVersion 代理定义的发布版本，发布后不可修改
+type AgentVersion struct {
+	ID         int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	AgentID    int64           `json:"agentId" gorm:"column:agent_id;not null;uniqueIndex:idx_agent_version"`
+	Version    string          `json:"version" gorm:"type:varchar(32);not null;uniqueIndex:idx_agent_version"`
+	Changelog  string          `json:"changelog" gorm:"type:text"`
+	Definition json.RawMessage `json:"definition" gorm:"type:json"`
+	CreatedBy  string          `json:"createdBy" gorm:"column:created_by"` // 发布人用户ID
+	CreatedAt  time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (AgentVersion) TableName() string {
+	return "agent_versions"
+}
+
+// BeforeUpdate 禁止修改已发布的版本
+func (v *AgentVersion) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAgentVersionImmutable
+}
+
+// BeforeDelete 禁止删除已发布的版本
+func (v *AgentVersion) BeforeDelete(tx *gorm.DB) error {
+	return ErrAgentVersionImmutable
+}
+
+// CreateVersionedAgent 创建代理并记录初始版本，version为空时使用InitialAgentVersion
+func CreateVersionedAgent(db *gorm.DB, agent *Agent, version, changelog, createdBy string) error {
+	if version == "" {
+		version = InitialAgentVersion
+	}
+	parsed, err := ParseSemver(version)
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		agent.LatestVersion = parsed.String()
+		if err := tx.Create(agent).Error; err != nil {
+			return err
+		}
+		initial := AgentVersion{
+			AgentID:    agent.ID,
+			Version:    agent.LatestVersion,
+			Changelog:  changelog,
+			Definition: agent.Definition,
+			CreatedBy:  createdBy,
+		}
+		if err := tx.Create(&initial).Error; err != nil {
+			return fmt.Errorf("记录初始版本失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// PublishAgentVersionInput 发布代理版本输入
+type PublishAgentVersionInput struct {
+	Version    string // 为空时在当前版本基础上递增修订号
+	Changelog  string
+	Definition json.RawMessage
+	CreatedBy  string
+}
+
+// PublishAgentVersion 为代理发布新版本并更新代理的当前定义，需在事务中调用
+//
+// 代理尚无版本记录（版本功能上线前创建）时，先将当前定义记录为初始版本，作为已购买工作流的升级基线。
+func PublishAgentVersion(tx *gorm.DB, agent *Agent, input PublishAgentVersionInput) (*AgentVersion, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(agent, agent.ID).Error; err != nil {
+		return nil, err
+	}
+
+	if agent.LatestVersion == "" {
+		initial := AgentVersion{
+			AgentID:    agent.ID,
+			Version:    InitialAgentVersion,
+			Changelog:  "初始版本",
+			Definition: agent.Definition,
+		}
+		if err := tx.Create(&initial).Error; err != nil {
+			return nil, fmt.Errorf("记录初始版本失败: %w", err)
+		}
+		agent.LatestVersion = InitialAgentVersion
+	}
+
+	latest, err := ParseSemver(agent.LatestVersion)
+	if err != nil {
+		return nil, err
+	}
+	next := latest.NextPatch()
+	if input.Version != "" {
+		if next, err = ParseSemver(input.Version); err != nil {
+			return nil, err
+		}
+		if next.Compare(latest) <= 0 {
+			return nil, ErrAgentVersionNotNewer
+		}
+	}
+
+	version := AgentVersion{
+		AgentID:    agent.ID,
+		Version:    next.String(),
+		Changelog:  input.Changelog,
+		Definition: input.Definition,
+		CreatedBy:  input.CreatedBy,
+	}
+	if err := tx.Create(&version).Error; err != nil {
+		return nil, fmt.Errorf("发布代理版本失败: %w", err)
+	}
+
+	if err := tx.Model(agent).Updates(map[string]interface{}{
+		"definition":     input.Definition,
+		"latest_version": version.Version,
+	}).Error; err != nil {
+		return nil, err
+	}
+	agent.Definition = input.Definition
+	agent.LatestVersion = version.Version
+
+	return &version, nil
+}
+
+// GetAgentVersion 获取代理的指定版本，version为空时返回最早的版本
+//
+// 版本功能上线前购买的工作流没有记录来源版本，以最早的版本作为升级基线。
+func GetAgentVersion(db *gorm.DB, agentID int64, version string) (*AgentVersion, error) {
+	query := db.Where("agent_id = ?", agentID)
+	if version != "" {
+		query = query.Where("version = ?", version)
+	}
+
+	var v AgentVersion
+	if err := query.Order("id ASC").First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAgentVersionNotFound
+		}
+		return nil, fmt.Errorf("获取代理版本失败: %w", err)
+	}
+	return &v, nil
+}
+
+// ListAgentVersions 获取代理的全部版本，按发布时间倒序
+func ListAgentVersions(db *gorm.DB, agentID int64) ([]AgentVersion, error) {
+	var versions []AgentVersion
+	if err := db.Where("agent_id = ?", agentID).Order("id DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("获取代理版本列表失败: %w", err)
+	}
+	return versions, nil
+}
+
+// AgentVersionsSince 获取晚于指定版本的全部版本（用于展示升级的变更日志），按版本从新到旧排列
+func AgentVersionsSince(db *gorm.DB, agentID int64, version string) ([]AgentVersion, error) {
+	versions, err := ListAgentVersions(db, agentID)
+	if err != nil {
+		return nil, err
+	}
+	from, err := ParseSemver(version)
+	if err != nil {
+		// 没有来源版本时返回全部版本
+		return versions, nil
+	}
+
+	newer := make([]AgentVersion, 0, len(versions))
+	for _, v := range versions {
+		if parsed, err := ParseSemver(v.Version); err == nil && parsed.Compare(from) > 0 {
+			newer = append(newer, v)
+		}
+	}
+	return newer, nil
+}
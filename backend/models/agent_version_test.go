@@ -0,0 +1,55 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseSemver(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Semver
+		wantErr bool
+	}{
+		{"1.2.3", Semver{1, 2, 3}, false},
+		{"v0.10.0", Semver{0, 10, 0}, false},
+		{" 2.0.1 ", Semver{2, 0, 1}, false},
+		{"1.2", Semver{}, true},
+		{"1.2.3.4", Semver{}, true},
+		{"1.02.3", Semver{}, true},
+		{"1.-2.3", Semver{}, true},
+		{"1.x.3", Semver{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseSemver(tt.input)
+		if tt.wantErr {
+			if !errors.Is(err, ErrAgentVersionInvalid) {
+				t.Errorf("%q应返回ErrAgentVersionInvalid，实际为%v", tt.input, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q应解析为%v，实际为%v（%v）", tt.input, tt.want, got, err)
+		}
+	}
+}
+
+func TestSemverCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "1.0.1", -1},
+		{"1.2.0", "1.10.0", -1},
+		{"2.0.0", "1.99.99", 1},
+		{"1.1.0", "1.0.9", 1},
+	}
+	for _, tt := range tests {
+		a, _ := ParseSemver(tt.a)
+		b, _ := ParseSemver(tt.b)
+		if got := a.Compare(b); got != tt.want {
+			t.Errorf("%s与%s比较应为%d，实际为%d", tt.a, tt.b, tt.want, got)
+		}
+	}
+}
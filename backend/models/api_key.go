@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKeyScope API密钥的权限范围
+type APIKeyScope string
+
+const (
+	APIKeyScopeWorkflowsExecute APIKeyScope = "workflows:execute" // 执行工作流
+	APIKeyScopeExecutionsRead   APIKeyScope = "executions:read"   // 查看执行记录
+	APIKeyScopePointsRead       APIKeyScope = "points:read"       // 查看点数余额和交易记录
+)
+
+// AllAPIKeyScopes 全部可用的权限范围
+var AllAPIKeyScopes = []APIKeyScope{
+	APIKeyScopeWorkflowsExecute,
+	APIKeyScopeExecutionsRead,
+	APIKeyScopePointsRead,
+}
+
+// IsValidAPIKeyScope 检查权限范围是否有效
+func IsValidAPIKeyScope(scope string) bool {
+	for _, s := range AllAPIKeyScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// APIKeyPrefix API密钥的固定前缀，便于识别和密钥扫描
+	APIKeyPrefix = "jlk_"
+	// apiKeyIDLength 密钥中可公开展示部分的长度
+	apiKeyIDLength = 8
+	// MaxAPIKeysPerUser 每个用户可同时持有的有效密钥数量
+	MaxAPIKeysPerUser = 20
+	// apiKeyTouchInterval 最近使用时间的最小更新间隔，避免每次请求都写库
+	apiKeyTouchInterval = time.Minute
+)
+
+// API密钥相关错误
+var (
+	ErrAPIKeyInvalid       = errors.New("无效的API密钥")
+	ErrAPIKeyExpired       = errors.New("API密钥已过期")
+	ErrAPIKeyRevoked       = errors.New("API密钥已被吊销")
+	ErrAPIKeyNotFound      = errors.New("API密钥不存在")
+	ErrAPIKeyLimitExceeded = fmt.Errorf("每个用户最多可创建%d个API密钥", MaxAPIKeysPerUser)
+)
+
+// APIKey 用户创建的个人API密钥，只保存密钥的哈希值
+type APIKey struct {
+	ID         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID     string     `json:"userID" gorm:"column:user_id;index;not null"`
+	Name       string     `json:"name" gorm:"type:varchar(100);not null"`
+	Prefix     string     `json:"prefix" gorm:"type:varchar(20);not null"` // 密钥开头部分，用于在列表中识别密钥
+	KeyHash    string     `json:"-" gorm:"column:key_hash;type:varchar(64);uniqueIndex;not null"`
+	Scopes     string     `json:"-" gorm:"type:varchar(255);not null"` // 逗号分隔的权限范围
+	ExpiresAt  *time.Time `json:"expiresAt" gorm:"column:expires_at"`  // 为空表示永不过期
+	LastUsedAt *time.Time `json:"lastUsedAt" gorm:"column:last_used_at"`
+	LastUsedIP string     `json:"lastUsedIp" gorm:"column:last_used_ip;type:varchar(64)"`
+	RevokedAt  *time.Time `json:"revokedAt" gorm:"column:revoked_at"`
+	CreatedAt  time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (APIKey) TableName() string {
+	return "api_keys"
+}
+
+// ScopeList 获取权限范围列表
+func (k *APIKey) ScopeList() []string {
+	if k.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(k.Scopes, ",")
+}
+
+// HasScope 检查密钥是否拥有指定权限范围
+func (k *APIKey) HasScope(scope APIKeyScope) bool {
+	for _, s := range k.ScopeList() {
+		if s == string(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive 密钥当前是否可用
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// CreateAPIKey 创建API密钥，返回只展示一次的密钥明文
+func CreateAPIKey(db *gorm.DB, userID, name string, scopes []string, expiresAt *time.Time) (string, *APIKey, error) {
+	secret, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("生成API密钥失败: %w", err)
+	}
+	// 去掉base64中的连字符和下划线，保证前缀部分可以整体双击选中
+	id := strings.NewReplacer("-", "", "_", "").Replace(secret)
+	if len(id) < apiKeyIDLength {
+		return "", nil, fmt.Errorf("生成API密钥失败")
+	}
+	prefix := APIKeyPrefix + id[:apiKeyIDLength]
+	plain := prefix + "_" + secret
+
+	key := &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		KeyHash:   HashToken(plain),
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expiresAt,
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&APIKey{}).
+			Where("user_id = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", userID, time.Now()).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count >= MaxAPIKeysPerUser {
+			return ErrAPIKeyLimitExceeded
+		}
+		return tx.Create(key).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrAPIKeyLimitExceeded) {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("保存API密钥失败: %w", err)
+	}
+
+	return plain, key, nil
+}
+
+// GetUserAPIKeys 获取用户的全部API密钥（含已吊销和已过期的）
+func GetUserAPIKeys(db *gorm.DB, userID string) ([]APIKey, error) {
+	var keys []APIKey
+	if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("获取API密钥失败: %w", err)
+	}
+	return keys, nil
+}
+
+// RevokeAPIKey 吊销用户的API密钥
+func RevokeAPIKey(db *gorm.DB, userID string, id int64) error {
+	result := db.Model(&APIKey{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("吊销API密钥失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// AuthenticateAPIKey 校验API密钥明文，返回对应的密钥记录
+func AuthenticateAPIKey(db *gorm.DB, plain string) (*APIKey, error) {
+	if !strings.HasPrefix(plain, APIKeyPrefix) {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	var key APIKey
+	if err := db.Where("key_hash = ?", HashToken(plain)).First(&key).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAPIKeyInvalid
+		}
+		return nil, fmt.Errorf("查询API密钥失败: %w", err)
+	}
+
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && !time.Now().Before(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+	return &key, nil
+}
+
+// TouchAPIKey 记录密钥的最近使用时间和IP，间隔过短且IP未变化时跳过
+func TouchAPIKey(db *gorm.DB, key *APIKey, ip string) error {
+	now := time.Now()
+	if key.LastUsedAt != nil && now.Sub(*key.LastUsedAt) < apiKeyTouchInterval && key.LastUsedIP == ip {
+		return nil
+	}
+
+	if err := db.Model(key).Updates(map[string]interface{}{
+		"last_used_at": now,
+		"last_used_ip": ip,
+	}).Error; err != nil {
+		return fmt.Errorf("更新API密钥使用记录失败: %w", err)
+	}
+	key.LastUsedAt = &now
+	key.LastUsedIP = ip
+	return nil
+}
@@ -0,0 +1,309 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize 彻底删除时每批处理的记录数
+const purgeBatchSize = 500
+
+var (
+	ErrDeletedNotFound        = errors.New("回收站中没有该记录，可能已超过保留期被彻底删除")
+	ErrWorkflowRunning        = errors.New("工作流有正在运行的执行，请先取消后再删除")
+	ErrExecutionRunning       = errors.New("只能删除已结束的执行记录，请先取消执行")
+	ErrExecutionWorkflowGone  = errors.New("执行记录所属的工作流已删除，请先恢复工作流")
+	ErrAgentPurchased         = errors.New("已有用户购买了此代理，无法删除，可改为下架")
+	ErrAgentListingNotAllowed = errors.New("只有已发布的代理可以上架")
+)
+
+// SoftDeleteWorkflow 软删除工作流及其执行记录，执行记录与工作流使用相同的删除时间，恢复工作流时一起恢复
+//
+// 之前单独删除的执行记录保留原删除时间，不随工作流恢复。
+func SoftDeleteWorkflow(db *gorm.DB, workflow *Workflow) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var running int64
+		if err := tx.Model(&WorkflowExecution{}).
+			Where("workflow_id = ? AND status IN ?", workflow.ID, []ExecutionStatus{ExecutionStatusPending, ExecutionStatusRunning}).
+			Count(&running).Error; err != nil {
+			return fmt.Errorf("检查运行中的执行失败: %w", err)
+		}
+		if running > 0 {
+			return ErrWorkflowRunning
+		}
+
+		now := time.Now()
+		if err := tx.Model(&WorkflowExecution{}).Where("workflow_id = ?", workflow.ID).Update("deleted_at", now).Error; err != nil {
+			return fmt.Errorf("删除执行记录失败: %w", err)
+		}
+		if err := tx.Model(workflow).Update("deleted_at", now).Error; err != nil {
+			return fmt.Errorf("删除工作流失败: %w", err)
+		}
+		workflow.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+		return nil
+	})
+}
+
+// RestoreWorkflow 恢复工作空间中已删除的工作流，以及与它一起删除的执行记录
+func RestoreWorkflow(db *gorm.DB, ws Workspace, id int64) (*Workflow, error) {
+	var workflow Workflow
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().
+			Scopes(ws.Scope("workflows")).
+			Where("id = ? AND deleted_at IS NOT NULL", id).
+			First(&workflow).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeletedNotFound
+			}
+			return fmt.Errorf("获取已删除的工作流失败: %w", err)
+		}
+
+		if err := tx.Unscoped().Model(&WorkflowExecution{}).
+			Where("workflow_id = ? AND deleted_at = ?", id, workflow.DeletedAt.Time).
+			Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("恢复执行记录失败: %w", err)
+		}
+		if err := tx.Unscoped().Model(&workflow).Update("deleted_at", nil).Error; err != nil {
+			return fmt.Errorf("恢复工作流失败: %w", err)
+		}
+		workflow.DeletedAt = gorm.DeletedAt{}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &workflow, nil
+}
+
+// ListDeletedWorkflows 获取工作空间回收站中的工作流，按删除时间倒序
+func ListDeletedWorkflows(db *gorm.DB, ws Workspace, limit, offset int) ([]Workflow, int64, error) {
+	query := db.Unscoped().Model(&Workflow{}).
+		Scopes(ws.Scope("workflows")).
+		Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计已删除的工作流失败: %w", err)
+	}
+	workflows := []Workflow{}
+	if err := query.Order("deleted_at DESC").Limit(limit).Offset(offset).Find(&workflows).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取已删除的工作流失败: %w", err)
+	}
+	return workflows, total, nil
+}
+
+// SoftDeleteExecution 软删除已结束的执行记录
+func SoftDeleteExecution(db *gorm.DB, execution *WorkflowExecution) error {
+	if execution.Status == ExecutionStatusPending || execution.Status == ExecutionStatusRunning {
+		return ErrExecutionRunning
+	}
+	if err := db.Delete(execution).Error; err != nil {
+		return fmt.Errorf("删除执行记录失败: %w", err)
+	}
+	return nil
+}
+
+// RestoreExecution 恢复工作空间中单独删除的执行记录，所属工作流已删除时需先恢复工作流
+func RestoreExecution(db *gorm.DB, ws Workspace, id int64) (*WorkflowExecution, error) {
+	var execution WorkflowExecution
+	if err := db.Unscoped().
+		Scopes(ws.Scope("workflow_executions")).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		First(&execution).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeletedNotFound
+		}
+		return nil, fmt.Errorf("获取已删除的执行记录失败: %w", err)
+	}
+
+	var count int64
+	if err := db.Model(&Workflow{}).Where("id = ?", execution.WorkflowID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("检查工作流失败: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrExecutionWorkflowGone
+	}
+
+	if err := db.Unscoped().Model(&execution).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("恢复执行记录失败: %w", err)
+	}
+	execution.DeletedAt = gorm.DeletedAt{}
+	return &execution, nil
+}
+
+// ListDeletedExecutions 获取工作空间回收站中单独删除的执行记录，随工作流删除的不在其中
+func ListDeletedExecutions(db *gorm.DB, ws Workspace, limit, offset int) ([]WorkflowExecution, int64, error) {
+	query := db.Unscoped().Model(&WorkflowExecution{}).
+		Scopes(ws.Scope("workflow_executions")).
+		Where("workflow_executions.deleted_at IS NOT NULL").
+		Joins("JOIN workflows ON workflows.id = workflow_executions.workflow_id AND workflows.deleted_at IS NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计已删除的执行记录失败: %w", err)
+	}
+	executions := []WorkflowExecution{}
+	if err := query.Select("workflow_executions.*").
+		Order("workflow_executions.deleted_at DESC").
+		Limit(limit).Offset(offset).
+		Find(&executions).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取已删除的执行记录失败: %w", err)
+	}
+	return executions, total, nil
+}
+
+// SoftDeleteAgent 软删除代理，已被购买的代理（含已删除但未彻底清除的工作流）不能删除，只能下架
+func SoftDeleteAgent(db *gorm.DB, agent *Agent) error {
+	var purchased int64
+	if err := db.Unscoped().Model(&Workflow{}).Where("agent_id = ?", agent.ID).Count(&purchased).Error; err != nil {
+		return fmt.Errorf("检查代理购买记录失败: %w", err)
+	}
+	if purchased > 0 {
+		return ErrAgentPurchased
+	}
+	if err := db.Delete(agent).Error; err != nil {
+		return fmt.Errorf("删除代理失败: %w", err)
+	}
+	return nil
+}
+
+// RestoreAgent 恢复已删除的代理，恢复后保持删除前的公开状态
+func RestoreAgent(db *gorm.DB, id int64) (*Agent, error) {
+	var agent Agent
+	if err := db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&agent).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeletedNotFound
+		}
+		return nil, fmt.Errorf("获取已删除的代理失败: %w", err)
+	}
+	if err := db.Unscoped().Model(&agent).Update("deleted_at", nil).Error; err != nil {
+		return nil, fmt.Errorf("恢复代理失败: %w", err)
+	}
+	agent.DeletedAt = gorm.DeletedAt{}
+	return &agent, nil
+}
+
+// ListDeletedAgents 获取回收站中的代理，按删除时间倒序
+func ListDeletedAgents(db *gorm.DB, limit, offset int) ([]Agent, int64, error) {
+	query := db.Unscoped().Model(&Agent{}).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计已删除的代理失败: %w", err)
+	}
+	agents := []Agent{}
+	if err := query.Order("deleted_at DESC").Limit(limit).Offset(offset).Find(&agents).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取已删除的代理失败: %w", err)
+	}
+	return agents, total, nil
+}
+
+// SetAgentListing 上架或下架代理
+//
+// 下架只是不在商店、搜索、合集和套餐中展示，已购买的工作流和升级不受影响；上架要求代理已发布。
+func SetAgentListing(db *gorm.DB, agent *Agent, listed bool, reason string) error {
+	updates := map[string]interface{}{
+		"is_public":     true,
+		"unlisted_at":   nil,
+		"unlist_reason": "",
+	}
+	if listed {
+		if agent.Status != AgentStatusPublished {
+			return ErrAgentListingNotAllowed
+		}
+	} else {
+		updates["is_public"] = false
+		updates["unlisted_at"] = time.Now()
+		updates["unlist_reason"] = reason
+	}
+	if err := db.Model(agent).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新代理上架状态失败: %w", err)
+	}
+	return db.First(agent, agent.ID).Error
+}
+
+// PurgeDeletedExecutions 彻底删除软删除时间早于before的执行记录
+func PurgeDeletedExecutions(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Unscoped().Where("deleted_at < ?", before).Delete(&WorkflowExecution{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理已删除的执行记录失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// PurgeDeletedWorkflows 彻底删除软删除时间早于before的工作流及其全部执行记录
+//
+// 购买记录以积分流水和创作者分成为准，不随工作流删除。
+func PurgeDeletedWorkflows(db *gorm.DB, before time.Time) (int64, error) {
+	var total int64
+	for {
+		var ids []int64
+		if err := db.Unscoped().Model(&Workflow{}).
+			Where("deleted_at < ?", before).
+			Limit(purgeBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return total, fmt.Errorf("获取待清理的工作流失败: %w", err)
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("workflow_id IN ?", ids).Delete(&WorkflowExecution{}).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&Workflow{}).Error
+		})
+		if err != nil {
+			return total, fmt.Errorf("清理已删除的工作流失败: %w", err)
+		}
+		total += int64(len(ids))
+		if len(ids) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// PurgeDeletedAgents 彻底删除软删除时间早于before的代理及其版本、标签、套餐和合集关联、试用记录
+//
+// 仍被工作流或创作者分成记录引用的代理不清理。
+func PurgeDeletedAgents(db *gorm.DB, before time.Time) (int64, error) {
+	var total int64
+	for {
+		var ids []int64
+		if err := db.Unscoped().Model(&Agent{}).
+			Where("deleted_at < ?", before).
+			Where("NOT EXISTS (SELECT 1 FROM workflows WHERE workflows.agent_id = agents.id)").
+			Where("NOT EXISTS (SELECT 1 FROM creator_earnings WHERE creator_earnings.agent_id = agents.id)").
+			Limit(purgeBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return total, fmt.Errorf("获取待清理的代理失败: %w", err)
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			// 版本记录禁止删除，清理代理时跳过钩子
+			if err := tx.Session(&gorm.Session{SkipHooks: true}).Where("agent_id IN ?", ids).Delete(&AgentVersion{}).Error; err != nil {
+				return err
+			}
+			for _, model := range []interface{}{&AgentTag{}, &AgentBundleItem{}, &AgentCollectionItem{}, &AgentTrialUsage{}, &AgentTrialRun{}} {
+				if err := tx.Where("agent_id IN ?", ids).Delete(model).Error; err != nil {
+					return err
+				}
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&Agent{}).Error
+		})
+		if err != nil {
+			return total, fmt.Errorf("清理已删除的代理失败: %w", err)
+		}
+		total += int64(len(ids))
+		if len(ids) < purgeBatchSize {
+			return total, nil
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AuditAction 审计事件类型
+type AuditAction string
+
+const (
+	AuditActionLogin            AuditAction = "auth.login"             // 登录成功
+	AuditActionLoginFailed      AuditAction = "auth.login_failed"      // 登录失败
+	AuditActionPasswordChange   AuditAction = "user.password_change"   // 修改密码
+	AuditActionPasswordReset    AuditAction = "user.password_reset"    // 通过邮件重置密码
+	AuditActionSettingsChange   AuditAction = "user.settings_change"   // 修改个人设置
+	AuditActionRoleChange       AuditAction = "user.role_change"       // 修改用户角色
+	AuditActionOrgRoleChange    AuditAction = "org.member_role_change" // 修改组织成员角色
+	AuditActionOrgMemberRemove  AuditAction = "org.member_remove"      // 移除组织成员
+	AuditActionAgentCreate      AuditAction = "agent.create"           // 创建代理
+	AuditActionAgentUpdate      AuditAction = "agent.update"           // 更新代理（含价格变更）
+	AuditActionAgentDelete      AuditAction = "agent.delete"           // 删除代理
+	AuditActionPurchase         AuditAction = "points.purchase"        // 购买代理
+	AuditActionRechargeCreate   AuditAction = "points.recharge_create" // 创建充值订单
+	AuditActionRecharge         AuditAction = "points.recharge"        // 充值到账
+	AuditActionAgentSubmit      AuditAction = "agent.submit"           // 创作者提交审核
+	AuditActionAgentApprove     AuditAction = "agent.approve"          // 审核通过
+	AuditActionAgentReject      AuditAction = "agent.reject"           // 审核拒绝
+	AuditActionAgentPublish     AuditAction = "agent.publish"          // 创作者发布
+	AuditActionPayoutRequest    AuditAction = "creator.payout_request" // 创作者申请提现
+	AuditActionPayoutProcess    AuditAction = "creator.payout_process" // 处理提现申请
+	AuditActionAgentFeature     AuditAction = "agent.feature"          // 设置推荐位
+	AuditActionCollectionUpdate AuditAction = "collection.update"      // 创建或修改合集
+	AuditActionCollectionDelete AuditAction = "collection.delete"      // 删除合集
+	AuditActionAgentSale        AuditAction = "agent.sale"             // 设置限时特价
+	AuditActionBundleUpdate     AuditAction = "bundle.update"          // 创建或修改套餐
+	AuditActionBundleDelete     AuditAction = "bundle.delete"          // 删除套餐
+	AuditActionBundlePurchase   AuditAction = "points.bundle_purchase" // 购买套餐
+	AuditActionAgentExport      AuditAction = "agent.export"           // 导出代理包
+	AuditActionAgentImport      AuditAction = "agent.import"           // 导入代理包
+	AuditActionAgentRestore     AuditAction = "agent.restore"          // 从回收站恢复代理
+	AuditActionAgentListing     AuditAction = "agent.listing"          // 上架或下架代理
+)
+
+// ErrAuditEventImmutable 审计事件只能追加，不能修改或删除
+var ErrAuditEventImmutable = errors.New("审计事件不可修改或删除")
+
+// AuditEvent 审计事件，只追加不修改
+type AuditEvent struct {
+	ID             int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Action         AuditAction     `json:"action" gorm:"type:varchar(50);not null;index"`
+	ActorID        string          `json:"actorId" gorm:"column:actor_id;index"` // 操作人用户ID，未登录的操作（如登录失败）为空
+	ActorRole      string          `json:"actorRole" gorm:"column:actor_role;type:varchar(20)"`
+	AuthMethod     string          `json:"authMethod" gorm:"column:auth_method;type:varchar(20)"` // jwt或api_key
+	OrganizationID *int64          `json:"organizationId" gorm:"column:organization_id;index"`    // 操作所在的组织工作空间
+	TargetType     string          `json:"targetType" gorm:"column:target_type;type:varchar(50);index:idx_audit_target"`
+	TargetID       string          `json:"targetId" gorm:"column:target_id;type:varchar(100);index:idx_audit_target"`
+	IP             string          `json:"ip" gorm:"column:ip;type:varchar(64)"`
+	UserAgent      string          `json:"userAgent" gorm:"column:user_agent;type:varchar(255)"`
+	RequestID      string          `json:"requestId" gorm:"column:request_id;type:varchar(64);index"`
+	Before         json.RawMessage `json:"before" gorm:"type:json"` // 变更前快照
+	After          json.RawMessage `json:"after" gorm:"type:json"`  // 变更后快照
+	Description    string          `json:"description" gorm:"type:varchar(255)"`
+	CreatedAt      time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime;index"`
+}
+
+// TableName 指定表名
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// BeforeUpdate 禁止修改审计事件
+func (e *AuditEvent) BeforeUpdate(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// BeforeDelete 禁止删除审计事件
+func (e *AuditEvent) BeforeDelete(tx *gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// AuditSnapshot 将对象序列化为审计快照，nil返回空快照
+func AuditSnapshot(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// CreateAuditEvent 记录审计事件，涉及资金的操作应在同一事务中调用
+func CreateAuditEvent(db *gorm.DB, event *AuditEvent) error {
+	event.UserAgent = truncateString(event.UserAgent, 255)
+	event.Description = truncateString(event.Description, 255)
+	if err := db.Create(event).Error; err != nil {
+		return fmt.Errorf("记录审计事件失败: %w", err)
+	}
+	return nil
+}
+
+// AuditEventFilter 审计事件查询条件
+type AuditEventFilter struct {
+	Action     string
+	ActorID    string
+	TargetType string
+	TargetID   string
+	RequestID  string
+	From       *time.Time
+	To         *time.Time
+}
+
+// scope 按查询条件过滤
+func (f AuditEventFilter) scope(db *gorm.DB) *gorm.DB {
+	if f.Action != "" {
+		db = db.Where("action = ?", f.Action)
+	}
+	if f.ActorID != "" {
+		db = db.Where("actor_id = ?", f.ActorID)
+	}
+	if f.TargetType != "" {
+		db = db.Where("target_type = ?", f.TargetType)
+	}
+	if f.TargetID != "" {
+		db = db.Where("target_id = ?", f.TargetID)
+	}
+	if f.RequestID != "" {
+		db = db.Where("request_id = ?", f.RequestID)
+	}
+	if f.From != nil {
+		db = db.Where("created_at >= ?", *f.From)
+	}
+	if f.To != nil {
+		db = db.Where("created_at < ?", *f.To)
+	}
+	return db
+}
+
+// ListAuditEvents 按条件分页查询审计事件，按时间倒序；limit为0时返回全部
+func ListAuditEvents(db *gorm.DB, filter AuditEventFilter, limit, offset int) ([]AuditEvent, int64, error) {
+	var total int64
+	if err := db.Model(&AuditEvent{}).Scopes(filter.scope).Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计审计事件失败: %w", err)
+	}
+
+	query := db.Scopes(filter.scope).Order("created_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+
+	var events []AuditEvent
+	if err := query.Find(&events).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询审计事件失败: %w", err)
+	}
+	return events, total, nil
+}
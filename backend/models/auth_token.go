@@ -0,0 +1,250 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 刷新令牌相关错误
+var (
+	ErrRefreshTokenInvalid = errors.New("无效的刷新令牌")
+	ErrRefreshTokenExpired = errors.New("刷新令牌已过期")
+	ErrRefreshTokenReused  = errors.New("刷新令牌已被使用，已注销该令牌所属的全部会话")
+)
+
+// RefreshToken 刷新令牌，只保存令牌的哈希值
+type RefreshToken struct {
+	ID           int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID       string     `json:"userID" gorm:"column:user_id;index;not null"`
+	TokenHash    string     `json:"-" gorm:"column:token_hash;type:varchar(64);uniqueIndex;not null"`
+	FamilyID     string     `json:"familyId" gorm:"column:family_id;type:varchar(36);index;not null"` // 同一次登录轮换产生的令牌属于同一家族
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"column:expires_at;not null;index"`
+	RevokedAt    *time.Time `json:"revokedAt" gorm:"column:revoked_at"`
+	ReplacedByID *int64     `json:"replacedById" gorm:"column:replaced_by_id"` // 轮换后的新令牌
+	UserAgent    string     `json:"userAgent" gorm:"column:user_agent;type:varchar(255)"`
+	IP           string     `json:"ip" gorm:"column:ip;type:varchar(64)"`
+	MFAVerified  bool       `json:"mfaVerified" gorm:"column:mfa_verified;not null;default:false"` // 该会话登录时是否通过了两步验证
+	CreatedAt    time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// TokenRevocation 访问令牌吊销记录
+//
+// JTI不为空时吊销单个访问令牌；JTI为空时吊销该用户在RevokedAt之前签发的全部访问令牌。
+type TokenRevocation struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	JTI       string    `json:"jti" gorm:"column:jti;type:varchar(64);index"`
+	UserID    string    `json:"userID" gorm:"column:user_id;index;not null"`
+	RevokedAt time.Time `json:"revokedAt" gorm:"column:revoked_at;not null"`
+	ExpiresAt time.Time `json:"expiresAt" gorm:"column:expires_at;not null;index"` // 被吊销令牌的最晚过期时间，之后可清理
+}
+
+// TableName 指定表名
+func (TokenRevocation) TableName() string {
+	return "token_revocations"
+}
+
+// RefreshTokenMeta 签发刷新令牌时记录的客户端信息
+type RefreshTokenMeta struct {
+	UserAgent   string
+	IP          string
+	MFAVerified bool
+}
+
+// IssueRefreshToken 签发新的刷新令牌，familyID为空时开启新的令牌家族，返回令牌明文
+func IssueRefreshToken(db *gorm.DB, userID, familyID string, ttl time.Duration, meta RefreshTokenMeta) (string, *RefreshToken, error) {
+	plain, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("生成刷新令牌失败: %w", err)
+	}
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	token := &RefreshToken{
+		UserID:      userID,
+		TokenHash:   HashToken(plain),
+		FamilyID:    familyID,
+		ExpiresAt:   time.Now().Add(ttl),
+		UserAgent:   truncateString(meta.UserAgent, 255),
+		IP:          meta.IP,
+		MFAVerified: meta.MFAVerified,
+	}
+	if err := db.Create(token).Error; err != nil {
+		return "", nil, fmt.Errorf("保存刷新令牌失败: %w", err)
+	}
+
+	return plain, token, nil
+}
+
+// RotateRefreshToken 使用刷新令牌换取新的刷新令牌
+//
+// 已被使用或吊销的令牌再次出现视为泄露，会吊销整个令牌家族。
+func RotateRefreshToken(db *gorm.DB, plain string, ttl time.Duration, meta RefreshTokenMeta) (string, *RefreshToken, error) {
+	var newPlain string
+	var newToken *RefreshToken
+	reused := false
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(plain)).
+			First(&current).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrRefreshTokenInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		if current.RevokedAt != nil {
+			// 令牌重复使用：吊销整个家族，事务需提交
+			reused = true
+			return revokeRefreshTokenFamily(tx, current.FamilyID, now)
+		}
+		if now.After(current.ExpiresAt) {
+			return ErrRefreshTokenExpired
+		}
+
+		// 两步验证状态随令牌家族延续
+		meta.MFAVerified = current.MFAVerified
+		newPlain, newToken, err = IssueRefreshToken(tx, current.UserID, current.FamilyID, ttl, meta)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&current).Updates(map[string]interface{}{
+			"revoked_at":     now,
+			"replaced_by_id": newToken.ID,
+		}).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	if reused {
+		return "", nil, ErrRefreshTokenReused
+	}
+
+	return newPlain, newToken, nil
+}
+
+// RevokeRefreshToken 吊销用户的单个刷新令牌
+func RevokeRefreshToken(db *gorm.DB, userID, plain string) error {
+	err := db.Model(&RefreshToken{}).
+		Where("token_hash = ? AND user_id = ? AND revoked_at IS NULL", HashToken(plain), userID).
+		Update("revoked_at", time.Now()).Error
+	if err != nil {
+		return fmt.Errorf("吊销刷新令牌失败: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken 将单个访问令牌加入吊销列表
+func RevokeAccessToken(db *gorm.DB, userID, jti string, expiresAt time.Time) error {
+	revocation := &TokenRevocation{
+		JTI:       jti,
+		UserID:    userID,
+		RevokedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	if err := db.Create(revocation).Error; err != nil {
+		return fmt.Errorf("吊销访问令牌失败: %w", err)
+	}
+	return nil
+}
+
+// RevokeAllUserTokens 吊销用户的全部刷新令牌，并递增令牌代数使此前签发的全部访问令牌失效
+//
+// 访问令牌的签发时间只精确到秒，按时间比较会误伤同一秒内重新登录签发的令牌，因此使用代数判断。
+func RevokeAllUserTokens(db *gorm.DB, userID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("吊销刷新令牌失败: %w", err)
+		}
+
+		if err := tx.Model(&User{}).
+			Where("user_id = ?", userID).
+			UpdateColumn("token_generation", gorm.Expr("token_generation + 1")).Error; err != nil {
+			return fmt.Errorf("吊销访问令牌失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// IsAccessTokenRevoked 检查访问令牌是否已被单独吊销，整体吊销通过令牌代数判断
+//
+// 不带jti的记录是改用令牌代数之前的整体吊销记录，随访问令牌过期被清理。
+func IsAccessTokenRevoked(db *gorm.DB, userID, jti string, issuedAt time.Time) (bool, error) {
+	var count int64
+	err := db.Model(&TokenRevocation{}).
+		Where("(jti = ? AND jti <> '') OR (user_id = ? AND (jti = '' OR jti IS NULL) AND revoked_at > ?)",
+			jti, userID, issuedAt).
+		Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("查询令牌吊销记录失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// PurgeExpiredTokens 清理已过期的刷新令牌和吊销记录
+func PurgeExpiredTokens(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("expires_at < ?", now).Delete(&RefreshToken{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理过期刷新令牌失败: %w", result.Error)
+	}
+	affected := result.RowsAffected
+
+	result = db.Where("expires_at < ?", now).Delete(&TokenRevocation{})
+	if result.Error != nil {
+		return affected, fmt.Errorf("清理过期吊销记录失败: %w", result.Error)
+	}
+
+	return affected + result.RowsAffected, nil
+}
+
+// HashToken 计算令牌的SHA-256哈希
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// revokeRefreshTokenFamily 吊销整个令牌家族
+func revokeRefreshTokenFamily(tx *gorm.DB, familyID string, now time.Time) error {
+	return tx.Model(&RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+// generateOpaqueToken 生成随机的不透明令牌
+func generateOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// truncateString 截断超长字符串
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return s[:max]
+}
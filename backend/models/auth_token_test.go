@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB 创建临时SQLite数据库并迁移指定模型
+func newTestDB(t *testing.T, models ...interface{}) *gorm.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "models.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("打开测试数据库失败: %v", err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatalf("迁移测试数据库失败: %v", err)
+	}
+	return db
+}
+
+// activeFamilyTokens 统计令牌家族中尚未吊销的令牌数量
+func activeFamilyTokens(t *testing.T, db *gorm.DB, familyID string) int64 {
+	t.Helper()
+	var count int64
+	if err := db.Model(&RefreshToken{}).Where("family_id = ? AND revoked_at IS NULL", familyID).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	tests := []struct {
+		name string
+		// prepare 签发令牌并返回用于轮换的明文
+		prepare    func(t *testing.T, db *gorm.DB) string
+		wantErr    error
+		wantActive int64 // 轮换后家族中仍有效的令牌数量
+	}{
+		{
+			name: "有效令牌轮换为新令牌",
+			prepare: func(t *testing.T, db *gorm.DB) string {
+				plain, _, err := IssueRefreshToken(db, "USER_1", "family", time.Hour, RefreshTokenMeta{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return plain
+			},
+			wantActive: 1,
+		},
+		{
+			name: "已轮换的令牌再次使用时吊销整个家族",
+			prepare: func(t *testing.T, db *gorm.DB) string {
+				first, _, err := IssueRefreshToken(db, "USER_1", "family", time.Hour, RefreshTokenMeta{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				second, _, err := RotateRefreshToken(db, first, time.Hour, RefreshTokenMeta{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := RotateRefreshToken(db, second, time.Hour, RefreshTokenMeta{}); err != nil {
+					t.Fatal(err)
+				}
+				return first
+			},
+			wantErr:    ErrRefreshTokenReused,
+			wantActive: 0,
+		},
+		{
+			name: "已注销的令牌再次使用时吊销整个家族",
+			prepare: func(t *testing.T, db *gorm.DB) string {
+				plain, _, err := IssueRefreshToken(db, "USER_1", "family", time.Hour, RefreshTokenMeta{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if _, _, err := IssueRefreshToken(db, "USER_1", "family", time.Hour, RefreshTokenMeta{}); err != nil {
+					t.Fatal(err)
+				}
+				if err := RevokeRefreshToken(db, "USER_1", plain); err != nil {
+					t.Fatal(err)
+				}
+				return plain
+			},
+			wantErr:    ErrRefreshTokenReused,
+			wantActive: 0,
+		},
+		{
+			name: "过期令牌",
+			prepare: func(t *testing.T, db *gorm.DB) string {
+				plain, _, err := IssueRefreshToken(db, "USER_1", "family", -time.Minute, RefreshTokenMeta{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				return plain
+			},
+			wantErr:    ErrRefreshTokenExpired,
+			wantActive: 1,
+		},
+		{
+			name: "不存在的令牌",
+			prepare: func(t *testing.T, db *gorm.DB) string {
+				return "unknown"
+			},
+			wantErr: ErrRefreshTokenInvalid,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &RefreshToken{})
+			// 其他家族的令牌不受影响
+			if _, _, err := IssueRefreshToken(db, "USER_1", "other", time.Hour, RefreshTokenMeta{}); err != nil {
+				t.Fatal(err)
+			}
+			plain := tt.prepare(t, db)
+
+			newPlain, newToken, err := RotateRefreshToken(db, plain, time.Hour, RefreshTokenMeta{})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("错误应为%v，实际为%v", tt.wantErr, err)
+			}
+			if tt.wantErr == nil {
+				if newPlain == "" || newPlain == plain || newToken.FamilyID != "family" {
+					t.Errorf("应在同一家族中签发新令牌，实际为%q %+v", newPlain, newToken)
+				}
+				var old RefreshToken
+				db.Where("token_hash = ?", HashToken(plain)).First(&old)
+				if old.RevokedAt == nil || old.ReplacedByID == nil || *old.ReplacedByID != newToken.ID {
+					t.Errorf("旧令牌应被吊销并指向新令牌，实际为%+v", old)
+				}
+			}
+
+			if active := activeFamilyTokens(t, db, "family"); active != tt.wantActive {
+				t.Errorf("家族中应有%d个有效令牌，实际为%d", tt.wantActive, active)
+			}
+			if active := activeFamilyTokens(t, db, "other"); active != 1 {
+				t.Errorf("其他家族的令牌不应被吊销，实际有效%d个", active)
+			}
+		})
+	}
+}
+
+func TestIsAccessTokenRevoked(t *testing.T) {
+	db := newTestDB(t, &TokenRevocation{})
+	issuedAt := time.Now().Add(-time.Minute)
+	if err := RevokeAccessToken(db, "USER_1", "revoked-jti", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		userID string
+		jti    string
+		want   bool
+	}{
+		{"已吊销的令牌", "USER_1", "revoked-jti", true},
+		{"同一用户的其他令牌", "USER_1", "other-jti", false},
+		{"其他用户的令牌", "USER_2", "other-jti", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			revoked, err := IsAccessTokenRevoked(db, tt.userID, tt.jti, issuedAt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Errorf("吊销状态应为%v，实际为%v", tt.want, revoked)
+			}
+		})
+	}
+}
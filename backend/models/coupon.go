@@ -0,0 +1,288 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CouponType 优惠码类型
+type CouponType string
+
+const (
+	CouponTypePoints     CouponType = "points"      // 赠送固定点数
+	CouponTypePercentOff CouponType = "percent_off" // 购买代理时按百分比折扣
+	CouponTypeFreeAgent  CouponType = "free_agent"  // 免费获得指定代理
+)
+
+// 优惠码相关错误
+var (
+	ErrCouponNotFound      = errors.New("优惠码不存在")
+	ErrCouponInactive      = errors.New("优惠码已停用")
+	ErrCouponNotStarted    = errors.New("优惠码尚未生效")
+	ErrCouponExpired       = errors.New("优惠码已过期")
+	ErrCouponUsedUp        = errors.New("优惠码已被领完")
+	ErrCouponUserLimit     = errors.New("您已达到该优惠码的使用次数上限")
+	ErrCouponNotApplicable = errors.New("优惠码不适用于当前操作")
+)
+
+// Coupon 优惠码模型
+type Coupon struct {
+	ID             int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	Code           string     `json:"code" gorm:"type:varchar(32);uniqueIndex;not null"`
+	Type           CouponType `json:"type" gorm:"type:varchar(20);not null"`
+	Points         int        `json:"points" gorm:"default:0"`                                 // 赠送点数（points类型）
+	PercentOff     int        `json:"percentOff" gorm:"column:percent_off;default:0"`          // 折扣百分比（percent_off类型，1-100）
+	AgentID        *int64     `json:"agentId" gorm:"column:agent_id;index"`                    // 指定代理（free_agent类型必填，percent_off类型可选）
+	MaxUses        int        `json:"maxUses" gorm:"column:max_uses;not null"`                 // 总使用次数上限，0表示不限
+	MaxUsesPerUser int        `json:"maxUsesPerUser" gorm:"column:max_uses_per_user;not null"` // 每个用户使用次数上限，0表示不限
+	UsedCount      int        `json:"usedCount" gorm:"column:used_count;default:0"`            // 已使用次数
+	ValidFrom      *time.Time `json:"validFrom" gorm:"column:valid_from"`                      // 生效时间
+	ValidUntil     *time.Time `json:"validUntil" gorm:"column:valid_until"`                    // 失效时间
+	BatchNo        string     `json:"batchNo" gorm:"column:batch_no;type:varchar(64);index"`   // 批次号
+	Description    string     `json:"description" gorm:"type:varchar(255)"`                    // 备注
+	IsActive       bool       `json:"isActive" gorm:"column:is_active;default:true"`           // 是否启用
+	CreatedBy      string     `json:"createdBy" gorm:"column:created_by;type:varchar(50)"`     // 创建者UserID
+	CreatedAt      time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Coupon) TableName() string {
+	return "coupons"
+}
+
+// CouponRedemption 优惠码使用记录
+type CouponRedemption struct {
+	ID         int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	CouponID   int64      `json:"couponId" gorm:"column:coupon_id;index;not null"`
+	UserID     string     `json:"userID" gorm:"column:user_id;index;not null"`
+	CouponType CouponType `json:"couponType" gorm:"column:coupon_type;type:varchar(20);not null"`
+	Points     int        `json:"points" gorm:"default:0"`              // 获得的点数
+	Discount   int        `json:"discount" gorm:"default:0"`            // 抵扣的点数
+	AgentID    *int64     `json:"agentId" gorm:"column:agent_id"`       // 关联代理
+	WorkflowID *int64     `json:"workflowId" gorm:"column:workflow_id"` // 关联生成的工作流
+	CreatedAt  time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (CouponRedemption) TableName() string {
+	return "coupon_redemptions"
+}
+
+// CouponBatchCreateInput 批量生成优惠码输入
+type CouponBatchCreateInput struct {
+	Type           CouponType `json:"type" validate:"required"`
+	Count          int        `json:"count" validate:"required,min=1,max=1000"`
+	Prefix         string     `json:"prefix"`
+	Points         int        `json:"points"`
+	PercentOff     int        `json:"percentOff"`
+	AgentID        *int64     `json:"agentId"`
+	MaxUses        int        `json:"maxUses"`
+	MaxUsesPerUser int        `json:"maxUsesPerUser"`
+	ValidFrom      *time.Time `json:"validFrom"`
+	ValidUntil     *time.Time `json:"validUntil"`
+	Description    string     `json:"description"`
+}
+
+// Validate 校验批量生成参数
+func (in *CouponBatchCreateInput) Validate() error {
+	switch in.Type {
+	case CouponTypePoints:
+		if in.Points <= 0 {
+			return errors.New("点数优惠码必须设置赠送点数")
+		}
+	case CouponTypePercentOff:
+		if in.PercentOff <= 0 || in.PercentOff > 100 {
+			return errors.New("折扣百分比必须在1到100之间")
+		}
+	case CouponTypeFreeAgent:
+		if in.AgentID == nil {
+			return errors.New("免费代理优惠码必须指定代理")
+		}
+	default:
+		return errors.New("无效的优惠码类型")
+	}
+
+	if in.ValidFrom != nil && in.ValidUntil != nil && !in.ValidUntil.After(*in.ValidFrom) {
+		return errors.New("失效时间必须晚于生效时间")
+	}
+
+	return nil
+}
+
+// CreateCouponBatch 批量生成优惠码，返回批次号和生成的优惠码
+func CreateCouponBatch(db *gorm.DB, createdBy string, input CouponBatchCreateInput) (string, []*Coupon, error) {
+	if err := input.Validate(); err != nil {
+		return "", nil, err
+	}
+
+	batchNo := generateCouponBatchNo()
+	coupons := make([]*Coupon, 0, input.Count)
+	for i := 0; i < input.Count; i++ {
+		coupons = append(coupons, &Coupon{
+			Code:           generateCouponCode(input.Prefix),
+			Type:           input.Type,
+			Points:         input.Points,
+			PercentOff:     input.PercentOff,
+			AgentID:        input.AgentID,
+			MaxUses:        input.MaxUses,
+			MaxUsesPerUser: input.MaxUsesPerUser,
+			ValidFrom:      input.ValidFrom,
+			ValidUntil:     input.ValidUntil,
+			BatchNo:        batchNo,
+			Description:    input.Description,
+			IsActive:       true,
+			CreatedBy:      createdBy,
+		})
+	}
+
+	if err := db.CreateInBatches(coupons, 100).Error; err != nil {
+		return "", nil, fmt.Errorf("生成优惠码失败: %w", err)
+	}
+
+	return batchNo, coupons, nil
+}
+
+// GetCouponByCodeForUpdate 在事务中按优惠码查询并加行锁
+func GetCouponByCodeForUpdate(tx *gorm.DB, code string) (*Coupon, error) {
+	var coupon Coupon
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code = ?", NormalizeCouponCode(code)).
+		First(&coupon).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCouponNotFound
+		}
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+// CheckRedeemable 检查优惠码对指定用户是否可用
+func (c *Coupon) CheckRedeemable(db *gorm.DB, userID string, now time.Time) error {
+	if !c.IsActive {
+		return ErrCouponInactive
+	}
+	if c.ValidFrom != nil && now.Before(*c.ValidFrom) {
+		return ErrCouponNotStarted
+	}
+	if c.ValidUntil != nil && now.After(*c.ValidUntil) {
+		return ErrCouponExpired
+	}
+	if c.MaxUses > 0 && c.UsedCount >= c.MaxUses {
+		return ErrCouponUsedUp
+	}
+
+	if c.MaxUsesPerUser > 0 {
+		var used int64
+		if err := db.Model(&CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ?", c.ID, userID).
+			Count(&used).Error; err != nil {
+			return fmt.Errorf("查询优惠码使用记录失败: %w", err)
+		}
+		if int(used) >= c.MaxUsesPerUser {
+			return ErrCouponUserLimit
+		}
+	}
+
+	return nil
+}
+
+// AppliesToAgent 判断优惠码是否可用于购买指定代理
+func (c *Coupon) AppliesToAgent(agentID int64) bool {
+	switch c.Type {
+	case CouponTypePercentOff:
+		return c.AgentID == nil || *c.AgentID == agentID
+	case CouponTypeFreeAgent:
+		return c.AgentID != nil && *c.AgentID == agentID
+	default:
+		return false
+	}
+}
+
+// DiscountFor 计算购买指定价格时可抵扣的点数
+func (c *Coupon) DiscountFor(price int) int {
+	switch c.Type {
+	case CouponTypePercentOff:
+		return price * c.PercentOff / 100
+	case CouponTypeFreeAgent:
+		return price
+	default:
+		return 0
+	}
+}
+
+// Redeem 记录一次优惠码使用，需在事务中调用
+func (c *Coupon) Redeem(tx *gorm.DB, redemption *CouponRedemption) error {
+	redemption.CouponID = c.ID
+	redemption.CouponType = c.Type
+
+	query := tx.Model(&Coupon{}).Where("id = ?", c.ID)
+	if c.MaxUses > 0 {
+		query = query.Where("used_count < max_uses")
+	}
+	result := query.Update("used_count", gorm.Expr("used_count + ?", 1))
+	if result.Error != nil {
+		return fmt.Errorf("更新优惠码使用次数失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrCouponUsedUp
+	}
+
+	if err := tx.Create(redemption).Error; err != nil {
+		return fmt.Errorf("创建优惠码使用记录失败: %w", err)
+	}
+
+	c.UsedCount++
+	return nil
+}
+
+// ListCoupons 获取优惠码列表
+func ListCoupons(db *gorm.DB, batchNo string, limit, offset int) ([]*Coupon, int64, error) {
+	var coupons []*Coupon
+	query := db.Model(&Coupon{})
+	if batchNo != "" {
+		query = query.Where("batch_no = ?", batchNo)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计优惠码数量失败: %w", err)
+	}
+
+	if limit > 0 {
+		query = query.Limit(limit).Offset(offset)
+	}
+	if err := query.Order("created_at DESC, id DESC").Find(&coupons).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询优惠码列表失败: %w", err)
+	}
+
+	return coupons, total, nil
+}
+
+// NormalizeCouponCode 统一优惠码格式（去除空白并转为大写）
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// generateCouponCode 生成优惠码
+func generateCouponCode(prefix string) string {
+	// 使用UUID的前12位作为随机部分
+	random := strings.ToUpper(strings.ReplaceAll(uuid.New().String(), "-", "")[:12])
+	prefix = NormalizeCouponCode(prefix)
+	if prefix == "" {
+		return random
+	}
+	return prefix + "-" + random
+}
+
+// generateCouponBatchNo 生成优惠码批次号
+func generateCouponBatchNo() string {
+	return fmt.Sprintf("CB%s", time.Now().Format("20060102150405")) + strings.ToUpper(uuid.New().String()[:6])
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PayoutStatus 提现申请状态
+type PayoutStatus string
+
+const (
+	PayoutStatusPending  PayoutStatus = "pending"  // 待审核，点数已从钱包冻结扣除
+	PayoutStatusApproved PayoutStatus = "approved" // 已审核，等待打款
+	PayoutStatusPaid     PayoutStatus = "paid"     // 已打款
+	PayoutStatusRejected PayoutStatus = "rejected" // 已拒绝，点数退回钱包
+)
+
+var (
+	ErrPayoutInsufficient  = errors.New("创作者钱包余额不足")
+	ErrPayoutBelowMinimum  = errors.New("提现点数低于最低提现额度")
+	ErrPayoutNotFound      = errors.New("提现申请不存在")
+	ErrPayoutStatusInvalid = errors.New("提现申请当前状态不允许此操作")
+)
+
+// CreatorWallet 创作者收益钱包，与用于消费的点数余额分开
+type CreatorWallet struct {
+	UserID       string    `json:"userId" gorm:"column:user_id;primaryKey"`
+	Balance      int       `json:"balance" gorm:"not null;default:0"`                            // 可提现点数
+	TotalEarned  int       `json:"totalEarned" gorm:"column:total_earned;not null;default:0"`    // 累计收益
+	TotalPaidOut int       `json:"totalPaidOut" gorm:"column:total_paid_out;not null;default:0"` // 累计已打款
+	UpdatedAt    time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (CreatorWallet) TableName() string {
+	return "creator_wallets"
+}
+
+// CreatorEarning 创作者的单笔销售分成记录
+type CreatorEarning struct {
+	ID           int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatorID    string    `json:"creatorId" gorm:"column:creator_id;not null;index"`
+	AgentID      int64     `json:"agentId" gorm:"column:agent_id;not null;index"`
+	BuyerID      string    `json:"buyerId" gorm:"column:buyer_id;not null"`
+	WorkflowID   *int64    `json:"workflowId" gorm:"column:workflow_id"`              // 购买生成的工作流
+	SalePrice    int       `json:"salePrice" gorm:"column:sale_price;not null"`       // 买家实付点数
+	SharePercent int       `json:"sharePercent" gorm:"column:share_percent;not null"` // 分成比例
+	Points       int       `json:"points" gorm:"not null"`                            // 创作者获得的点数
+	CreatedAt    time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (CreatorEarning) TableName() string {
+	return "creator_earnings"
+}
+
+// CreatorPayout 创作者提现申请
+type CreatorPayout struct {
+	ID           int64        `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreatorID    string       `json:"creatorId" gorm:"column:creator_id;not null;index"`
+	Points       int          `json:"points" gorm:"not null"`
+	Account      string       `json:"account" gorm:"type:varchar(255);not null"` // 收款账户
+	Status       PayoutStatus `json:"status" gorm:"type:varchar(20);default:'pending';not null;index"`
+	RejectReason string       `json:"rejectReason" gorm:"column:reject_reason;type:varchar(255)"`
+	ProcessedBy  string       `json:"processedBy" gorm:"column:processed_by"`
+	ProcessedAt  *time.Time   `json:"processedAt" gorm:"column:processed_at"`
+	CreatedAt    time.Time    `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time    `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (CreatorPayout) TableName() string {
+	return "creator_payouts"
+}
+
+// CreatorShare 按分成比例计算创作者所得点数，向下取整
+func CreatorShare(price, percent int) int {
+	if price <= 0 || percent <= 0 {
+		return 0
+	}
+	return price * percent / 100
+}
+
+// lockCreatorWallet 锁定创作者钱包，不存在时先创建，需在事务中调用
+func lockCreatorWallet(tx *gorm.DB, creatorID string) (*CreatorWallet, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&CreatorWallet{UserID: creatorID}).Error; err != nil {
+		return nil, fmt.Errorf("创建创作者钱包失败: %w", err)
+	}
+
+	var wallet CreatorWallet
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", creatorID).First(&wallet).Error; err != nil {
+		return nil, fmt.Errorf("获取创作者钱包失败: %w", err)
+	}
+	return &wallet, nil
+}
+
+// GetCreatorWallet 获取创作者钱包，尚无收益时返回空钱包
+func GetCreatorWallet(db *gorm.DB, creatorID string) (*CreatorWallet, error) {
+	var wallet CreatorWallet
+	err := db.Where("user_id = ?", creatorID).First(&wallet).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &CreatorWallet{UserID: creatorID}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("获取创作者钱包失败: %w", err)
+	}
+	return &wallet, nil
+}
+
+// CreditCreatorEarning 记录销售分成并计入创作者钱包，需在购买事务中调用
+func CreditCreatorEarning(tx *gorm.DB, earning *CreatorEarning) error {
+	wallet, err := lockCreatorWallet(tx, earning.CreatorID)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(earning).Error; err != nil {
+		return fmt.Errorf("记录创作者收益失败: %w", err)
+	}
+	return tx.Model(wallet).Updates(map[string]interface{}{
+		"balance":      gorm.Expr("balance + ?", earning.Points),
+		"total_earned": gorm.Expr("total_earned + ?", earning.Points),
+	}).Error
+}
+
+// ListCreatorEarnings 分页获取创作者的收益记录
+func ListCreatorEarnings(db *gorm.DB, creatorID string, limit, offset int) ([]CreatorEarning, int64, error) {
+	query := db.Model(&CreatorEarning{}).Where("creator_id = ?", creatorID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计创作者收益失败: %w", err)
+	}
+
+	var earnings []CreatorEarning
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&earnings).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取创作者收益失败: %w", err)
+	}
+	return earnings, total, nil
+}
+
+// RequestCreatorPayout 申请提现，点数立即从钱包扣除，被拒绝时退回，需在事务中调用
+func RequestCreatorPayout(tx *gorm.DB, creatorID string, points int, account string, minPoints int) (*CreatorPayout, error) {
+	if points < minPoints || points <= 0 {
+		return nil, ErrPayoutBelowMinimum
+	}
+
+	wallet, err := lockCreatorWallet(tx, creatorID)
+	if err != nil {
+		return nil, err
+	}
+	if wallet.Balance < points {
+		return nil, ErrPayoutInsufficient
+	}
+	if err := tx.Model(wallet).Update("balance", gorm.Expr("balance - ?", points)).Error; err != nil {
+		return nil, err
+	}
+
+	payout := CreatorPayout{
+		CreatorID: creatorID,
+		Points:    points,
+		Account:   account,
+		Status:    PayoutStatusPending,
+	}
+	if err := tx.Create(&payout).Error; err != nil {
+		return nil, fmt.Errorf("创建提现申请失败: %w", err)
+	}
+	return &payout, nil
+}
+
+// ListCreatorPayouts 分页获取提现申请，creatorID为空时获取全部创作者的申请
+func ListCreatorPayouts(db *gorm.DB, creatorID string, status string, limit, offset int) ([]CreatorPayout, int64, error) {
+	query := db.Model(&CreatorPayout{})
+	if creatorID != "" {
+		query = query.Where("creator_id = ?", creatorID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计提现申请失败: %w", err)
+	}
+
+	var payouts []CreatorPayout
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&payouts).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取提现申请失败: %w", err)
+	}
+	return payouts, total, nil
+}
+
+// ProcessCreatorPayout 处理提现申请：approve审核通过，paid标记已打款，reject拒绝并退回点数，需在事务中调用
+//
+// 待审核的申请可以通过或拒绝，已通过的申请可以标记已打款或拒绝。返回处理前的状态。
+func ProcessCreatorPayout(tx *gorm.DB, payout *CreatorPayout, operatorID, action, reason string) (PayoutStatus, error) {
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(payout, payout.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrPayoutNotFound
+		}
+		return "", err
+	}
+
+	from := payout.Status
+	var next PayoutStatus
+	switch {
+	case action == "approve" && from == PayoutStatusPending:
+		next = PayoutStatusApproved
+	case action == "paid" && from == PayoutStatusApproved:
+		next = PayoutStatusPaid
+	case action == "reject" && (from == PayoutStatusPending || from == PayoutStatusApproved):
+		next = PayoutStatusRejected
+	default:
+		return from, ErrPayoutStatusInvalid
+	}
+
+	wallet, err := lockCreatorWallet(tx, payout.CreatorID)
+	if err != nil {
+		return from, err
+	}
+	switch next {
+	case PayoutStatusPaid:
+		err = tx.Model(wallet).Update("total_paid_out", gorm.Expr("total_paid_out + ?", payout.Points)).Error
+	case PayoutStatusRejected:
+		err = tx.Model(wallet).Update("balance", gorm.Expr("balance + ?", payout.Points)).Error
+	}
+	if err != nil {
+		return from, err
+	}
+
+	now := time.Now()
+	payout.Status = next
+	payout.ProcessedBy = operatorID
+	payout.ProcessedAt = &now
+	if next == PayoutStatusRejected {
+		payout.RejectReason = truncateString(reason, 255)
+	}
+	return from, tx.Model(payout).Updates(map[string]interface{}{
+		"status":        payout.Status,
+		"processed_by":  payout.ProcessedBy,
+		"processed_at":  payout.ProcessedAt,
+		"reject_reason": payout.RejectReason,
+	}).Error
+}
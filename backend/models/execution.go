@@ -23,20 +23,22 @@ const (
 
 // WorkflowExecution 工作流执行记录
 type WorkflowExecution struct {
-	ID           int64           `json:"id" gorm:"primaryKey;autoIncrement"`
-	WorkflowID   int64           `json:"workflowId" gorm:"column:workflow_id;index;not null"`
-	UserID       string          `json:"userID" gorm:"column:user_id;index;not null"`
-	AgentID      *int64          `json:"agentId" gorm:"column:agent_id;index"`
-	Status       ExecutionStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
-	StartedAt    time.Time       `json:"startedAt" gorm:"column:started_at;not null"`
-	CompletedAt  *time.Time      `json:"completedAt" gorm:"column:completed_at"`
-	Duration     int             `json:"duration" gorm:"default:0"` // 执行时长（秒）
-	Logs         string          `json:"logs" gorm:"type:text"`
-	ErrorMessage string          `json:"errorMessage" gorm:"column:error_message;type:text"`
-	InputData    json.RawMessage `json:"inputData" gorm:"column:input_data;type:json"`   // 输入数据
-	OutputData   json.RawMessage `json:"outputData" gorm:"column:output_data;type:json"` // 输出数据
-	CreatedAt    time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt    time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	ID             int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	WorkflowID     int64           `json:"workflowId" gorm:"column:workflow_id;index;not null"`
+	UserID         string          `json:"userID" gorm:"column:user_id;index;not null"`        // 发起执行的用户
+	OrganizationID *int64          `json:"organizationId" gorm:"column:organization_id;index"` // 所属组织，与工作流一致
+	AgentID        *int64          `json:"agentId" gorm:"column:agent_id;index"`
+	Status         ExecutionStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	StartedAt      time.Time       `json:"startedAt" gorm:"column:started_at;not null"`
+	CompletedAt    *time.Time      `json:"completedAt" gorm:"column:completed_at"`
+	Duration       int             `json:"duration" gorm:"default:0"` // 执行时长（秒）
+	Logs           string          `json:"logs" gorm:"type:text"`
+	ErrorMessage   string          `json:"errorMessage" gorm:"column:error_message;type:text"`
+	InputData      json.RawMessage `json:"inputData" gorm:"column:input_data;type:json"`   // 输入数据
+	OutputData     json.RawMessage `json:"outputData" gorm:"column:output_data;type:json"` // 输出数据
+	CreatedAt      time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	DeletedAt      gorm.DeletedAt  `json:"deletedAt" gorm:"column:deleted_at;index"` // 软删除时间，随工作流一起删除和恢复
 
 	// GORM 关联关系
 	Workflow *Workflow `json:"workflow,omitempty" gorm:"foreignKey:WorkflowID;references:ID"`
@@ -95,7 +97,7 @@ func CreateExecution(db *sql.DB, userID string, input ExecutionCreateInput) (*Wo
 	// 检查工作流是否存在并且属于当前用户
 	var workflowUserID string
 	var workflowStatus string
-	err := db.QueryRow("SELECT user_id, status FROM workflows WHERE id = ?", input.WorkflowID).Scan(&workflowUserID, &workflowStatus)
+	err := db.QueryRow("SELECT user_id, status FROM workflows WHERE id = ? AND deleted_at IS NULL", input.WorkflowID).Scan(&workflowUserID, &workflowStatus)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, errors.New("工作流不存在")
@@ -114,7 +116,7 @@ func CreateExecution(db *sql.DB, userID string, input ExecutionCreateInput) (*Wo
 	// 如果指定了代理，检查代理是否存在
 	if input.AgentID != nil {
 		var count int
-		err := db.QueryRow("SELECT COUNT(*) FROM agents WHERE id = ?", *input.AgentID).Scan(&count)
+		err := db.QueryRow("SELECT COUNT(*) FROM agents WHERE id = ? AND deleted_at IS NULL", *input.AgentID).Scan(&count)
 		if err != nil {
 			return nil, fmt.Errorf("检查代理失败: %w", err)
 		}
@@ -181,9 +183,7 @@ func CreateExecutionGorm(db *gorm.DB, userID string, input ExecutionCreateInput)
 		return nil, fmt.Errorf("获取工作流信息失败: %w", err)
 	}
 
-	// 将userID转换为字符串进行比较
-	userIDStr := fmt.Sprintf("%d", userID)
-	if workflow.UserID != userIDStr {
+	if workflow.UserID != userID {
 		return nil, errors.New("无权访问此工作流")
 	}
 
@@ -230,6 +230,32 @@ func CreateExecutionGorm(db *gorm.DB, userID string, input ExecutionCreateInput)
 	return &execution, nil
 }
 
+// CountActiveExecutions 统计工作空间中处于等待或运行中的执行数量
+func CountActiveExecutions(db *gorm.DB, ws Workspace) (int64, error) {
+	var count int64
+	err := db.Model(&WorkflowExecution{}).
+		Scopes(ws.Scope("workflow_executions")).
+		Where("status IN ?", []ExecutionStatus{ExecutionStatusPending, ExecutionStatusRunning}).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计运行中的执行失败: %w", err)
+	}
+	return count, nil
+}
+
+// CountExecutionsSince 统计工作空间自指定时间以来发起的执行次数，已删除的执行记录同样计入
+func CountExecutionsSince(db *gorm.DB, ws Workspace, since time.Time) (int64, error) {
+	var count int64
+	err := db.Unscoped().Model(&WorkflowExecution{}).
+		Scopes(ws.Scope("workflow_executions")).
+		Where("created_at >= ?", since).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计执行次数失败: %w", err)
+	}
+	return count, nil
+}
+
 // GetExecutionGorm 使用GORM获取单个执行记录
 func GetExecutionGorm(db *gorm.DB, id int64) (*WorkflowExecution, error) {
 	var execution WorkflowExecution
@@ -239,7 +265,7 @@ func GetExecutionGorm(db *gorm.DB, id int64) (*WorkflowExecution, error) {
 		Select("e.*, w.name as workflow_name, a.name as agent_name").
 		Joins("JOIN workflows w ON e.workflow_id = w.id").
 		Joins("LEFT JOIN agents a ON e.agent_id = a.id").
-		Where("e.id = ?", id).
+		Where("e.id = ? AND e.deleted_at IS NULL", id).
 		Scan(&execution).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -252,7 +278,7 @@ func GetExecutionGorm(db *gorm.DB, id int64) (*WorkflowExecution, error) {
 }
 
 // ListExecutionsGorm 使用GORM获取执行记录列表
-func ListExecutionsGorm(db *gorm.DB, workflowID *int64, userID int64, status *ExecutionStatus, limit, offset int) ([]*WorkflowExecution, error) {
+func ListExecutionsGorm(db *gorm.DB, workflowID *int64, userID string, status *ExecutionStatus, limit, offset int) ([]*WorkflowExecution, error) {
 	var executions []*WorkflowExecution
 
 	// 构建查询
@@ -260,7 +286,7 @@ func ListExecutionsGorm(db *gorm.DB, workflowID *int64, userID int64, status *Ex
 		Select("e.*, w.name as workflow_name, a.name as agent_name").
 		Joins("JOIN workflows w ON e.workflow_id = w.id").
 		Joins("LEFT JOIN agents a ON e.agent_id = a.id").
-		Where("w.user_id = ?", userID)
+		Where("w.user_id = ? AND e.deleted_at IS NULL", userID)
 
 	// 添加工作流ID筛选
 	if workflowID != nil {
@@ -324,7 +350,7 @@ func UpdateExecutionStatusGorm(db *gorm.DB, id int64, status ExecutionStatus, lo
 }
 
 // CancelExecutionGorm 使用GORM取消执行
-func CancelExecutionGorm(db *gorm.DB, id int64, userID int64) error {
+func CancelExecutionGorm(db *gorm.DB, id int64, userID string) error {
 	// 获取执行记录
 	var execution WorkflowExecution
 	if err := db.Where("id = ?", id).First(&execution).Error; err != nil {
@@ -340,9 +366,7 @@ func CancelExecutionGorm(db *gorm.DB, id int64, userID int64) error {
 		return fmt.Errorf("获取工作流信息失败: %w", err)
 	}
 
-	// 将userID转换为字符串进行比较
-	userIDStr := fmt.Sprintf("%d", userID)
-	if workflow.UserID != userIDStr {
+	if workflow.UserID != userID {
 		return errors.New("无权访问此执行记录")
 	}
 
@@ -356,7 +380,7 @@ func CancelExecutionGorm(db *gorm.DB, id int64, userID int64) error {
 }
 
 // GetExecutionStatsGorm 使用GORM获取执行统计数据
-func GetExecutionStatsGorm(db *gorm.DB, userID int64) (*ExecutionStats, error) {
+func GetExecutionStatsGorm(db *gorm.DB, userID string) (*ExecutionStats, error) {
 	var stats ExecutionStats
 
 	// 获取总数
@@ -402,7 +426,7 @@ func GetExecutionStatsGorm(db *gorm.DB, userID int64) (*ExecutionStats, error) {
 }
 
 // GetExecutionStatsByWorkflowGorm 使用GORM获取按工作流分组的执行统计数据
-func GetExecutionStatsByWorkflowGorm(db *gorm.DB, userID int64) ([]*WorkflowExecutionStats, error) {
+func GetExecutionStatsByWorkflowGorm(db *gorm.DB, userID string) ([]*WorkflowExecutionStats, error) {
 	var stats []*WorkflowExecutionStats
 
 	// 查询每个工作流的执行统计
@@ -416,7 +440,7 @@ func GetExecutionStatsByWorkflowGorm(db *gorm.DB, userID int64) ([]*WorkflowExec
 			MAX(e.started_at) as last_executed
 		FROM workflow_executions e
 		JOIN workflows w ON e.workflow_id = w.id
-		WHERE w.user_id = ?
+		WHERE w.user_id = ? AND e.deleted_at IS NULL
 		GROUP BY w.id, w.name
 		ORDER BY total DESC
 	`, userID).Rows()
@@ -458,7 +482,7 @@ func GetExecutionStatsByWorkflowGorm(db *gorm.DB, userID int64) ([]*WorkflowExec
 }
 
 // GetExecutionStatsByDateRangeGorm 使用GORM获取指定日期范围内的执行统计数据
-func GetExecutionStatsByDateRangeGorm(db *gorm.DB, userID int64, startDate, endDate time.Time) (*ExecutionStats, error) {
+func GetExecutionStatsByDateRangeGorm(db *gorm.DB, userID string, startDate, endDate time.Time) (*ExecutionStats, error) {
 	var stats ExecutionStats
 
 	// 获取总数
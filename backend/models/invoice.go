@@ -0,0 +1,224 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 发票相关错误
+var (
+	ErrInvoiceOrderNotCompleted = errors.New("订单尚未完成支付，无法开具发票")
+)
+
+// Invoice 充值订单发票（收据）
+type Invoice struct {
+	ID               int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	InvoiceNo        string          `json:"invoiceNo" gorm:"column:invoice_no;type:varchar(32);uniqueIndex;not null"` // 发票号，按年连续编号
+	OrderID          int64           `json:"orderId" gorm:"column:order_id;uniqueIndex;not null"`                      // 关联充值订单
+	UserID           string          `json:"userID" gorm:"column:user_id;index;not null"`
+	BuyerName        string          `json:"buyerName" gorm:"column:buyer_name;type:varchar(100)"`                 // 购买方名称（公司名或用户姓名）
+	BuyerEmail       string          `json:"buyerEmail" gorm:"column:buyer_email;type:varchar(100)"`               // 购买方邮箱
+	BuyerTaxID       string          `json:"buyerTaxId" gorm:"column:buyer_tax_id;type:varchar(50)"`               // 购买方纳税人识别号
+	BuyerAddress     string          `json:"buyerAddress" gorm:"column:buyer_address;type:varchar(255)"`           // 购买方地址
+	BuyerPhone       string          `json:"buyerPhone" gorm:"column:buyer_phone;type:varchar(50)"`                // 购买方电话
+	BuyerBankName    string          `json:"buyerBankName" gorm:"column:buyer_bank_name;type:varchar(100)"`        // 购买方开户行
+	BuyerBankAccount string          `json:"buyerBankAccount" gorm:"column:buyer_bank_account;type:varchar(50)"`   // 购买方银行账号
+	Items            json.RawMessage `json:"items" gorm:"type:json"`                                               // 明细行
+	Subtotal         int             `json:"subtotal" gorm:"not null"`                                             // 不含税金额（分）
+	TaxRate          float64         `json:"taxRate" gorm:"column:tax_rate;type:decimal(5,2);not null"`            // 税率（百分比）
+	TaxAmount        int             `json:"taxAmount" gorm:"column:tax_amount;not null"`                          // 税额（分）
+	Total            int             `json:"total" gorm:"not null"`                                                // 价税合计（分）
+	Currency         string          `json:"currency" gorm:"type:varchar(10);default:'CNY'"`                       // 币种
+	PaymentMethod    PaymentMethod   `json:"paymentMethod" gorm:"column:payment_method;type:varchar(20);not null"` // 支付方式
+	PaidAt           *time.Time      `json:"paidAt" gorm:"column:paid_at"`                                         // 支付时间
+	IssuedAt         time.Time       `json:"issuedAt" gorm:"column:issued_at;not null"`                            // 开具时间
+	CreatedAt        time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+// InvoiceItem 发票明细行
+type InvoiceItem struct {
+	Description string `json:"description"`
+	Quantity    int    `json:"quantity"`
+	UnitPrice   int    `json:"unitPrice"` // 含税单价（分）
+	Amount      int    `json:"amount"`    // 含税金额（分）
+}
+
+// InvoiceSequence 发票号序列，每年一行
+type InvoiceSequence struct {
+	Year      int       `json:"year" gorm:"primaryKey;autoIncrement:false"`
+	LastValue int64     `json:"lastValue" gorm:"column:last_value;not null"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (InvoiceSequence) TableName() string {
+	return "invoice_sequences"
+}
+
+// InvoiceOptions 开票参数
+type InvoiceOptions struct {
+	Prefix  string  // 发票号前缀
+	TaxRate float64 // 税率（百分比），订单金额视为含税价
+}
+
+// GetInvoiceItems 解析发票明细行
+func (i *Invoice) GetInvoiceItems() ([]InvoiceItem, error) {
+	var items []InvoiceItem
+	if len(i.Items) == 0 {
+		return items, nil
+	}
+	if err := json.Unmarshal(i.Items, &items); err != nil {
+		return nil, fmt.Errorf("解析发票明细失败: %w", err)
+	}
+	return items, nil
+}
+
+// GetOrCreateInvoice 获取订单对应的发票，不存在时按订单和用户账单信息开具新发票
+func GetOrCreateInvoice(db *gorm.DB, order *RechargeOrder, opts InvoiceOptions) (*Invoice, error) {
+	if order.Status != OrderStatusCompleted && order.Status != OrderStatusPaid {
+		return nil, ErrInvoiceOrderNotCompleted
+	}
+
+	var invoice Invoice
+	err := db.Where("order_id = ?", order.ID).First(&invoice).Error
+	if err == nil {
+		return &invoice, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("获取发票失败: %w", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 并发请求时以订单行锁串行化，避免同一订单重复开票
+		var locked RechargeOrder
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&locked, order.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("order_id = ?", order.ID).First(&invoice).Error; err == nil {
+			return nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var user User
+		if err := tx.Where("user_id = ?", order.UserID).First(&user).Error; err != nil {
+			return fmt.Errorf("获取购买方信息失败: %w", err)
+		}
+
+		items, err := buildInvoiceItems(tx, order)
+		if err != nil {
+			return err
+		}
+		itemsJSON, err := json.Marshal(items)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		invoiceNo, err := nextInvoiceNo(tx, opts.Prefix, now.Year())
+		if err != nil {
+			return err
+		}
+
+		subtotal, taxAmount := splitTax(order.Amount, opts.TaxRate)
+		invoice = Invoice{
+			InvoiceNo:        invoiceNo,
+			OrderID:          order.ID,
+			UserID:           order.UserID,
+			BuyerName:        user.BillingName(),
+			BuyerEmail:       user.Email,
+			BuyerTaxID:       user.BillingTaxID,
+			BuyerAddress:     user.BillingAddress,
+			BuyerPhone:       user.BillingPhone,
+			BuyerBankName:    user.BillingBankName,
+			BuyerBankAccount: user.BillingBankAccount,
+			Items:            itemsJSON,
+			Subtotal:         subtotal,
+			TaxRate:          opts.TaxRate,
+			TaxAmount:        taxAmount,
+			Total:            order.Amount,
+			Currency:         "CNY",
+			PaymentMethod:    order.PaymentMethod,
+			PaidAt:           order.PaidAt,
+			IssuedAt:         now,
+		}
+		if invoice.PaidAt == nil {
+			invoice.PaidAt = &order.UpdatedAt
+		}
+
+		return tx.Create(&invoice).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("开具发票失败: %w", err)
+	}
+
+	return &invoice, nil
+}
+
+// buildInvoiceItems 根据订单类型生成发票明细
+func buildInvoiceItems(tx *gorm.DB, order *RechargeOrder) ([]InvoiceItem, error) {
+	description := fmt.Sprintf("点数充值（%d点）", order.Points)
+
+	if order.SubscriptionID != nil {
+		description = "订阅套餐月费"
+		var subscription UserSubscription
+		if err := tx.First(&subscription, *order.SubscriptionID).Error; err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+		} else if plan, ok := GetSubscriptionPlan(subscription.PlanCode); ok {
+			description = fmt.Sprintf("订阅套餐月费 - %s（含%d点）", plan.Name, order.Points)
+		}
+	}
+
+	return []InvoiceItem{
+		{
+			Description: description,
+			Quantity:    1,
+			UnitPrice:   order.Amount,
+			Amount:      order.Amount,
+		},
+	}, nil
+}
+
+// nextInvoiceNo 生成下一个连续发票号，需在事务中调用
+//
+// 先以忽略冲突的方式创建当年的序列行，再加锁读取，并发开具当年第一张发票时不会因主键冲突失败。
+func nextInvoiceNo(tx *gorm.DB, prefix string, year int) (string, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&InvoiceSequence{Year: year}).Error; err != nil {
+		return "", fmt.Errorf("创建发票序列失败: %w", err)
+	}
+
+	var seq InvoiceSequence
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("year = ?", year).First(&seq).Error; err != nil {
+		return "", fmt.Errorf("获取发票序列失败: %w", err)
+	}
+
+	seq.LastValue++
+	if err := tx.Model(&seq).Update("last_value", seq.LastValue).Error; err != nil {
+		return "", fmt.Errorf("更新发票序列失败: %w", err)
+	}
+
+	return fmt.Sprintf("%s%d%06d", prefix, year, seq.LastValue), nil
+}
+
+// splitTax 将含税金额拆分为不含税金额和税额（分）
+func splitTax(total int, taxRate float64) (subtotal int, tax int) {
+	if taxRate <= 0 {
+		return total, 0
+	}
+	subtotal = int(math.Round(float64(total) / (1 + taxRate/100)))
+	return subtotal, total - subtotal
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestNextInvoiceNo(t *testing.T) {
+	db := newTestDB(t, &InvoiceSequence{})
+	// 模拟并发事务已先创建了2024年的序列行
+	if err := db.Create(&InvoiceSequence{Year: 2024, LastValue: 7}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		year int
+		want string
+	}{
+		{2025, "INV2025000001"},
+		{2025, "INV2025000002"},
+		{2024, "INV2024000008"},
+		{2025, "INV2025000003"},
+	}
+	for _, tt := range tests {
+		var got string
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			got, err = nextInvoiceNo(tx, "INV", tt.year)
+			return err
+		})
+		if err != nil {
+			t.Fatalf("%d年生成发票号失败: %v", tt.year, err)
+		}
+		if got != tt.want {
+			t.Errorf("发票号应为%s，实际为%s", tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LoginAttempt 登录失败计数，按账号或IP为键（多副本部署时使用）
+type LoginAttempt struct {
+	Key           string     `json:"key" gorm:"column:attempt_key;primaryKey;type:varchar(191)"` // account:<邮箱> 或 ip:<地址>
+	Failures      int        `json:"failures" gorm:"not null;default:0"`                         // 统计窗口内的失败次数
+	WindowStart   time.Time  `json:"windowStart" gorm:"column:window_start;not null"`
+	NextAllowedAt *time.Time `json:"nextAllowedAt" gorm:"column:next_allowed_at"` // 递增等待结束时间
+	LockedUntil   *time.Time `json:"lockedUntil" gorm:"column:locked_until"`      // 锁定结束时间
+	UpdatedAt     time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime;index"`
+}
+
+// TableName 指定表名
+func (LoginAttempt) TableName() string {
+	return "login_attempts"
+}
+
+// PurgeStaleLoginAttempts 清理统计窗口已过且未处于锁定状态的登录失败计数
+func PurgeStaleLoginAttempts(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("updated_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, time.Now()).
+		Delete(&LoginAttempt{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理登录失败计数失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// SecurityAlertType 安全告警类型
+type SecurityAlertType string
+
+const (
+	SecurityAlertAccountLocked SecurityAlertType = "account_locked" // 账号因多次登录失败被锁定
+	SecurityAlertIPLocked      SecurityAlertType = "ip_locked"      // IP因多次登录失败被锁定
+)
+
+// SecurityAlert 安全告警记录
+type SecurityAlert struct {
+	ID        int64             `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    string            `json:"userID" gorm:"column:user_id;index"` // 关联用户，IP告警为空
+	Type      SecurityAlertType `json:"type" gorm:"type:varchar(30);not null;index"`
+	IP        string            `json:"ip" gorm:"column:ip;type:varchar(64)"`
+	Message   string            `json:"message" gorm:"type:varchar(255)"`
+	CreatedAt time.Time         `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (SecurityAlert) TableName() string {
+	return "security_alerts"
+}
+
+// CreateSecurityAlert 记录安全告警
+func CreateSecurityAlert(db *gorm.DB, alert *SecurityAlert) error {
+	if err := db.Create(alert).Error; err != nil {
+		return fmt.Errorf("记录安全告警失败: %w", err)
+	}
+	return nil
+}
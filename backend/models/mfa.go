@@ -0,0 +1,312 @@
+package models
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/pkg/totp"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// mfaRecoveryCodeCount 每次生成的恢复码数量
+const mfaRecoveryCodeCount = 10
+
+// mfaClockSkew 校验验证码时允许的前后时间步偏差
+const mfaClockSkew = 1
+
+// 两步验证相关错误
+var (
+	ErrMFANotEnrolled     = errors.New("尚未开始绑定两步验证")
+	ErrMFAAlreadyEnabled  = errors.New("两步验证已启用")
+	ErrMFANotEnabled      = errors.New("两步验证未启用")
+	ErrMFACodeInvalid     = errors.New("验证码不正确")
+	ErrMFARecoveryInvalid = errors.New("恢复码无效或已被使用")
+)
+
+// UserMFA 用户的TOTP两步验证配置
+//
+// 开始绑定时生成密钥，用户使用验证器应用提交一次正确的验证码后才正式启用。
+type UserMFA struct {
+	ID           int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID       string     `json:"userID" gorm:"column:user_id;uniqueIndex;not null"`
+	Secret       string     `json:"-" gorm:"column:secret;type:varchar(64);not null"`  // Base32编码的TOTP密钥
+	EnabledAt    *time.Time `json:"enabledAt" gorm:"column:enabled_at"`                // 启用时间，为空表示绑定未完成
+	LastUsedStep int64      `json:"-" gorm:"column:last_used_step;not null;default:0"` // 最近一次使用的时间步，防止验证码重放
+	CreatedAt    time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (UserMFA) TableName() string {
+	return "user_mfa"
+}
+
+// IsEnabled 两步验证是否已启用
+func (m *UserMFA) IsEnabled() bool {
+	return m != nil && m.EnabledAt != nil
+}
+
+// MFARecoveryCode 两步验证恢复码，只保存哈希值，每个恢复码只能使用一次
+type MFARecoveryCode struct {
+	ID        int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    string     `json:"userID" gorm:"column:user_id;index;not null"`
+	CodeHash  string     `json:"-" gorm:"column:code_hash;type:varchar(64);uniqueIndex;not null"`
+	UsedAt    *time.Time `json:"usedAt" gorm:"column:used_at"`
+	CreatedAt time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (MFARecoveryCode) TableName() string {
+	return "mfa_recovery_codes"
+}
+
+// GetUserMFA 获取用户的两步验证配置，未绑定时返回nil
+func GetUserMFA(db *gorm.DB, userID string) (*UserMFA, error) {
+	var mfa UserMFA
+	if err := db.Where("user_id = ?", userID).First(&mfa).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取两步验证配置失败: %w", err)
+	}
+	return &mfa, nil
+}
+
+// StartMFAEnrollment 开始绑定两步验证，生成新的密钥，未完成的旧绑定随即失效
+func StartMFAEnrollment(db *gorm.DB, userID string) (*UserMFA, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	var mfa UserMFA
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&mfa).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if err == nil {
+			if mfa.IsEnabled() {
+				return ErrMFAAlreadyEnabled
+			}
+			mfa.Secret = secret
+			mfa.LastUsedStep = 0
+			return tx.Save(&mfa).Error
+		}
+
+		mfa = UserMFA{UserID: userID, Secret: secret}
+		return tx.Create(&mfa).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrMFAAlreadyEnabled) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("保存两步验证配置失败: %w", err)
+	}
+
+	return &mfa, nil
+}
+
+// EnableMFA 使用验证器应用生成的验证码确认绑定并启用两步验证，返回恢复码明文
+func EnableMFA(db *gorm.DB, userID, code string) ([]string, error) {
+	var codes []string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		mfa, err := lockUserMFA(tx, userID)
+		if err != nil {
+			return err
+		}
+		if mfa == nil {
+			return ErrMFANotEnrolled
+		}
+		if mfa.IsEnabled() {
+			return ErrMFAAlreadyEnabled
+		}
+
+		step, ok := totp.Validate(mfa.Secret, code, time.Now(), mfaClockSkew)
+		if !ok {
+			return ErrMFACodeInvalid
+		}
+
+		now := time.Now()
+		if err := tx.Model(mfa).Updates(map[string]interface{}{
+			"enabled_at":     now,
+			"last_used_step": step,
+		}).Error; err != nil {
+			return err
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifyMFACode 校验两步验证码，同一时间步的验证码只能使用一次
+func VerifyMFACode(db *gorm.DB, userID, code string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		mfa, err := lockUserMFA(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !mfa.IsEnabled() {
+			return ErrMFANotEnabled
+		}
+
+		step, ok := totp.Validate(mfa.Secret, code, time.Now(), mfaClockSkew)
+		if !ok || step <= mfa.LastUsedStep {
+			return ErrMFACodeInvalid
+		}
+
+		return tx.Model(mfa).Update("last_used_step", step).Error
+	})
+}
+
+// UseMFARecoveryCode 使用一个恢复码代替验证码，恢复码随即失效
+func UseMFARecoveryCode(db *gorm.DB, userID, code string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		mfa, err := lockUserMFA(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !mfa.IsEnabled() {
+			return ErrMFANotEnabled
+		}
+
+		result := tx.Model(&MFARecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(normalizeRecoveryCode(code))).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrMFARecoveryInvalid
+		}
+		return nil
+	})
+}
+
+// VerifyMFA 校验验证码或恢复码，两者都提供时优先使用验证码
+func VerifyMFA(db *gorm.DB, userID, code, recoveryCode string) error {
+	if strings.TrimSpace(code) != "" {
+		return VerifyMFACode(db, userID, code)
+	}
+	if strings.TrimSpace(recoveryCode) != "" {
+		return UseMFARecoveryCode(db, userID, recoveryCode)
+	}
+	return ErrMFACodeInvalid
+}
+
+// RegenerateMFARecoveryCodes 重新生成恢复码，旧的恢复码全部失效
+func RegenerateMFARecoveryCodes(db *gorm.DB, userID string) ([]string, error) {
+	var codes []string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		mfa, err := lockUserMFA(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !mfa.IsEnabled() {
+			return ErrMFANotEnabled
+		}
+
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableMFA 停用两步验证，删除密钥和全部恢复码
+func DisableMFA(db *gorm.DB, userID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&MFARecoveryCode{}).Error; err != nil {
+			return fmt.Errorf("删除恢复码失败: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&UserMFA{}).Error; err != nil {
+			return fmt.Errorf("删除两步验证配置失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// CountUnusedMFARecoveryCodes 统计用户剩余可用的恢复码数量
+func CountUnusedMFARecoveryCodes(db *gorm.DB, userID string) (int64, error) {
+	var count int64
+	err := db.Model(&MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("统计恢复码失败: %w", err)
+	}
+	return count, nil
+}
+
+// lockUserMFA 加行锁读取两步验证配置，未绑定时返回nil
+func lockUserMFA(tx *gorm.DB, userID string) (*UserMFA, error) {
+	var mfa UserMFA
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&mfa).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &mfa, nil
+}
+
+// replaceRecoveryCodes 删除旧恢复码并生成新的一组，返回明文
+func replaceRecoveryCodes(tx *gorm.DB, userID string) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, mfaRecoveryCodeCount)
+	records := make([]MFARecoveryCode, 0, mfaRecoveryCodeCount)
+	for i := 0; i < mfaRecoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		records = append(records, MFARecoveryCode{
+			UserID:   userID,
+			CodeHash: HashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode 生成形如 abcde-fghij 的恢复码
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("生成恢复码失败: %w", err)
+	}
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+	return s[:5] + "-" + s[5:], nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格和连字符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/pkg/totp"
+)
+
+func TestVerifyMFACodeRejectsReplay(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := totp.Step(time.Now())
+	codeAt := func(step int64) string {
+		code, err := totp.Code(secret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name         string
+		enabled      bool
+		lastUsedStep int64
+		codes        []string // 依次提交的验证码，只检查最后一次的结果
+		wantErr      error
+		wantLastStep int64
+	}{
+		{"新的验证码", true, current - 2, []string{codeAt(current)}, nil, current},
+		{"同一验证码不能重复使用", true, current - 2, []string{codeAt(current), codeAt(current)}, ErrMFACodeInvalid, current},
+		{"不接受已使用时间步之前的验证码", true, current - 2, []string{codeAt(current), codeAt(current - 1)}, ErrMFACodeInvalid, current},
+		{"已使用时间步之后的验证码仍可使用", true, current - 2, []string{codeAt(current - 1), codeAt(current)}, nil, current},
+		{"启用时使用过的时间步", true, current, []string{codeAt(current)}, ErrMFACodeInvalid, current},
+		{"错误的验证码", true, current - 2, []string{"000000"}, ErrMFACodeInvalid, current - 2},
+		{"未启用两步验证", false, 0, []string{codeAt(current)}, ErrMFANotEnabled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t, &UserMFA{})
+			mfa := UserMFA{UserID: "USER_1", Secret: secret, LastUsedStep: tt.lastUsedStep}
+			if tt.enabled {
+				now := time.Now()
+				mfa.EnabledAt = &now
+			}
+			if err := db.Create(&mfa).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			for _, code := range tt.codes {
+				err = VerifyMFACode(db, "USER_1", code)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("错误应为%v，实际为%v", tt.wantErr, err)
+			}
+
+			saved, err := GetUserMFA(db, "USER_1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if saved.LastUsedStep != tt.wantLastStep {
+				t.Errorf("last_used_step应为%d，实际为%d", tt.wantLastStep, saved.LastUsedStep)
+			}
+		})
+	}
+}
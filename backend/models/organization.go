@@ -0,0 +1,444 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 组织成员角色
+const (
+	OrgRoleOwner  = "owner"  // 所有者，拥有全部权限，每个组织只有一个
+	OrgRoleAdmin  = "admin"  // 管理员，可管理成员、购买代理和充值
+	OrgRoleMember = "member" // 成员，可创建、编辑和执行工作流
+	OrgRoleViewer = "viewer" // 访客，只能查看
+)
+
+// OrgPermission 组织内的权限标识
+type OrgPermission string
+
+const (
+	OrgPermissionRead          OrgPermission = "org:read"           // 查看工作流、执行记录和点数
+	OrgPermissionExecute       OrgPermission = "org:execute"        // 执行工作流
+	OrgPermissionWorkflowEdit  OrgPermission = "org:workflow_edit"  // 创建、编辑、删除工作流
+	OrgPermissionBilling       OrgPermission = "org:billing"        // 购买代理、充值
+	OrgPermissionMemberManage  OrgPermission = "org:member_manage"  // 邀请、移除成员及修改成员角色
+	OrgPermissionSettingsWrite OrgPermission = "org:settings_write" // 修改组织信息
+)
+
+// orgRolePermissions 组织角色权限矩阵，所有者拥有全部权限无需列出
+var orgRolePermissions = map[string][]OrgPermission{
+	OrgRoleAdmin: {
+		OrgPermissionRead,
+		OrgPermissionExecute,
+		OrgPermissionWorkflowEdit,
+		OrgPermissionBilling,
+		OrgPermissionMemberManage,
+		OrgPermissionSettingsWrite,
+	},
+	OrgRoleMember: {
+		OrgPermissionRead,
+		OrgPermissionExecute,
+		OrgPermissionWorkflowEdit,
+	},
+	OrgRoleViewer: {
+		OrgPermissionRead,
+	},
+}
+
+// IsValidOrgRole 检查组织角色是否合法
+func IsValidOrgRole(role string) bool {
+	if role == OrgRoleOwner {
+		return true
+	}
+	_, ok := orgRolePermissions[role]
+	return ok
+}
+
+// HasOrgPermission 检查组织角色是否拥有指定权限
+func HasOrgPermission(role string, permission OrgPermission) bool {
+	if role == OrgRoleOwner {
+		return true
+	}
+	for _, p := range orgRolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// 组织相关错误
+var (
+	ErrOrganizationNotFound   = errors.New("组织不存在")
+	ErrNotOrganizationMember  = errors.New("您不是该组织的成员")
+	ErrOrgMemberNotFound      = errors.New("成员不存在")
+	ErrOrgMemberExists        = errors.New("该用户已是组织成员")
+	ErrOrgOwnerImmutable      = errors.New("不能修改或移除组织所有者")
+	ErrOrgRoleInvalid         = errors.New("无效的成员角色")
+	ErrOrgRoleNotAllowed      = errors.New("只有组织所有者可以授予或撤销管理员角色")
+	ErrOrgInvitationInvalid   = errors.New("邀请无效或已被使用")
+	ErrOrgInvitationExpired   = errors.New("邀请已过期，请联系管理员重新邀请")
+	ErrOrgInvitationEmail     = errors.New("该邀请不是发给当前账号邮箱的")
+	ErrOrgInvitationNotFound  = errors.New("邀请不存在")
+	ErrOrgInvitationDuplicate = errors.New("已向该邮箱发送过邀请，请等待对方接受或先撤销")
+)
+
+// Organization 组织（团队工作空间），拥有独立的工作流和点数钱包
+type Organization struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null"`
+	OwnerID   string    `json:"ownerID" gorm:"column:owner_id;index;not null"`
+	Points    int       `json:"points" gorm:"default:0;not null"` // 组织点数余额
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// OrganizationMember 组织成员
+type OrganizationMember struct {
+	ID             int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64     `json:"organizationId" gorm:"column:organization_id;not null;uniqueIndex:idx_org_member"`
+	UserID         string    `json:"userID" gorm:"column:user_id;not null;uniqueIndex:idx_org_member;index"`
+	Role           string    `json:"role" gorm:"type:varchar(20);not null"`
+	CreatedAt      time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (OrganizationMember) TableName() string {
+	return "organization_members"
+}
+
+// OrganizationInvitation 通过邮件发送的组织邀请，只保存邀请令牌的哈希值
+type OrganizationInvitation struct {
+	ID             int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	OrganizationID int64      `json:"organizationId" gorm:"column:organization_id;index;not null"`
+	Email          string     `json:"email" gorm:"type:varchar(100);not null"`
+	Role           string     `json:"role" gorm:"type:varchar(20);not null"`
+	TokenHash      string     `json:"-" gorm:"column:token_hash;type:varchar(64);uniqueIndex;not null"`
+	InvitedBy      string     `json:"invitedBy" gorm:"column:invited_by;not null"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"column:expires_at;not null"`
+	AcceptedAt     *time.Time `json:"acceptedAt" gorm:"column:accepted_at"`
+	AcceptedBy     string     `json:"acceptedBy" gorm:"column:accepted_by"`
+	RevokedAt      *time.Time `json:"revokedAt" gorm:"column:revoked_at"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (OrganizationInvitation) TableName() string {
+	return "organization_invitations"
+}
+
+// IsPending 邀请是否仍可接受
+func (i *OrganizationInvitation) IsPending(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// UserOrganization 用户所在的组织及其角色
+type UserOrganization struct {
+	Organization
+	Role string `json:"role"`
+}
+
+// CreateOrganization 创建组织，创建者成为所有者
+func CreateOrganization(db *gorm.DB, ownerID, name string) (*Organization, error) {
+	org := &Organization{
+		Name:    name,
+		OwnerID: ownerID,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&OrganizationMember{
+			OrganizationID: org.ID,
+			UserID:         ownerID,
+			Role:           OrgRoleOwner,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("创建组织失败: %w", err)
+	}
+
+	return org, nil
+}
+
+// GetOrganization 获取组织
+func GetOrganization(db *gorm.DB, id int64) (*Organization, error) {
+	var org Organization
+	if err := db.First(&org, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOrganizationNotFound
+		}
+		return nil, fmt.Errorf("获取组织失败: %w", err)
+	}
+	return &org, nil
+}
+
+// GetUserOrganizations 获取用户所在的全部组织
+func GetUserOrganizations(db *gorm.DB, userID string) ([]UserOrganization, error) {
+	var orgs []UserOrganization
+	err := db.Table("organizations").
+		Select("organizations.*, organization_members.role AS role").
+		Joins("JOIN organization_members ON organization_members.organization_id = organizations.id").
+		Where("organization_members.user_id = ?", userID).
+		Order("organizations.created_at ASC").
+		Scan(&orgs).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取组织列表失败: %w", err)
+	}
+	return orgs, nil
+}
+
+// GetOrganizationMember 获取用户在组织中的成员记录
+func GetOrganizationMember(db *gorm.DB, orgID int64, userID string) (*OrganizationMember, error) {
+	var member OrganizationMember
+	if err := db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotOrganizationMember
+		}
+		return nil, fmt.Errorf("获取组织成员失败: %w", err)
+	}
+	return &member, nil
+}
+
+// OrganizationMemberInfo 组织成员及其用户信息
+type OrganizationMemberInfo struct {
+	OrganizationMember
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	FullName string `json:"fullName"`
+	Avatar   string `json:"avatar"`
+}
+
+// GetOrganizationMembers 获取组织的全部成员
+func GetOrganizationMembers(db *gorm.DB, orgID int64) ([]OrganizationMemberInfo, error) {
+	var members []OrganizationMemberInfo
+	err := db.Table("organization_members").
+		Select("organization_members.*, users.username, users.email, users.full_name, users.avatar").
+		Joins("JOIN users ON users.user_id = organization_members.user_id").
+		Where("organization_members.organization_id = ?", orgID).
+		Order("organization_members.created_at ASC").
+		Scan(&members).Error
+	if err != nil {
+		return nil, fmt.Errorf("获取组织成员失败: %w", err)
+	}
+	return members, nil
+}
+
+// UpdateOrganizationMemberRole 修改成员角色
+//
+// 所有者的角色不可修改，也不能通过此方法转让所有权；只有所有者可以授予或撤销管理员角色。
+func UpdateOrganizationMemberRole(db *gorm.DB, orgID int64, actorRole, userID, role string) (*OrganizationMember, error) {
+	if !IsValidOrgRole(role) || role == OrgRoleOwner {
+		return nil, ErrOrgRoleInvalid
+	}
+
+	var member OrganizationMember
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("organization_id = ? AND user_id = ?", orgID, userID).
+			First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrgMemberNotFound
+			}
+			return err
+		}
+
+		if member.Role == OrgRoleOwner {
+			return ErrOrgOwnerImmutable
+		}
+		if (member.Role == OrgRoleAdmin || role == OrgRoleAdmin) && actorRole != OrgRoleOwner {
+			return ErrOrgRoleNotAllowed
+		}
+
+		member.Role = role
+		return tx.Model(&member).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &member, nil
+}
+
+// RemoveOrganizationMember 移除成员，成员也可以通过此方法退出组织
+//
+// 所有者不能被移除；只有所有者可以移除管理员，成员退出自己时不受此限制。
+func RemoveOrganizationMember(db *gorm.DB, orgID int64, actorID, actorRole, userID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var member OrganizationMember
+		if err := tx.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrgMemberNotFound
+			}
+			return err
+		}
+
+		if member.Role == OrgRoleOwner {
+			return ErrOrgOwnerImmutable
+		}
+		if actorID != userID && member.Role == OrgRoleAdmin && actorRole != OrgRoleOwner {
+			return ErrOrgRoleNotAllowed
+		}
+
+		return tx.Delete(&member).Error
+	})
+}
+
+// normalizeEmail 统一邮箱大小写，便于比较
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// CreateOrganizationInvitation 创建组织邀请，返回只通过邮件发送的邀请令牌明文
+func CreateOrganizationInvitation(db *gorm.DB, orgID int64, actorRole, email, role, invitedBy string, ttl time.Duration) (string, *OrganizationInvitation, error) {
+	if !IsValidOrgRole(role) || role == OrgRoleOwner {
+		return "", nil, ErrOrgRoleInvalid
+	}
+	if role == OrgRoleAdmin && actorRole != OrgRoleOwner {
+		return "", nil, ErrOrgRoleNotAllowed
+	}
+
+	plain, err := generateOpaqueToken()
+	if err != nil {
+		return "", nil, fmt.Errorf("生成邀请令牌失败: %w", err)
+	}
+
+	email = normalizeEmail(email)
+	now := time.Now()
+	invitation := &OrganizationInvitation{
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           role,
+		TokenHash:      HashToken(plain),
+		InvitedBy:      invitedBy,
+		ExpiresAt:      now.Add(ttl),
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 已是成员的邮箱无需邀请
+		var memberCount int64
+		if err := tx.Model(&OrganizationMember{}).
+			Joins("JOIN users ON users.user_id = organization_members.user_id").
+			Where("organization_members.organization_id = ? AND LOWER(users.email) = ?", orgID, email).
+			Count(&memberCount).Error; err != nil {
+			return err
+		}
+		if memberCount > 0 {
+			return ErrOrgMemberExists
+		}
+
+		var pendingCount int64
+		if err := tx.Model(&OrganizationInvitation{}).
+			Where("organization_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", orgID, email, now).
+			Count(&pendingCount).Error; err != nil {
+			return err
+		}
+		if pendingCount > 0 {
+			return ErrOrgInvitationDuplicate
+		}
+
+		return tx.Create(invitation).Error
+	})
+	if err != nil {
+		if errors.Is(err, ErrOrgMemberExists) || errors.Is(err, ErrOrgInvitationDuplicate) {
+			return "", nil, err
+		}
+		return "", nil, fmt.Errorf("保存邀请失败: %w", err)
+	}
+
+	return plain, invitation, nil
+}
+
+// GetOrganizationInvitations 获取组织尚未处理的邀请
+func GetOrganizationInvitations(db *gorm.DB, orgID int64) ([]OrganizationInvitation, error) {
+	var invitations []OrganizationInvitation
+	if err := db.Where("organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?", orgID, time.Now()).
+		Order("created_at DESC").
+		Find(&invitations).Error; err != nil {
+		return nil, fmt.Errorf("获取邀请列表失败: %w", err)
+	}
+	return invitations, nil
+}
+
+// RevokeOrganizationInvitation 撤销尚未接受的邀请
+func RevokeOrganizationInvitation(db *gorm.DB, orgID, invitationID int64) error {
+	result := db.Model(&OrganizationInvitation{}).
+		Where("id = ? AND organization_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", invitationID, orgID).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("撤销邀请失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrOrgInvitationNotFound
+	}
+	return nil
+}
+
+// AcceptOrganizationInvitation 接受邀请加入组织，邀请邮箱须与当前账号邮箱一致
+func AcceptOrganizationInvitation(db *gorm.DB, plain string, user *User) (*OrganizationMember, error) {
+	var member *OrganizationMember
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var invitation OrganizationInvitation
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", HashToken(plain)).
+			First(&invitation).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOrgInvitationInvalid
+			}
+			return err
+		}
+
+		now := time.Now()
+		if invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+			return ErrOrgInvitationInvalid
+		}
+		if !now.Before(invitation.ExpiresAt) {
+			return ErrOrgInvitationExpired
+		}
+		if normalizeEmail(user.Email) != invitation.Email {
+			return ErrOrgInvitationEmail
+		}
+
+		var count int64
+		if err := tx.Model(&OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", invitation.OrganizationID, user.UserID).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrOrgMemberExists
+		}
+
+		member = &OrganizationMember{
+			OrganizationID: invitation.OrganizationID,
+			UserID:         user.UserID,
+			Role:           invitation.Role,
+		}
+		if err := tx.Create(member).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&invitation).Updates(map[string]interface{}{
+			"accepted_at": now,
+			"accepted_by": user.UserID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
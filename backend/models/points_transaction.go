@@ -6,28 +6,32 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // TransactionType 交易类型
 type TransactionType string
 
 const (
-	TransactionTypeRecharge  TransactionType = "recharge"  // 充值
-	TransactionTypePurchase  TransactionType = "purchase"  // 购买
-	TransactionTypeExecution TransactionType = "execution" // 执行消费
-	TransactionTypeRefund    TransactionType = "refund"    // 退款
+	TransactionTypeRecharge     TransactionType = "recharge"     // 充值
+	TransactionTypePurchase     TransactionType = "purchase"     // 购买
+	TransactionTypeExecution    TransactionType = "execution"    // 执行消费
+	TransactionTypeRefund       TransactionType = "refund"       // 退款
+	TransactionTypeCoupon       TransactionType = "coupon"       // 优惠码兑换
+	TransactionTypeSubscription TransactionType = "subscription" // 订阅每月赠送
 )
 
 // PointsTransaction 点数交易记录模型
 type PointsTransaction struct {
-	ID          int64           `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID      string          `json:"userID" gorm:"column:user_id;index;not null"`
-	Type        TransactionType `json:"type" gorm:"type:varchar(20);not null"`
-	Amount      int             `json:"amount" gorm:"not null"`                   // 正数为增加，负数为减少
-	Balance     int             `json:"balance" gorm:"not null"`                  // 交易后余额
-	Description string          `json:"description" gorm:"type:text"`             // 交易描述
-	RelatedID   *int64          `json:"relatedId" gorm:"column:related_id;index"` // 关联ID（工作流ID、订单ID等）
-	CreatedAt   time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	ID             int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         string          `json:"userID" gorm:"column:user_id;index;not null"`
+	OrganizationID *int64          `json:"organizationId" gorm:"column:organization_id;index"` // 组织钱包的交易，为空表示个人钱包
+	Type           TransactionType `json:"type" gorm:"type:varchar(20);not null"`
+	Amount         int             `json:"amount" gorm:"not null"`                   // 正数为增加，负数为减少
+	Balance        int             `json:"balance" gorm:"not null"`                  // 交易后余额
+	Description    string          `json:"description" gorm:"type:text"`             // 交易描述
+	RelatedID      *int64          `json:"relatedId" gorm:"column:related_id;index"` // 关联ID（工作流ID、订单ID等）
+	CreatedAt      time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
 }
 
 // TableName 指定表名
@@ -55,7 +59,7 @@ func CreatePointsTransaction(db *sql.DB, input PointsTransactionCreateInput) (*P
 
 	// 获取用户当前余额
 	var currentBalance int
-	err = tx.QueryRow("SELECT points FROM users WHERE id = ?", input.UserID).Scan(&currentBalance)
+	err = tx.QueryRow("SELECT points FROM users WHERE user_id = ? FOR UPDATE", input.UserID).Scan(&currentBalance)
 	if err != nil {
 		return nil, fmt.Errorf("获取用户余额失败: %w", err)
 	}
@@ -67,7 +71,7 @@ func CreatePointsTransaction(db *sql.DB, input PointsTransactionCreateInput) (*P
 	}
 
 	// 更新用户余额
-	_, err = tx.Exec("UPDATE users SET points = ? WHERE id = ?", newBalance, input.UserID)
+	_, err = tx.Exec("UPDATE users SET points = ? WHERE user_id = ?", newBalance, input.UserID)
 	if err != nil {
 		return nil, fmt.Errorf("更新用户余额失败: %w", err)
 	}
@@ -110,7 +114,7 @@ func CreatePointsTransaction(db *sql.DB, input PointsTransactionCreateInput) (*P
 }
 
 // ListPointsTransactions 获取用户的点数交易历史
-func ListPointsTransactions(db *sql.DB, userID int64, transactionType *TransactionType, limit, offset int) ([]*PointsTransaction, error) {
+func ListPointsTransactions(db *sql.DB, userID string, transactionType *TransactionType, limit, offset int) ([]*PointsTransaction, error) {
 	query := `
 		SELECT id, user_id, type, amount, balance, description, related_id, created_at
 		FROM points_transactions
@@ -164,9 +168,9 @@ func ListPointsTransactions(db *sql.DB, userID int64, transactionType *Transacti
 }
 
 // GetUserPointsBalance 获取用户点数余额
-func GetUserPointsBalance(db *sql.DB, userID int64) (int, error) {
+func GetUserPointsBalance(db *sql.DB, userID string) (int, error) {
 	var balance int
-	err := db.QueryRow("SELECT points FROM users WHERE id = ?", userID).Scan(&balance)
+	err := db.QueryRow("SELECT points FROM users WHERE user_id = ?", userID).Scan(&balance)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return 0, fmt.Errorf("用户不存在")
@@ -183,7 +187,7 @@ func CreatePointsTransactionGorm(db *gorm.DB, input PointsTransactionCreateInput
 	err := db.Transaction(func(tx *gorm.DB) error {
 		// 获取用户当前余额
 		var user User
-		if err := tx.First(&user, input.UserID).Error; err != nil {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", input.UserID).First(&user).Error; err != nil {
 			return fmt.Errorf("获取用户信息失败: %w", err)
 		}
 
@@ -223,7 +227,7 @@ func CreatePointsTransactionGorm(db *gorm.DB, input PointsTransactionCreateInput
 }
 
 // ListPointsTransactionsGorm 使用GORM获取用户的点数交易历史
-func ListPointsTransactionsGorm(db *gorm.DB, userID int64, transactionType *TransactionType, limit, offset int) ([]*PointsTransaction, error) {
+func ListPointsTransactionsGorm(db *gorm.DB, userID string, transactionType *TransactionType, limit, offset int) ([]*PointsTransaction, error) {
 	var transactions []*PointsTransaction
 	query := db.Where("user_id = ?", userID)
 
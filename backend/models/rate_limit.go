@@ -0,0 +1,30 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RateLimitBucket 接口限流令牌桶，用于多副本部署时共享限流状态
+type RateLimitBucket struct {
+	Key        string    `json:"key" gorm:"column:bucket_key;primaryKey;type:varchar(191)"` // <策略>:<维度>:<标识>
+	Tokens     float64   `json:"tokens" gorm:"not null"`                                    // 剩余令牌数
+	RefilledAt time.Time `json:"refilledAt" gorm:"column:refilled_at;not null"`             // 上次补充令牌的时间
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime;index"`
+}
+
+// TableName 指定表名
+func (RateLimitBucket) TableName() string {
+	return "rate_limit_buckets"
+}
+
+// PurgeStaleRateLimitBuckets 清理长时间未使用的令牌桶，清理后的桶在下次请求时按满桶重新开始
+func PurgeStaleRateLimitBuckets(db *gorm.DB, before time.Time) (int64, error) {
+	result := db.Where("updated_at < ?", before).Delete(&RateLimitBucket{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理限流令牌桶失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -33,17 +33,19 @@ const (
 
 // RechargeOrder 充值订单模型
 type RechargeOrder struct {
-	ID            int64         `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID        string        `json:"userID" gorm:"column:user_id;index;not null"`
-	OrderNo       string        `json:"orderNo" gorm:"column:order_no;type:varchar(64);uniqueIndex;not null"` // 订单号
-	Amount        int           `json:"amount" gorm:"not null"`                                               // 充值金额（分）
-	Points        int           `json:"points" gorm:"not null"`                                               // 获得点数
-	PaymentMethod PaymentMethod `json:"paymentMethod" gorm:"column:payment_method;type:varchar(20);not null"`
-	Status        OrderStatus   `json:"status" gorm:"type:varchar(20);default:'pending';not null"`
-	PaymentID     string        `json:"paymentId" gorm:"column:payment_id;type:varchar(255);index"` // 第三方支付ID
-	PaidAt        *time.Time    `json:"paidAt" gorm:"column:paid_at"`                               // 支付时间
-	CreatedAt     time.Time     `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt     time.Time     `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	ID             int64         `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID         string        `json:"userID" gorm:"column:user_id;index;not null"`
+	OrganizationID *int64        `json:"organizationId" gorm:"column:organization_id;index"`                   // 为组织钱包充值，为空表示个人充值
+	OrderNo        string        `json:"orderNo" gorm:"column:order_no;type:varchar(64);uniqueIndex;not null"` // 订单号
+	Amount         int           `json:"amount" gorm:"not null"`                                               // 充值金额（分）
+	Points         int           `json:"points" gorm:"not null"`                                               // 获得点数
+	PaymentMethod  PaymentMethod `json:"paymentMethod" gorm:"column:payment_method;type:varchar(20);not null"`
+	Status         OrderStatus   `json:"status" gorm:"type:varchar(20);default:'pending';not null"`
+	PaymentID      string        `json:"paymentId" gorm:"column:payment_id;type:varchar(255);index"` // 第三方支付ID
+	PaidAt         *time.Time    `json:"paidAt" gorm:"column:paid_at"`                               // 支付时间
+	SubscriptionID *int64        `json:"subscriptionId" gorm:"column:subscription_id;index"`         // 关联订阅（订阅计费订单）
+	CreatedAt      time.Time     `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time     `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 }
 
 // TableName 指定表名
@@ -220,7 +222,7 @@ func (o *RechargeOrder) UpdateStatus(db *sql.DB, status OrderStatus, paymentID s
 }
 
 // ListRechargeOrders 获取用户的充值订单列表
-func ListRechargeOrders(db *sql.DB, userID int64, status *OrderStatus, limit, offset int) ([]*RechargeOrder, error) {
+func ListRechargeOrders(db *sql.DB, userID string, status *OrderStatus, limit, offset int) ([]*RechargeOrder, error) {
 	query := `
 		SELECT id, user_id, order_no, amount, points, payment_method, status, payment_id, paid_at, created_at, updated_at
 		FROM recharge_orders
@@ -0,0 +1,75 @@
+package models
+
+// 用户角色
+const (
+	RoleUser    = "user"    // 普通用户
+	RoleCreator = "creator" // 创作者，可发布代理
+	RoleAdmin   = "admin"   // 管理员，拥有全部权限
+	RoleFinance = "finance" // 财务，可查看订单、管理优惠码并处理创作者提现
+)
+
+// Permission 权限标识
+type Permission string
+
+const (
+	PermissionAgentManage    Permission = "agent:manage"    // 管理商店代理（创建、更新、删除）
+	PermissionAgentPublish   Permission = "agent:publish"   // 发布自己创作的代理
+	PermissionCouponManage   Permission = "coupon:manage"   // 生成、导出、启停优惠码
+	PermissionFinanceView    Permission = "finance:view"    // 查看订单、发票等财务数据
+	PermissionUserManage     Permission = "user:manage"     // 管理用户及其角色
+	PermissionAuditView      Permission = "audit:view"      // 查询和导出审计日志
+	PermissionReviewModerate Permission = "review:moderate" // 审核被举报的评价
+	PermissionPayoutManage   Permission = "payout:manage"   // 处理创作者提现申请
+)
+
+// rolePermissions 角色权限矩阵，管理员拥有全部权限无需列出
+var rolePermissions = map[string][]Permission{
+	RoleUser: {},
+	RoleCreator: {
+		PermissionAgentPublish,
+	},
+	RoleFinance: {
+		PermissionCouponManage,
+		PermissionFinanceView,
+		PermissionPayoutManage,
+	},
+}
+
+// IsValidRole 检查角色是否合法
+func IsValidRole(role string) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// HasPermission 检查角色是否拥有指定权限
+func HasPermission(role string, permission Permission) bool {
+	if role == RoleAdmin {
+		return true
+	}
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRolePermissions 获取角色拥有的权限列表
+func GetRolePermissions(role string) []Permission {
+	if role == RoleAdmin {
+		return []Permission{
+			PermissionAgentManage,
+			PermissionAgentPublish,
+			PermissionCouponManage,
+			PermissionFinanceView,
+			PermissionUserManage,
+			PermissionAuditView,
+			PermissionReviewModerate,
+			PermissionPayoutManage,
+		}
+	}
+	return append([]Permission{}, rolePermissions[role]...)
+}
@@ -0,0 +1,254 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 套餐编码
+const (
+	PlanCodeFree = "free"
+	PlanCodePro  = "pro"
+	PlanCodeTeam = "team"
+)
+
+// SubscriptionStatus 订阅状态
+type SubscriptionStatus string
+
+const (
+	SubscriptionStatusPending   SubscriptionStatus = "pending"   // 待首次支付
+	SubscriptionStatusActive    SubscriptionStatus = "active"    // 生效中
+	SubscriptionStatusPastDue   SubscriptionStatus = "past_due"  // 已到期，处于续费宽限期
+	SubscriptionStatusCancelled SubscriptionStatus = "cancelled" // 已取消
+	SubscriptionStatusExpired   SubscriptionStatus = "expired"   // 已过期
+)
+
+// SubscriptionPlan 订阅套餐定义
+type SubscriptionPlan struct {
+	Code               string `json:"code"`
+	Name               string `json:"name"`
+	Price              int    `json:"price"`              // 每月价格（分）
+	MonthlyPoints      int    `json:"monthlyPoints"`      // 每月赠送点数
+	MaxPurchasedAgents int    `json:"maxPurchasedAgents"` // 可购买代理数量上限，0表示不限
+	Description        string `json:"description"`
+	Popular            bool   `json:"popular"`
+}
+
+// UserSubscription 用户订阅模型
+type UserSubscription struct {
+	ID                 int64              `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID             string             `json:"userID" gorm:"column:user_id;index;not null"`
+	PlanCode           string             `json:"planCode" gorm:"column:plan_code;type:varchar(20);not null"`
+	Status             SubscriptionStatus `json:"status" gorm:"type:varchar(20);default:'pending';not null;index"`
+	PaymentMethod      PaymentMethod      `json:"paymentMethod" gorm:"column:payment_method;type:varchar(20);not null"`
+	CurrentPeriodStart *time.Time         `json:"currentPeriodStart" gorm:"column:current_period_start"`
+	CurrentPeriodEnd   *time.Time         `json:"currentPeriodEnd" gorm:"column:current_period_end;index"`
+	CancelAtPeriodEnd  bool               `json:"cancelAtPeriodEnd" gorm:"column:cancel_at_period_end;default:false"`
+	RenewalOrderID     *int64             `json:"renewalOrderId" gorm:"column:renewal_order_id"` // 待支付的续费订单
+	CancelledAt        *time.Time         `json:"cancelledAt" gorm:"column:cancelled_at"`
+	CreatedAt          time.Time          `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt          time.Time          `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (UserSubscription) TableName() string {
+	return "user_subscriptions"
+}
+
+// GetSubscriptionPlans 获取订阅套餐列表
+func GetSubscriptionPlans() []*SubscriptionPlan {
+	return []*SubscriptionPlan{
+		{
+			Code:               PlanCodeFree,
+			Name:               "免费版",
+			Price:              0,
+			MonthlyPoints:      0,
+			MaxPurchasedAgents: 10,
+			Description:        "适合个人体验",
+		},
+		{
+			Code:               PlanCodePro,
+			Name:               "专业版",
+			Price:              9900, // 99元/月
+			MonthlyPoints:      5000,
+			MaxPurchasedAgents: 0,
+			Description:        "每月5000点数，20个并发执行",
+			Popular:            true,
+		},
+		{
+			Code:               PlanCodeTeam,
+			Name:               "团队版",
+			Price:              29900, // 299元/月
+			MonthlyPoints:      20000,
+			MaxPurchasedAgents: 0,
+			Description:        "每月20000点数，50个并发执行",
+		},
+	}
+}
+
+// GetSubscriptionPlan 根据编码获取订阅套餐
+func GetSubscriptionPlan(code string) (*SubscriptionPlan, bool) {
+	for _, plan := range GetSubscriptionPlans() {
+		if plan.Code == code {
+			return plan, true
+		}
+	}
+	return nil, false
+}
+
+// GetActiveSubscription 获取用户当前生效的订阅（包含宽限期内的订阅）
+func GetActiveSubscription(db *gorm.DB, userID string) (*UserSubscription, error) {
+	var subscription UserSubscription
+	err := db.Where("user_id = ? AND status IN ?", userID, []SubscriptionStatus{
+		SubscriptionStatusActive,
+		SubscriptionStatusPastDue,
+	}).Order("created_at DESC").First(&subscription).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取用户订阅失败: %w", err)
+	}
+	return &subscription, nil
+}
+
+// GetUserPlan 获取用户当前适用的套餐，无订阅时返回免费版
+func GetUserPlan(db *gorm.DB, userID string) (*SubscriptionPlan, error) {
+	subscription, err := GetActiveSubscription(db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription != nil {
+		if plan, ok := GetSubscriptionPlan(subscription.PlanCode); ok {
+			return plan, nil
+		}
+	}
+
+	plan, _ := GetSubscriptionPlan(PlanCodeFree)
+	return plan, nil
+}
+
+// CreateSubscriptionOrder 为订阅创建一个计费周期的支付订单，需在事务中调用
+func CreateSubscriptionOrder(tx *gorm.DB, subscription *UserSubscription, plan *SubscriptionPlan) (*RechargeOrder, error) {
+	order := &RechargeOrder{
+		UserID:         subscription.UserID,
+		OrderNo:        generateOrderNo(),
+		Amount:         plan.Price,
+		Points:         plan.MonthlyPoints,
+		PaymentMethod:  subscription.PaymentMethod,
+		Status:         OrderStatusPending,
+		SubscriptionID: &subscription.ID,
+	}
+
+	if err := tx.Create(order).Error; err != nil {
+		return nil, fmt.Errorf("创建订阅订单失败: %w", err)
+	}
+
+	return order, nil
+}
+
+// ActivatePeriod 订阅支付成功后开启新的计费周期，需在事务中调用
+func (s *UserSubscription) ActivatePeriod(tx *gorm.DB, now time.Time) error {
+	// 续费时从上个周期结束时间接续，否则从当前时间开始
+	start := now
+	if s.CurrentPeriodEnd != nil && s.CurrentPeriodEnd.After(now) {
+		start = *s.CurrentPeriodEnd
+	}
+	end := start.AddDate(0, 1, 0)
+
+	updates := map[string]interface{}{
+		"status":               SubscriptionStatusActive,
+		"current_period_start": start,
+		"current_period_end":   end,
+		"renewal_order_id":     nil,
+	}
+	if err := tx.Model(s).Updates(updates).Error; err != nil {
+		return fmt.Errorf("更新订阅周期失败: %w", err)
+	}
+
+	s.Status = SubscriptionStatusActive
+	s.CurrentPeriodStart = &start
+	s.CurrentPeriodEnd = &end
+	s.RenewalOrderID = nil
+
+	return nil
+}
+
+// Cancel 取消订阅，已生效的订阅在当前周期结束后失效
+func (s *UserSubscription) Cancel(db *gorm.DB) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"cancelled_at": now,
+	}
+
+	if s.Status == SubscriptionStatusPending {
+		updates["status"] = SubscriptionStatusCancelled
+	} else {
+		updates["cancel_at_period_end"] = true
+	}
+
+	if err := db.Model(s).Updates(updates).Error; err != nil {
+		return fmt.Errorf("取消订阅失败: %w", err)
+	}
+
+	s.CancelledAt = &now
+	if s.Status == SubscriptionStatusPending {
+		s.Status = SubscriptionStatusCancelled
+	} else {
+		s.CancelAtPeriodEnd = true
+	}
+
+	return nil
+}
+
+// ListSubscriptionsDueForRenewal 获取在指定时间前到期且需要生成续费订单的订阅
+func ListSubscriptionsDueForRenewal(db *gorm.DB, before time.Time) ([]*UserSubscription, error) {
+	var subscriptions []*UserSubscription
+	err := db.Where("status = ? AND cancel_at_period_end = ? AND renewal_order_id IS NULL AND current_period_end <= ?",
+		SubscriptionStatusActive, false, before).
+		Find(&subscriptions).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询待续费订阅失败: %w", err)
+	}
+	return subscriptions, nil
+}
+
+// ExpireOverdueSubscriptions 处理已到期的订阅：取消续订的直接过期，未支付续费的进入宽限期，超出宽限期的过期
+func ExpireOverdueSubscriptions(db *gorm.DB, now time.Time, gracePeriod time.Duration) (int64, error) {
+	var affected int64
+
+	// 已取消续订的订阅到期后直接过期
+	result := db.Model(&UserSubscription{}).
+		Where("status IN ? AND cancel_at_period_end = ? AND current_period_end < ?",
+			[]SubscriptionStatus{SubscriptionStatusActive, SubscriptionStatusPastDue}, true, now).
+		Update("status", SubscriptionStatusExpired)
+	if result.Error != nil {
+		return affected, fmt.Errorf("更新已取消订阅状态失败: %w", result.Error)
+	}
+	affected += result.RowsAffected
+
+	// 超出宽限期仍未续费的订阅过期
+	result = db.Model(&UserSubscription{}).
+		Where("status IN ? AND current_period_end < ?",
+			[]SubscriptionStatus{SubscriptionStatusActive, SubscriptionStatusPastDue}, now.Add(-gracePeriod)).
+		Update("status", SubscriptionStatusExpired)
+	if result.Error != nil {
+		return affected, fmt.Errorf("更新过期订阅状态失败: %w", result.Error)
+	}
+	affected += result.RowsAffected
+
+	// 刚到期的订阅进入宽限期
+	result = db.Model(&UserSubscription{}).
+		Where("status = ? AND current_period_end < ?", SubscriptionStatusActive, now).
+		Update("status", SubscriptionStatusPastDue)
+	if result.Error != nil {
+		return affected, fmt.Errorf("更新宽限期订阅状态失败: %w", result.Error)
+	}
+	affected += result.RowsAffected
+
+	return affected, nil
+}
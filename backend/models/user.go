@@ -10,22 +10,31 @@ import (
 
 // User 用户模型
 type User struct {
-	ID           int64      `json:"id" gorm:"primaryKey;autoIncrement"`
-	UserID       string     `json:"userID" gorm:"type:varchar(50);uniqueIndex;not null"`
-	Username     string     `json:"username" gorm:"type:varchar(50);uniqueIndex;not null"`
-	Email        string     `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
-	PasswordHash string     `json:"-" gorm:"column:password_hash;type:varchar(255);not null"` // 不暴露密码哈希
-	FullName     string     `json:"fullName" gorm:"column:full_name;type:varchar(100)"`
-	Avatar       string     `json:"avatar" gorm:"type:varchar(255)"`
-	Bio          string     `json:"bio" gorm:"type:text"`                                     // 个人简介
-	Timezone     string     `json:"timezone" gorm:"type:varchar(50);default:'Asia/Shanghai'"` // 时区
-	Language     string     `json:"language" gorm:"type:varchar(10);default:'zh_CN'"`         // 语言
-	Theme        string     `json:"theme" gorm:"type:varchar(20);default:'light'"`            // 主题
-	Role         string     `json:"role" gorm:"type:varchar(20);default:'user'"`
-	Points       int        `json:"points" gorm:"default:0;not null"` // 用户点数余额
-	CreatedAt    time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt    time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
-	LastLoginAt  *time.Time `json:"lastLoginAt" gorm:"column:last_login_at"`
+	ID           int64  `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID       string `json:"userID" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Username     string `json:"username" gorm:"type:varchar(50);uniqueIndex;not null"`
+	Email        string `json:"email" gorm:"type:varchar(100);uniqueIndex;not null"`
+	PasswordHash string `json:"-" gorm:"column:password_hash;type:varchar(255);not null"` // 不暴露密码哈希
+	FullName     string `json:"fullName" gorm:"column:full_name;type:varchar(100)"`
+	Avatar       string `json:"avatar" gorm:"type:varchar(255)"`
+	Bio          string `json:"bio" gorm:"type:text"`                                     // 个人简介
+	Timezone     string `json:"timezone" gorm:"type:varchar(50);default:'Asia/Shanghai'"` // 时区
+	Language     string `json:"language" gorm:"type:varchar(10);default:'zh_CN'"`         // 语言
+	Theme        string `json:"theme" gorm:"type:varchar(20);default:'light'"`            // 主题
+	Role         string `json:"role" gorm:"type:varchar(20);default:'user'"`
+	Points       int    `json:"points" gorm:"default:0;not null"` // 用户点数余额
+	// 开票信息
+	BillingCompanyName string     `json:"billingCompanyName" gorm:"column:billing_company_name;type:varchar(100)"` // 公司名称（发票抬头）
+	BillingTaxID       string     `json:"billingTaxId" gorm:"column:billing_tax_id;type:varchar(50)"`              // 纳税人识别号
+	BillingAddress     string     `json:"billingAddress" gorm:"column:billing_address;type:varchar(255)"`          // 公司地址
+	BillingPhone       string     `json:"billingPhone" gorm:"column:billing_phone;type:varchar(50)"`               // 公司电话
+	BillingBankName    string     `json:"billingBankName" gorm:"column:billing_bank_name;type:varchar(100)"`       // 开户行
+	BillingBankAccount string     `json:"billingBankAccount" gorm:"column:billing_bank_account;type:varchar(50)"`  // 银行账号
+	CreatedAt          time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt          time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	LastLoginAt        *time.Time `json:"lastLoginAt" gorm:"column:last_login_at"`
+	EmailVerifiedAt    *time.Time `json:"emailVerifiedAt" gorm:"column:email_verified_at"`     // 邮箱验证时间，为空表示未验证
+	TokenGeneration    int        `json:"-" gorm:"column:token_generation;not null;default:0"` // 令牌代数，吊销全部令牌时递增，访问令牌中的代数不一致即失效
 }
 
 // TableName 指定表名
@@ -56,6 +65,13 @@ type UserUpdateInput struct {
 	Timezone string `json:"timezone"`
 	Language string `json:"language"`
 	Theme    string `json:"theme"`
+	// 开票信息，传入nil表示不修改，传入空字符串表示清空
+	BillingCompanyName *string `json:"billingCompanyName" validate:"omitempty,max=100"`
+	BillingTaxID       *string `json:"billingTaxId" validate:"omitempty,max=50"`
+	BillingAddress     *string `json:"billingAddress" validate:"omitempty,max=255"`
+	BillingPhone       *string `json:"billingPhone" validate:"omitempty,max=50"`
+	BillingBankName    *string `json:"billingBankName" validate:"omitempty,max=100"`
+	BillingBankAccount *string `json:"billingBankAccount" validate:"omitempty,max=50"`
 }
 
 // PasswordChangeInput 密码更改输入
@@ -144,10 +160,10 @@ func CreateUser(db *gorm.DB, input UserRegisterInput) (*User, error) {
 	return user, nil
 }
 
-// GetUserByID 使用GORM根据ID获取用户
-func GetUserByID(db *gorm.DB, id int64) (*User, error) {
+// GetUserByID 使用GORM根据公开的用户ID（USER_...）获取用户
+func GetUserByID(db *gorm.DB, userID string) (*User, error) {
 	var user User
-	if err := db.First(&user, id).Error; err != nil {
+	if err := db.Where("user_id = ?", userID).First(&user).Error; err != nil {
 		return nil, err
 	}
 	return &user, nil
@@ -177,9 +193,12 @@ func (u *User) Update(db *gorm.DB, input UserUpdateInput) error {
 
 	// 准备更新数据
 	updates := map[string]interface{}{}
-	if input.Email != "" {
+	if input.Email != "" && input.Email != u.Email {
+		// 更换邮箱后需要重新验证
 		updates["email"] = input.Email
+		updates["email_verified_at"] = nil
 		u.Email = input.Email
+		u.EmailVerifiedAt = nil
 	}
 	if input.FullName != "" {
 		updates["full_name"] = input.FullName
@@ -205,11 +224,50 @@ func (u *User) Update(db *gorm.DB, input UserUpdateInput) error {
 		updates["theme"] = input.Theme
 		u.Theme = input.Theme
 	}
+	if input.BillingCompanyName != nil {
+		updates["billing_company_name"] = *input.BillingCompanyName
+		u.BillingCompanyName = *input.BillingCompanyName
+	}
+	if input.BillingTaxID != nil {
+		updates["billing_tax_id"] = *input.BillingTaxID
+		u.BillingTaxID = *input.BillingTaxID
+	}
+	if input.BillingAddress != nil {
+		updates["billing_address"] = *input.BillingAddress
+		u.BillingAddress = *input.BillingAddress
+	}
+	if input.BillingPhone != nil {
+		updates["billing_phone"] = *input.BillingPhone
+		u.BillingPhone = *input.BillingPhone
+	}
+	if input.BillingBankName != nil {
+		updates["billing_bank_name"] = *input.BillingBankName
+		u.BillingBankName = *input.BillingBankName
+	}
+	if input.BillingBankAccount != nil {
+		updates["billing_bank_account"] = *input.BillingBankAccount
+		u.BillingBankAccount = *input.BillingBankAccount
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
 
 	// 更新用户信息
 	return db.Model(u).Updates(updates).Error
 }
 
+// BillingName 获取发票抬头：优先使用公司名称，其次为姓名和用户名
+func (u *User) BillingName() string {
+	if u.BillingCompanyName != "" {
+		return u.BillingCompanyName
+	}
+	if u.FullName != "" {
+		return u.FullName
+	}
+	return u.Username
+}
+
 // ChangePassword 使用GORM修改用户密码
 func (u *User) ChangePassword(db *gorm.DB, input PasswordChangeInput) error {
 	// 验证当前密码
@@ -236,6 +294,41 @@ func (u *User) ChangePassword(db *gorm.DB, input PasswordChangeInput) error {
 	return nil
 }
 
+// SetPassword 直接设置新密码（用于密码重置）
+func (u *User) SetPassword(db *gorm.DB, newPassword string) error {
+	newPasswordHash, err := HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := db.Model(u).Update("password_hash", newPasswordHash).Error; err != nil {
+		return err
+	}
+
+	u.PasswordHash = newPasswordHash
+	return nil
+}
+
+// HasPassword 是否设置了登录密码，通过第三方登录创建的账号在设置密码前为空
+func (u *User) HasPassword() bool {
+	return u.PasswordHash != ""
+}
+
+// IsEmailVerified 邮箱是否已验证
+func (u *User) IsEmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// MarkEmailVerified 标记邮箱已验证
+func (u *User) MarkEmailVerified(db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Model(u).Update("email_verified_at", now).Error; err != nil {
+		return err
+	}
+	u.EmailVerifiedAt = &now
+	return nil
+}
+
 // UpdateLastLogin 使用GORM更新最后登录时间
 func (u *User) UpdateLastLogin(db *gorm.DB) error {
 	now := time.Now()
@@ -0,0 +1,322 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/utils"
+	"gorm.io/gorm"
+)
+
+// 第三方登录相关错误
+var (
+	ErrOAuthStateInvalid      = errors.New("登录请求无效或已被使用，请重新发起登录")
+	ErrOAuthStateExpired      = errors.New("登录请求已过期，请重新发起登录")
+	ErrIdentityLinkedToOther  = errors.New("该第三方账号已绑定其他用户")
+	ErrIdentityProviderLinked = errors.New("已绑定该登录方式，请先解除绑定")
+	ErrIdentityNotFound       = errors.New("绑定记录不存在")
+	ErrLastLoginMethod        = errors.New("这是账号唯一的登录方式，请先设置密码或绑定其他登录方式")
+)
+
+// UserIdentity 用户绑定的第三方登录身份
+type UserIdentity struct {
+	ID          int64      `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID      string     `json:"userID" gorm:"column:user_id;not null;uniqueIndex:idx_user_identity_user_provider"`
+	Provider    string     `json:"provider" gorm:"type:varchar(50);not null;uniqueIndex:idx_user_identity_subject;uniqueIndex:idx_user_identity_user_provider"`
+	Subject     string     `json:"-" gorm:"type:varchar(191);not null;uniqueIndex:idx_user_identity_subject"` // 提供方内的用户唯一标识
+	Email       string     `json:"email" gorm:"type:varchar(100)"`                                            // 提供方返回的邮箱，仅用于展示
+	CreatedAt   time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	LastLoginAt *time.Time `json:"lastLoginAt" gorm:"column:last_login_at"`
+}
+
+// TableName 指定表名
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OAuthState 发起第三方授权时保存的state，回调时一次性使用
+//
+// UserID不为空表示已登录用户发起的绑定请求，否则为登录请求。
+type OAuthState struct {
+	ID           int64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	StateHash    string    `json:"-" gorm:"column:state_hash;type:varchar(64);uniqueIndex;not null"`
+	Provider     string    `json:"provider" gorm:"type:varchar(50);not null"`
+	CodeVerifier string    `json:"-" gorm:"column:code_verifier;type:varchar(128);not null"` // PKCE校验值
+	Nonce        string    `json:"-" gorm:"type:varchar(64);not null"`
+	UserID       string    `json:"userID" gorm:"column:user_id"`
+	ExpiresAt    time.Time `json:"expiresAt" gorm:"column:expires_at;not null;index"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
+
+// CreateOAuthState 保存授权请求state，只保存state的哈希值
+func CreateOAuthState(db *gorm.DB, plainState string, state *OAuthState) error {
+	state.StateHash = HashToken(plainState)
+	if err := db.Create(state).Error; err != nil {
+		return fmt.Errorf("保存登录请求失败: %w", err)
+	}
+	return nil
+}
+
+// ConsumeOAuthState 校验并删除授权请求state，同一state只能使用一次
+func ConsumeOAuthState(db *gorm.DB, plainState, provider string) (*OAuthState, error) {
+	var state OAuthState
+	err := db.Where("state_hash = ? AND provider = ?", HashToken(plainState), provider).First(&state).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrOAuthStateInvalid
+		}
+		return nil, fmt.Errorf("获取登录请求失败: %w", err)
+	}
+
+	// 以删除成功为准，防止并发回调重复使用同一state
+	result := db.Where("id = ?", state.ID).Delete(&OAuthState{})
+	if result.Error != nil {
+		return nil, fmt.Errorf("删除登录请求失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrOAuthStateInvalid
+	}
+
+	if time.Now().After(state.ExpiresAt) {
+		return nil, ErrOAuthStateExpired
+	}
+	return &state, nil
+}
+
+// PurgeExpiredOAuthStates 清理过期的授权请求state
+func PurgeExpiredOAuthStates(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("expires_at < ?", now).Delete(&OAuthState{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("清理过期登录请求失败: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// FindUserByIdentity 根据第三方身份查找已绑定的用户，未绑定时返回nil
+func FindUserByIdentity(db *gorm.DB, provider, subject string) (*User, *UserIdentity, error) {
+	var identity UserIdentity
+	if err := db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("获取绑定记录失败: %w", err)
+	}
+
+	var user User
+	if err := db.Where("user_id = ?", identity.UserID).First(&user).Error; err != nil {
+		return nil, nil, fmt.Errorf("获取绑定用户失败: %w", err)
+	}
+	return &user, &identity, nil
+}
+
+// GetUserIdentities 获取用户绑定的全部第三方身份
+func GetUserIdentities(db *gorm.DB, userID string) ([]UserIdentity, error) {
+	var identities []UserIdentity
+	if err := db.Where("user_id = ?", userID).Order("created_at ASC").Find(&identities).Error; err != nil {
+		return nil, fmt.Errorf("获取绑定记录失败: %w", err)
+	}
+	return identities, nil
+}
+
+// LinkUserIdentity 为已有用户绑定第三方身份
+func LinkUserIdentity(db *gorm.DB, userID, provider, subject, email string) (*UserIdentity, error) {
+	var identity *UserIdentity
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var existing UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", provider, subject).First(&existing).Error
+		if err == nil {
+			if existing.UserID != userID {
+				return ErrIdentityLinkedToOther
+			}
+			identity = &existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		var count int64
+		if err := tx.Model(&UserIdentity{}).Where("user_id = ? AND provider = ?", userID, provider).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrIdentityProviderLinked
+		}
+
+		identity = &UserIdentity{
+			UserID:   userID,
+			Provider: provider,
+			Subject:  subject,
+			Email:    email,
+		}
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+// UnlinkUserIdentity 解除绑定，账号未设置密码时至少保留一种登录方式
+func UnlinkUserIdentity(db *gorm.DB, user *User, identityID int64) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var identity UserIdentity
+		if err := tx.Where("id = ? AND user_id = ?", identityID, user.UserID).First(&identity).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrIdentityNotFound
+			}
+			return err
+		}
+
+		if !user.HasPassword() {
+			var count int64
+			if err := tx.Model(&UserIdentity{}).Where("user_id = ?", user.UserID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count <= 1 {
+				return ErrLastLoginMethod
+			}
+		}
+
+		return tx.Delete(&identity).Error
+	})
+}
+
+// TouchUserIdentity 更新第三方身份的最后登录时间
+func TouchUserIdentity(db *gorm.DB, identity *UserIdentity) error {
+	now := time.Now()
+	if err := db.Model(identity).Update("last_login_at", now).Error; err != nil {
+		return err
+	}
+	identity.LastLoginAt = &now
+	return nil
+}
+
+// IdentityUserInput 通过第三方身份创建用户的输入
+type IdentityUserInput struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Username      string // 提供方返回的用户名，仅作为生成本地用户名的参考
+	FullName      string
+	Avatar        string
+}
+
+// CreateUserFromIdentity 首次通过第三方登录时创建用户并绑定身份
+//
+// 新用户不设置密码，之后可通过找回密码设置；提供方确认过的邮箱视为已验证。
+func CreateUserFromIdentity(db *gorm.DB, input IdentityUserInput) (*User, *UserIdentity, error) {
+	var user *User
+	var identity *UserIdentity
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		userID, err := generateUniqueUserID(tx)
+		if err != nil {
+			return err
+		}
+
+		username, err := generateUniqueUsername(tx, input.Username, input.Email)
+		if err != nil {
+			return err
+		}
+
+		fullName := input.FullName
+		if fullName == "" {
+			fullName = username
+		}
+		avatar := input.Avatar
+		if avatar == "" {
+			avatar = "/static/avatars/default.png"
+		}
+
+		user = &User{
+			UserID:   userID,
+			Username: username,
+			Email:    input.Email,
+			FullName: truncateString(fullName, 100),
+			Avatar:   truncateString(avatar, 255),
+			Role:     RoleUser,
+			Points:   0,
+		}
+		if input.EmailVerified {
+			now := time.Now()
+			user.EmailVerifiedAt = &now
+		}
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		identity = &UserIdentity{
+			UserID:   userID,
+			Provider: input.Provider,
+			Subject:  input.Subject,
+			Email:    input.Email,
+		}
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建用户失败: %w", err)
+	}
+
+	return user, identity, nil
+}
+
+// generateUniqueUserID 生成全局唯一的用户ID，最多重试3次
+func generateUniqueUserID(db *gorm.DB) (string, error) {
+	for i := 0; i < 3; i++ {
+		userID := utils.GenerateUserID()
+
+		var count int64
+		if err := db.Model(&User{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return userID, nil
+		}
+	}
+	return "", gorm.ErrDuplicatedKey
+}
+
+// usernameInvalidChars 用户名中不允许的字符
+var usernameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+// generateUniqueUsername 根据提供方用户名或邮箱前缀生成不重复的用户名
+func generateUniqueUsername(db *gorm.DB, preferred, email string) (string, error) {
+	base := usernameInvalidChars.ReplaceAllString(preferred, "")
+	if len(base) < 3 {
+		base = usernameInvalidChars.ReplaceAllString(strings.SplitN(email, "@", 2)[0], "")
+	}
+	if len(base) < 3 {
+		base = "user"
+	}
+	base = truncateString(base, 40)
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		var count int64
+		if err := db.Model(&User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix, err := generateOpaqueToken()
+		if err != nil {
+			return "", err
+		}
+		candidate = base + "_" + strings.ToLower(usernameInvalidChars.ReplaceAllString(suffix, ""))[:6]
+	}
+	return "", gorm.ErrDuplicatedKey
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserTokenPurpose 一次性令牌用途
+type UserTokenPurpose string
+
+const (
+	UserTokenPurposeEmailVerify   UserTokenPurpose = "email_verify"   // 邮箱验证
+	UserTokenPurposePasswordReset UserTokenPurpose = "password_reset" // 密码重置
+)
+
+// 一次性令牌相关错误
+var (
+	ErrUserTokenInvalid = errors.New("链接无效或已被使用")
+	ErrUserTokenExpired = errors.New("链接已过期，请重新获取")
+)
+
+// UserToken 通过邮件发送的一次性令牌，只保存令牌的哈希值
+type UserToken struct {
+	ID        int64            `json:"id" gorm:"primaryKey;autoIncrement"`
+	UserID    string           `json:"userID" gorm:"column:user_id;index;not null"`
+	Purpose   UserTokenPurpose `json:"purpose" gorm:"type:varchar(20);not null"`
+	TokenHash string           `json:"-" gorm:"column:token_hash;type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time        `json:"expiresAt" gorm:"column:expires_at;not null"`
+	UsedAt    *time.Time       `json:"usedAt" gorm:"column:used_at"`
+	CreatedAt time.Time        `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
+
+// CreateUserToken 创建一次性令牌，同一用途下未使用的旧令牌随即失效，返回令牌明文
+func CreateUserToken(db *gorm.DB, userID string, purpose UserTokenPurpose, ttl time.Duration) (string, error) {
+	plain, err := generateOpaqueToken()
+	if err != nil {
+		return "", fmt.Errorf("生成令牌失败: %w", err)
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: HashToken(plain),
+			ExpiresAt: now.Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("保存令牌失败: %w", err)
+	}
+
+	return plain, nil
+}
+
+// ConsumeUserToken 校验并使用一次性令牌，需在事务中调用
+func ConsumeUserToken(tx *gorm.DB, plain string, purpose UserTokenPurpose) (*UserToken, error) {
+	var token UserToken
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ? AND purpose = ?", HashToken(plain), purpose).
+		First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUserTokenInvalid
+		}
+		return nil, err
+	}
+
+	if token.UsedAt != nil {
+		return nil, ErrUserTokenInvalid
+	}
+
+	now := time.Now()
+	if now.After(token.ExpiresAt) {
+		return nil, ErrUserTokenExpired
+	}
+
+	if err := tx.Model(&token).Update("used_at", now).Error; err != nil {
+		return nil, err
+	}
+	token.UsedAt = &now
+
+	return &token, nil
+}
@@ -21,18 +21,24 @@ const (
 
 // Workflow 工作流模型 - 用户购买的工作流实例
 type Workflow struct {
-	ID          int64           `json:"id" gorm:"primaryKey;autoIncrement"`
-	Name        string          `json:"name" gorm:"type:varchar(100);not null"`
-	Description string          `json:"description" gorm:"type:text"`
-	UserID      string          `json:"userID" gorm:"column:user_id;index;not null"`
-	AgentID     *int64          `json:"agentId" gorm:"column:agent_id;index"` // 关联的代理ID（购买来源）
-	Status      WorkflowStatus  `json:"status" gorm:"type:varchar(20);default:'draft';not null"`
-	Definition  json.RawMessage `json:"definition" gorm:"type:json"`            // JSON格式的工作流定义
-	PurchasedAt *time.Time      `json:"purchasedAt" gorm:"column:purchased_at"` // 购买时间
-	CreatedAt   time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt   time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
-	LastRunAt   *time.Time      `json:"lastRunAt" gorm:"column:last_run_at"`
-	RunCount    int             `json:"runCount" gorm:"column:run_count;default:0"`
+	ID             int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name           string          `json:"name" gorm:"type:varchar(100);not null"`
+	Description    string          `json:"description" gorm:"type:text"`
+	UserID         string          `json:"userID" gorm:"column:user_id;index;not null"`
+	OrganizationID *int64          `json:"organizationId" gorm:"column:organization_id;index"`                            // 所属组织，为空表示个人工作流
+	AgentID        *int64          `json:"agentId" gorm:"column:agent_id;index"`                                          // 关联的代理ID（购买来源）
+	BundleID       *int64          `json:"bundleId" gorm:"column:bundle_id;index"`                                        // 通过套餐购买时的套餐ID
+	AgentVersion   string          `json:"agentVersion" gorm:"column:agent_version;type:varchar(32);not null;default:''"` // 购买或最近一次升级时的代理版本
+	ClonedFromID   *int64          `json:"clonedFromId" gorm:"column:cloned_from_id;index"`                               // 克隆或模板的来源工作流
+	TemplateID     *int64          `json:"templateId" gorm:"column:template_id;index"`                                    // 从个人模板创建时的模板ID
+	Status         WorkflowStatus  `json:"status" gorm:"type:varchar(20);default:'draft';not null"`
+	Definition     json.RawMessage `json:"definition" gorm:"type:json"`            // JSON格式的工作流定义
+	PurchasedAt    *time.Time      `json:"purchasedAt" gorm:"column:purchased_at"` // 购买时间
+	CreatedAt      time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	LastRunAt      *time.Time      `json:"lastRunAt" gorm:"column:last_run_at"`
+	RunCount       int             `json:"runCount" gorm:"column:run_count;default:0"`
+	DeletedAt      gorm.DeletedAt  `json:"deletedAt" gorm:"column:deleted_at;index"` // 软删除时间，保留期内可恢复
 }
 
 // TableName 指定表名
@@ -40,6 +46,11 @@ func (Workflow) TableName() string {
 	return "workflows"
 }
 
+// PurchasedWorkflows 只保留购买获得的工作流，克隆和从模板创建的工作流保留代理来源，但不是购买记录
+func PurchasedWorkflows(db *gorm.DB) *gorm.DB {
+	return db.Where("workflows.agent_id IS NOT NULL AND workflows.purchased_at IS NOT NULL")
+}
+
 // WorkflowAgent 工作流中使用的代理模型
 type WorkflowAgent struct {
 	ID          int64           `json:"id" gorm:"primaryKey;autoIncrement"`
@@ -217,12 +228,21 @@ func (w *Workflow) Execute(db *gorm.DB, userID string, inputData json.RawMessage
 }
 
 // CountWorkflows 使用GORM统计用户的工作流数量
-func CountWorkflows(db *gorm.DB, userID int64) (int64, error) {
+func CountWorkflows(db *gorm.DB, userID string) (int64, error) {
 	var count int64
 	err := db.Model(&Workflow{}).Where("user_id = ?", userID).Count(&count).Error
 	return count, err
 }
 
+// CountWorkspaceWorkflows 统计工作空间中的工作流数量
+func CountWorkspaceWorkflows(db *gorm.DB, ws Workspace) (int64, error) {
+	var count int64
+	if err := db.Model(&Workflow{}).Scopes(ws.Scope("workflows")).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("统计工作流数量失败: %w", err)
+	}
+	return count, nil
+}
+
 // CreateWorkflowGorm 使用GORM创建新工作流
 func CreateWorkflowGorm(db *gorm.DB, userID string, input WorkflowCreateInput) (*Workflow, error) {
 	// 验证工作流状态是否有效
@@ -273,7 +293,7 @@ func GetWorkflowGorm(db *gorm.DB, id int64) (*Workflow, error) {
 }
 
 // ListWorkflowsGorm 使用GORM获取用户的工作流列表
-func ListWorkflowsGorm(db *gorm.DB, userID int64, status *WorkflowStatus, limit, offset int) ([]*Workflow, error) {
+func ListWorkflowsGorm(db *gorm.DB, userID string, status *WorkflowStatus, limit, offset int) ([]*Workflow, error) {
 	var workflows []*Workflow
 	query := db.Where("user_id = ?", userID)
 
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// MaxWorkflowTemplates 每个用户在一个工作空间中最多保存的模板数量
+const MaxWorkflowTemplates = 100
+
+// maxWorkflowNameLength 工作流和模板名称的最大长度（字符数）
+const maxWorkflowNameLength = 100
+
+// copyNameSuffix 克隆未指定名称时追加的后缀
+const copyNameSuffix = " (副本)"
+
+var (
+	ErrTemplateNotFound = errors.New("模板不存在")
+	ErrTemplateLimit    = fmt.Errorf("最多可保存%d个模板，请先删除不需要的模板", MaxWorkflowTemplates)
+)
+
+// WorkflowTemplate 用户从自己的工作流保存的个人模板，只有作者本人可见
+//
+// 模板保存时复制工作流的定义，之后修改或删除来源工作流不影响模板。来源是购买的代理时保留代理来源，
+// 从模板创建的工作流可以继续升级到代理的新版本。
+type WorkflowTemplate struct {
+	ID               int64           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name             string          `json:"name" gorm:"type:varchar(100);not null"`
+	Description      string          `json:"description" gorm:"type:text"`
+	UserID           string          `json:"userId" gorm:"column:user_id;index;not null"`
+	OrganizationID   *int64          `json:"organizationId" gorm:"column:organization_id;index"`                            // 保存时所在的组织，为空表示个人空间
+	SourceWorkflowID *int64          `json:"sourceWorkflowId" gorm:"column:source_workflow_id;index"`                       // 来源工作流
+	AgentID          *int64          `json:"agentId" gorm:"column:agent_id;index"`                                          // 来源工作流的代理来源
+	AgentVersion     string          `json:"agentVersion" gorm:"column:agent_version;type:varchar(32);not null;default:''"` // 来源工作流的代理版本
+	Definition       json.RawMessage `json:"definition" gorm:"type:json"`
+	CreatedAt        time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (WorkflowTemplate) TableName() string {
+	return "workflow_templates"
+}
+
+// WorkflowCopyInput 克隆工作流、保存模板或从模板创建工作流时的名称和描述，为空时沿用来源
+type WorkflowCopyInput struct {
+	Name        string
+	Description string
+}
+
+// personalTemplates 只保留当前用户在工作空间中保存的模板
+func personalTemplates(ws Workspace) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Scopes(ws.Scope("workflow_templates")).Where("workflow_templates.user_id = ?", ws.UserID)
+	}
+}
+
+// copyName 未指定名称时在来源名称后追加后缀，超出长度时截断来源名称
+func copyName(name string) string {
+	runes := []rune(name)
+	if max := maxWorkflowNameLength - len([]rune(copyNameSuffix)); len(runes) > max {
+		runes = runes[:max]
+	}
+	return string(runes) + copyNameSuffix
+}
+
+// CloneWorkflow 在工作空间中复制工作流，新工作流为草稿并记录来源工作流
+//
+// 来源是购买的代理时保留代理ID和版本，克隆的工作流可以升级，但不是购买记录，不计入购买数量。
+func CloneWorkflow(db *gorm.DB, ws Workspace, source *Workflow, input WorkflowCopyInput) (*Workflow, error) {
+	if input.Name == "" {
+		input.Name = copyName(source.Name)
+	}
+	if input.Description == "" {
+		input.Description = source.Description
+	}
+
+	workflow := &Workflow{
+		Name:           input.Name,
+		Description:    input.Description,
+		UserID:         ws.UserID,
+		OrganizationID: ws.OrganizationID,
+		AgentID:        source.AgentID,
+		AgentVersion:   source.AgentVersion,
+		ClonedFromID:   &source.ID,
+		Status:         WorkflowStatusDraft,
+		Definition:     source.Definition,
+	}
+	if err := db.Create(workflow).Error; err != nil {
+		return nil, fmt.Errorf("克隆工作流失败: %w", err)
+	}
+	return workflow, nil
+}
+
+// SaveWorkflowTemplate 将工作流保存为当前用户的个人模板
+func SaveWorkflowTemplate(db *gorm.DB, ws Workspace, source *Workflow, input WorkflowCopyInput) (*WorkflowTemplate, error) {
+	if input.Name == "" {
+		input.Name = source.Name
+	}
+	if input.Description == "" {
+		input.Description = source.Description
+	}
+
+	template := &WorkflowTemplate{
+		Name:             input.Name,
+		Description:      input.Description,
+		UserID:           ws.UserID,
+		OrganizationID:   ws.OrganizationID,
+		SourceWorkflowID: &source.ID,
+		AgentID:          source.AgentID,
+		AgentVersion:     source.AgentVersion,
+		Definition:       source.Definition,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&WorkflowTemplate{}).Scopes(personalTemplates(ws)).Count(&count).Error; err != nil {
+			return fmt.Errorf("统计模板数量失败: %w", err)
+		}
+		if count >= MaxWorkflowTemplates {
+			return ErrTemplateLimit
+		}
+		if err := tx.Create(template).Error; err != nil {
+			return fmt.Errorf("保存模板失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// ListWorkflowTemplates 获取当前用户在工作空间中保存的模板，按保存时间倒序
+func ListWorkflowTemplates(db *gorm.DB, ws Workspace, limit, offset int) ([]WorkflowTemplate, int64, error) {
+	query := db.Model(&WorkflowTemplate{}).Scopes(personalTemplates(ws))
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("统计模板数量失败: %w", err)
+	}
+	templates := []WorkflowTemplate{}
+	if err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&templates).Error; err != nil {
+		return nil, 0, fmt.Errorf("获取模板列表失败: %w", err)
+	}
+	return templates, total, nil
+}
+
+// GetWorkflowTemplate 获取当前用户的模板
+func GetWorkflowTemplate(db *gorm.DB, ws Workspace, id int64) (*WorkflowTemplate, error) {
+	var template WorkflowTemplate
+	if err := db.Scopes(personalTemplates(ws)).Where("workflow_templates.id = ?", id).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, fmt.Errorf("获取模板失败: %w", err)
+	}
+	return &template, nil
+}
+
+// DeleteWorkflowTemplate 删除当前用户的模板，已从模板创建的工作流不受影响
+func DeleteWorkflowTemplate(db *gorm.DB, ws Workspace, id int64) error {
+	result := db.Scopes(personalTemplates(ws)).Where("workflow_templates.id = ?", id).Delete(&WorkflowTemplate{})
+	if result.Error != nil {
+		return fmt.Errorf("删除模板失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// CreateWorkflowFromTemplate 从模板创建草稿工作流，记录模板和模板的来源工作流，保留代理来源
+func CreateWorkflowFromTemplate(db *gorm.DB, ws Workspace, template *WorkflowTemplate, input WorkflowCopyInput) (*Workflow, error) {
+	if input.Name == "" {
+		input.Name = template.Name
+	}
+	if input.Description == "" {
+		input.Description = template.Description
+	}
+
+	workflow := &Workflow{
+		Name:           input.Name,
+		Description:    input.Description,
+		UserID:         ws.UserID,
+		OrganizationID: ws.OrganizationID,
+		AgentID:        template.AgentID,
+		AgentVersion:   template.AgentVersion,
+		ClonedFromID:   template.SourceWorkflowID,
+		TemplateID:     &template.ID,
+		Status:         WorkflowStatusDraft,
+		Definition:     template.Definition,
+	}
+	if err := db.Create(workflow).Error; err != nil {
+		return nil, fmt.Errorf("从模板创建工作流失败: %w", err)
+	}
+	return workflow, nil
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrInsufficientPoints 点数余额不足
+var ErrInsufficientPoints = errors.New("余额不足，请先充值")
+
+// Workspace 当前请求操作的工作空间
+//
+// 个人空间的OrganizationID为空，数据按user_id归属；组织空间的数据按organization_id归属，
+// 同时user_id记录实际操作的成员。
+type Workspace struct {
+	UserID         string
+	OrganizationID *int64
+	Role           string // 组织空间中的成员角色，个人空间视为所有者
+}
+
+// PersonalWorkspace 用户的个人空间
+func PersonalWorkspace(userID string) Workspace {
+	return Workspace{UserID: userID, Role: OrgRoleOwner}
+}
+
+// IsOrganization 是否为组织空间
+func (w Workspace) IsOrganization() bool {
+	return w.OrganizationID != nil
+}
+
+// Can 检查当前用户在工作空间中是否拥有指定权限，个人空间拥有全部权限
+func (w Workspace) Can(permission OrgPermission) bool {
+	return HasOrgPermission(w.Role, permission)
+}
+
+// Scope 按工作空间过滤数据的GORM作用域，table为包含user_id和organization_id列的表名
+func (w Workspace) Scope(table string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if w.IsOrganization() {
+			return db.Where(table+".organization_id = ?", *w.OrganizationID)
+		}
+		return db.Where(table+".user_id = ? AND "+table+".organization_id IS NULL", w.UserID)
+	}
+}
+
+// GetWorkspacePlan 获取工作空间适用的订阅套餐，组织空间使用所有者的套餐
+func GetWorkspacePlan(db *gorm.DB, ws Workspace) (*SubscriptionPlan, error) {
+	if !ws.IsOrganization() {
+		return GetUserPlan(db, ws.UserID)
+	}
+	org, err := GetOrganization(db, *ws.OrganizationID)
+	if err != nil {
+		return nil, err
+	}
+	return GetUserPlan(db, org.OwnerID)
+}
+
+// GetWorkspaceBalance 获取工作空间的点数余额
+func GetWorkspaceBalance(db *gorm.DB, ws Workspace) (int, error) {
+	var balance int
+	var err error
+	if ws.IsOrganization() {
+		err = db.Model(&Organization{}).Select("points").Where("id = ?", *ws.OrganizationID).Scan(&balance).Error
+	} else {
+		err = db.Model(&User{}).Select("points").Where("user_id = ?", ws.UserID).Scan(&balance).Error
+	}
+	if err != nil {
+		return 0, fmt.Errorf("获取点数余额失败: %w", err)
+	}
+	return balance, nil
+}
+
+// LockWorkspace 锁定工作空间的用户或组织行，使同一工作空间的配额检查和创建依次执行，需在事务中调用
+func LockWorkspace(tx *gorm.DB, ws Workspace) error {
+	var err error
+	if ws.IsOrganization() {
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&Organization{}, *ws.OrganizationID).Error
+	} else {
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("user_id = ?", ws.UserID).First(&User{}).Error
+	}
+	if err != nil {
+		return fmt.Errorf("锁定工作空间失败: %w", err)
+	}
+	return nil
+}
+
+// WorkspacePointsChange 工作空间点数变动
+type WorkspacePointsChange struct {
+	Type        TransactionType
+	Amount      int // 正数为增加，负数为扣除
+	Description string
+	RelatedID   *int64
+}
+
+// ChangeWorkspacePoints 变动工作空间钱包的点数并记录交易，需在事务中调用
+//
+// 钱包行在事务内加锁，扣除后余额为负时返回ErrInsufficientPoints。
+func ChangeWorkspacePoints(tx *gorm.DB, ws Workspace, change WorkspacePointsChange) (*PointsTransaction, error) {
+	var balance int
+	if ws.IsOrganization() {
+		var org Organization
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&org, *ws.OrganizationID).Error; err != nil {
+			return nil, fmt.Errorf("获取组织钱包失败: %w", err)
+		}
+		balance = org.Points + change.Amount
+		if balance < 0 {
+			return nil, ErrInsufficientPoints
+		}
+		if err := tx.Model(&org).Update("points", balance).Error; err != nil {
+			return nil, fmt.Errorf("更新组织余额失败: %w", err)
+		}
+	} else {
+		var user User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", ws.UserID).First(&user).Error; err != nil {
+			return nil, fmt.Errorf("获取用户钱包失败: %w", err)
+		}
+		balance = user.Points + change.Amount
+		if balance < 0 {
+			return nil, ErrInsufficientPoints
+		}
+		if err := tx.Model(&user).Update("points", balance).Error; err != nil {
+			return nil, fmt.Errorf("更新用户余额失败: %w", err)
+		}
+	}
+
+	transaction := &PointsTransaction{
+		UserID:         ws.UserID,
+		OrganizationID: ws.OrganizationID,
+		Type:           change.Type,
+		Amount:         change.Amount,
+		Balance:        balance,
+		Description:    change.Description,
+		RelatedID:      change.RelatedID,
+	}
+	if err := tx.Create(transaction).Error; err != nil {
+		return nil, fmt.Errorf("创建交易记录失败: %w", err)
+	}
+	return transaction, nil
+}
@@ -0,0 +1,264 @@
+// Package agentpack 代理的可移植包格式，用于在实例之间迁移代理以及维护示例数据
+//
+// 一个包是一个JSON文档，包含代理的定义、元数据、封面图和输入参数Schema，
+// Checksum为agent字段内容的SHA-256，导入时校验以发现传输损坏或手工修改。
+package agentpack
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+)
+
+const (
+	Format        = "jilang-agent-package" // 包格式标识
+	FormatVersion = 1                      // 当前包格式版本，不兼容的格式变更时递增
+
+	// MaxPackageBytes 包文件的最大大小，内嵌的封面图计入其中
+	MaxPackageBytes = 10 << 20
+	// MaxCoverImageBytes 内嵌封面图的最大大小，超过时只导出URL
+	MaxCoverImageBytes = 5 << 20
+
+	uploadsURLPrefix = "/uploads/"
+	coverUploadDir   = "uploads/covers"
+)
+
+// coverImageExts 支持的封面图格式及保存时的扩展名
+var coverImageExts = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+var (
+	ErrInvalidPackage     = errors.New("无效的代理包")
+	ErrUnsupportedVersion = fmt.Errorf("不支持的代理包格式版本，当前支持版本%d", FormatVersion)
+	ErrChecksumMismatch   = errors.New("代理包校验和不匹配，文件可能已损坏或被修改")
+)
+
+// Package 代理包
+type Package struct {
+	Format        string    `json:"format"`
+	FormatVersion int       `json:"formatVersion"`
+	ExportedAt    time.Time `json:"exportedAt"`
+	Checksum      string    `json:"checksum"` // sha256:<agent字段的十六进制摘要>
+	Agent         Agent     `json:"agent"`
+}
+
+// Agent 包中的代理内容，不包含购买数、评分等实例相关的数据
+type Agent struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Type        string          `json:"type"`
+	Category    string          `json:"category"`
+	Icon        string          `json:"icon"`
+	Version     string          `json:"version"` // 导出时代理的当前版本号
+	Price       int             `json:"price"`
+	Tags        []string        `json:"tags"`
+	Definition  json.RawMessage `json:"definition"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+	CoverImage  *CoverImage     `json:"coverImage,omitempty"`
+}
+
+// CoverImage 封面图，本地上传的图片内嵌文件内容，外部图片只记录URL
+type CoverImage struct {
+	URL         string `json:"url,omitempty"`
+	ContentType string `json:"contentType,omitempty"`
+	Data        []byte `json:"data,omitempty"` // base64编码的图片内容
+}
+
+// New 根据代理内容创建包并计算校验和
+func New(agent Agent) (*Package, error) {
+	sum, err := checksum(agent)
+	if err != nil {
+		return nil, err
+	}
+	return &Package{
+		Format:        Format,
+		FormatVersion: FormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		Checksum:      sum,
+		Agent:         agent,
+	}, nil
+}
+
+// checksum 计算代理内容的校验和，定义等JSON字段按紧凑格式参与计算，与文件的缩进格式无关
+func checksum(agent Agent) (string, error) {
+	data, err := json.Marshal(agent)
+	if err != nil {
+		return "", fmt.Errorf("计算代理包校验和失败: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// Encode 将包编码为带缩进的JSON，便于在版本库中比较差异
+func Encode(pkg *Package) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(pkg); err != nil {
+		return nil, fmt.Errorf("编码代理包失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode 读取并校验代理包，检查格式版本、校验和以及必填字段
+func Decode(r io.Reader) (*Package, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxPackageBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("读取代理包失败: %w", err)
+	}
+	if len(data) > MaxPackageBytes {
+		return nil, fmt.Errorf("%w: 文件超过%dMB", ErrInvalidPackage, MaxPackageBytes>>20)
+	}
+
+	var pkg Package
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPackage, err)
+	}
+	if pkg.Format != Format {
+		return nil, fmt.Errorf("%w: 格式标识应为%s", ErrInvalidPackage, Format)
+	}
+	if pkg.FormatVersion != FormatVersion {
+		return nil, ErrUnsupportedVersion
+	}
+	sum, err := checksum(pkg.Agent)
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Checksum != sum {
+		return nil, ErrChecksumMismatch
+	}
+	if err := pkg.Agent.validate(); err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// validate 检查必填字段和JSON字段的格式
+func (a *Agent) validate() error {
+	if strings.TrimSpace(a.Name) == "" || strings.TrimSpace(a.Type) == "" {
+		return fmt.Errorf("%w: 代理名称和类型不能为空", ErrInvalidPackage)
+	}
+	if a.Price < 0 {
+		return fmt.Errorf("%w: 价格不能为负数", ErrInvalidPackage)
+	}
+	var definition map[string]interface{}
+	if err := json.Unmarshal(a.Definition, &definition); err != nil {
+		return fmt.Errorf("%w: 代理定义必须是JSON对象", ErrInvalidPackage)
+	}
+	if len(a.InputSchema) > 0 {
+		var schema map[string]interface{}
+		if err := json.Unmarshal(a.InputSchema, &schema); err != nil {
+			return fmt.Errorf("%w: 输入参数Schema必须是JSON对象", ErrInvalidPackage)
+		}
+	}
+	if a.CoverImage != nil && len(a.CoverImage.Data) > MaxCoverImageBytes {
+		return fmt.Errorf("%w: 封面图超过%dMB", ErrInvalidPackage, MaxCoverImageBytes>>20)
+	}
+	return nil
+}
+
+// FromAgent 根据代理创建包，publicDir为静态文件目录，本地上传的封面图从中读取并内嵌
+//
+// 需要先填充代理的Tags。封面图读取失败时只导出URL。
+func FromAgent(agent *models.Agent, publicDir string) (*Package, error) {
+	content := Agent{
+		Name:        agent.Name,
+		Description: agent.Description,
+		Type:        agent.Type,
+		Category:    agent.Category,
+		Icon:        agent.Icon,
+		Version:     agent.LatestVersion,
+		Price:       agent.Price,
+		Tags:        agent.Tags,
+		Definition:  agent.Definition,
+		InputSchema: agent.InputSchema,
+	}
+	if content.Tags == nil {
+		content.Tags = []string{}
+	}
+	if agent.CoverImage != "" {
+		content.CoverImage = embedCoverImage(agent.CoverImage, publicDir)
+	}
+	return New(content)
+}
+
+// embedCoverImage 读取本地上传的封面图，外部URL或文件不可读时只保留URL
+func embedCoverImage(url, publicDir string) *CoverImage {
+	cover := &CoverImage{URL: url}
+	if !strings.HasPrefix(url, uploadsURLPrefix) {
+		return cover
+	}
+
+	// 只读取public目录内的文件
+	rel := path.Clean(strings.TrimPrefix(url, "/"))
+	if strings.HasPrefix(rel, "..") {
+		return cover
+	}
+	file := filepath.Join(publicDir, filepath.FromSlash(rel))
+	info, err := os.Stat(file)
+	if err != nil || info.IsDir() || info.Size() > MaxCoverImageBytes {
+		return cover
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return cover
+	}
+
+	cover.Data = data
+	cover.ContentType = http.DetectContentType(data)
+	return cover
+}
+
+// saveCoverImage 将内嵌的封面图写入publicDir的封面目录并返回访问URL，未内嵌图片时返回原URL
+//
+// 文件名取内容摘要，重复导入同一个包不会产生多余的文件。
+func saveCoverImage(cover *CoverImage, publicDir string) (string, error) {
+	if cover == nil {
+		return "", nil
+	}
+	if len(cover.Data) == 0 {
+		return cover.URL, nil
+	}
+
+	ext, ok := coverImageExts[http.DetectContentType(cover.Data)]
+	if !ok {
+		return "", fmt.Errorf("%w: 封面图只支持PNG、JPEG、GIF和WebP格式", ErrInvalidPackage)
+	}
+
+	sum := sha256.Sum256(cover.Data)
+	filename := hex.EncodeToString(sum[:16]) + ext
+	dir := filepath.Join(publicDir, filepath.FromSlash(coverUploadDir))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建封面图目录失败: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filename), cover.Data, 0644); err != nil {
+		return "", fmt.Errorf("保存封面图失败: %w", err)
+	}
+	return "/" + coverUploadDir + "/" + filename, nil
+}
+
+// Filename 导出文件名，由代理ID和版本号组成
+func (p *Package) Filename(agentID int64) string {
+	version := p.Agent.Version
+	if version == "" {
+		version = models.InitialAgentVersion
+	}
+	return fmt.Sprintf("agent-%d-%s.json", agentID, version)
+}
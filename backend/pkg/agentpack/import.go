@@ -0,0 +1,221 @@
+package agentpack
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"gorm.io/gorm"
+)
+
+// ConflictMode 导入时已存在同名代理的处理方式
+type ConflictMode string
+
+const (
+	ConflictFail      ConflictMode = "fail"      // 返回ErrConflict
+	ConflictSkip      ConflictMode = "skip"      // 保留已有代理，不做修改
+	ConflictOverwrite ConflictMode = "overwrite" // 用包中的内容更新已有代理，定义变化时发布新版本
+	ConflictRename    ConflictMode = "rename"    // 以新名称创建另一个代理
+)
+
+// ImportAction 导入的结果
+type ImportAction string
+
+const (
+	ActionCreated     ImportAction = "created"
+	ActionSkipped     ImportAction = "skipped"
+	ActionOverwritten ImportAction = "overwritten"
+)
+
+// maxRenameAttempts 重命名时尝试的最大序号
+const maxRenameAttempts = 100
+
+var ErrConflict = errors.New("已存在同名代理")
+
+// ParseConflictMode 解析冲突处理方式，为空时使用ConflictFail
+func ParseConflictMode(s string) (ConflictMode, error) {
+	switch mode := ConflictMode(s); mode {
+	case "":
+		return ConflictFail, nil
+	case ConflictFail, ConflictSkip, ConflictOverwrite, ConflictRename:
+		return mode, nil
+	}
+	return "", fmt.Errorf("无效的冲突处理方式%q，可选 fail、skip、overwrite、rename", s)
+}
+
+// ImportOptions 导入选项
+type ImportOptions struct {
+	OnConflict ConflictMode
+	Publish    bool   // 新建的代理设为公开；覆盖时将已有代理设为公开，为false时不改变其公开状态
+	CreatedBy  string // 版本发布人用户ID，命令行导入时为空
+	PublicDir  string // 静态文件目录，内嵌的封面图保存到其中，默认public
+}
+
+// ImportResult 导入结果
+type ImportResult struct {
+	Action  ImportAction  `json:"action"`
+	Agent   *models.Agent `json:"agent"`
+	Version string        `json:"version"` // 导入后代理的当前版本号
+}
+
+// Import 导入代理包，按名称判断是否已存在同名代理
+func Import(db *gorm.DB, pkg *Package, opts ImportOptions) (*ImportResult, error) {
+	if opts.OnConflict == "" {
+		opts.OnConflict = ConflictFail
+	}
+	if opts.PublicDir == "" {
+		opts.PublicDir = "public"
+	}
+	content := pkg.Agent
+	tags, err := models.NormalizeTags(content.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if content.Version != "" {
+		if _, err := models.ParseSemver(content.Version); err != nil {
+			return nil, err
+		}
+	}
+
+	// 内嵌的封面图按内容命名，导入失败时残留的文件可被再次导入复用
+	coverImage, err := saveCoverImage(content.CoverImage, opts.PublicDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var result *ImportResult
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var existing models.Agent
+		err := tx.Where("name = ?", content.Name).Order("id ASC").First(&existing).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查同名代理失败: %w", err)
+		}
+
+		name := content.Name
+		if err == nil {
+			switch opts.OnConflict {
+			case ConflictSkip:
+				result = &ImportResult{Action: ActionSkipped, Agent: &existing, Version: existing.LatestVersion}
+				return nil
+			case ConflictOverwrite:
+				result, err = overwrite(tx, &existing, content, coverImage, tags, opts)
+				return err
+			case ConflictRename:
+				if name, err = availableName(tx, content.Name); err != nil {
+					return err
+				}
+			default:
+				return ErrConflict
+			}
+		}
+
+		agent := models.Agent{
+			Name:        name,
+			Description: content.Description,
+			Type:        content.Type,
+			Category:    content.Category,
+			Icon:        content.Icon,
+			CoverImage:  coverImage,
+			Definition:  content.Definition,
+			InputSchema: content.InputSchema,
+			Price:       content.Price,
+			IsPublic:    opts.Publish,
+			Status:      models.AgentStatusPublished,
+		}
+		if err := models.CreateVersionedAgent(tx, &agent, content.Version, "从代理包导入", opts.CreatedBy); err != nil {
+			return err
+		}
+		if err := models.SetAgentTags(tx, agent.ID, tags); err != nil {
+			return err
+		}
+		agent.Tags = tags
+		result = &ImportResult{Action: ActionCreated, Agent: &agent, Version: agent.LatestVersion}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// overwrite 用包中的内容更新已有代理，定义变化时发布新版本：包中的版本号更新时沿用，否则递增修订号
+func overwrite(tx *gorm.DB, agent *models.Agent, content Agent, coverImage string, tags []string, opts ImportOptions) (*ImportResult, error) {
+	updates := map[string]interface{}{
+		"description":  content.Description,
+		"type":         content.Type,
+		"category":     content.Category,
+		"icon":         content.Icon,
+		"cover_image":  coverImage,
+		"input_schema": content.InputSchema,
+		"price":        content.Price,
+	}
+	if opts.Publish {
+		updates["is_public"] = true
+	}
+	if err := tx.Model(agent).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("更新代理失败: %w", err)
+	}
+	if err := models.SetAgentTags(tx, agent.ID, tags); err != nil {
+		return nil, err
+	}
+
+	if !jsonEqual(agent.Definition, content.Definition) {
+		input := models.PublishAgentVersionInput{
+			Changelog:  "从代理包导入",
+			Definition: content.Definition,
+			CreatedBy:  opts.CreatedBy,
+		}
+		if newerVersion(content.Version, agent.LatestVersion) {
+			input.Version = content.Version
+		}
+		if _, err := models.PublishAgentVersion(tx, agent, input); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.First(agent, agent.ID).Error; err != nil {
+		return nil, err
+	}
+	agent.Tags = tags
+	return &ImportResult{Action: ActionOverwritten, Agent: agent, Version: agent.LatestVersion}, nil
+}
+
+// newerVersion 包中的版本号是否晚于代理的当前版本
+func newerVersion(version, latest string) bool {
+	v, err := models.ParseSemver(version)
+	if err != nil {
+		return false
+	}
+	l, err := models.ParseSemver(latest)
+	if err != nil {
+		// 代理尚无版本记录时，PublishAgentVersion以初始版本为基线
+		l, _ = models.ParseSemver(models.InitialAgentVersion)
+	}
+	return v.Compare(l) > 0
+}
+
+// availableName 为重命名导入找到未被使用的名称，如"名称 (2)"
+func availableName(tx *gorm.DB, name string) (string, error) {
+	for i := 2; i <= maxRenameAttempts; i++ {
+		candidate := fmt.Sprintf("%s (%d)", name, i)
+		var count int64
+		if err := tx.Model(&models.Agent{}).Where("name = ?", candidate).Count(&count).Error; err != nil {
+			return "", fmt.Errorf("检查同名代理失败: %w", err)
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+	return "", ErrConflict
+}
+
+// jsonEqual 比较两个JSON文档的内容是否相同，忽略格式差异
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
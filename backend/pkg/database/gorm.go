@@ -77,10 +77,46 @@ func AutoMigrate(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&models.User{},
 		&models.Workflow{},
+		&models.WorkflowTemplate{},
 		&models.WorkflowExecution{},
 		&models.Agent{},
+		&models.AgentVersion{},
+		&models.AgentReview{},
+		&models.AgentReviewVote{},
+		&models.AgentReviewFlag{},
+		&models.Tag{},
+		&models.AgentTag{},
+		&models.AgentCollection{},
+		&models.AgentCollectionItem{},
+		&models.AgentBundle{},
+		&models.AgentBundleItem{},
+		&models.AgentTrialUsage{},
+		&models.AgentTrialRun{},
+		&models.CreatorWallet{},
+		&models.CreatorEarning{},
+		&models.CreatorPayout{},
 		&models.PointsTransaction{},
 		&models.RechargeOrder{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.UserSubscription{},
+		&models.Invoice{},
+		&models.InvoiceSequence{},
+		&models.RefreshToken{},
+		&models.TokenRevocation{},
+		&models.UserToken{},
+		&models.LoginAttempt{},
+		&models.SecurityAlert{},
+		&models.UserMFA{},
+		&models.MFARecoveryCode{},
+		&models.UserIdentity{},
+		&models.OAuthState{},
+		&models.APIKey{},
+		&models.Organization{},
+		&models.OrganizationMember{},
+		&models.OrganizationInvitation{},
+		&models.RateLimitBucket{},
+		&models.AuditEvent{},
 	)
 }
 
@@ -0,0 +1,89 @@
+package invoice
+
+import (
+	"html/template"
+	"io"
+)
+
+// htmlTemplate 发票HTML模板
+var htmlTemplate = template.Must(template.New("invoice").Funcs(template.FuncMap{
+	"amount": formatAmount,
+}).Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<title>发票 {{.Doc.Invoice.InvoiceNo}}</title>
+<style>
+body { font-family: "PingFang SC", "Microsoft YaHei", "Noto Sans CJK SC", sans-serif; color: #222; margin: 40px auto; max-width: 800px; }
+h1 { text-align: center; font-size: 24px; margin-bottom: 32px; }
+.parties { display: flex; gap: 24px; margin-bottom: 24px; }
+.parties section { flex: 1; border: 1px solid #ddd; padding: 12px 16px; }
+h2 { font-size: 14px; margin: 0 0 8px; color: #555; }
+dl { margin: 0; display: grid; grid-template-columns: max-content 1fr; gap: 4px 12px; font-size: 13px; }
+dt { color: #777; }
+dd { margin: 0; }
+table { width: 100%; border-collapse: collapse; font-size: 13px; margin-bottom: 24px; }
+th, td { border-bottom: 1px solid #ddd; padding: 8px; text-align: left; }
+th.num, td.num { text-align: right; }
+.totals { margin-left: auto; width: 320px; }
+.totals dl { grid-template-columns: 1fr max-content; }
+.totals dd { text-align: right; }
+.totals .grand { font-weight: bold; font-size: 15px; }
+footer { margin-top: 40px; font-size: 12px; color: #888; text-align: center; }
+</style>
+</head>
+<body>
+<h1>发票 / 收据</h1>
+<section class="meta">
+<dl>
+{{range .Meta}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>
+{{end}}</dl>
+</section>
+<div class="parties">
+<section>
+<h2>销售方</h2>
+<dl>
+{{range .Seller}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>
+{{end}}</dl>
+</section>
+<section>
+<h2>购买方</h2>
+<dl>
+{{range .Buyer}}<dt>{{.Label}}</dt><dd>{{.Value}}</dd>
+{{end}}</dl>
+</section>
+</div>
+<table>
+<thead>
+<tr><th>项目</th><th class="num">数量</th><th class="num">单价</th><th class="num">金额</th></tr>
+</thead>
+<tbody>
+{{range .Doc.Items}}<tr><td>{{.Description}}</td><td class="num">{{.Quantity}}</td><td class="num">{{amount .UnitPrice}}</td><td class="num">{{amount .Amount}}</td></tr>
+{{end}}</tbody>
+</table>
+<section class="totals">
+<dl>
+{{range $i, $f := .Totals}}<dt{{if eq $i 3}} class="grand"{{end}}>{{$f.Label}}</dt><dd{{if eq $i 3}} class="grand"{{end}}>{{$f.Value}}</dd>
+{{end}}</dl>
+</section>
+<footer>单价及金额均为含税价，币种：{{.Doc.Invoice.Currency}}</footer>
+</body>
+</html>
+`))
+
+// RenderHTML 将发票渲染为HTML
+func (d *Document) RenderHTML(w io.Writer) error {
+	return htmlTemplate.Execute(w, struct {
+		Doc    *Document
+		Meta   []field
+		Seller []field
+		Buyer  []field
+		Totals []field
+	}{
+		Doc:    d,
+		Meta:   d.metaFields(),
+		Seller: d.sellerFields(),
+		Buyer:  d.buyerFields(),
+		Totals: d.totalFields(),
+	})
+}
@@ -0,0 +1,140 @@
+package invoice
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+)
+
+// Document 用于渲染的发票文档
+type Document struct {
+	Invoice *models.Invoice
+	Items   []models.InvoiceItem
+	OrderNo string
+	Seller  config.InvoiceConfig
+}
+
+// NewDocument 根据发票记录创建可渲染的发票文档
+func NewDocument(inv *models.Invoice, orderNo string, seller config.InvoiceConfig) (*Document, error) {
+	items, err := inv.GetInvoiceItems()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		Invoice: inv,
+		Items:   items,
+		OrderNo: orderNo,
+		Seller:  seller,
+	}, nil
+}
+
+// Filename 获取下载文件名
+func (d *Document) Filename(ext string) string {
+	return fmt.Sprintf("invoice-%s.%s", d.Invoice.InvoiceNo, ext)
+}
+
+// field 发票上的一行键值信息
+type field struct {
+	Label string
+	Value string
+}
+
+// sellerFields 销售方信息
+func (d *Document) sellerFields() []field {
+	return nonEmptyFields([]field{
+		{"名称", d.Seller.SellerName},
+		{"纳税人识别号", d.Seller.SellerTaxID},
+		{"地址", d.Seller.SellerAddress},
+		{"电话", d.Seller.SellerPhone},
+	})
+}
+
+// buyerFields 购买方信息
+func (d *Document) buyerFields() []field {
+	inv := d.Invoice
+	return nonEmptyFields([]field{
+		{"名称", inv.BuyerName},
+		{"纳税人识别号", inv.BuyerTaxID},
+		{"地址", inv.BuyerAddress},
+		{"电话", inv.BuyerPhone},
+		{"开户行", inv.BuyerBankName},
+		{"银行账号", inv.BuyerBankAccount},
+		{"邮箱", inv.BuyerEmail},
+	})
+}
+
+// metaFields 发票基本信息
+func (d *Document) metaFields() []field {
+	inv := d.Invoice
+	fields := []field{
+		{"发票号码", inv.InvoiceNo},
+		{"订单号", d.OrderNo},
+		{"开具日期", formatDate(inv.IssuedAt)},
+	}
+	if inv.PaidAt != nil {
+		fields = append(fields, field{"支付日期", formatDate(*inv.PaidAt)})
+	}
+	fields = append(fields, field{"支付方式", paymentMethodLabel(inv.PaymentMethod)})
+	return fields
+}
+
+// totalFields 金额合计信息
+func (d *Document) totalFields() []field {
+	inv := d.Invoice
+	return []field{
+		{"不含税金额", formatAmount(inv.Subtotal)},
+		{"税率", formatTaxRate(inv.TaxRate)},
+		{"税额", formatAmount(inv.TaxAmount)},
+		{"价税合计", formatAmount(inv.Total)},
+	}
+}
+
+// nonEmptyFields 过滤掉值为空的字段
+func nonEmptyFields(fields []field) []field {
+	result := make([]field, 0, len(fields))
+	for _, f := range fields {
+		if f.Value != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// formatAmount 将金额（分）格式化为元
+func formatAmount(fen int) string {
+	sign := ""
+	if fen < 0 {
+		sign = "-"
+		fen = -fen
+	}
+	return fmt.Sprintf("%s￥%d.%02d", sign, fen/100, fen%100)
+}
+
+// formatTaxRate 格式化税率
+func formatTaxRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'f', -1, 64) + "%"
+}
+
+// formatDate 格式化日期
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+// paymentMethodLabel 获取支付方式的显示名称
+func paymentMethodLabel(method models.PaymentMethod) string {
+	labels := map[models.PaymentMethod]string{
+		models.PaymentMethodAlipay: "支付宝",
+		models.PaymentMethodWechat: "微信支付",
+		models.PaymentMethodUnion:  "银联",
+		models.PaymentMethodCredit: "银行卡",
+		models.PaymentMethodPaypal: "PayPal",
+	}
+	if label, ok := labels[method]; ok {
+		return label
+	}
+	return string(method)
+}
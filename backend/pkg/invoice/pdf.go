@@ -0,0 +1,194 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// A4页面尺寸（pt）及边距
+const (
+	pageWidth    = 595.0
+	pageHeight   = 842.0
+	pageMargin   = 50.0
+	contentRight = pageWidth - pageMargin
+)
+
+// 表格列位置
+const (
+	colQuantity = 360.0
+	colPrice    = 450.0
+)
+
+// RenderPDF 将发票渲染为PDF
+//
+// 使用PDF阅读器内置的STSong-Light中文字体（Adobe-GB1），无需嵌入字体文件。
+func (d *Document) RenderPDF(w io.Writer) error {
+	p := newPDFPage()
+
+	// 标题
+	p.textCenter(pageHeight-70, 20, "发票 / 收据")
+	y := pageHeight - 110.0
+
+	// 基本信息
+	for _, f := range d.metaFields() {
+		p.text(pageMargin, y, 10, f.Label+"：")
+		p.text(pageMargin+80, y, 10, f.Value)
+		y -= 16
+	}
+	y -= 10
+
+	// 销售方与购买方
+	half := (pageWidth - 2*pageMargin) / 2
+	p.text(pageMargin, y, 11, "销售方")
+	p.text(pageMargin+half, y, 11, "购买方")
+	y -= 18
+	seller, buyer := d.sellerFields(), d.buyerFields()
+	rows := len(seller)
+	if len(buyer) > rows {
+		rows = len(buyer)
+	}
+	for i := 0; i < rows; i++ {
+		if i < len(seller) {
+			p.text(pageMargin, y, 9, seller[i].Label+"："+seller[i].Value)
+		}
+		if i < len(buyer) {
+			p.text(pageMargin+half, y, 9, buyer[i].Label+"："+buyer[i].Value)
+		}
+		y -= 14
+	}
+	y -= 16
+
+	// 明细表
+	p.line(pageMargin, y+14, contentRight, y+14)
+	p.text(pageMargin, y, 10, "项目")
+	p.textRight(colQuantity, y, 10, "数量")
+	p.textRight(colPrice, y, 10, "单价")
+	p.textRight(contentRight, y, 10, "金额")
+	p.line(pageMargin, y-6, contentRight, y-6)
+	y -= 22
+	for _, item := range d.Items {
+		p.text(pageMargin, y, 10, truncateText(item.Description, 10, colQuantity-pageMargin-40))
+		p.textRight(colQuantity, y, 10, fmt.Sprintf("%d", item.Quantity))
+		p.textRight(colPrice, y, 10, formatAmount(item.UnitPrice))
+		p.textRight(contentRight, y, 10, formatAmount(item.Amount))
+		y -= 18
+	}
+	p.line(pageMargin, y+10, contentRight, y+10)
+	y -= 10
+
+	// 合计
+	totals := d.totalFields()
+	for i, f := range totals {
+		size := 10.0
+		if i == len(totals)-1 {
+			size = 12
+		}
+		p.textRight(colPrice, y, size, f.Label)
+		p.textRight(contentRight, y, size, f.Value)
+		y -= 18
+	}
+
+	p.textCenter(pageMargin, 8, "单价及金额均为含税价，币种："+d.Invoice.Currency)
+
+	_, err := w.Write(p.bytes())
+	return err
+}
+
+// pdfPage 单页PDF内容构建器
+type pdfPage struct {
+	content bytes.Buffer
+}
+
+// newPDFPage 创建单页PDF构建器
+func newPDFPage() *pdfPage {
+	return &pdfPage{}
+}
+
+// text 在指定位置输出文本（左对齐）
+func (p *pdfPage) text(x, y, size float64, s string) {
+	fmt.Fprintf(&p.content, "BT /F1 %.1f Tf %.2f %.2f Td <%s> Tj ET\n", size, x, y, encodeUCS2(s))
+}
+
+// textRight 在指定位置输出右对齐文本
+func (p *pdfPage) textRight(right, y, size float64, s string) {
+	p.text(right-textWidth(s, size), y, size, s)
+}
+
+// textCenter 输出水平居中文本
+func (p *pdfPage) textCenter(y, size float64, s string) {
+	p.text((pageWidth-textWidth(s, size))/2, y, size, s)
+}
+
+// line 绘制直线
+func (p *pdfPage) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.content, "0.5 w %.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// bytes 生成完整的PDF文件内容
+func (p *pdfPage) bytes() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 5 0 R >> >> /Contents 4 0 R >>", pageWidth, pageHeight),
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", p.content.Len(), p.content.String()),
+		"<< /Type /Font /Subtype /Type0 /BaseFont /STSong-Light /Encoding /UniGB-UCS2-H /DescendantFonts [6 0 R] >>",
+		"<< /Type /Font /Subtype /CIDFontType0 /BaseFont /STSong-Light /CIDSystemInfo << /Registry (Adobe) /Ordering (GB1) /Supplement 2 >> /FontDescriptor 7 0 R /DW 1000 /W [1 95 500] >>",
+		"<< /Type /FontDescriptor /FontName /STSong-Light /Flags 6 /FontBBox [-25 -254 1000 880] /ItalicAngle 0 /Ascent 880 /Descent -120 /CapHeight 880 /StemV 93 >>",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	return buf.Bytes()
+}
+
+// encodeUCS2 将文本编码为UCS-2大端序的十六进制字符串，超出BMP的字符替换为问号
+func encodeUCS2(s string) string {
+	var buf bytes.Buffer
+	for _, r := range s {
+		if r > 0xFFFF {
+			r = '?'
+		}
+		fmt.Fprintf(&buf, "%04X", r)
+	}
+	return buf.String()
+}
+
+// textWidth 估算文本宽度：ASCII字符为半角，其余为全角
+func textWidth(s string, size float64) float64 {
+	width := 0.0
+	for _, r := range s {
+		if r < 0x80 {
+			width += size / 2
+		} else {
+			width += size
+		}
+	}
+	return width
+}
+
+// truncateText 截断超出最大宽度的文本
+func truncateText(s string, size, maxWidth float64) string {
+	if textWidth(s, size) <= maxWidth {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && textWidth(string(runes)+"…", size) > maxWidth {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
@@ -0,0 +1,75 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// deletedPurgeInterval 回收站清理间隔
+const deletedPurgeInterval = 6 * time.Hour
+
+// DeletedPurgeJob 定期彻底删除在回收站中超过保留期的执行记录、工作流和代理
+type DeletedPurgeJob struct {
+	DB        *gorm.DB
+	Logger    *zap.Logger
+	Retention time.Duration
+}
+
+// NewDeletedPurgeJob 创建回收站清理任务
+func NewDeletedPurgeJob(db *gorm.DB, logger *zap.Logger, retention time.Duration) *DeletedPurgeJob {
+	return &DeletedPurgeJob{
+		DB:        db,
+		Logger:    logger,
+		Retention: retention,
+	}
+}
+
+// Start 周期性执行清理，直到ctx被取消
+func (j *DeletedPurgeJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(deletedPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 执行一次清理，先清理执行记录和工作流，使其引用的代理可以被清理
+func (j *DeletedPurgeJob) RunOnce() {
+	before := time.Now().Add(-j.Retention)
+
+	affected, err := models.PurgeDeletedExecutions(j.DB, before)
+	if err != nil {
+		j.Logger.Error("清理已删除的执行记录失败", zap.Error(err))
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理已删除的执行记录", zap.Int64("count", affected))
+	}
+
+	affected, err = models.PurgeDeletedWorkflows(j.DB, before)
+	if err != nil {
+		j.Logger.Error("清理已删除的工作流失败", zap.Error(err))
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理已删除的工作流", zap.Int64("count", affected))
+	}
+
+	affected, err = models.PurgeDeletedAgents(j.DB, before)
+	if err != nil {
+		j.Logger.Error("清理已删除的代理失败", zap.Error(err))
+		return
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理已删除的代理", zap.Int64("count", affected))
+	}
+}
@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/payment"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SubscriptionRenewalJob 订阅续费任务：为即将到期的订阅生成续费订单，并处理逾期订阅
+type SubscriptionRenewalJob struct {
+	DB       *gorm.DB
+	Logger   *zap.Logger
+	Payments payment.Provider
+	Config   config.SubscriptionConfig
+}
+
+// NewSubscriptionRenewalJob 创建订阅续费任务
+func NewSubscriptionRenewalJob(db *gorm.DB, logger *zap.Logger, payments payment.Provider, cfg config.SubscriptionConfig) *SubscriptionRenewalJob {
+	return &SubscriptionRenewalJob{
+		DB:       db,
+		Logger:   logger,
+		Payments: payments,
+		Config:   cfg,
+	}
+}
+
+// Start 按配置的间隔周期性执行续费任务，直到ctx被取消
+func (j *SubscriptionRenewalJob) Start(ctx context.Context) {
+	interval := time.Duration(j.Config.RenewalCheckInterval) * time.Minute
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	j.Logger.Info("订阅续费任务已启动", zap.Duration("interval", interval))
+
+	for {
+		j.RunOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			j.Logger.Info("订阅续费任务已停止")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 执行一次续费检查
+func (j *SubscriptionRenewalJob) RunOnce(ctx context.Context) {
+	now := time.Now()
+
+	// 为即将到期的订阅生成续费订单
+	dueBefore := now.Add(time.Duration(j.Config.RenewAheadHours) * time.Hour)
+	subscriptions, err := models.ListSubscriptionsDueForRenewal(j.DB, dueBefore)
+	if err != nil {
+		j.Logger.Error("查询待续费订阅失败", zap.Error(err))
+	}
+	for _, subscription := range subscriptions {
+		if ctx.Err() != nil {
+			return
+		}
+		j.renew(ctx, subscription)
+	}
+
+	// 处理到期未续费的订阅
+	gracePeriod := time.Duration(j.Config.GracePeriodDays) * 24 * time.Hour
+	affected, err := models.ExpireOverdueSubscriptions(j.DB, now, gracePeriod)
+	if err != nil {
+		j.Logger.Error("处理到期订阅失败", zap.Error(err))
+	} else if affected > 0 {
+		j.Logger.Info("已处理到期订阅", zap.Int64("count", affected))
+	}
+}
+
+// renew 为单个订阅生成续费订单并发起支付
+func (j *SubscriptionRenewalJob) renew(ctx context.Context, subscription *models.UserSubscription) {
+	plan, ok := models.GetSubscriptionPlan(subscription.PlanCode)
+	if !ok {
+		j.Logger.Warn("订阅套餐不存在，跳过续费",
+			zap.Int64("subscriptionId", subscription.ID),
+			zap.String("plan", subscription.PlanCode),
+		)
+		return
+	}
+
+	var order *models.RechargeOrder
+	err := j.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = models.CreateSubscriptionOrder(tx, subscription, plan)
+		if err != nil {
+			return err
+		}
+		return tx.Model(subscription).Update("renewal_order_id", order.ID).Error
+	})
+	if err != nil {
+		j.Logger.Error("创建续费订单失败", zap.Error(err), zap.Int64("subscriptionId", subscription.ID))
+		return
+	}
+
+	result, err := j.Payments.CreatePayment(ctx, payment.PaymentRequest{
+		OrderNo: order.OrderNo,
+		Amount:  order.Amount,
+		Method:  string(order.PaymentMethod),
+		Subject: "续费" + plan.Name,
+		UserID:  subscription.UserID,
+	})
+	if err != nil {
+		j.Logger.Error("发起续费支付失败", zap.Error(err), zap.String("orderNo", order.OrderNo))
+		return
+	}
+
+	j.Logger.Info("续费订单已创建",
+		zap.Int64("subscriptionId", subscription.ID),
+		zap.String("orderNo", order.OrderNo),
+		zap.String("paymentUrl", result.PaymentURL),
+	)
+}
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// tokenCleanupInterval 令牌清理间隔
+const tokenCleanupInterval = time.Hour
+
+// rateLimitBucketRetention 限流令牌桶的保留时长，需大于任一限流策略的补满时长
+const rateLimitBucketRetention = 24 * time.Hour
+
+// TokenCleanupJob 定期清理过期的刷新令牌、访问令牌吊销记录、第三方登录请求、登录失败计数和限流令牌桶
+type TokenCleanupJob struct {
+	DB                 *gorm.DB
+	Logger             *zap.Logger
+	LoginFailureWindow time.Duration
+}
+
+// NewTokenCleanupJob 创建令牌清理任务
+func NewTokenCleanupJob(db *gorm.DB, logger *zap.Logger, loginFailureWindow time.Duration) *TokenCleanupJob {
+	return &TokenCleanupJob{
+		DB:                 db,
+		Logger:             logger,
+		LoginFailureWindow: loginFailureWindow,
+	}
+}
+
+// Start 周期性执行清理，直到ctx被取消
+func (j *TokenCleanupJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(tokenCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		j.RunOnce()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce 执行一次清理
+func (j *TokenCleanupJob) RunOnce() {
+	affected, err := models.PurgeExpiredTokens(j.DB, time.Now())
+	if err != nil {
+		j.Logger.Error("清理过期令牌失败", zap.Error(err))
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理过期令牌", zap.Int64("count", affected))
+	}
+
+	affected, err = models.PurgeExpiredOAuthStates(j.DB, time.Now())
+	if err != nil {
+		j.Logger.Error("清理过期第三方登录请求失败", zap.Error(err))
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理过期第三方登录请求", zap.Int64("count", affected))
+	}
+
+	affected, err = models.PurgeStaleLoginAttempts(j.DB, time.Now().Add(-j.LoginFailureWindow))
+	if err != nil {
+		j.Logger.Error("清理登录失败计数失败", zap.Error(err))
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理登录失败计数", zap.Int64("count", affected))
+	}
+
+	affected, err = models.PurgeStaleRateLimitBuckets(j.DB, time.Now().Add(-rateLimitBucketRetention))
+	if err != nil {
+		j.Logger.Error("清理限流令牌桶失败", zap.Error(err))
+		return
+	}
+	if affected > 0 {
+		j.Logger.Info("已清理限流令牌桶", zap.Int64("count", affected))
+	}
+}
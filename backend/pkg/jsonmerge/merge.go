@@ -0,0 +1,200 @@
+// Package jsonmerge 提供JSON文档的差异比较和三方合并，用于在升级已购买的工作流时保留用户的自定义修改
+package jsonmerge
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// 变更类型
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+)
+
+// Change 两个JSON文档之间的一处差异
+type Change struct {
+	Path string      `json:"path"`           // 以/分隔的字段路径，根为/
+	Op   string      `json:"op"`             // add、remove或replace
+	From interface{} `json:"from,omitempty"` // 原值
+	To   interface{} `json:"to,omitempty"`   // 新值
+}
+
+// Conflict 用户修改与上游修改冲突的字段，合并时保留用户的值
+type Conflict struct {
+	Path     string      `json:"path"`
+	Base     interface{} `json:"base,omitempty"`     // 原版本的值
+	Mine     interface{} `json:"mine,omitempty"`     // 用户的值
+	Upstream interface{} `json:"upstream,omitempty"` // 新版本的值
+}
+
+// absent 表示字段不存在，与JSON null区分
+type absentValue struct{}
+
+var absent = absentValue{}
+
+// decode 解析JSON文档，空文档视为null
+func decode(data json.RawMessage) (interface{}, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("解析JSON失败: %w", err)
+	}
+	return v, nil
+}
+
+// exported 将内部值转换为输出值，不存在的字段输出为nil
+func exported(v interface{}) interface{} {
+	if v == absent {
+		return nil
+	}
+	return v
+}
+
+// childPath 拼接字段路径，字段名中的~和/按JSON Pointer规则转义
+func childPath(parent, key string) string {
+	key = strings.ReplaceAll(key, "~", "~0")
+	key = strings.ReplaceAll(key, "/", "~1")
+	if parent == "/" {
+		return "/" + key
+	}
+	return parent + "/" + key
+}
+
+// unionKeys 多个对象的全部字段名，按字典序排列保证结果稳定
+func unionKeys(objects ...map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, obj := range objects {
+		for key := range obj {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// field 获取对象字段，不存在时返回absent
+func field(obj map[string]interface{}, key string) interface{} {
+	if v, ok := obj[key]; ok {
+		return v
+	}
+	return absent
+}
+
+// Diff 比较两个JSON文档，对象逐字段比较，数组和标量整体比较
+func Diff(from, to json.RawMessage) ([]Change, error) {
+	a, err := decode(from)
+	if err != nil {
+		return nil, err
+	}
+	b, err := decode(to)
+	if err != nil {
+		return nil, err
+	}
+	changes := []Change{}
+	diff("/", a, b, &changes)
+	return changes, nil
+}
+
+func diff(path string, a, b interface{}, changes *[]Change) {
+	if reflect.DeepEqual(a, b) {
+		return
+	}
+	objA, okA := a.(map[string]interface{})
+	objB, okB := b.(map[string]interface{})
+	if okA && okB {
+		for _, key := range unionKeys(objA, objB) {
+			diff(childPath(path, key), field(objA, key), field(objB, key), changes)
+		}
+		return
+	}
+
+	change := Change{Path: path, From: exported(a), To: exported(b)}
+	switch {
+	case a == absent:
+		change.Op = OpAdd
+	case b == absent:
+		change.Op = OpRemove
+	default:
+		change.Op = OpReplace
+	}
+	*changes = append(*changes, change)
+}
+
+// Merge 以base为共同祖先三方合并mine（用户当前版本）和upstream（新版本）
+//
+// 只有一方修改的字段采用修改方的值；双方都修改且结果不同的字段视为冲突，保留用户的值。
+// overwrite为true时直接采用新版本，丢弃用户的修改。
+func Merge(base, mine, upstream json.RawMessage, overwrite bool) (json.RawMessage, []Conflict, error) {
+	if overwrite {
+		return upstream, []Conflict{}, nil
+	}
+
+	b, err := decode(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, err := decode(mine)
+	if err != nil {
+		return nil, nil, err
+	}
+	u, err := decode(upstream)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conflicts := []Conflict{}
+	merged := merge("/", b, m, u, &conflicts)
+	if merged == absent {
+		merged = nil
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("序列化合并结果失败: %w", err)
+	}
+	return data, conflicts, nil
+}
+
+func merge(path string, base, mine, upstream interface{}, conflicts *[]Conflict) interface{} {
+	switch {
+	case reflect.DeepEqual(mine, base):
+		return upstream
+	case reflect.DeepEqual(upstream, base), reflect.DeepEqual(mine, upstream):
+		return mine
+	}
+
+	objBase, okBase := base.(map[string]interface{})
+	objMine, okMine := mine.(map[string]interface{})
+	objUpstream, okUpstream := upstream.(map[string]interface{})
+	if okMine && okUpstream {
+		if !okBase {
+			objBase = map[string]interface{}{}
+		}
+		result := make(map[string]interface{}, len(objMine))
+		for _, key := range unionKeys(objBase, objMine, objUpstream) {
+			v := merge(childPath(path, key), field(objBase, key), field(objMine, key), field(objUpstream, key), conflicts)
+			if v != absent {
+				result[key] = v
+			}
+		}
+		return result
+	}
+
+	*conflicts = append(*conflicts, Conflict{
+		Path:     path,
+		Base:     exported(base),
+		Mine:     exported(mine),
+		Upstream: exported(upstream),
+	})
+	return mine
+}
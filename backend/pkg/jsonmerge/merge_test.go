@@ -0,0 +1,172 @@
+package jsonmerge
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// equalJSON 比较两个JSON文档的语义是否相同
+func equalJSON(t *testing.T, a, b json.RawMessage) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("解析JSON失败: %v: %s", err, a)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("解析JSON失败: %v: %s", err, b)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func conflictPaths(conflicts []Conflict) []string {
+	paths := []string{}
+	for _, c := range conflicts {
+		paths = append(paths, c.Path)
+	}
+	return paths
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name          string
+		base          string
+		mine          string
+		upstream      string
+		overwrite     bool
+		want          string
+		wantConflicts []string
+	}{
+		{
+			name:          "用户未修改时采用新版本",
+			base:          `{"prompt":"a","model":"x"}`,
+			mine:          `{"prompt":"a","model":"x"}`,
+			upstream:      `{"prompt":"b","model":"y"}`,
+			want:          `{"prompt":"b","model":"y"}`,
+			wantConflicts: []string{},
+		},
+		{
+			name:          "上游未修改时保留用户的值",
+			base:          `{"prompt":"a"}`,
+			mine:          `{"prompt":"mine"}`,
+			upstream:      `{"prompt":"a"}`,
+			want:          `{"prompt":"mine"}`,
+			wantConflicts: []string{},
+		},
+		{
+			name:          "双方修改不同字段时合并",
+			base:          `{"prompt":"a","model":"x","temperature":0.5}`,
+			mine:          `{"prompt":"mine","model":"x","temperature":0.5}`,
+			upstream:      `{"prompt":"a","model":"y","temperature":0.5,"maxTokens":100}`,
+			want:          `{"prompt":"mine","model":"y","temperature":0.5,"maxTokens":100}`,
+			wantConflicts: []string{},
+		},
+		{
+			name:          "双方修改为相同的值不冲突",
+			base:          `{"model":"x"}`,
+			mine:          `{"model":"y"}`,
+			upstream:      `{"model":"y"}`,
+			want:          `{"model":"y"}`,
+			wantConflicts: []string{},
+		},
+		{
+			name:          "双方修改同一字段时冲突并保留用户的值",
+			base:          `{"config":{"prompt":"a","model":"x"}}`,
+			mine:          `{"config":{"prompt":"mine","model":"x"}}`,
+			upstream:      `{"config":{"prompt":"new","model":"y"}}`,
+			want:          `{"config":{"prompt":"mine","model":"y"}}`,
+			wantConflicts: []string{"/config/prompt"},
+		},
+		{
+			name:          "上游删除用户未修改的字段",
+			base:          `{"prompt":"a","legacy":true}`,
+			mine:          `{"prompt":"mine","legacy":true}`,
+			upstream:      `{"prompt":"a"}`,
+			want:          `{"prompt":"mine"}`,
+			wantConflicts: []string{},
+		},
+		{
+			name:          "用户删除而上游修改的字段冲突",
+			base:          `{"legacy":1}`,
+			mine:          `{}`,
+			upstream:      `{"legacy":2}`,
+			want:          `{}`,
+			wantConflicts: []string{"/legacy"},
+		},
+		{
+			name:          "数组整体比较",
+			base:          `{"steps":[1,2]}`,
+			mine:          `{"steps":[1,2,3]}`,
+			upstream:      `{"steps":[2]}`,
+			want:          `{"steps":[1,2,3]}`,
+			wantConflicts: []string{"/steps"},
+		},
+		{
+			name:          "字段名按JSON Pointer转义",
+			base:          `{"a/b":1}`,
+			mine:          `{"a/b":2}`,
+			upstream:      `{"a/b":3}`,
+			want:          `{"a/b":2}`,
+			wantConflicts: []string{"/a~1b"},
+		},
+		{
+			name:          "覆盖时直接采用新版本",
+			base:          `{"prompt":"a"}`,
+			mine:          `{"prompt":"mine"}`,
+			upstream:      `{"prompt":"new"}`,
+			overwrite:     true,
+			want:          `{"prompt":"new"}`,
+			wantConflicts: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			merged, conflicts, err := Merge(json.RawMessage(tt.base), json.RawMessage(tt.mine), json.RawMessage(tt.upstream), tt.overwrite)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !equalJSON(t, merged, json.RawMessage(tt.want)) {
+				t.Errorf("合并结果应为%s，实际为%s", tt.want, merged)
+			}
+			if paths := conflictPaths(conflicts); !reflect.DeepEqual(paths, tt.wantConflicts) {
+				t.Errorf("冲突字段应为%v，实际为%v", tt.wantConflicts, paths)
+			}
+		})
+	}
+}
+
+func TestMergeConflictValues(t *testing.T) {
+	_, conflicts, err := Merge(json.RawMessage(`{"model":"x"}`), json.RawMessage(`{}`), json.RawMessage(`{"model":"y"}`), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Conflict{{Path: "/model", Base: "x", Mine: nil, Upstream: "y"}}
+	if !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("冲突应为%+v，实际为%+v", want, conflicts)
+	}
+}
+
+func TestMergeInvalidJSON(t *testing.T) {
+	if _, _, err := Merge(json.RawMessage(`{}`), json.RawMessage(`{`), json.RawMessage(`{}`), false); err == nil {
+		t.Error("无效的JSON应返回错误")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	changes, err := Diff(
+		json.RawMessage(`{"prompt":"a","model":"x","legacy":true,"steps":[1]}`),
+		json.RawMessage(`{"prompt":"a","model":"y","steps":[1,2],"maxTokens":100}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Path: "/legacy", Op: OpRemove, From: true},
+		{Path: "/maxTokens", Op: OpAdd, To: float64(100)},
+		{Path: "/model", Op: OpReplace, From: "x", To: "y"},
+		{Path: "/steps", Op: OpReplace, From: []interface{}{float64(1)}, To: []interface{}{float64(1), float64(2)}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("差异应为%+v，实际为%+v", want, changes)
+	}
+}
@@ -0,0 +1,201 @@
+package loginguard
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+)
+
+// State 单个键（账号或IP）的失败计数状态
+type State struct {
+	Failures      int       // 统计窗口内的失败次数
+	WindowStart   time.Time // 统计窗口开始时间
+	NextAllowedAt time.Time // 递增等待结束前不允许再次尝试
+	LockedUntil   time.Time // 锁定结束时间
+}
+
+// Store 失败计数存储
+type Store interface {
+	// Get 获取键的当前状态，不存在时返回零值
+	Get(ctx context.Context, key string) (State, error)
+	// Update 在互斥保护下修改键的状态并返回修改后的状态
+	Update(ctx context.Context, key string, fn func(s *State)) (State, error)
+	// Delete 删除键的状态
+	Delete(ctx context.Context, key string) error
+}
+
+// Limits 登录限制参数
+type Limits struct {
+	MaxAccountFailures int           // 账号失败次数上限
+	MaxIPFailures      int           // IP失败次数上限
+	Window             time.Duration // 统计窗口
+	Lockout            time.Duration // 锁定时长
+	DelayAfter         int           // 连续失败多少次后开始等待
+	DelayBase          time.Duration // 首次等待时长
+	DelayMax           time.Duration // 最长等待时长
+}
+
+// LimitsFromConfig 从认证配置读取登录限制
+func LimitsFromConfig(cfg config.AuthConfig) Limits {
+	return Limits{
+		MaxAccountFailures: cfg.MaxLoginFailuresPerAccount,
+		MaxIPFailures:      cfg.MaxLoginFailuresPerIP,
+		Window:             time.Duration(cfg.LoginFailureWindow) * time.Minute,
+		Lockout:            time.Duration(cfg.LoginLockoutDuration) * time.Minute,
+		DelayAfter:         cfg.LoginDelayAfter,
+		DelayBase:          time.Duration(cfg.LoginDelayBase) * time.Second,
+		DelayMax:           time.Duration(cfg.LoginDelayMax) * time.Second,
+	}
+}
+
+// Decision 登录前检查结果
+type Decision struct {
+	Allowed    bool          // 是否允许本次尝试
+	Locked     bool          // 是否处于锁定状态（否则为递增等待）
+	RetryAfter time.Duration // 需等待的时长
+}
+
+// FailureResult 记录失败后的结果
+type FailureResult struct {
+	AccountLocked bool      // 本次失败导致账号被锁定
+	IPLocked      bool      // 本次失败导致IP被锁定
+	LockedUntil   time.Time // 锁定结束时间
+}
+
+// Guard 登录防暴力破解守卫，分别按账号和IP统计失败次数
+type Guard struct {
+	store  Store
+	limits Limits
+	now    func() time.Time
+}
+
+// New 创建登录守卫
+func New(store Store, limits Limits) *Guard {
+	return &Guard{
+		store:  store,
+		limits: limits,
+		now:    time.Now,
+	}
+}
+
+// Check 检查账号和IP当前是否允许尝试登录
+func (g *Guard) Check(ctx context.Context, account, ip string) (Decision, error) {
+	now := g.now()
+	decision := Decision{Allowed: true}
+
+	for _, key := range []string{accountKey(account), ipKey(ip)} {
+		state, err := g.store.Get(ctx, key)
+		if err != nil {
+			return Decision{}, err
+		}
+
+		if state.LockedUntil.After(now) {
+			wait := state.LockedUntil.Sub(now)
+			if !decision.Locked || wait > decision.RetryAfter {
+				decision = Decision{Allowed: false, Locked: true, RetryAfter: wait}
+			}
+			continue
+		}
+		if !decision.Locked && state.NextAllowedAt.After(now) {
+			if wait := state.NextAllowedAt.Sub(now); wait > decision.RetryAfter {
+				decision = Decision{Allowed: false, RetryAfter: wait}
+			}
+		}
+	}
+
+	return decision, nil
+}
+
+// RecordFailure 记录一次登录失败
+func (g *Guard) RecordFailure(ctx context.Context, account, ip string) (FailureResult, error) {
+	var result FailureResult
+
+	locked, until, err := g.recordFailure(ctx, accountKey(account), g.limits.MaxAccountFailures)
+	if err != nil {
+		return result, err
+	}
+	if locked {
+		result.AccountLocked = true
+		result.LockedUntil = until
+	}
+
+	locked, until, err = g.recordFailure(ctx, ipKey(ip), g.limits.MaxIPFailures)
+	if err != nil {
+		return result, err
+	}
+	if locked {
+		result.IPLocked = true
+		if until.After(result.LockedUntil) {
+			result.LockedUntil = until
+		}
+	}
+
+	return result, nil
+}
+
+// RecordSuccess 登录成功后清除账号的失败计数
+//
+// IP计数不清除，避免攻击者用自己的账号登录来重置IP计数。
+func (g *Guard) RecordSuccess(ctx context.Context, account string) error {
+	return g.store.Delete(ctx, accountKey(account))
+}
+
+// recordFailure 增加单个键的失败次数，返回本次是否触发锁定
+func (g *Guard) recordFailure(ctx context.Context, key string, maxFailures int) (bool, time.Time, error) {
+	now := g.now()
+	lockedNow := false
+
+	state, err := g.store.Update(ctx, key, func(s *State) {
+		// 统计窗口过期或锁定结束后重新计数
+		if s.WindowStart.IsZero() || now.Sub(s.WindowStart) > g.limits.Window ||
+			(!s.LockedUntil.IsZero() && !s.LockedUntil.After(now)) {
+			*s = State{WindowStart: now}
+		}
+
+		s.Failures++
+
+		if maxFailures > 0 && s.Failures >= maxFailures {
+			if !s.LockedUntil.After(now) {
+				lockedNow = true
+			}
+			s.LockedUntil = now.Add(g.limits.Lockout)
+			return
+		}
+
+		if g.limits.DelayAfter > 0 && s.Failures >= g.limits.DelayAfter {
+			s.NextAllowedAt = now.Add(g.delayFor(s.Failures))
+		}
+	})
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	return lockedNow, state.LockedUntil, nil
+}
+
+// delayFor 计算第n次失败后的等待时长，从DelayBase开始每次翻倍
+func (g *Guard) delayFor(failures int) time.Duration {
+	delay := g.limits.DelayBase
+	for i := g.limits.DelayAfter; i < failures; i++ {
+		delay *= 2
+		if delay >= g.limits.DelayMax {
+			return g.limits.DelayMax
+		}
+	}
+	if g.limits.DelayMax > 0 && delay > g.limits.DelayMax {
+		return g.limits.DelayMax
+	}
+	return delay
+}
+
+// accountKey 账号维度的键，邮箱不区分大小写
+func accountKey(account string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(account))
+}
+
+// ipKey IP维度的键
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
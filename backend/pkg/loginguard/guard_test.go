@@ -0,0 +1,153 @@
+package loginguard
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testLimits 测试用的登录限制参数
+var testLimits = Limits{
+	MaxAccountFailures: 5,
+	MaxIPFailures:      10,
+	Window:             15 * time.Minute,
+	Lockout:            30 * time.Minute,
+	DelayAfter:         2,
+	DelayBase:          time.Second,
+	DelayMax:           4 * time.Second,
+}
+
+// newTestGuard 创建使用可控时钟的登录守卫
+func newTestGuard(limits Limits) (*Guard, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := New(NewMemoryStore(limits.Window), limits)
+	g.now = func() time.Time { return now }
+	return g, &now
+}
+
+func TestDelayFor(t *testing.T) {
+	g, _ := newTestGuard(testLimits)
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{2, time.Second},
+		{3, 2 * time.Second},
+		{4, 4 * time.Second},
+		{5, 4 * time.Second},
+		{20, 4 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := g.delayFor(tt.failures); got != tt.want {
+			t.Errorf("第%d次失败后应等待%v，实际为%v", tt.failures, tt.want, got)
+		}
+	}
+}
+
+func TestGuardProgressiveDelay(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name      string
+		failures  int
+		elapsed   time.Duration // 最后一次失败后经过的时间
+		wantAllow bool
+		wantRetry time.Duration
+	}{
+		{"未达到等待阈值", 1, 0, true, 0},
+		{"第二次失败后等待基础时长", 2, 0, false, time.Second},
+		{"等待时长逐次翻倍", 3, 0, false, 2 * time.Second},
+		{"等待时长不超过上限", 4, time.Second, false, 3 * time.Second},
+		{"等待结束后允许尝试", 3, 2 * time.Second, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, now := newTestGuard(testLimits)
+			for i := 0; i < tt.failures; i++ {
+				if _, err := g.RecordFailure(ctx, "user@example.com", "1.1.1.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			*now = now.Add(tt.elapsed)
+
+			decision, err := g.Check(ctx, "User@Example.com", "2.2.2.2")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Allowed != tt.wantAllow || decision.Locked || decision.RetryAfter != tt.wantRetry {
+				t.Errorf("应为allowed=%v retry=%v，实际为%+v", tt.wantAllow, tt.wantRetry, decision)
+			}
+		})
+	}
+}
+
+func TestGuardLockout(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		accounts    int // 每次失败使用不同账号，只累计IP次数
+		failures    int
+		elapsed     time.Duration
+		wantAccount bool // 最后一次失败是否锁定账号
+		wantIP      bool // 最后一次失败是否锁定IP
+		wantLocked  bool // 经过elapsed后检查是否仍锁定
+	}{
+		{"账号达到上限后锁定", 1, 5, 0, true, false, true},
+		{"锁定期内仍被拒绝", 1, 5, 29 * time.Minute, true, false, true},
+		{"锁定结束后解除", 1, 5, 30 * time.Minute, true, false, false},
+		{"IP达到上限后锁定", 10, 10, 0, false, true, true},
+		{"未达到上限不锁定", 1, 4, 10 * time.Second, false, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, now := newTestGuard(testLimits)
+			var result FailureResult
+			for i := 0; i < tt.failures; i++ {
+				account := "user@example.com"
+				if tt.accounts > 1 {
+					account = string(rune('a'+i)) + "@example.com"
+				}
+				var err error
+				if result, err = g.RecordFailure(ctx, account, "1.1.1.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if result.AccountLocked != tt.wantAccount || result.IPLocked != tt.wantIP {
+				t.Errorf("锁定结果应为account=%v ip=%v，实际为%+v", tt.wantAccount, tt.wantIP, result)
+			}
+			*now = now.Add(tt.elapsed)
+
+			decision, err := g.Check(ctx, "user@example.com", "1.1.1.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decision.Locked != tt.wantLocked {
+				t.Errorf("锁定状态应为%v，实际为%+v", tt.wantLocked, decision)
+			}
+			if tt.wantLocked && decision.RetryAfter != 30*time.Minute-tt.elapsed {
+				t.Errorf("剩余锁定时长应为%v，实际为%v", 30*time.Minute-tt.elapsed, decision.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestGuardRecordSuccessKeepsIPCount(t *testing.T) {
+	ctx := context.Background()
+	g, _ := newTestGuard(testLimits)
+	for i := 0; i < 4; i++ {
+		if _, err := g.RecordFailure(ctx, "user@example.com", "1.1.1.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := g.RecordSuccess(ctx, "user@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	account, _ := g.store.Get(ctx, accountKey("user@example.com"))
+	if account.Failures != 0 {
+		t.Errorf("登录成功后应清除账号计数，实际为%d", account.Failures)
+	}
+	ip, _ := g.store.Get(ctx, ipKey("1.1.1.1"))
+	if ip.Failures != 4 {
+		t.Errorf("登录成功后不应清除IP计数，实际为%d", ip.Failures)
+	}
+}
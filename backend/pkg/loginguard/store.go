@@ -0,0 +1,189 @@
+package loginguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewStore 根据配置创建失败计数存储：memory用于单节点，database用于多副本部署
+func NewStore(kind string, db *gorm.DB, limits Limits) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(limits.Window), nil
+	case "database":
+		return NewDBStore(db), nil
+	default:
+		return nil, fmt.Errorf("不支持的登录失败计数存储: %s", kind)
+	}
+}
+
+// memorySweepInterval 内存存储清理过期状态的间隔
+const memorySweepInterval = time.Minute
+
+// MemoryStore 进程内失败计数存储
+type MemoryStore struct {
+	mu        sync.Mutex
+	states    map[string]State
+	window    time.Duration
+	lastSweep time.Time
+}
+
+// NewMemoryStore 创建进程内失败计数存储，window为失败次数统计窗口
+func NewMemoryStore(window time.Duration) *MemoryStore {
+	return &MemoryStore{
+		states: make(map[string]State),
+		window: window,
+	}
+}
+
+// Get 获取键的当前状态
+func (s *MemoryStore) Get(ctx context.Context, key string) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.states[key], nil
+}
+
+// Update 在互斥锁保护下修改键的状态
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(st *State)) (State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+
+	state := s.states[key]
+	fn(&state)
+	s.states[key] = state
+	return state, nil
+}
+
+// Delete 删除键的状态
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.states, key)
+	return nil
+}
+
+// sweep 定期清理已无等待和锁定的状态，调用方需持有锁
+//
+// 清理后的键在下次失败时会重新开启统计窗口，与窗口过期后的行为一致。
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, state := range s.states {
+		if !state.LockedUntil.After(now) && !state.NextAllowedAt.After(now) && now.Sub(state.WindowStart) > s.window {
+			delete(s.states, key)
+		}
+	}
+}
+
+// DBStore 数据库失败计数存储，多副本共享
+type DBStore struct {
+	DB *gorm.DB
+}
+
+// NewDBStore 创建数据库失败计数存储
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+// Get 获取键的当前状态
+func (s *DBStore) Get(ctx context.Context, key string) (State, error) {
+	var attempt models.LoginAttempt
+	err := s.DB.WithContext(ctx).Where("attempt_key = ?", key).First(&attempt).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return State{}, nil
+		}
+		return State{}, fmt.Errorf("获取登录失败计数失败: %w", err)
+	}
+	return stateFromAttempt(attempt), nil
+}
+
+// Update 在行锁保护下修改键的状态
+func (s *DBStore) Update(ctx context.Context, key string, fn func(st *State)) (State, error) {
+	var state State
+
+	update := func(tx *gorm.DB) error {
+		var attempt models.LoginAttempt
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("attempt_key = ?", key).First(&attempt).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if exists {
+			state = stateFromAttempt(attempt)
+		}
+		fn(&state)
+
+		attempt = attemptFromState(key, state)
+		if exists {
+			return tx.Save(&attempt).Error
+		}
+		return tx.Create(&attempt).Error
+	}
+
+	err := s.DB.WithContext(ctx).Transaction(update)
+	if err != nil {
+		// 并发首次写入同一个键时插入会冲突，重试一次即可读到已存在的行
+		state = State{}
+		err = s.DB.WithContext(ctx).Transaction(update)
+	}
+	if err != nil {
+		return State{}, fmt.Errorf("更新登录失败计数失败: %w", err)
+	}
+
+	return state, nil
+}
+
+// Delete 删除键的状态
+func (s *DBStore) Delete(ctx context.Context, key string) error {
+	if err := s.DB.WithContext(ctx).Where("attempt_key = ?", key).Delete(&models.LoginAttempt{}).Error; err != nil {
+		return fmt.Errorf("清除登录失败计数失败: %w", err)
+	}
+	return nil
+}
+
+// stateFromAttempt 数据库记录转换为状态
+func stateFromAttempt(attempt models.LoginAttempt) State {
+	state := State{
+		Failures:    attempt.Failures,
+		WindowStart: attempt.WindowStart,
+	}
+	if attempt.NextAllowedAt != nil {
+		state.NextAllowedAt = *attempt.NextAllowedAt
+	}
+	if attempt.LockedUntil != nil {
+		state.LockedUntil = *attempt.LockedUntil
+	}
+	return state
+}
+
+// attemptFromState 状态转换为数据库记录
+func attemptFromState(key string, state State) models.LoginAttempt {
+	attempt := models.LoginAttempt{
+		Key:         key,
+		Failures:    state.Failures,
+		WindowStart: state.WindowStart,
+	}
+	if !state.NextAllowedAt.IsZero() {
+		t := state.NextAllowedAt
+		attempt.NextAllowedAt = &t
+	}
+	if !state.LockedUntil.IsZero() {
+		t := state.LockedUntil
+		attempt.LockedUntil = &t
+	}
+	return attempt
+}
@@ -0,0 +1,175 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alexfaker/jilang-agent/config"
+)
+
+// newMockProvider 启动模拟提供方，返回指向它的客户端
+func newMockProvider(t *testing.T) (*MockServer, *Provider) {
+	t.Helper()
+	mock, err := NewMockServer("http://placeholder")
+	if err != nil {
+		t.Fatalf("创建模拟提供方失败: %v", err)
+	}
+	srv := httptest.NewServer(mock)
+	t.Cleanup(srv.Close)
+	mock.Issuer = srv.URL
+
+	return mock, NewProvider(config.OIDCProviderConfig{
+		Name:        "mock",
+		Issuer:      srv.URL,
+		ClientID:    "test-client",
+		RedirectURL: "http://app.example.com/oauth/callback",
+	})
+}
+
+// authorize 请求授权地址，返回模拟提供方重定向回客户端时携带的code和state
+func authorize(t *testing.T, authURL string) (code, state string) {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("请求授权端点失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("授权端点应重定向，实际为%d", resp.StatusCode)
+	}
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatalf("解析重定向地址失败: %v", err)
+	}
+	if !strings.HasPrefix(location.String(), "http://app.example.com/oauth/callback?") {
+		t.Fatalf("应重定向到回调地址，实际为%s", location)
+	}
+	return location.Query().Get("code"), location.Query().Get("state")
+}
+
+func TestMockAuthorizationCodeFlow(t *testing.T) {
+	mock, provider := newMockProvider(t)
+	ctx := context.Background()
+
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", challenge)
+	if err != nil {
+		t.Fatalf("生成授权地址失败: %v", err)
+	}
+
+	code, state := authorize(t, authURL)
+	if code == "" || state != "state-1" {
+		t.Fatalf("回调应带回授权码和原state，实际为code=%q state=%q", code, state)
+	}
+
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("换取令牌失败: %v", err)
+	}
+	if token.AccessToken == "" || token.IDToken == "" {
+		t.Fatalf("应返回访问令牌和ID令牌，实际为%+v", token)
+	}
+
+	identity, err := provider.Identity(ctx, token, "nonce-1")
+	if err != nil {
+		t.Fatalf("获取用户身份失败: %v", err)
+	}
+	want := mock.DefaultUser
+	if identity.Subject != want.Subject || identity.Email != want.Email || identity.Username != want.Username || !identity.EmailVerified {
+		t.Errorf("用户身份应为%+v，实际为%+v", want, identity)
+	}
+
+	// 授权码只能使用一次
+	if _, err := provider.Exchange(ctx, code, verifier); err == nil {
+		t.Error("重复使用授权码应失败")
+	}
+}
+
+func TestMockLoginHint(t *testing.T) {
+	_, provider := newMockProvider(t)
+	ctx := context.Background()
+
+	verifier, challenge, _ := GeneratePKCE()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := authorize(t, authURL+"&login_hint=alice@example.com")
+
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("换取令牌失败: %v", err)
+	}
+	identity, err := provider.Identity(ctx, token, "nonce")
+	if err != nil {
+		t.Fatalf("获取用户身份失败: %v", err)
+	}
+	if identity.Email != "alice@example.com" || identity.Username != "alice" || identity.Subject != "mock-alice@example.com" {
+		t.Errorf("应按login_hint模拟用户，实际为%+v", identity)
+	}
+}
+
+func TestMockRejectsWrongCodeVerifier(t *testing.T) {
+	_, provider := newMockProvider(t)
+	ctx := context.Background()
+
+	_, challenge, _ := GeneratePKCE()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := authorize(t, authURL)
+
+	otherVerifier, _, _ := GeneratePKCE()
+	_, err = provider.Exchange(ctx, code, otherVerifier)
+	if err == nil || !strings.Contains(err.Error(), "PKCE") {
+		t.Fatalf("PKCE校验值不匹配时应拒绝换取令牌，实际为%v", err)
+	}
+}
+
+func TestMockRequiresPKCE(t *testing.T) {
+	mock, _ := newMockProvider(t)
+	params := url.Values{
+		"response_type": {"code"},
+		"client_id":     {"test-client"},
+		"redirect_uri":  {"http://app.example.com/oauth/callback"},
+		"state":         {"state"},
+	}
+	req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+	w := httptest.NewRecorder()
+	mock.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("未携带code_challenge时应返回400，实际为%d", w.Code)
+	}
+}
+
+func TestIdentityRejectsNonceMismatch(t *testing.T) {
+	_, provider := newMockProvider(t)
+	ctx := context.Background()
+
+	verifier, challenge, _ := GeneratePKCE()
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := authorize(t, authURL)
+	token, err := provider.Exchange(ctx, code, verifier)
+	if err != nil {
+		t.Fatalf("换取令牌失败: %v", err)
+	}
+
+	if _, err := provider.Identity(ctx, token, "nonce-2"); err == nil {
+		t.Fatal("nonce不匹配时应拒绝ID令牌")
+	}
+}
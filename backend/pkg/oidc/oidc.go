@@ -0,0 +1,411 @@
+// Package oidc 实现OIDC/OAuth2授权码模式（PKCE）登录所需的客户端逻辑：
+// 端点发现、授权地址生成、授权码换取令牌、ID令牌校验以及用户信息获取。
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+)
+
+// httpTimeout 请求提供方接口的超时时间
+const httpTimeout = 10 * time.Second
+
+// ErrProviderNotFound 未配置的提供方
+var ErrProviderNotFound = errors.New("不支持的登录方式")
+
+// Token 授权码换取的令牌
+type Token struct {
+	AccessToken string
+	TokenType   string
+	IDToken     string
+}
+
+// Identity 提供方返回的用户身份
+type Identity struct {
+	Subject       string // 提供方内的用户唯一标识
+	Email         string
+	EmailVerified bool
+	Username      string
+	Name          string
+	Avatar        string
+}
+
+// Registry 已配置的登录提供方
+type Registry struct {
+	providers map[string]*Provider
+	order     []string
+}
+
+// NewRegistry 根据配置创建登录提供方集合，端点在首次使用时才发现
+func NewRegistry(cfgs []config.OIDCProviderConfig) (*Registry, error) {
+	r := &Registry{providers: make(map[string]*Provider)}
+	for _, cfg := range cfgs {
+		if cfg.Name == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+			return nil, fmt.Errorf("登录提供方配置不完整: %q", cfg.Name)
+		}
+		if cfg.Issuer == "" && (cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "") {
+			return nil, fmt.Errorf("登录提供方 %s 需配置issuer或完整的端点地址", cfg.Name)
+		}
+		if _, exists := r.providers[cfg.Name]; exists {
+			return nil, fmt.Errorf("登录提供方重复: %s", cfg.Name)
+		}
+		r.providers[cfg.Name] = NewProvider(cfg)
+		r.order = append(r.order, cfg.Name)
+	}
+	return r, nil
+}
+
+// Get 按名称获取提供方
+func (r *Registry) Get(name string) (*Provider, error) {
+	if r == nil {
+		return nil, ErrProviderNotFound
+	}
+	p, ok := r.providers[name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+	return p, nil
+}
+
+// List 按配置顺序列出全部提供方
+func (r *Registry) List() []*Provider {
+	if r == nil {
+		return nil
+	}
+	list := make([]*Provider, 0, len(r.order))
+	for _, name := range r.order {
+		list = append(list, r.providers[name])
+	}
+	return list
+}
+
+// Provider 单个OIDC/OAuth2提供方
+type Provider struct {
+	cfg    config.OIDCProviderConfig
+	client *http.Client
+
+	mu         sync.Mutex
+	discovered bool
+	authURL    string
+	tokenURL   string
+	userInfo   string
+	jwksURL    string
+	keys       *keySet
+}
+
+// NewProvider 创建提供方
+func NewProvider(cfg config.OIDCProviderConfig) *Provider {
+	return &Provider{
+		cfg:    cfg,
+		client: &http.Client{Timeout: httpTimeout},
+	}
+}
+
+// Name 提供方标识
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// DisplayName 提供方显示名称
+func (p *Provider) DisplayName() string {
+	if p.cfg.DisplayName != "" {
+		return p.cfg.DisplayName
+	}
+	return p.cfg.Name
+}
+
+// AuthCodeURL 生成授权地址，codeChallenge为PKCE的S256挑战值
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeChallenge string) (string, error) {
+	if err := p.discover(ctx); err != nil {
+		return "", err
+	}
+
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.cfg.ClientID)
+	params.Set("redirect_uri", p.cfg.RedirectURL)
+	params.Set("scope", strings.Join(scopes, " "))
+	params.Set("state", state)
+	params.Set("nonce", nonce)
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(p.authURL, "?") {
+		sep = "&"
+	}
+	return p.authURL + sep + params.Encode(), nil
+}
+
+// Exchange 使用授权码和PKCE校验值换取令牌
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (*Token, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("client_id", p.cfg.ClientID)
+	form.Set("code_verifier", codeVerifier)
+	if p.cfg.ClientSecret != "" {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := p.doJSON(req, &body); err != nil {
+		return nil, fmt.Errorf("换取令牌失败: %w", err)
+	}
+	if body.Error != "" {
+		return nil, fmt.Errorf("换取令牌失败: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.AccessToken == "" {
+		return nil, fmt.Errorf("换取令牌失败: 响应中没有access_token")
+	}
+
+	return &Token{
+		AccessToken: body.AccessToken,
+		TokenType:   body.TokenType,
+		IDToken:     body.IDToken,
+	}, nil
+}
+
+// Identity 获取用户身份
+//
+// 返回ID令牌时先校验签名、发行方、受众和nonce，再用用户信息端点补充缺失的字段；
+// 没有ID令牌的OAuth2提供方（如GitHub）只使用用户信息端点。
+func (p *Provider) Identity(ctx context.Context, token *Token, nonce string) (*Identity, error) {
+	if err := p.discover(ctx); err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if token.IDToken != "" {
+		idClaims, err := p.verifyIDToken(ctx, token.IDToken, nonce)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range idClaims {
+			claims[k] = v
+		}
+	}
+
+	if p.userInfo != "" {
+		info, err := p.fetchUserInfo(ctx, token.AccessToken)
+		if err != nil {
+			return nil, err
+		}
+		subjectClaim := claimName(p.cfg.SubjectClaim, "sub")
+		if sub, ok := claims[subjectClaim]; ok && claimString(info[subjectClaim]) != "" && claimString(info[subjectClaim]) != claimString(sub) {
+			return nil, fmt.Errorf("用户信息与ID令牌的主体不一致")
+		}
+		for k, v := range info {
+			if _, exists := claims[k]; !exists {
+				claims[k] = v
+			}
+		}
+	} else if token.IDToken == "" {
+		return nil, fmt.Errorf("提供方未返回ID令牌且未配置用户信息端点")
+	}
+
+	identity := &Identity{
+		Subject:  claimString(claims[claimName(p.cfg.SubjectClaim, "sub")]),
+		Email:    claimString(claims[claimName(p.cfg.EmailClaim, "email")]),
+		Username: claimString(claims[claimName(p.cfg.UsernameClaim, "preferred_username")]),
+		Name:     claimString(claims[claimName(p.cfg.NameClaim, "name")]),
+		Avatar:   claimString(claims[claimName(p.cfg.AvatarClaim, "picture")]),
+	}
+	if verified, ok := claims["email_verified"].(bool); ok {
+		identity.EmailVerified = verified
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("提供方未返回用户标识")
+	}
+
+	return identity, nil
+}
+
+// discover 通过发行方的配置文档补全未显式配置的端点
+func (p *Provider) discover(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovered {
+		return nil
+	}
+
+	p.authURL = p.cfg.AuthURL
+	p.tokenURL = p.cfg.TokenURL
+	p.userInfo = p.cfg.UserInfoURL
+	p.jwksURL = p.cfg.JWKSURL
+
+	if p.cfg.Issuer != "" && (p.authURL == "" || p.tokenURL == "" || p.jwksURL == "") {
+		wellKnown := strings.TrimRight(p.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+		if err != nil {
+			return err
+		}
+
+		var doc struct {
+			Issuer                string `json:"issuer"`
+			AuthorizationEndpoint string `json:"authorization_endpoint"`
+			TokenEndpoint         string `json:"token_endpoint"`
+			UserInfoEndpoint      string `json:"userinfo_endpoint"`
+			JWKSURI               string `json:"jwks_uri"`
+		}
+		if err := p.doJSON(req, &doc); err != nil {
+			return fmt.Errorf("获取OIDC配置失败: %w", err)
+		}
+		if strings.TrimRight(doc.Issuer, "/") != strings.TrimRight(p.cfg.Issuer, "/") {
+			return fmt.Errorf("OIDC配置中的issuer不匹配: %s", doc.Issuer)
+		}
+
+		if p.authURL == "" {
+			p.authURL = doc.AuthorizationEndpoint
+		}
+		if p.tokenURL == "" {
+			p.tokenURL = doc.TokenEndpoint
+		}
+		if p.userInfo == "" {
+			p.userInfo = doc.UserInfoEndpoint
+		}
+		if p.jwksURL == "" {
+			p.jwksURL = doc.JWKSURI
+		}
+	}
+
+	if p.authURL == "" || p.tokenURL == "" {
+		return fmt.Errorf("登录提供方 %s 缺少授权或令牌端点", p.cfg.Name)
+	}
+	if p.jwksURL != "" {
+		p.keys = newKeySet(p.jwksURL, p.client)
+	}
+
+	p.discovered = true
+	return nil
+}
+
+// fetchUserInfo 调用用户信息端点
+func (p *Provider) fetchUserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.userInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+
+	info := map[string]interface{}{}
+	if err := p.doJSON(req, &info); err != nil {
+		return nil, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	return info, nil
+}
+
+// doJSON 发送请求并解析JSON响应，数字保留为json.Number以免大整数ID丢失精度
+func (p *Provider) doJSON(req *http.Request, out interface{}) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		var oauthErr struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if json.Unmarshal(data, &oauthErr) == nil && oauthErr.Error != "" {
+			return fmt.Errorf("HTTP %d: %s %s", resp.StatusCode, oauthErr.Error, oauthErr.ErrorDescription)
+		}
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(out)
+}
+
+// GeneratePKCE 生成PKCE校验值及其S256挑战值
+func GeneratePKCE() (verifier, challenge string, err error) {
+	verifier, err = RandomString(32)
+	if err != nil {
+		return "", "", err
+	}
+	return verifier, S256Challenge(verifier), nil
+}
+
+// S256Challenge 计算PKCE校验值的S256挑战值
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// RandomString 生成n字节随机数的URL安全编码
+func RandomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// claimName 返回配置的声明名，未配置时使用默认值
+func claimName(configured, fallback string) string {
+	if configured != "" {
+		return configured
+	}
+	return fallback
+}
+
+// claimString 将声明值转换为字符串，数字ID（如GitHub）原样输出
+func claimString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case float64:
+		return fmt.Sprintf("%.0f", val)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,109 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/alexfaker/jilang-agent/config"
+)
+
+// PaymentRequest 发起支付请求
+type PaymentRequest struct {
+	OrderNo string // 订单号
+	Amount  int    // 支付金额（分）
+	Method  string // 支付方式
+	Subject string // 订单标题
+	UserID  string // 付款用户
+}
+
+// PaymentResult 发起支付结果
+type PaymentResult struct {
+	PaymentURL string // 用户跳转支付的链接
+	PaymentID  string // 第三方支付流水号（可能为空，支付完成后回调提供）
+}
+
+// ErrInvalidSignature 支付回调签名校验失败
+var ErrInvalidSignature = errors.New("支付回调签名无效")
+
+// CallbackRequest 支付网关回调通知
+type CallbackRequest struct {
+	OrderNo   string // 订单号
+	PaymentID string // 第三方支付流水号
+	Amount    int    // 实际支付金额（分）
+	Signature string // 支付网关对通知内容的签名
+}
+
+// Provider 支付提供方抽象
+type Provider interface {
+	// Name 返回支付提供方名称
+	Name() string
+	// CreatePayment 为订单发起支付
+	CreatePayment(ctx context.Context, req PaymentRequest) (*PaymentResult, error)
+	// VerifyCallback 校验支付回调确实来自支付网关，校验失败返回ErrInvalidSignature
+	VerifyCallback(ctx context.Context, req CallbackRequest) error
+}
+
+// NewProvider 根据配置创建支付提供方
+func NewProvider(cfg config.PaymentConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "", "mock":
+		return NewMockProvider(cfg.PayURL, cfg.CallbackSecret), nil
+	default:
+		return nil, fmt.Errorf("不支持的支付提供方: %s", cfg.Provider)
+	}
+}
+
+// MockProvider 模拟支付提供方，仅生成支付链接，由支付回调完成订单
+//
+// 回调使用CallbackSecret做HMAC-SHA256签名，未配置密钥时拒绝所有回调。
+type MockProvider struct {
+	PayURL         string
+	CallbackSecret string
+}
+
+// NewMockProvider 创建模拟支付提供方
+func NewMockProvider(payURL, callbackSecret string) *MockProvider {
+	if payURL == "" {
+		payURL = "https://pay.example.com/pay"
+	}
+	return &MockProvider{PayURL: strings.TrimRight(payURL, "?"), CallbackSecret: callbackSecret}
+}
+
+// Name 返回支付提供方名称
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+// CreatePayment 生成模拟支付链接
+func (p *MockProvider) CreatePayment(ctx context.Context, req PaymentRequest) (*PaymentResult, error) {
+	if req.OrderNo == "" {
+		return nil, fmt.Errorf("订单号不能为空")
+	}
+	return &PaymentResult{
+		PaymentURL: p.PayURL + "?orderNo=" + url.QueryEscape(req.OrderNo),
+	}, nil
+}
+
+// Sign 计算回调通知的签名，签名内容为 订单号|支付流水号|金额
+func (p *MockProvider) Sign(req CallbackRequest) string {
+	mac := hmac.New(sha256.New, []byte(p.CallbackSecret))
+	fmt.Fprintf(mac, "%s|%s|%d", req.OrderNo, req.PaymentID, req.Amount)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyCallback 校验回调签名
+func (p *MockProvider) VerifyCallback(ctx context.Context, req CallbackRequest) error {
+	if p.CallbackSecret == "" || req.Signature == "" {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(p.Sign(req)), []byte(strings.ToLower(req.Signature))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
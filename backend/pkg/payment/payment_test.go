@@ -0,0 +1,46 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMockProviderVerifyCallback(t *testing.T) {
+	signed := func(secret string, req CallbackRequest) CallbackRequest {
+		req.Signature = NewMockProvider("", secret).Sign(req)
+		return req
+	}
+	req := CallbackRequest{OrderNo: "RC_TEST", PaymentID: "PAY_1", Amount: 1000}
+
+	tests := []struct {
+		name    string
+		secret  string
+		req     CallbackRequest
+		wantErr error
+	}{
+		{"签名有效", "secret", signed("secret", req), nil},
+		{"签名不区分大小写", "secret", func() CallbackRequest {
+			r := signed("secret", req)
+			r.Signature = strings.ToUpper(r.Signature)
+			return r
+		}(), nil},
+		{"密钥错误", "secret", signed("other", req), ErrInvalidSignature},
+		{"缺少签名", "secret", req, ErrInvalidSignature},
+		{"未配置密钥", "", signed("", req), ErrInvalidSignature},
+		{"金额被篡改", "secret", func() CallbackRequest {
+			r := signed("secret", req)
+			r.Amount = 1
+			return r
+		}(), ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewMockProvider("", tt.secret).VerifyCallback(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("错误应为%v，实际为%v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+package quota
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"gorm.io/gorm"
+)
+
+// 配额项
+const (
+	ConcurrentExecutions = "concurrent_executions" // 并发执行数
+	DailyExecutions      = "daily_executions"      // 每日执行次数
+	Workflows            = "workflows"             // 工作流数量
+	ExecutionOutputSize  = "execution_output_size" // 单次执行输出大小
+	UploadSize           = "upload_size"           // 单个上传文件大小
+)
+
+// 配额计量单位
+const (
+	UnitCount = "count"
+	UnitBytes = "bytes"
+)
+
+// Limits 套餐的配额上限，0表示不限
+type Limits struct {
+	ConcurrentExecutions int64
+	DailyExecutions      int64
+	Workflows            int64
+	ExecutionOutputBytes int64
+	UploadBytes          int64
+}
+
+// LimitsFromConfig 从套餐配额配置读取配额上限
+func LimitsFromConfig(cfg config.PlanQuotaConfig) Limits {
+	return Limits{
+		ConcurrentExecutions: int64(cfg.MaxConcurrentExecutions),
+		DailyExecutions:      int64(cfg.MaxExecutionsPerDay),
+		Workflows:            int64(cfg.MaxWorkflows),
+		ExecutionOutputBytes: int64(cfg.MaxExecutionOutputKB) * 1024,
+		UploadBytes:          int64(cfg.MaxUploadSizeMB) * 1024 * 1024,
+	}
+}
+
+// ExceededError 超出配额
+type ExceededError struct {
+	Quota   string // 配额项
+	Limit   int64  // 配额上限
+	Message string // 面向用户的提示
+}
+
+// Error 实现error接口
+func (e *ExceededError) Error() string {
+	return e.Message
+}
+
+// HTTPStatus 超出配额时返回的状态码：执行频率类配额稍后可恢复，返回429；容量类配额返回403
+func (e *ExceededError) HTTPStatus() int {
+	switch e.Quota {
+	case ConcurrentExecutions, DailyExecutions:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusForbidden
+}
+
+// Usage 单个配额项的使用情况
+type Usage struct {
+	Quota   string     `json:"quota"`
+	Used    int64      `json:"used"`
+	Limit   int64      `json:"limit"` // 0表示不限
+	Unit    string     `json:"unit"`
+	ResetAt *time.Time `json:"resetAt,omitempty"` // 按天计算的配额下次重置时间
+}
+
+// Enforcer 按工作空间适用的订阅套餐检查配额
+type Enforcer struct {
+	db    *gorm.DB
+	plans map[string]Limits
+	now   func() time.Time
+}
+
+// New 创建配额检查器
+func New(db *gorm.DB, cfg config.QuotaConfig) *Enforcer {
+	plans := make(map[string]Limits, len(cfg.Plans))
+	for code, plan := range cfg.Plans {
+		plans[code] = LimitsFromConfig(plan)
+	}
+	return &Enforcer{
+		db:    db,
+		plans: plans,
+		now:   time.Now,
+	}
+}
+
+// Limits 获取工作空间适用的套餐及其配额，组织空间使用所有者的套餐
+func (e *Enforcer) Limits(ws models.Workspace) (*models.SubscriptionPlan, Limits, error) {
+	return e.limits(e.db, ws)
+}
+
+// limits 使用指定的连接或事务获取工作空间的套餐及其配额
+func (e *Enforcer) limits(db *gorm.DB, ws models.Workspace) (*models.SubscriptionPlan, Limits, error) {
+	plan, err := models.GetWorkspacePlan(db, ws)
+	if err != nil {
+		return nil, Limits{}, err
+	}
+	limits, ok := e.plans[plan.Code]
+	if !ok {
+		// 未配置的套餐使用免费版配额，避免误配置导致不限
+		limits = e.plans[models.PlanCodeFree]
+	}
+	return plan, limits, nil
+}
+
+// startOfDay 当天零点，每日配额按服务器时区重置
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+// countActive 统计工作空间中运行中的执行和试用数量
+func countActive(db *gorm.DB, ws models.Workspace) (int64, error) {
+	executions, err := models.CountActiveExecutions(db, ws)
+	if err != nil {
+		return 0, err
+	}
+	trials, err := models.CountActiveTrialRuns(db, ws)
+	if err != nil {
+		return 0, err
+	}
+	return executions + trials, nil
+}
+
+// countSince 统计工作空间自指定时间以来发起的执行和试用次数
+func countSince(db *gorm.DB, ws models.Workspace, since time.Time) (int64, error) {
+	executions, err := models.CountExecutionsSince(db, ws, since)
+	if err != nil {
+		return 0, err
+	}
+	trials, err := models.CountTrialRunsSince(db, ws, since)
+	if err != nil {
+		return 0, err
+	}
+	return executions + trials, nil
+}
+
+// ReserveExecution 检查并发执行数和每日执行次数，通过后调用create创建执行记录，返回工作空间的配额
+//
+// 检查和创建在锁定工作空间的同一事务中进行，同时发起的执行依次检查，不会一起通过而超出配额。
+// create返回错误时事务回滚，错误原样返回。
+func (e *Enforcer) ReserveExecution(ws models.Workspace, create func(tx *gorm.DB) error) (Limits, error) {
+	var limits Limits
+	err := e.db.Transaction(func(tx *gorm.DB) error {
+		if err := models.LockWorkspace(tx, ws); err != nil {
+			return err
+		}
+		var err error
+		if limits, err = e.checkExecution(tx, ws); err != nil {
+			return err
+		}
+		return create(tx)
+	})
+	if err != nil {
+		return Limits{}, err
+	}
+	return limits, nil
+}
+
+// checkExecution 检查并发执行数和每日执行次数，通过时返回工作空间的配额
+func (e *Enforcer) checkExecution(db *gorm.DB, ws models.Workspace) (Limits, error) {
+	plan, limits, err := e.limits(db, ws)
+	if err != nil {
+		return Limits{}, err
+	}
+
+	if limits.ConcurrentExecutions > 0 {
+		running, err := countActive(db, ws)
+		if err != nil {
+			return Limits{}, err
+		}
+		if running >= limits.ConcurrentExecutions {
+			return Limits{}, &ExceededError{
+				Quota:   ConcurrentExecutions,
+				Limit:   limits.ConcurrentExecutions,
+				Message: fmt.Sprintf("当前套餐（%s）最多同时运行%d个工作流，请稍后再试或升级套餐", plan.Name, limits.ConcurrentExecutions),
+			}
+		}
+	}
+
+	if limits.DailyExecutions > 0 {
+		today, err := countSince(db, ws, startOfDay(e.now()))
+		if err != nil {
+			return Limits{}, err
+		}
+		if today >= limits.DailyExecutions {
+			return Limits{}, &ExceededError{
+				Quota:   DailyExecutions,
+				Limit:   limits.DailyExecutions,
+				Message: fmt.Sprintf("当前套餐（%s）每天最多执行%d次，请明天再试或升级套餐", plan.Name, limits.DailyExecutions),
+			}
+		}
+	}
+
+	return limits, nil
+}
+
+// ReserveWorkflow 检查工作流数量，通过后调用create创建或恢复工作流
+//
+// 与ReserveExecution相同，检查和创建在锁定工作空间的同一事务中进行，create返回错误时事务回滚，错误原样返回。
+func (e *Enforcer) ReserveWorkflow(ws models.Workspace, create func(tx *gorm.DB) error) error {
+	return e.db.Transaction(func(tx *gorm.DB) error {
+		if err := models.LockWorkspace(tx, ws); err != nil {
+			return err
+		}
+		if err := e.checkWorkflowCreate(tx, ws); err != nil {
+			return err
+		}
+		return create(tx)
+	})
+}
+
+// checkWorkflowCreate 检查工作流数量
+func (e *Enforcer) checkWorkflowCreate(db *gorm.DB, ws models.Workspace) error {
+	plan, limits, err := e.limits(db, ws)
+	if err != nil {
+		return err
+	}
+	if limits.Workflows <= 0 {
+		return nil
+	}
+
+	count, err := models.CountWorkspaceWorkflows(db, ws)
+	if err != nil {
+		return err
+	}
+	if count >= limits.Workflows {
+		return &ExceededError{
+			Quota:   Workflows,
+			Limit:   limits.Workflows,
+			Message: fmt.Sprintf("当前套餐（%s）最多创建%d个工作流，请删除不用的工作流或升级套餐", plan.Name, limits.Workflows),
+		}
+	}
+	return nil
+}
+
+// CheckUpload 检查上传文件大小
+func (e *Enforcer) CheckUpload(ws models.Workspace, size int64) error {
+	plan, limits, err := e.Limits(ws)
+	if err != nil {
+		return err
+	}
+	if limits.UploadBytes > 0 && size > limits.UploadBytes {
+		return &ExceededError{
+			Quota:   UploadSize,
+			Limit:   limits.UploadBytes,
+			Message: fmt.Sprintf("当前套餐（%s）单个文件不能超过%dMB", plan.Name, limits.UploadBytes/1024/1024),
+		}
+	}
+	return nil
+}
+
+// CheckExecutionOutput 检查执行输出大小，limit为发起执行时工作空间的输出配额
+func CheckExecutionOutput(limit int64, size int) error {
+	if limit > 0 && int64(size) > limit {
+		return &ExceededError{
+			Quota:   ExecutionOutputSize,
+			Limit:   limit,
+			Message: fmt.Sprintf("执行输出超过套餐限制（%dKB），结果未保存", limit/1024),
+		}
+	}
+	return nil
+}
+
+// Usage 获取工作空间各配额项的使用情况
+func (e *Enforcer) Usage(ws models.Workspace) (*models.SubscriptionPlan, []Usage, error) {
+	plan, limits, err := e.Limits(ws)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	running, err := countActive(e.db, ws)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := e.now()
+	today, err := countSince(e.db, ws, startOfDay(now))
+	if err != nil {
+		return nil, nil, err
+	}
+	workflows, err := models.CountWorkspaceWorkflows(e.db, ws)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resetAt := startOfDay(now).AddDate(0, 0, 1)
+	return plan, []Usage{
+		{Quota: ConcurrentExecutions, Used: running, Limit: limits.ConcurrentExecutions, Unit: UnitCount},
+		{Quota: DailyExecutions, Used: today, Limit: limits.DailyExecutions, Unit: UnitCount, ResetAt: &resetAt},
+		{Quota: Workflows, Used: workflows, Limit: limits.Workflows, Unit: UnitCount},
+		{Quota: ExecutionOutputSize, Limit: limits.ExecutionOutputBytes, Unit: UnitBytes},
+		{Quota: UploadSize, Limit: limits.UploadBytes, Unit: UnitBytes},
+	}, nil
+}
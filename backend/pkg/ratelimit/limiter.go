@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+)
+
+// 限流维度
+const (
+	KeyByIP     = "ip"      // 按客户端IP
+	KeyByUser   = "user"    // 按登录用户，未登录时按IP
+	KeyByAPIKey = "api_key" // 按API密钥，JWT认证时按用户，未登录时按IP
+)
+
+// Policy 令牌桶限流策略
+type Policy struct {
+	Name  string  // 策略名称，即路由组名称
+	Rate  float64 // 每秒补充的令牌数
+	Burst int     // 桶容量
+	KeyBy string  // 限流维度
+}
+
+// PolicyFromConfig 从限流策略配置创建策略
+func PolicyFromConfig(name string, cfg config.RateLimitPolicyConfig) (Policy, error) {
+	if cfg.RequestsPerMinute <= 0 || cfg.Burst <= 0 {
+		return Policy{}, fmt.Errorf("限流策略%s的requestsPerMinute和burst必须大于0", name)
+	}
+	switch cfg.KeyBy {
+	case KeyByIP, KeyByUser, KeyByAPIKey:
+	default:
+		return Policy{}, fmt.Errorf("限流策略%s不支持的限流维度: %s", name, cfg.KeyBy)
+	}
+	return Policy{
+		Name:  name,
+		Rate:  float64(cfg.RequestsPerMinute) / 60,
+		Burst: cfg.Burst,
+		KeyBy: cfg.KeyBy,
+	}, nil
+}
+
+// PoliciesFromConfig 读取全部路由组的限流策略
+func PoliciesFromConfig(cfg config.RateLimitConfig) (map[string]Policy, error) {
+	policies := make(map[string]Policy, len(cfg.Policies))
+	for name, policyCfg := range cfg.Policies {
+		policy, err := PolicyFromConfig(name, policyCfg)
+		if err != nil {
+			return nil, err
+		}
+		policies[name] = policy
+	}
+	return policies, nil
+}
+
+// FillDuration 空桶补满所需的时长
+func (p Policy) FillDuration() time.Duration {
+	return time.Duration(float64(p.Burst) / p.Rate * float64(time.Second))
+}
+
+// Bucket 令牌桶状态
+type Bucket struct {
+	Tokens     float64   // 剩余令牌数
+	RefilledAt time.Time // 上次补充令牌的时间，零值表示新桶
+}
+
+// Store 令牌桶存储
+type Store interface {
+	// Update 在互斥保护下修改键对应的令牌桶并返回修改后的状态
+	Update(ctx context.Context, key string, fn func(b *Bucket)) (Bucket, error)
+}
+
+// Result 限流检查结果
+type Result struct {
+	Allowed    bool          // 是否放行本次请求
+	Limit      int           // 桶容量
+	Remaining  int           // 剩余可用请求数
+	Reset      time.Duration // 桶补满所需时长
+	RetryAfter time.Duration // 被拒绝时距下一个令牌可用的时长
+}
+
+// Limiter 令牌桶限流器，按路由组使用各自的限流策略
+type Limiter struct {
+	store    Store
+	policies map[string]Policy
+	now      func() time.Time
+}
+
+// New 创建限流器
+func New(store Store, policies map[string]Policy) *Limiter {
+	return &Limiter{
+		store:    store,
+		policies: policies,
+		now:      time.Now,
+	}
+}
+
+// Policy 获取路由组的限流策略，未配置时返回false
+func (l *Limiter) Policy(group string) (Policy, bool) {
+	policy, ok := l.policies[group]
+	return policy, ok
+}
+
+// Allow 按策略为键消耗一个令牌
+func (l *Limiter) Allow(ctx context.Context, policy Policy, key string) (Result, error) {
+	now := l.now()
+	var allowed bool
+
+	bucket, err := l.store.Update(ctx, policy.Name+":"+key, func(b *Bucket) {
+		// 存储重试时回调可能执行多次，以最后一次为准
+		allowed = false
+		refill(b, policy, now)
+		if b.Tokens >= 1 {
+			b.Tokens--
+			allowed = true
+		}
+	})
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Burst,
+		Remaining: int(math.Floor(bucket.Tokens)),
+		Reset:     secondsToDuration((float64(policy.Burst) - bucket.Tokens) / policy.Rate),
+	}
+	if !allowed {
+		result.RetryAfter = secondsToDuration((1 - bucket.Tokens) / policy.Rate)
+	}
+	return result, nil
+}
+
+// refill 按距上次补充经过的时间补充令牌，新桶为满桶
+func refill(b *Bucket, policy Policy, now time.Time) {
+	if b.RefilledAt.IsZero() {
+		b.Tokens = float64(policy.Burst)
+		b.RefilledAt = now
+		return
+	}
+	if elapsed := now.Sub(b.RefilledAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(policy.Burst), b.Tokens+elapsed*policy.Rate)
+		b.RefilledAt = now
+	}
+}
+
+// secondsToDuration 秒数转换为时长
+func secondsToDuration(seconds float64) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds * float64(time.Second))
+}
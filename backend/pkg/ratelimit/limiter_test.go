@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+)
+
+// testPolicy 每秒补充1个令牌、桶容量3的策略
+var testPolicy = Policy{Name: "api", Rate: 1, Burst: 3, KeyBy: KeyByUser}
+
+// newTestLimiter 创建使用可控时钟的限流器
+func newTestLimiter() (*Limiter, *time.Time) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(NewMemoryStore(time.Hour), map[string]Policy{testPolicy.Name: testPolicy})
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+func TestLimiterTokenBucket(t *testing.T) {
+	type request struct {
+		advance    time.Duration // 本次请求前经过的时间
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}
+	tests := []struct {
+		name     string
+		requests []request
+	}{
+		{
+			name: "新桶为满桶，耗尽后拒绝",
+			requests: []request{
+				{0, true, 2, 0},
+				{0, true, 1, 0},
+				{0, true, 0, 0},
+				{0, false, 0, time.Second},
+			},
+		},
+		{
+			name: "按经过的时间补充令牌",
+			requests: []request{
+				{0, true, 2, 0},
+				{0, true, 1, 0},
+				{0, true, 0, 0},
+				{500 * time.Millisecond, false, 0, 500 * time.Millisecond},
+				{500 * time.Millisecond, true, 0, 0},
+				{2 * time.Second, true, 1, 0},
+			},
+		},
+		{
+			name: "补充不超过桶容量",
+			requests: []request{
+				{0, true, 2, 0},
+				{time.Hour, true, 2, 0},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, now := newTestLimiter()
+			for i, req := range tt.requests {
+				*now = now.Add(req.advance)
+				result, err := l.Allow(context.Background(), testPolicy, "USER_1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if result.Allowed != req.allowed || result.Remaining != req.remaining || result.RetryAfter != req.retryAfter {
+					t.Errorf("第%d次请求应为allowed=%v remaining=%d retryAfter=%v，实际为%+v",
+						i+1, req.allowed, req.remaining, req.retryAfter, result)
+				}
+				if result.Limit != testPolicy.Burst {
+					t.Errorf("第%d次请求的limit应为%d，实际为%d", i+1, testPolicy.Burst, result.Limit)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterSeparatesKeys(t *testing.T) {
+	l, _ := newTestLimiter()
+	ctx := context.Background()
+	for i := 0; i < testPolicy.Burst; i++ {
+		if _, err := l.Allow(ctx, testPolicy, "USER_1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if result, _ := l.Allow(ctx, testPolicy, "USER_1"); result.Allowed {
+		t.Error("令牌耗尽后应拒绝")
+	}
+	if result, _ := l.Allow(ctx, testPolicy, "USER_2"); !result.Allowed {
+		t.Error("其他键的令牌桶不应受影响")
+	}
+	other := testPolicy
+	other.Name = "auth"
+	if result, _ := l.Allow(ctx, other, "USER_1"); !result.Allowed {
+		t.Error("其他路由组的令牌桶不应受影响")
+	}
+}
+
+func TestPolicyFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.RateLimitPolicyConfig
+		wantErr bool
+		want    Policy
+	}{
+		{"有效策略", config.RateLimitPolicyConfig{RequestsPerMinute: 120, Burst: 10, KeyBy: KeyByIP}, false, Policy{Name: "p", Rate: 2, Burst: 10, KeyBy: KeyByIP}},
+		{"速率为0", config.RateLimitPolicyConfig{RequestsPerMinute: 0, Burst: 10, KeyBy: KeyByIP}, true, Policy{}},
+		{"桶容量为0", config.RateLimitPolicyConfig{RequestsPerMinute: 60, Burst: 0, KeyBy: KeyByIP}, true, Policy{}},
+		{"不支持的维度", config.RateLimitPolicyConfig{RequestsPerMinute: 60, Burst: 10, KeyBy: "session"}, true, Policy{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := PolicyFromConfig("p", tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("错误应为%v，实际为%v", tt.wantErr, err)
+			}
+			if policy != tt.want {
+				t.Errorf("策略应为%+v，实际为%+v", tt.want, policy)
+			}
+		})
+	}
+}
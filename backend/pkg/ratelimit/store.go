@@ -0,0 +1,137 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NewStore 根据配置创建令牌桶存储：memory用于单节点，database用于多副本部署
+//
+// maxIdle为令牌桶从空到满的最长时间，超过该时长未使用的桶可以安全清理。
+func NewStore(kind string, db *gorm.DB, maxIdle time.Duration) (Store, error) {
+	switch kind {
+	case "", "memory":
+		return NewMemoryStore(maxIdle), nil
+	case "database":
+		return NewDBStore(db), nil
+	default:
+		return nil, fmt.Errorf("不支持的限流令牌桶存储: %s", kind)
+	}
+}
+
+// MaxFillDuration 多个策略中空桶补满所需的最长时长
+func MaxFillDuration(policies map[string]Policy) time.Duration {
+	var longest time.Duration
+	for _, policy := range policies {
+		if d := policy.FillDuration(); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// memorySweepInterval 内存存储清理空闲令牌桶的间隔
+const memorySweepInterval = time.Minute
+
+// MemoryStore 进程内令牌桶存储
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]Bucket
+	maxIdle   time.Duration
+	lastSweep time.Time
+}
+
+// NewMemoryStore 创建进程内令牌桶存储
+func NewMemoryStore(maxIdle time.Duration) *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]Bucket),
+		maxIdle: maxIdle,
+	}
+}
+
+// Update 在互斥锁保护下修改令牌桶
+func (s *MemoryStore) Update(ctx context.Context, key string, fn func(b *Bucket)) (Bucket, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(time.Now())
+
+	bucket := s.buckets[key]
+	fn(&bucket)
+	s.buckets[key] = bucket
+	return bucket, nil
+}
+
+// sweep 定期清理空闲时间已足够补满的令牌桶，调用方需持有锁
+//
+// 清理后的键在下次请求时按满桶重新开始，与补满后的状态一致。
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, bucket := range s.buckets {
+		if now.Sub(bucket.RefilledAt) > s.maxIdle {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// DBStore 数据库令牌桶存储，多副本共享
+type DBStore struct {
+	DB *gorm.DB
+}
+
+// NewDBStore 创建数据库令牌桶存储
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+// Update 在行锁保护下修改令牌桶
+func (s *DBStore) Update(ctx context.Context, key string, fn func(b *Bucket)) (Bucket, error) {
+	var bucket Bucket
+
+	update := func(tx *gorm.DB) error {
+		var record models.RateLimitBucket
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("bucket_key = ?", key).First(&record).Error
+		exists := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		bucket = Bucket{}
+		if exists {
+			bucket = Bucket{Tokens: record.Tokens, RefilledAt: record.RefilledAt}
+		}
+		fn(&bucket)
+
+		record = models.RateLimitBucket{
+			Key:        key,
+			Tokens:     bucket.Tokens,
+			RefilledAt: bucket.RefilledAt,
+		}
+		if exists {
+			return tx.Save(&record).Error
+		}
+		return tx.Create(&record).Error
+	}
+
+	err := s.DB.WithContext(ctx).Transaction(update)
+	if err != nil {
+		// 并发首次写入同一个键时插入会冲突，重试一次即可读到已存在的行
+		err = s.DB.WithContext(ctx).Transaction(update)
+	}
+	if err != nil {
+		return Bucket{}, fmt.Errorf("更新限流令牌桶失败: %w", err)
+	}
+
+	return bucket, nil
+}
@@ -0,0 +1,107 @@
+package search
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ngramTokenSize MySQL ngram分词长度（ngram_token_size默认值）
+const ngramTokenSize = 2
+
+// likePattern 转义LIKE通配符，生成包含匹配的模式
+func likePattern(text string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(text)
+	return "%" + escaped + "%"
+}
+
+// likeMatcher 模糊匹配，名称命中优先于描述命中
+type likeMatcher struct{}
+
+func (likeMatcher) where(text string) clause.Expr {
+	pattern := likePattern(text)
+	return clause.Expr{SQL: "(name LIKE ? OR description LIKE ?)", Vars: []interface{}{pattern, pattern}}
+}
+
+func (likeMatcher) score(text string) clause.Expr {
+	pattern := likePattern(text)
+	return clause.Expr{SQL: "CASE WHEN name LIKE ? THEN 2 WHEN description LIKE ? THEN 1 ELSE 0 END", Vars: []interface{}{pattern, pattern}}
+}
+
+// mysqlFulltextIndex MySQL全文索引名
+const mysqlFulltextIndex = "idx_agents_fulltext"
+
+// mysqlMatcher MySQL全文检索，ngram分词支持中文
+type mysqlMatcher struct{}
+
+func (mysqlMatcher) where(text string) clause.Expr {
+	return clause.Expr{SQL: "MATCH(name, description) AGAINST (? IN NATURAL LANGUAGE MODE)", Vars: []interface{}{text}}
+}
+
+func (mysqlMatcher) score(text string) clause.Expr {
+	return clause.Expr{SQL: "MATCH(name, description) AGAINST (? IN NATURAL LANGUAGE MODE)", Vars: []interface{}{text}}
+}
+
+func (mysqlMatcher) ensureIndex(db *gorm.DB) error {
+	var count int64
+	err := db.Raw(
+		"SELECT COUNT(*) FROM information_schema.statistics WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?",
+		"agents", mysqlFulltextIndex,
+	).Scan(&count).Error
+	if err != nil {
+		return fmt.Errorf("检查全文索引失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+	if err := db.Exec("CREATE FULLTEXT INDEX " + mysqlFulltextIndex + " ON agents (name, description) WITH PARSER ngram").Error; err != nil {
+		return fmt.Errorf("创建全文索引失败: %w", err)
+	}
+	return nil
+}
+
+// postgresMatcher Postgres全文检索，名称权重高于描述
+//
+// simple配置不能切分中文，因此同时用ILIKE匹配，中文关键词在未安装分词扩展时仍可搜索。
+type postgresMatcher struct {
+	document string // tsvector表达式，与索引表达式一致才能使用索引
+	config   string
+}
+
+func newPostgresMatcher(config string) postgresMatcher {
+	return postgresMatcher{
+		document: fmt.Sprintf(
+			"(setweight(to_tsvector('%[1]s', coalesce(name, '')), 'A') || setweight(to_tsvector('%[1]s', coalesce(description, '')), 'B'))",
+			config,
+		),
+		config: config,
+	}
+}
+
+func (m postgresMatcher) query() string {
+	return fmt.Sprintf("websearch_to_tsquery('%s', ?)", m.config)
+}
+
+func (m postgresMatcher) where(text string) clause.Expr {
+	pattern := likePattern(text)
+	return clause.Expr{
+		SQL:  "(" + m.document + " @@ " + m.query() + " OR name ILIKE ? OR description ILIKE ?)",
+		Vars: []interface{}{text, pattern, pattern},
+	}
+}
+
+func (m postgresMatcher) score(text string) clause.Expr {
+	return clause.Expr{
+		SQL:  "(ts_rank(" + m.document + ", " + m.query() + ") + CASE WHEN name ILIKE ? THEN 1 ELSE 0 END)",
+		Vars: []interface{}{text, likePattern(text)},
+	}
+}
+
+func (m postgresMatcher) ensureIndex(db *gorm.DB) error {
+	if err := db.Exec("CREATE INDEX IF NOT EXISTS idx_agents_search ON agents USING GIN (" + m.document + ")").Error; err != nil {
+		return fmt.Errorf("创建全文索引失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+// Package search 实现代理商店的搜索、筛选和分面统计
+//
+// 关键词匹配由数据库全文索引完成：MySQL使用ngram分词的FULLTEXT索引，Postgres使用tsvector表达式索引；
+// 其他数据库或配置为like时退化为模糊匹配。
+package search
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 排序方式
+const (
+	SortRelevance = "relevance"  // 相关度，仅有关键词时可用，有关键词时的默认排序
+	SortPopular   = "popular"    // 购买次数，无关键词时的默认排序
+	SortRating    = "rating"     // 评分
+	SortNewest    = "newest"     // 上架时间
+	SortPriceAsc  = "price_asc"  // 价格从低到高
+	SortPriceDesc = "price_desc" // 价格从高到低
+)
+
+var (
+	ErrInvalidSort  = errors.New("无效的排序方式")
+	ErrInvalidQuery = errors.New("无效的筛选条件")
+)
+
+// Query 搜索条件
+type Query struct {
+	Text      string  // 关键词，匹配名称和描述
+	Category  string  // 分类
+	Type      string  // 类型
+	Tag       string  // 标签
+	MinPrice  *int    // 最低价格（点数），按当前售价计算，包含
+	MaxPrice  *int    // 最高价格（点数），按当前售价计算，包含
+	MinRating float64 // 最低评分
+	Sort      string  // 排序方式，为空时按是否有关键词选择默认排序
+	Limit     int
+	Offset    int
+	Now       time.Time // 计算限时特价的时间，为空时使用当前时间
+}
+
+// FacetCount 分面中的一个取值及其代理数量
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// Facets 分面统计，每个维度的统计应用除该维度外的其他筛选条件，便于前端展示切换后的结果数
+type Facets struct {
+	Categories  []FacetCount `json:"categories"`
+	Types       []FacetCount `json:"types"`
+	PriceRanges []FacetCount `json:"priceRanges"` // free, 1-99, 100-499, 500+，按当前售价统计
+	Ratings     []FacetCount `json:"ratings"`     // 4, 3, 2, 1，表示该评分及以上
+}
+
+// Result 搜索结果
+type Result struct {
+	Agents []models.Agent
+	Total  int64
+	Facets Facets
+}
+
+// Engine 代理搜索引擎
+type Engine interface {
+	// EnsureIndex 创建关键词搜索所需的索引，可重复调用
+	EnsureIndex() error
+	// Search 搜索公开的代理
+	Search(q Query) (*Result, error)
+}
+
+// priceRange 价格分面区间，Max小于0表示不设上限
+type priceRange struct {
+	Key string
+	Min int
+	Max int
+}
+
+var priceRanges = []priceRange{
+	{Key: "free", Min: 0, Max: 0},
+	{Key: "1-99", Min: 1, Max: 99},
+	{Key: "100-499", Min: 100, Max: 499},
+	{Key: "500+", Min: 500, Max: -1},
+}
+
+// ratingThresholds 评分分面的下限
+var ratingThresholds = []int{4, 3, 2, 1}
+
+// 分面维度，构造统计查询时排除对应维度自身的筛选
+const (
+	facetNone     = ""
+	facetCategory = "category"
+	facetType     = "type"
+	facetPrice    = "price"
+	facetRating   = "rating"
+)
+
+// matcher 关键词匹配方式
+type matcher interface {
+	// where 关键词筛选条件
+	where(text string) clause.Expr
+	// score 相关度表达式，值越大越相关
+	score(text string) clause.Expr
+}
+
+// indexer 需要创建索引的匹配方式
+type indexer interface {
+	ensureIndex(db *gorm.DB) error
+}
+
+// configNamePattern Postgres全文检索配置名会拼接进索引表达式，只允许标识符
+var configNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// NewEngine 根据配置和数据库驱动创建搜索引擎
+func NewEngine(db *gorm.DB, cfg config.SearchConfig) (Engine, error) {
+	switch cfg.Backend {
+	case "like":
+		return &dbEngine{db: db, matcher: likeMatcher{}}, nil
+	case "database", "":
+	default:
+		return nil, fmt.Errorf("不支持的搜索后端: %s", cfg.Backend)
+	}
+
+	switch db.Dialector.Name() {
+	case "mysql":
+		return &dbEngine{db: db, matcher: mysqlMatcher{}}, nil
+	case "postgres":
+		if !configNamePattern.MatchString(cfg.TextSearchConfig) {
+			return nil, fmt.Errorf("无效的全文检索配置名: %s", cfg.TextSearchConfig)
+		}
+		return &dbEngine{db: db, matcher: newPostgresMatcher(cfg.TextSearchConfig)}, nil
+	default:
+		return &dbEngine{db: db, matcher: likeMatcher{}}, nil
+	}
+}
+
+// dbEngine 基于数据库的搜索引擎
+type dbEngine struct {
+	db      *gorm.DB
+	matcher matcher
+}
+
+// EnsureIndex 创建全文索引，创建失败时改用模糊匹配，避免缺少索引导致查询报错
+func (e *dbEngine) EnsureIndex() error {
+	idx, ok := e.matcher.(indexer)
+	if !ok {
+		return nil
+	}
+	if err := idx.ensureIndex(e.db); err != nil {
+		e.matcher = likeMatcher{}
+		return err
+	}
+	return nil
+}
+
+// matcherFor 选择关键词的匹配方式，MySQL的ngram索引无法匹配短于分词长度的关键词，改用模糊匹配
+func (e *dbEngine) matcherFor(text string) matcher {
+	if _, ok := e.matcher.(mysqlMatcher); ok && len([]rune(text)) < ngramTokenSize {
+		return likeMatcher{}
+	}
+	return e.matcher
+}
+
+// filtered 构造应用筛选条件的查询，except指定的维度不参与筛选
+func (e *dbEngine) filtered(q Query, except string) *gorm.DB {
+	tx := e.db.Model(&models.Agent{}).Where("is_public = ?", true)
+	if q.Text != "" {
+		tx = tx.Where(e.matcherFor(q.Text).where(q.Text))
+	}
+	if q.Category != "" && except != facetCategory {
+		tx = tx.Where("category = ?", q.Category)
+	}
+	if q.Type != "" && except != facetType {
+		tx = tx.Where("type = ?", q.Type)
+	}
+	if except != facetPrice {
+		if q.MinPrice != nil {
+			tx = tx.Where(priceCompare(q.Now, " >= ?", *q.MinPrice))
+		}
+		if q.MaxPrice != nil {
+			tx = tx.Where(priceCompare(q.Now, " <= ?", *q.MaxPrice))
+		}
+	}
+	if q.MinRating > 0 && except != facetRating {
+		tx = tx.Where("rating >= ?", q.MinRating)
+	}
+	if q.Tag != "" {
+		tx = tx.Where("id IN (?)", e.db.Table("agent_tags").
+			Select("agent_tags.agent_id").
+			Joins("JOIN tags ON tags.id = agent_tags.tag_id").
+			Where("tags.name = ?", q.Tag))
+	}
+	return tx
+}
+
+// order 排序表达式，相同条件下按ID倒序保证分页稳定
+func (e *dbEngine) order(q Query) clause.Expr {
+	switch q.Sort {
+	case SortRelevance:
+		score := e.matcherFor(q.Text).score(q.Text)
+		score.SQL += " DESC, purchase_count DESC, id DESC"
+		return score
+	case SortRating:
+		return clause.Expr{SQL: "rating DESC, review_count DESC, id DESC"}
+	case SortNewest:
+		return clause.Expr{SQL: "created_at DESC, id DESC"}
+	case SortPriceAsc:
+		return priceCompare(q.Now, " ASC, id DESC")
+	case SortPriceDesc:
+		return priceCompare(q.Now, " DESC, id DESC")
+	default:
+		return clause.Expr{SQL: "purchase_count DESC, rating DESC, created_at DESC, id DESC"}
+	}
+}
+
+// priceCompare 在当前售价表达式后拼接SQL片段
+func priceCompare(now time.Time, sql string, vars ...interface{}) clause.Expr {
+	expr := models.CurrentPriceExpr(now)
+	expr.SQL += sql
+	expr.Vars = append(expr.Vars, vars...)
+	return expr
+}
+
+// normalize 校验搜索条件并补全默认排序和时间
+func normalize(q *Query) error {
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+	q.Text = strings.TrimSpace(q.Text)
+	q.Tag = strings.ToLower(strings.TrimSpace(q.Tag))
+	if q.MinPrice != nil && q.MaxPrice != nil && *q.MinPrice > *q.MaxPrice {
+		return ErrInvalidQuery
+	}
+	if q.MinRating < 0 || q.MinRating > 5 {
+		return ErrInvalidQuery
+	}
+
+	switch q.Sort {
+	case "":
+		q.Sort = SortPopular
+		if q.Text != "" {
+			q.Sort = SortRelevance
+		}
+	case SortRelevance:
+		if q.Text == "" {
+			q.Sort = SortPopular
+		}
+	case SortPopular, SortRating, SortNewest, SortPriceAsc, SortPriceDesc:
+	default:
+		return ErrInvalidSort
+	}
+	return nil
+}
+
+// Search 搜索公开的代理并统计分面
+func (e *dbEngine) Search(q Query) (*Result, error) {
+	if err := normalize(&q); err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	if err := e.filtered(q, facetNone).Count(&result.Total).Error; err != nil {
+		return nil, fmt.Errorf("统计代理数量失败: %w", err)
+	}
+	if err := e.filtered(q, facetNone).
+		Clauses(clause.OrderBy{Expression: e.order(q)}).
+		Limit(q.Limit).
+		Offset(q.Offset).
+		Find(&result.Agents).Error; err != nil {
+		return nil, fmt.Errorf("搜索代理失败: %w", err)
+	}
+
+	facets, err := e.facets(q)
+	if err != nil {
+		return nil, err
+	}
+	result.Facets = *facets
+	return result, nil
+}
+
+// facets 统计各维度的分面
+func (e *dbEngine) facets(q Query) (*Facets, error) {
+	facets := &Facets{}
+	var err error
+
+	if facets.Categories, err = e.groupCounts(q, facetCategory, "category"); err != nil {
+		return nil, err
+	}
+	if facets.Types, err = e.groupCounts(q, facetType, "type"); err != nil {
+		return nil, err
+	}
+
+	priceCases := make([]string, len(priceRanges))
+	var priceVars []interface{}
+	for i, r := range priceRanges {
+		var cond clause.Expr
+		if r.Max < 0 {
+			cond = priceCompare(q.Now, " >= ?", r.Min)
+		} else {
+			cond = priceCompare(q.Now, " BETWEEN ? AND ?", r.Min, r.Max)
+		}
+		priceCases[i] = "COALESCE(SUM(CASE WHEN " + cond.SQL + " THEN 1 ELSE 0 END), 0)"
+		priceVars = append(priceVars, cond.Vars...)
+	}
+	priceCounts, err := e.bucketCounts(q, facetPrice, priceCases, priceVars)
+	if err != nil {
+		return nil, err
+	}
+	for i, r := range priceRanges {
+		facets.PriceRanges = append(facets.PriceRanges, FacetCount{Value: r.Key, Count: priceCounts[i]})
+	}
+
+	ratingCases := make([]string, len(ratingThresholds))
+	ratingVars := make([]interface{}, len(ratingThresholds))
+	for i, threshold := range ratingThresholds {
+		ratingCases[i] = "COALESCE(SUM(CASE WHEN rating >= ? THEN 1 ELSE 0 END), 0)"
+		ratingVars[i] = threshold
+	}
+	ratingCounts, err := e.bucketCounts(q, facetRating, ratingCases, ratingVars)
+	if err != nil {
+		return nil, err
+	}
+	for i, threshold := range ratingThresholds {
+		facets.Ratings = append(facets.Ratings, FacetCount{Value: fmt.Sprint(threshold), Count: ratingCounts[i]})
+	}
+
+	return facets, nil
+}
+
+// groupCounts 按列分组统计，数量多的在前
+func (e *dbEngine) groupCounts(q Query, facet, column string) ([]FacetCount, error) {
+	counts := []FacetCount{}
+	err := e.filtered(q, facet).
+		Select(column + " AS value, COUNT(*) AS count").
+		Where(column + " <> ''").
+		Group(column).
+		Order("COUNT(*) DESC, " + column).
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("统计%s分面失败: %w", facet, err)
+	}
+	return counts, nil
+}
+
+// bucketCounts 在一次查询中统计多个区间的数量
+func (e *dbEngine) bucketCounts(q Query, facet string, cases []string, vars []interface{}) ([]int64, error) {
+	counts := make([]int64, len(cases))
+	dest := make([]interface{}, len(cases))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+
+	row := e.filtered(q, facet).Select(strings.Join(cases, ", "), vars...).Row()
+	if err := row.Scan(dest...); err != nil {
+		return nil, fmt.Errorf("统计%s分面失败: %w", facet, err)
+	}
+	return counts, nil
+}
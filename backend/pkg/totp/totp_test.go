@@ -0,0 +1,99 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret RFC 6238附录B中SHA1测试向量使用的密钥"12345678901234567890"
+var rfcSecret = encoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// RFC 6238附录B给出8位验证码，6位验证码为其后6位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("时间%d的验证码应为%s，实际为%s", tt.unix, tt.want, got)
+		}
+	}
+}
+
+func TestValidateStepWindow(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+	codeAt := func(offset int64) string {
+		code, err := Code(rfcSecret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		skew     int
+		wantOK   bool
+		wantStep int64
+	}{
+		{"当前时间步", codeAt(0), 1, true, current},
+		{"上一个时间步", codeAt(-1), 1, true, current - 1},
+		{"下一个时间步", codeAt(1), 1, true, current + 1},
+		{"超出偏差窗口", codeAt(-2), 1, false, 0},
+		{"不允许偏差时拒绝相邻时间步", codeAt(1), 0, false, 0},
+		{"首尾空白被忽略", " " + codeAt(0) + " ", 1, true, current},
+		{"位数不正确", codeAt(0)[:5], 1, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, now, tt.skew)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("应为(%d, %v)，实际为(%d, %v)", tt.wantStep, tt.wantOK, step, ok)
+			}
+		})
+	}
+}
+
+func TestValidateInvalidSecret(t *testing.T) {
+	if _, ok := Validate("not base32!", "123456", time.Now(), 1); ok {
+		t.Error("无效密钥不应通过校验")
+	}
+}
+
+func TestDecodeSecretNormalizes(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := Step(time.Now())
+	want, _ := Code(secret, step)
+
+	// 验证器应用中常见小写和四位一组的输入
+	grouped := ""
+	for i, r := range secret {
+		if i > 0 && i%4 == 0 {
+			grouped += " "
+		}
+		grouped += string(r)
+	}
+	for _, input := range []string{secret, grouped, " " + secret} {
+		got, err := Code(strings.ToLower(input), step)
+		if err != nil || got != want {
+			t.Errorf("密钥%q的验证码应为%s，实际为%s（%v）", input, want, got, err)
+		}
+	}
+}
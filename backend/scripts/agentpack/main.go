@@ -0,0 +1,187 @@
+// agentpack 导出和导入代理包，用于在实例之间迁移代理以及导入示例数据
+//
+//	go run ./scripts/agentpack export -id 12 -out ./packages
+//	go run ./scripts/agentpack export -all -out ./packages
+//	go run ./scripts/agentpack import -on-conflict skip -publish scripts/seeds/agents
+//
+// 数据库连接使用 APP_ENV 对应的配置文件。import 接受文件或目录，目录中的 .json 文件按文件名顺序导入。
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/alexfaker/jilang-agent/config"
+	"github.com/alexfaker/jilang-agent/models"
+	"github.com/alexfaker/jilang-agent/pkg/agentpack"
+	"github.com/alexfaker/jilang-agent/pkg/database"
+	"gorm.io/gorm"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `用法:
+  agentpack export [-id ID | -all] [-out 目录] [-public 静态文件目录]
+  agentpack import [-on-conflict fail|skip|overwrite|rename] [-publish] [-public 静态文件目录] 文件或目录...`)
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	switch os.Args[1] {
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+// connect 按配置文件连接数据库
+func connect() *gorm.DB {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("无法加载配置: %v", err)
+	}
+	db, err := database.ConnectGormDB(cfg.Database)
+	if err != nil {
+		log.Fatalf("无法连接数据库: %v", err)
+	}
+	return db
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	id := fs.Int64("id", 0, "要导出的代理ID")
+	all := fs.Bool("all", false, "导出全部代理")
+	out := fs.String("out", ".", "输出目录")
+	publicDir := fs.String("public", "public", "静态文件目录，本地上传的封面图从中读取")
+	fs.Parse(args)
+	if (*id == 0) == !*all {
+		log.Fatal("需要指定 -id 或 -all 其中之一")
+	}
+
+	db := connect()
+	query := db.Order("id ASC")
+	if *id != 0 {
+		query = query.Where("id = ?", *id)
+	}
+	var agents []models.Agent
+	if err := query.Find(&agents).Error; err != nil {
+		log.Fatalf("获取代理失败: %v", err)
+	}
+	if len(agents) == 0 {
+		log.Fatal("没有可导出的代理")
+	}
+	if err := models.LoadAgentTags(db, agents); err != nil {
+		log.Fatalf("获取代理标签失败: %v", err)
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		log.Fatalf("创建输出目录失败: %v", err)
+	}
+
+	for i := range agents {
+		pkg, err := agentpack.FromAgent(&agents[i], *publicDir)
+		if err != nil {
+			log.Fatalf("导出代理 '%s' 失败: %v", agents[i].Name, err)
+		}
+		data, err := agentpack.Encode(pkg)
+		if err != nil {
+			log.Fatalf("导出代理 '%s' 失败: %v", agents[i].Name, err)
+		}
+		file := filepath.Join(*out, pkg.Filename(agents[i].ID))
+		if err := os.WriteFile(file, data, 0644); err != nil {
+			log.Fatalf("写入 %s 失败: %v", file, err)
+		}
+		fmt.Printf("✓ 导出代理: %s -> %s\n", agents[i].Name, file)
+	}
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	onConflict := fs.String("on-conflict", string(agentpack.ConflictFail), "已存在同名代理时的处理方式: fail、skip、overwrite、rename")
+	publish := fs.Bool("publish", false, "将导入的代理设为公开")
+	publicDir := fs.String("public", "public", "静态文件目录，内嵌的封面图保存到其中")
+	fs.Parse(args)
+
+	mode, err := agentpack.ParseConflictMode(*onConflict)
+	if err != nil {
+		log.Fatal(err)
+	}
+	files, err := packageFiles(fs.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(files) == 0 {
+		log.Fatal("需要指定要导入的代理包文件或目录")
+	}
+
+	db := connect()
+	opts := agentpack.ImportOptions{OnConflict: mode, Publish: *publish, PublicDir: *publicDir}
+	for _, file := range files {
+		result, err := importFile(db, file, opts)
+		if err != nil {
+			log.Fatalf("导入 %s 失败: %v", file, err)
+		}
+		switch result.Action {
+		case agentpack.ActionSkipped:
+			fmt.Printf("- 代理 '%s' 已存在，跳过\n", result.Agent.Name)
+		case agentpack.ActionOverwritten:
+			fmt.Printf("✓ 更新代理: %s (版本 %s)\n", result.Agent.Name, result.Version)
+		default:
+			fmt.Printf("✓ 导入代理: %s (版本 %s)\n", result.Agent.Name, result.Version)
+		}
+	}
+}
+
+// importFile 读取并导入一个代理包文件
+func importFile(db *gorm.DB, file string, opts agentpack.ImportOptions) (*agentpack.ImportResult, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pkg, err := agentpack.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+	return agentpack.Import(db, pkg, opts)
+}
+
+// packageFiles 展开参数中的目录，目录中的 .json 文件按文件名排序
+func packageFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, entry := range entries {
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+				names = append(names, filepath.Join(arg, entry.Name()))
+			}
+		}
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
@@ -0,0 +1,31 @@
+// mock_oidc 启动本地模拟OIDC提供方，用于调试第三方登录
+//
+//	go run ./scripts/mock_oidc -addr :9000
+//
+// 配置中对应的提供方只需填写 issuer（http://localhost:9000）、clientId 和 redirectUrl。
+// 授权时不需要登录，传入 login_hint=<邮箱> 可模拟不同的用户。
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/alexfaker/jilang-agent/pkg/oidc"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "监听地址")
+	issuer := flag.String("issuer", "http://localhost:9000", "发行方地址，需与监听地址一致")
+	flag.Parse()
+
+	server, err := oidc.NewMockServer(*issuer)
+	if err != nil {
+		log.Fatalf("创建模拟OIDC提供方失败: %v", err)
+	}
+
+	log.Printf("模拟OIDC提供方已启动: %s", *issuer)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("模拟OIDC提供方退出: %v", err)
+	}
+}